@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/iangcarroll/cookiemonster/pkg/monster"
+)
+
+// Reads the cookies for -batch from `path`, by its extension.
+func readBatch(path string) ([]monster.CookieInput, error) {
+	var r io.Reader = os.Stdin
+
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		r = file
+	}
+
+	switch {
+	case strings.HasSuffix(path, ".har"):
+		cookies, err := monster.CookiesFromHAR(r)
+		if err != nil {
+			return nil, err
+		}
+
+		return monster.InputsFromNamedCookies(cookies), nil
+	case strings.HasSuffix(path, ".xml"):
+		cookies, err := monster.CookiesFromBurpXML(r)
+		if err != nil {
+			return nil, err
+		}
+
+		return monster.InputsFromNamedCookies(cookies), nil
+	default:
+		return monster.InputsFromLines(r)
+	}
+}
+
+// Formats a secret the way `keyDiscoveredMessage()` does.
+func displaySecret(secret []byte) string {
+	if isASCII(string(secret)) {
+		return fmt.Sprintf("\"%s\"", secret)
+	}
+
+	return fmt.Sprintf("(in base64) \"%s\"", base64Key(secret))
+}
+
+// Decodes and unsigns every cookie in `inputs`, then summarizes which
+// secrets were found.
+func batchMain(inputs []monster.CookieInput) {
+	// Every cookie is checked against the same list, so it can't be streamed.
+	var wl *monster.Wordlist
+	if *potfileFlag != "" {
+		var hashes []monster.HashcatHash
+		for _, input := range inputs {
+			if cookie := monster.NewCookie(input.Value); cookie.Decode() {
+				hashes = append(hashes, cookie.HashcatHashes()...)
+			}
+		}
+
+		wl = loadPotfile(hashes)
+	} else {
+		wl, _ = loadWordlist(false)
+	}
+
+	if *potfileFlag == "" && (*defaultsFlag || *wordlistFlag == defaultWordlistKey) {
+		defaults := monster.DefaultWordlist()
+		defaults.LoadFromArray(wl.Entries())
+		wl = defaults
+	}
+
+	unsignOptions := unsignOptionsFromFlags()
+	results := monster.BatchUnsign(inputs, wl, uint64(*concurrencyFlag), unsignOptions...)
+
+	for _, result := range results {
+		label := result.Input.Source
+		if result.Input.Name != "" {
+			label += " (" + result.Input.Name + ")"
+		}
+
+		cloud, isCloud := result.Cookie.CloudToken()
+
+		switch {
+		case *jsonFlag && !result.Duplicate:
+			out, err := json.Marshal(result.Cookie)
+			if err != nil {
+				failureMessage(fmt.Sprintf("Sorry, I could not encode the cookie as JSON. Error: %v", err))
+			}
+
+			fmt.Println(string(out))
+		case result.Duplicate && *verboseFlag:
+			fmt.Println("ℹ️  " + label + ": the same cookie as an earlier one.")
+		case result.Duplicate:
+		case result.Decoded && result.Cookie.HasNoSignature():
+			fmt.Println(ColorYellow + "⚠️  " + label + ": not signed at all, so it can be forged freely." + ColorReset)
+		case result.Decoded && isCloud:
+			fmt.Printf("ℹ️  %s: issued by %s (%s), so there is no secret to discover.\n", label, cloudProviderNames[cloud.Provider], cloud.Kind)
+		case result.Found:
+			fmt.Printf(ColorGreen+"✅ %s: the key is %s, with the %s decoder.\n"+ColorReset, label, displaySecret(result.Result.Secret), result.Result.Decoder)
+		case result.Decoded:
+			fmt.Println("❌ " + label + ": I did not discover the key.")
+		case *verboseFlag:
+			fmt.Println("ℹ️  " + label + ": not in a supported format.")
+		}
+	}
+
+	summary := monster.SummarizeBatch(results)
+	fmt.Printf("ℹ️  Of %d cookies (%d unique), I decoded %d and discovered the key for %d.\n", summary.Cookies, summary.Unique, summary.Decoded, summary.Unsigned)
+
+	for _, secret := range summary.Secrets {
+		fmt.Printf(ColorGreen+"✅ The key %s unsigned %d cookies with the %s decoder.\n"+ColorReset, displaySecret(secret.Secret), secret.Cookies, secret.Decoder)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/iangcarroll/cookiemonster/pkg/monster"
+)
+
+// Returns the secret cache's path, from -cache-file or the default.
+func secretCachePath() string {
+	if *cacheFileFlag != "" {
+		return *cacheFileFlag
+	}
+
+	path, err := monster.DefaultSecretCachePath()
+	if err != nil {
+		failureMessage(fmt.Sprintf("Sorry, I could not find your home directory for the secret cache. Error: %v", err))
+	}
+
+	return path
+}
+
+func openSecretCache() *monster.SecretCache {
+	cache, err := monster.OpenSecretCache(secretCachePath())
+	if err != nil {
+		failureMessage(fmt.Sprintf("Sorry, I could not read the secret cache. Error: %v", err))
+	}
+
+	return cache
+}
+
+// Prints the cached secrets, most used first.
+func listSecretCache() {
+	entries := openSecretCache().Entries()
+	fmt.Println("ℹ️  The secret cache at", secretCachePath(), "has", len(entries), "secrets.")
+
+	for _, entry := range entries {
+		fmt.Printf("%s\t%s\t%s\t%d hits, last on %s\n", entry.Decoder, entry.Fingerprint, displaySecret(entry.Secret), entry.Hits, entry.LastSeen.Format(time.RFC3339))
+	}
+}
+
+// Removes the cached secrets, or only -decoder's.
+func purgeSecretCache() {
+	var decoders []string
+	if *decoderFlag != "" {
+		decoders = append(decoders, *decoderFlag)
+	}
+
+	purged, err := openSecretCache().Purge(decoders...)
+	if err != nil {
+		failureMessage(fmt.Sprintf("Sorry, I could not purge the secret cache. Error: %v", err))
+	}
+
+	fmt.Println("ℹ️  I removed", purged, "secrets from the secret cache.")
+}
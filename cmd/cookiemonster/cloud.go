@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/iangcarroll/cookiemonster/pkg/monster"
+)
+
+// The names we show cloud providers under.
+var cloudProviderNames = map[string]string{
+	monster.CloudFirebase: "Firebase",
+	monster.CloudIAP:      "Google Cloud IAP",
+	monster.CloudALB:      "AWS ALB",
+}
+
+// Explains what a cookie issued by a cloud provider is, when it expires, and
+// whether algorithm confusion is worth trying.
+func cloudMessage(token monster.CloudToken) {
+	name := cloudProviderNames[token.Provider]
+
+	switch {
+	case token.Provider == monster.CloudFirebase:
+		fmt.Printf("ℹ️  This is a Firebase %s for the %s project (subject %s); Google signs these, so there is no secret to discover.\n", token.Kind, token.Audience, token.Subject)
+	case token.Encrypted:
+		fmt.Printf("ℹ️  This %s %s is encrypted with a key only the provider holds, so there is no secret to discover, and it can't be read or forged.\n", name, token.Kind)
+	default:
+		fmt.Printf("ℹ️  This %s %s is for %s (subject %s); it's signed with %s by the provider, so there is no secret to discover.\n", name, token.Kind, token.Audience, token.Subject, token.Algorithm)
+	}
+
+	if !token.Expiry.IsZero() {
+		if time.Now().Before(token.Expiry) {
+			fmt.Println("ℹ️  It expires at", token.Expiry.Format(time.RFC3339)+"; it is still valid.")
+		} else {
+			fmt.Println("ℹ️  It expired at", token.Expiry.Format(time.RFC3339)+", so the app should reject it.")
+		}
+	}
+
+	if token.AppVerified {
+		fmt.Printf("ℹ️  The app verifies this token itself, so check that it requires %s; algorithm confusion (e.g. `alg: none`, or HS256 signed with the provider's public key) may apply.\n", token.Algorithm)
+	} else {
+		fmt.Println("ℹ️  The provider verifies this cookie itself, so algorithm confusion doesn't apply.")
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+
+	"github.com/iangcarroll/cookiemonster/pkg/fetch"
+	"github.com/iangcarroll/cookiemonster/pkg/monster"
+)
+
+// The headers given with -header, as `Name: value`.
+type headerList []string
+
+func (l *headerList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *headerList) Set(header string) error {
+	if !strings.Contains(header, ":") {
+		return fmt.Errorf("%q is not a `Name: value` header", header)
+	}
+
+	*l = append(*l, header)
+	return nil
+}
+
+var headerFlags headerList
+
+func init() {
+	flag.Var(&headerFlags, "header", "Optional. With -url, a header to send with each request, as `Name: value`; may be given more than once.")
+}
+
+// Requests `target` as the flags describe, and returns the cookies it set.
+func fetchInputs(target string) []monster.CookieInput {
+	options := fetch.Options{Headers: http.Header{}}
+
+	for _, header := range headerFlags {
+		components := strings.SplitN(header, ":", 2)
+		options.Headers.Add(textproto.TrimString(components[0]), textproto.TrimString(components[1]))
+	}
+
+	if *loginURLFlag != "" {
+		form, err := url.ParseQuery(*loginDataFlag)
+		if err != nil {
+			failureMessage(fmt.Sprintf("Sorry, I could not parse your login data. Error: %v", err))
+		}
+
+		options.LoginURL, options.LoginForm = *loginURLFlag, form
+	}
+
+	cookies, err := fetch.Cookies(target, options)
+	if err != nil {
+		failureMessage(fmt.Sprintf("Sorry, I could not fetch cookies from %s. Error: %v", target, err))
+	}
+
+	inputs := monster.InputsFromNamedCookies(cookies)
+	fmt.Println("ℹ️  CookieMonster was sent", len(inputs), "cookies.")
+
+	return inputs
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/iangcarroll/cookiemonster/pkg/monster"
+)
+
+// The custom charsets given with -charset, in order.
+var charsetFlags stringList
+
+func init() {
+	flag.Var(&charsetFlags, "charset", "Optional. A custom charset for -mask, which may use the built-in ones (e.g. ?l?d_); the first is ?1, and up to four may be given.")
+}
+
+// Parses -mask and its charsets.
+func loadKeyspace() *monster.Keyspace {
+	keyspace, err := monster.NewKeyspace(*maskFlag, *maskMinFlag, charsetFlags...)
+	if err != nil {
+		failureMessage(fmt.Sprintf("Sorry, I could not parse your mask. Error: %v", err))
+	}
+
+	fmt.Println("ℹ️  CookieMonster parsed your mask; it has", keyspace.Size(), "candidates.")
+	return keyspace
+}
+
+// Reads the index to resume from out of the -checkpoint file, if there is
+// one.
+func readCheckpoint(path string) uint64 {
+	contents, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0
+	} else if err != nil {
+		failureMessage(fmt.Sprintf("Sorry, I could not read your checkpoint. Error: %v", err))
+	}
+
+	start, err := strconv.ParseUint(strings.TrimSpace(string(contents)), 10, 64)
+	if err != nil {
+		failureMessage(fmt.Sprintf("Sorry, your checkpoint is not a candidate index. Error: %v", err))
+	}
+
+	return start
+}
+
+// Tries every candidate of `keyspace`, saving and resuming from -checkpoint
+// if it was given, and reports whether the key was found.
+func unsignKeyspace(cookie *monster.Cookie, keyspace *monster.Keyspace, unsignOptions []monster.UnsignOption) bool {
+	var start uint64
+	if *checkpointFlag != "" {
+		if start = readCheckpoint(*checkpointFlag); start > 0 {
+			fmt.Println("ℹ️  CookieMonster is resuming from candidate", start, "of", keyspace.Size())
+		}
+	}
+
+	// We stop at the next checkpoint once interrupted, so no work is lost.
+	var interrupted int32
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	go func() {
+		if _, ok := <-interrupts; ok {
+			atomic.StoreInt32(&interrupted, 1)
+		}
+	}()
+
+	unsignOptions = append(unsignOptions, monster.WithCheckpoint(func(next uint64) bool {
+		if *checkpointFlag != "" {
+			if err := os.WriteFile(*checkpointFlag, []byte(strconv.FormatUint(next, 10)+"\n"), 0o644); err != nil {
+				failureMessage(fmt.Sprintf("Sorry, I could not save your checkpoint. Error: %v", err))
+			}
+		}
+
+		return atomic.LoadInt32(&interrupted) == 0
+	}))
+
+	_, success, next, err := cookie.UnsignKeyspace(keyspace, start, uint64(*concurrencyFlag), unsignOptions...)
+	if errors.Is(err, monster.ErrKeyspaceCancelled) {
+		if *checkpointFlag != "" {
+			failureMessage(fmt.Sprintf("Stopped at candidate %d of %d; run me again with the same -checkpoint to resume.", next, keyspace.Size()))
+		}
+
+		failureMessage(fmt.Sprintf("Stopped at candidate %d of %d.", next, keyspace.Size()))
+	}
+
+	// There's nothing left to resume.
+	if *checkpointFlag != "" && (success || next == keyspace.Size()) {
+		os.Remove(*checkpointFlag)
+	}
+
+	return success
+}
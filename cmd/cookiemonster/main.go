@@ -2,9 +2,14 @@ package main
 
 import (
 	_ "embed"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/iangcarroll/cookiemonster/pkg/monster"
 )
@@ -16,15 +21,66 @@ const (
 
 var (
 	cookieFlag      = flag.String("cookie", "", "Required. The cookie to attempt to decode and unsign.")
-	wordlistFlag    = flag.String("wordlist", defaultWordlistKey, "Optional. The path to load a base64-encoded wordlist from; the default is the `builtin` list.")
-	concurrencyFlag = flag.Int("concurrency", 100, "Optional. How many attempts should run concurrently; the default is 100.")
+	urlFlag         = flag.String("url", "", "Optional. Instead of -cookie, requests this URL (following redirects) and checks every cookie it sets.")
+	loginURLFlag    = flag.String("login-url", "", "Optional. With -url, a login form to post -login-data to first; the cookies it sets are sent to -url, and checked too.")
+	loginDataFlag   = flag.String("login-data", "", "Optional. The URL-encoded form for -login-url, e.g. `user=admin&password=admin`.")
+	batchFlag       = flag.String("batch", "", "Optional. Instead of -cookie, a file of cookies to decode and unsign, one per line; a path ending in .har or .xml is read as a HAR file or a Burp Suite export. Use - for standard input.")
+	signatureFlag   = flag.String("signature", "", "Optional. A signature sent separately from the cookie (e.g. in an `X-Signature` header), which the cookie's value is verified against.")
+	wordlistFlag    = flag.String("wordlist", defaultWordlistKey, "Optional. The path to load a base64-encoded wordlist from; the default is the `builtin` list. Use - for standard input; a path ending in .gz is decompressed.")
+	saltSuffixFlag  = flag.Bool("django-salt-suffix", false, "Optional. Derives Django's signing key from the secret followed by the salt, as some custom signers do, rather than the salt followed by the secret.")
+	railsSaltFlag   = flag.String("rails-salt", "", "Optional. The salt Rails derives the encrypted cookie key with; the default is \"authenticated encrypted cookie\".")
+	railsIterFlag   = flag.Int("rails-iterations", 0, "Optional. The PBKDF2 iterations Rails derives the encrypted cookie key with; the default is 1000.")
+	secretFileFlag  = flag.String("secret-file", "", "Optional. The path to a single known secret to check instead of a wordlist; it may be a PEM-encoded key or a Laravel base64: key.")
+	keyRingFlag     = flag.String("keyring", "", "Optional. The path to an ASP.NET Core Data Protection key ring XML file, whose master keys are tried instead of a wordlist.")
+	concurrencyFlag = flag.Int("concurrency", 0, "Optional. How many attempts should run concurrently; the default is one per CPU.")
 	verboseFlag     = flag.Bool("verbose", false, "Optional. Enables additional output on how the cookie is decoded.")
-	resignFlag      = flag.String("resign", "", "Optional. Unencoded data to resign the cookie with; presently only supported by Django.")
+	resignFlag      = flag.String("resign", "", "Optional. Unencoded data to resign the cookie with; presently only supported by Django, Flask, JWTs, Rails, Play, Spring Security, Yii, CakePHP, hash_hmac and Express.")
+	resignAlgFlag   = flag.String("resign-algorithm", "", "Optional. The algorithm to resign with; the default is the cookie's original algorithm.")
+	downgradeFlag   = flag.Bool("allow-downgrade", false, "Optional. Allows -resign-algorithm to be weaker than the cookie's original algorithm.")
+	reserializeFlag = flag.Bool("reserialize", false, "Optional. Treats -resign as edited JSON and re-serializes it the way Django or Flask does.")
+	algorithmsFlag  = flag.String("algorithms", "", "Optional. A comma-separated list of algorithms to try (e.g. `sha256,sha512`); the default is all of them.")
+	autoTuneFlag    = flag.Bool("autotune", false, "Optional. Benchmarks a few concurrency levels before cracking and uses the fastest, instead of -concurrency.")
+	uuidFlag        = flag.Bool("uuids", false, "Optional. Treats the wordlist as UUIDs, and tries each in every common format (dashless, uppercase, braced).")
+	firebaseFlag    = flag.Bool("verify-firebase", false, "Optional. For Firebase tokens, fetches Google's public keys and verifies the token's signature.")
+	canonicalFlag   = flag.Bool("canonical-json", false, "Optional. With -signature, also verifies the JSON value with its keys sorted and whitespace removed.")
+	kdfFlag         = flag.String("kdf", "", "Optional. With -signature, derives the key from each secret with hkdf or hkdf-expand instead of signing with it directly.")
+	kdfInfoFlag     = flag.String("kdf-info", "", "Optional. The info string for -kdf.")
+	cookieNameFlag  = flag.String("cookie-name", "", "Optional. With -signature, also verifies the value with this cookie name in front, for schemes which sign the name too.")
+	keyIDFlag       = flag.Bool("key-id", false, "Optional. Also treats the first of three dot-separated segments as an unsigned key ID, as in keyid.data.signature.")
+	utf16Flag       = flag.Bool("utf16", false, "Optional. Encodes each secret as UTF-16LE before signing with it, as some .NET apps do.")
+	maxFlag         = flag.Uint64("max-candidates", 0, "Optional. Stops after trying this many candidate keys; the default is no limit.")
+	exportFlag      = flag.String("export", "", "Optional. A file to write the discovered secret to, as CSV; a path ending in .xml is written as KeePass XML instead.")
+	fieldsFlag      = flag.Bool("fields", false, "Optional. Also tries secrets derived from the cookie's own fields (e.g. the username, reversed or hashed) before the wordlist.")
+	templateFlag    = flag.String("template", "", "Optional. A Go text/template to print the result with, using fields such as {{.Decoder}}, {{.Algorithm}} and {{.Secret}}.")
+	oneLineFlag     = flag.Bool("oneline", false, "Optional. Also prints the result as one tab-separated line of decoder, algorithm, secret and subject, for grepping.")
+	jsonFlag        = flag.Bool("json", false, "Optional. Also prints each decoder's fields and the result as one JSON object, for scripts; with -verbose, it replaces the text dump.")
+	maskFlag        = flag.String("mask", "", "Optional. Instead of a wordlist, tries every secret matching a hashcat-style mask, e.g. ?l?l?l?d?d; ?l, ?u, ?d, ?h, ?H, ?s, ?a and ?b are the usual charsets, and ?1 to ?4 are those given with -charset.")
+	maskMinFlag     = flag.Int("mask-min-length", 0, "Optional. With -mask, also tries the mask's prefixes down to this many characters, shortest first.")
+	checkpointFlag  = flag.String("checkpoint", "", "Optional. With -mask, a file to save progress to as it goes, and to resume from if it exists; interrupting with Ctrl-C saves it too.")
+	defaultsFlag    = flag.Bool("defaults", false, "Optional. Tries the default secrets CookieMonster knows for the cookie's frameworks before your wordlist; this is always done with the builtin list.")
+	payloadFlag     = flag.Bool("payload", false, "Optional. Deserializes the data inside the cookie (JSON, Python pickles, Ruby Marshal and serialized PHP) without running it, prints it as JSON, and points out fields like user IDs, roles and expiries.")
+	decoderFlag     = flag.String("decoder", "", "Optional. Only tries keys against this decoder (e.g. `django`), for cookies which look like several formats; the default is every decoder that recognizes the cookie.")
+	cacheFlag       = flag.Bool("cache", false, "Optional. Tries the secrets cracked in earlier runs first, and remembers the ones this run cracks, in ~/.cookiemonster/secrets.json.")
+	cacheFileFlag   = flag.String("cache-file", "", "Optional. The secret cache to use instead of ~/.cookiemonster/secrets.json; implies -cache.")
+	cacheListFlag   = flag.Bool("cache-list", false, "Optional. Prints the secrets in the secret cache and exits.")
+	cachePurgeFlag  = flag.Bool("cache-purge", false, "Optional. Removes every secret from the secret cache, or only -decoder's, and exits.")
+	hashcatFlag     = flag.String("hashcat", "", "Optional. Instead of cracking the cookie, writes its signature to this file as a hashcat hash and prints the hashcat command to crack it with.")
+	potfileFlag     = flag.String("potfile", "", "Optional. Instead of a wordlist, tries the passwords in this hashcat potfile which cracked the cookie's hashes, as written by -hashcat.")
+	truncatedFlag   = flag.Int("truncated", 0, "Optional. Also try prefixes of each wordlist entry down to this many bytes if the full entries fail; disabled by default.")
 
 	//go:embed wordlists/flask-unsign.txt
 	defaultWordlist string
 )
 
+// The salts given with -django-salt, and the passwords given with
+// -spring-password, in order.
+var djangoSaltFlags, springPasswordFlags stringList
+
+func init() {
+	flag.Var(&djangoSaltFlags, "django-salt", "Optional. A salt Django may derive the signing key with, including its signer suffix (e.g. `django.contrib.messagessigner`); each is tried along with the session salt, and it may be given more than once.")
+	flag.Var(&springPasswordFlags, "spring-password", "Optional. For Spring Security remember-me cookies, the user's password as the app stores it (e.g. `{noop}password` or a bcrypt hash), which is signed along with the key; it may be given more than once.")
+}
+
 // Say hello!
 func sayHello() {
 	fmt.Println("🍪 CookieMonster", version)
@@ -43,61 +99,535 @@ func keyDiscoveredMessage(cookie *monster.Cookie) {
 	if isASCII(string(key)) {
 		fmt.Printf(ColorGreen+"✅ Success! I discovered the key for this cookie with the %s decoder; it is \"%s\".\n"+ColorReset, decoder, string(key))
 	} else {
-		fmt.Printf(ColorGreen+"✅ Success! I discovered the key for this cookie with the %s decoder; it is (in base64): \"%s\"."+ColorReset, decoder, base64Key(key))
+		fmt.Printf(ColorGreen+"✅ Success! I discovered the key for this cookie with the %s decoder; it is (in base64): \"%s\".\n"+ColorReset, decoder, base64Key(key))
+	}
+
+	entry, truncated := cookie.KeySource()
+	if truncated {
+		fmt.Println("ℹ️  The app only uses the first", len(key), "bytes of this secret.")
+	}
+
+	if *utf16Flag {
+		fmt.Printf("ℹ️  The app signs with the UTF-16LE encoding of the wordlist entry \"%s\".\n", string(entry))
+	}
+
+	if session, err := cookie.RailsSession(); err == nil {
+		fmt.Printf("ℹ️  The decrypted cookie is: %s\n", session)
+
+		if token, err := cookie.RailsCSRFToken(); err == nil {
+			fmt.Printf("ℹ️  The session's CSRF token (_csrf_token) is: %s\n", token)
+		}
+
+		if *payloadFlag {
+			printPayload(cookie, decoder)
+		}
+	}
+
+	if value, err := cookie.CakePHPValue(); err == nil {
+		fmt.Printf("ℹ️  The decrypted cookie is: %s\n", value)
+
+		if *payloadFlag {
+			printPayload(cookie, decoder)
+		}
+	}
+
+	if salt, ok := cookie.DjangoSalt(); ok && len(djangoSaltFlags) > 0 {
+		fmt.Printf("ℹ️  Django derived the key with the salt \"%s\".\n", salt)
+	}
+
+	if index, ok := cookie.MatchedSignature(); ok && index > 0 {
+		fmt.Println("ℹ️  This key made signature", index+1, "of the chain, not the primary one; it was likely rotated out.")
+	}
+
+	if expiry, ok := cookie.EmbeddedExpiry(); ok {
+		if cookie.Valid() {
+			fmt.Println("ℹ️  The signed data expires at", expiry.Format(time.RFC3339)+"; it is still valid.")
+		} else {
+			fmt.Println("ℹ️  The signed data expired at", expiry.Format(time.RFC3339)+", so the app should reject it.")
+		}
 	}
 }
 
+// Lists the decoders which recognized the cookie, most likely first, if
+// there's more than one.
+func matchesMessage(cookie *monster.Cookie) {
+	matches := cookie.Matches()
+	if len(matches) < 2 {
+		return
+	}
+
+	fmt.Println("ℹ️  This cookie looks like more than one format; from most to least likely:")
+
+	for _, match := range matches {
+		reasons := make([]string, 0, len(match.Signals))
+		for _, signal := range match.Signals {
+			reasons = append(reasons, signal.Reason)
+		}
+
+		line := fmt.Sprintf("     %s (%.0f%%)", match.Decoder, match.Confidence*100)
+		if len(reasons) > 0 {
+			line += ": " + strings.Join(reasons, "; ")
+		}
+
+		fmt.Println(line)
+	}
+
+	fmt.Println("ℹ️  Keys are tried against all of them; pass -decoder to try just one.")
+}
+
 // Output a nice success message if we decode the cookie.
 func resignedMessage(out string) {
 	fmt.Printf(ColorGreen+"✅ I resigned this cookie for you; the new one is: %s\n"+ColorReset, out)
 }
 
+// Writes the discovered secret to `path`, as KeePass XML if it ends with
+// `.xml` and as CSV otherwise.
+func exportResult(cookie *monster.Cookie, path string) error {
+	result, _ := cookie.ResultFor(*cookieFlag)
+	results := []monster.UnsignResult{result}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if strings.HasSuffix(path, ".xml") {
+		return monster.WriteKeePassXML(file, results)
+	}
+
+	return monster.WriteCSV(file, results)
+}
+
+// Writes the likeliest hashcat hash for the cookie (or the one for
+// -decoder) to `path`, and prints how to crack it.
+func exportHashcat(cookie *monster.Cookie, path string) {
+	hashes := cookie.HashcatHashes()
+	if *decoderFlag != "" {
+		hash, err := cookie.HashcatHashFor(*decoderFlag)
+		if err != nil {
+			failureMessage(fmt.Sprintf("Sorry, I could not export the %s signature for hashcat. Error: %v", *decoderFlag, err))
+		}
+
+		hashes = []monster.HashcatHash{hash}
+	}
+
+	if len(hashes) == 0 {
+		failureMessage("Sorry, hashcat can't crack this cookie's signature; Django and Flask derive their key from the secret in a way it has no mode for, and long cookies don't fit in its salts.")
+	}
+
+	if likeliest := cookie.Matches()[0].Decoder; *decoderFlag == "" && hashes[0].Decoder != likeliest {
+		fmt.Printf(ColorYellow+"⚠️  hashcat can't crack the %s signature this cookie most likely has, so this is the signature it would have as %s instead.\n"+ColorReset, likeliest, hashes[0].Decoder)
+	}
+
+	if err := os.WriteFile(path, []byte(hashes[0].Hash+"\n"), 0644); err != nil {
+		failureMessage(fmt.Sprintf("Sorry, I could not write the hash. Error: %v", err))
+	}
+
+	fmt.Printf("ℹ️  I wrote the %s signature to %s; crack it with:\n", hashes[0].Decoder, path)
+	fmt.Println("hashcat " + strings.Join(hashes[0].Args(), " ") + " " + path + " <wordlist>")
+	fmt.Println("ℹ️  Then pass -potfile with hashcat's potfile (hashcat.potfile, by default) to check the key and resign the cookie.")
+}
+
+// Loads the passwords in -potfile which cracked any of `hashes`.
+func loadPotfile(hashes []monster.HashcatHash) *monster.Wordlist {
+	file, err := os.Open(*potfileFlag)
+	if err != nil {
+		failureMessage(fmt.Sprintf("Sorry, I could not open your potfile. Error: %v", err))
+	}
+	defer file.Close()
+
+	secrets, err := monster.SecretsFromPotfile(file, hashes)
+	if err != nil {
+		failureMessage(fmt.Sprintf("Sorry, I could not read your potfile. Error: %v", err))
+	}
+
+	wl := monster.NewWordlist()
+	wl.LoadFromArray(secrets)
+	fmt.Println("ℹ️  CookieMonster found", wl.Count(), "matching passwords in your potfile.")
+
+	return wl
+}
+
+// Loads the wordlist the flags ask for. If `streamable`, a wordlist which
+// should be streamed is opened instead, and returned for the caller to close.
+func loadWordlist(streamable bool) (wl *monster.Wordlist, stream io.ReadCloser) {
+	wl = monster.NewWordlist()
+
+	if *secretFileFlag != "" {
+		contents, err := os.ReadFile(*secretFileFlag)
+		if err != nil {
+			failureMessage(fmt.Sprintf("Sorry, I could not read your secret. Error: %v", err))
+		}
+
+		secret, err := monster.ParseSecret(string(contents))
+		if err != nil {
+			failureMessage(fmt.Sprintf("Sorry, I could not parse your secret. Error: %v", err))
+		}
+
+		wl.LoadFromArray([][]byte{secret})
+		fmt.Println("ℹ️  CookieMonster loaded your secret; it is", len(secret), "bytes.")
+	} else if *keyRingFlag != "" {
+		file, err := os.Open(*keyRingFlag)
+		if err != nil {
+			failureMessage(fmt.Sprintf("Sorry, I could not open your key ring. Error: %v", err))
+		}
+
+		keys, err := monster.KeysFromDataProtectionXML(file)
+		file.Close()
+
+		if err != nil {
+			failureMessage(fmt.Sprintf("Sorry, I could not read your key ring. Error: %v", err))
+		}
+
+		wl.LoadFromArray(keys)
+		fmt.Println("ℹ️  CookieMonster loaded your key ring; it has", wl.Count(), "unencrypted keys.")
+	} else if *wordlistFlag == defaultWordlistKey {
+		if err := wl.LoadFromString(defaultWordlist); err != nil {
+			failureMessage(fmt.Sprintf("Sorry, I could not load the default wordlist. Please report this to the maintainers. Error: %v", err))
+		}
+
+		fmt.Println("ℹ️  CookieMonster loaded the default wordlist; it has", wl.Count(), "entries.")
+	} else if streamable {
+		var err error
+		if stream, err = monster.OpenWordlist(*wordlistFlag); err != nil {
+			failureMessage(fmt.Sprintf("Sorry, I could not open your wordlist. Error: %v", err))
+		}
+
+		fmt.Println("ℹ️  CookieMonster is streaming your wordlist.")
+	} else {
+		if err := wl.Load(*wordlistFlag); err != nil {
+			failureMessage(fmt.Sprintf("Sorry, I could not load your wordlist. Please ensure every line contains valid base64. Error: %v", err))
+		}
+
+		fmt.Println("ℹ️  CookieMonster loaded your wordlist; it has", wl.Count(), "entries.")
+	}
+
+	return wl, stream
+}
+
+// Turns the flags into options for `Unsign()`.
+func unsignOptionsFromFlags() (unsignOptions []monster.UnsignOption) {
+	if *algorithmsFlag != "" {
+		withAlgorithms, err := monster.WithAlgorithms(strings.Split(*algorithmsFlag, ","))
+		if err != nil {
+			failureMessage(fmt.Sprintf("Sorry, I don't support one of those algorithms. Error: %v", err))
+		}
+
+		unsignOptions = append(unsignOptions, withAlgorithms)
+	}
+
+	if len(djangoSaltFlags) > 0 {
+		unsignOptions = append(unsignOptions, monster.WithDjangoSalts(djangoSaltFlags))
+	}
+
+	if *autoTuneFlag {
+		unsignOptions = append(unsignOptions, monster.WithAutoTune())
+	}
+
+	if *truncatedFlag > 0 {
+		unsignOptions = append(unsignOptions, monster.WithTruncatedSecrets(*truncatedFlag))
+	}
+
+	if *canonicalFlag {
+		unsignOptions = append(unsignOptions, monster.WithCanonicalJSON())
+	}
+
+	switch *kdfFlag {
+	case "":
+	case "hkdf":
+		unsignOptions = append(unsignOptions, monster.WithKDF(monster.HKDF{Info: []byte(*kdfInfoFlag)}))
+	case "hkdf-expand":
+		unsignOptions = append(unsignOptions, monster.WithKDF(monster.HKDFExpand{Info: []byte(*kdfInfoFlag)}))
+	default:
+		failureMessage("Sorry, I don't support that KDF; try hkdf or hkdf-expand.")
+	}
+
+	if *keyIDFlag {
+		unsignOptions = append(unsignOptions, monster.WithKeyID())
+	}
+
+	if *cookieNameFlag != "" {
+		unsignOptions = append(unsignOptions, monster.WithCookieName(*cookieNameFlag))
+	}
+
+	if *utf16Flag {
+		unsignOptions = append(unsignOptions, monster.WithSecretTransform(monster.UTF16LE))
+	}
+
+	if *decoderFlag != "" {
+		unsignOptions = append(unsignOptions, monster.WithDecoder(*decoderFlag))
+	}
+
+	if *maxFlag > 0 {
+		unsignOptions = append(unsignOptions, monster.WithMaxCandidates(*maxFlag))
+	}
+
+	if *cacheFlag || *cacheFileFlag != "" {
+		unsignOptions = append(unsignOptions, monster.WithSecretCache(secretCachePath()))
+	}
+
+	return unsignOptions
+}
+
 func main() {
 	sayHello()
+
+	if len(os.Args) > 1 && os.Args[1] == "resign" {
+		resignMain(os.Args[2:])
+		return
+	}
+
 	flag.Parse()
 
+	if *cacheListFlag {
+		listSecretCache()
+		return
+	}
+
+	if *cachePurgeFlag {
+		purgeSecretCache()
+		return
+	}
+
 	// We need both of these.
-	if *cookieFlag == "" || *wordlistFlag == "" {
+	if (*cookieFlag == "" && *batchFlag == "" && *urlFlag == "") || *wordlistFlag == "" {
 		flag.Usage()
 		os.Exit(1)
 	}
 
+	if *batchFlag != "" {
+		if *batchFlag == "-" && *wordlistFlag == "-" {
+			failureMessage("Sorry, the cookies and the wordlist can't both be read from standard input.")
+		}
+
+		inputs, err := readBatch(*batchFlag)
+		if err != nil {
+			failureMessage(fmt.Sprintf("Sorry, I could not read your cookies. Error: %v", err))
+		}
+
+		fmt.Println("ℹ️  CookieMonster read", len(inputs), "cookies.")
+		batchMain(inputs)
+		return
+	}
+
+	if *urlFlag != "" {
+		batchMain(fetchInputs(*urlFlag))
+		return
+	}
+
 	cookie := monster.NewCookie(*cookieFlag)
+	if *signatureFlag != "" {
+		cookie = monster.NewDetachedCookie(*cookieFlag, *signatureFlag)
+	}
+
+	if *saltSuffixFlag {
+		cookie.SetDjangoSaltOrder(monster.SaltSuffix)
+	}
+
+	cookie.SetRailsKeyDerivation(*railsSaltFlag, *railsIterFlag)
+	cookie.SetRememberMePasswords(springPasswordFlags...)
+
 	if !cookie.Decode() {
+		for _, diagnostic := range cookie.Diagnostics() {
+			fmt.Println("ℹ️  " + diagnostic)
+		}
+
 		failureMessage("Sorry, I could not decode this cookie; it's likely not in a supported format.")
 	}
 
-	if *verboseFlag {
+	if *verboseFlag && !*jsonFlag {
 		fmt.Println(cookie.String())
 	}
 
-	wl := monster.NewWordlist()
+	if *payloadFlag {
+		printPayloads(cookie)
+	}
 
-	if *wordlistFlag == defaultWordlistKey {
-		if err := wl.LoadFromString(defaultWordlist); err != nil {
-			failureMessage(fmt.Sprintf("Sorry, I could not load the default wordlist. Please report this to the maintainers. Error: %v", err))
+	if *decoderFlag == "" {
+		matchesMessage(cookie)
+	} else if !wasDecodedBy(cookie, *decoderFlag) {
+		failureMessage(fmt.Sprintf("Sorry, the %s decoder does not recognize this cookie.", *decoderFlag))
+	}
+
+	if len(springPasswordFlags) == 0 && wasDecodedBy(cookie, monster.DecoderSpring) {
+		fmt.Println("ℹ️  This looks like a Spring Security remember-me cookie, which signs the user's stored password along with the key; give it with -spring-password to search for the key.")
+	}
+
+	if cookie.HasNoSignature() {
+		fmt.Println("ℹ️  This cookie is compressed JSON without a signature; anyone can modify it, so there is no secret to discover.")
+		os.Exit(0)
+	}
+
+	// Cloud providers sign or encrypt their own sessions with keys they
+	// hold, so there's no secret to crack.
+	if cloud, ok := cookie.CloudToken(); ok {
+		cloudMessage(cloud)
+
+		if token, ok := cookie.FirebaseToken(); ok && *firebaseFlag {
+			certsURL := monster.FirebaseIDTokenCertsURL
+			if token.Session {
+				certsURL = monster.FirebaseSessionCertsURL
+			}
+
+			verified, err := cookie.VerifyFirebase(&monster.FirebaseCerts{URL: certsURL})
+			if err != nil {
+				failureMessage(fmt.Sprintf("Sorry, I could not verify this token against Google's keys. Error: %v", err))
+			}
+
+			if verified {
+				fmt.Println(ColorGreen + "✅ This token's signature is valid for Google's current keys." + ColorReset)
+			} else {
+				failureMessage("This token's signature is not valid for Google's current keys.")
+			}
 		}
 
-		fmt.Println("ℹ️  CookieMonster loaded the default wordlist; it has", wl.Count(), "entries.")
+		os.Exit(0)
+	}
+
+	if *hashcatFlag != "" {
+		exportHashcat(cookie, *hashcatFlag)
+		os.Exit(0)
+	}
+
+	// Standard input and gzipped lists are usually too big to load, so we
+	// stream them unless a flag needs every entry up front.
+	streamable := (*wordlistFlag == "-" || strings.HasSuffix(*wordlistFlag, ".gz")) && !*uuidFlag && !*fieldsFlag && !*autoTuneFlag && *truncatedFlag == 0 && !*defaultsFlag
+
+	// A mask replaces the wordlist entirely.
+	var keyspace *monster.Keyspace
+	wl, stream := monster.NewWordlist(), io.ReadCloser(nil)
+
+	if *maskFlag != "" {
+		keyspace = loadKeyspace()
+	} else if *potfileFlag != "" {
+		wl = loadPotfile(cookie.HashcatHashes())
 	} else {
-		if err := wl.Load(*wordlistFlag); err != nil {
-			failureMessage(fmt.Sprintf("Sorry, I could not load your wordlist. Please ensure every line contains valid base64. Error: %v", err))
+		wl, stream = loadWordlist(streamable)
+	}
+
+	if stream != nil {
+		defer stream.Close()
+	}
+
+	if *uuidFlag {
+		wl = monster.UUIDVariants(wl, 0)
+		fmt.Println("ℹ️  CookieMonster expanded the UUIDs in the wordlist to", wl.Count(), "entries.")
+	}
+
+	if *fieldsFlag {
+		derived := monster.FieldCandidates(cookie)
+		fmt.Println("ℹ️  CookieMonster derived", derived.Count(), "candidate secrets from the cookie's fields.")
+
+		derived.LoadFromArray(wl.Entries())
+		wl = derived
+	}
+
+	if keyspace == nil && *potfileFlag == "" && (*defaultsFlag || *wordlistFlag == defaultWordlistKey) {
+		var decoders []string
+		for _, fields := range cookie.DecodedFields() {
+			decoders = append(decoders, fields.Decoder)
 		}
 
-		fmt.Println("ℹ️  CookieMonster loaded your wordlist; it has", wl.Count(), "entries.")
+		defaults := monster.DefaultWordlist(decoders...)
+		fmt.Println("ℹ️  CookieMonster will try", defaults.Count(), "default secrets for this cookie's frameworks first.")
+
+		defaults.LoadFromArray(wl.Entries())
+		wl = defaults
+	}
+
+	unsignOptions := unsignOptionsFromFlags()
+
+	// We print the engine's warnings from one goroutine, so they never
+	// interleave with our own output.
+	events := make(monster.ChannelSink)
+	drained := make(chan struct{})
+	unsignOptions = append(unsignOptions, monster.WithLogSink(events))
+
+	go func() {
+		for event := range events {
+			if event.Kind == monster.LogWarning {
+				fmt.Println(ColorYellow + "⚠️  " + event.Message + ColorReset)
+			}
+		}
+
+		close(drained)
+	}()
+
+	var success bool
+	var streamErr error
+	if stream != nil {
+		_, success, streamErr = cookie.UnsignReader(stream, uint64(*concurrencyFlag), unsignOptions...)
+	} else if keyspace != nil {
+		success = unsignKeyspace(cookie, keyspace, unsignOptions)
+	} else {
+		_, success = cookie.Unsign(wl, uint64(*concurrencyFlag), unsignOptions...)
 	}
 
-	if _, success := cookie.Unsign(wl, uint64(*concurrencyFlag)); success {
+	close(events)
+	<-drained
+
+	if *verboseFlag {
+		progress := cookie.Progress()
+		fmt.Printf("ℹ️  Tried %d candidate keys in %s (%.0f per second).\n", progress.Tried, progress.Elapsed.Round(time.Millisecond), progress.Rate())
+	}
+
+	if streamErr != nil && !success {
+		failureMessage(fmt.Sprintf("Sorry, I could not read your wordlist. Please ensure every line contains valid base64. Error: %v", streamErr))
+	}
+
+	if *templateFlag != "" {
+		out, err := cookie.Render(*templateFlag)
+		if err != nil {
+			failureMessage(fmt.Sprintf("Sorry, I could not render your template. Error: %v", err))
+		}
+
+		fmt.Println(out)
+	}
+
+	if *oneLineFlag {
+		fmt.Println(cookie.OneLine())
+	}
+
+	if *jsonFlag {
+		out, err := json.Marshal(cookie)
+		if err != nil {
+			failureMessage(fmt.Sprintf("Sorry, I could not encode the cookie as JSON. Error: %v", err))
+		}
+
+		fmt.Println(string(out))
+	}
+
+	if success {
 		keyDiscoveredMessage(cookie)
+
+		if *exportFlag != "" {
+			if err := exportResult(cookie, *exportFlag); err != nil {
+				failureMessage(fmt.Sprintf("Sorry, I could not export the secret. Error: %v", err))
+			}
+		}
+	} else if cookie.LimitReached() {
+		failureMessage(fmt.Sprintf("Sorry, I did not discover the key for this cookie within %d candidates.", *maxFlag))
 	} else {
 		failureMessage("Sorry, I did not discover the key for this cookie.")
 	}
 
 	if *resignFlag != "" {
-		if resigned := cookie.Resign(*resignFlag); resigned != "" {
+		if cookies, err := cookie.ResignCookiesWith(*resignFlag, monster.ResignOptions{Algorithm: *resignAlgFlag, AllowDowngrade: *downgradeFlag}); err == nil {
+			names := make([]string, 0, len(cookies))
+			for name := range cookies {
+				names = append(names, name)
+			}
+
+			sort.Strings(names)
+
+			for _, name := range names {
+				resignedMessage(name + "=" + cookies[name])
+			}
+		} else if resigned, err := cookie.ResignWith(*resignFlag, monster.ResignOptions{Algorithm: *resignAlgFlag, AllowDowngrade: *downgradeFlag, Reserialize: *reserializeFlag}); err == nil {
 			resignedMessage(resigned)
 		} else {
-			failureMessage("Sorry, I was unable to resign this cookie for you. It may not be supported for this decoder.")
+			failureMessage(fmt.Sprintf("Sorry, I was unable to resign this cookie for you. It may not be supported for this decoder. Error: %v", err))
 		}
 	}
 }
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/iangcarroll/cookiemonster/pkg/monster"
+	"github.com/iangcarroll/cookiemonster/pkg/payload"
+)
+
+// Prints what each decoder found inside the cookie, for -payload.
+func printPayloads(cookie *monster.Cookie) {
+	for _, fields := range cookie.DecodedFields() {
+		printPayload(cookie, fields.Decoder)
+	}
+}
+
+// Deserializes and prints `decoder`'s payload as JSON, then the fields worth
+// tampering with; decoders without a payload we can read are skipped.
+func printPayload(cookie *monster.Cookie, decoder string) {
+	data, ok := cookie.Payload(decoder)
+	if !ok {
+		return
+	}
+
+	decoded, err := payload.Decode(data)
+	if err != nil {
+		if *verboseFlag {
+			fmt.Printf("ℹ️  I could not deserialize the %s payload. Error: %v\n", decoder, err)
+		}
+
+		return
+	}
+
+	out, err := json.MarshalIndent(decoded.Value, "", "  ")
+	if err != nil {
+		failureMessage(fmt.Sprintf("Sorry, I could not encode the payload as JSON. Error: %v", err))
+	}
+
+	fmt.Printf("ℹ️  The %s payload (%s) is:\n%s\n", decoder, decoded.Format, out)
+
+	for _, flag := range decoded.Flags {
+		value, _ := json.Marshal(flag.Value)
+
+		switch {
+		case flag.Reason == payload.ReasonCallable || flag.Reason == payload.ReasonObject:
+			fmt.Printf(ColorYellow+"⚠️  %s is %s, which the app instantiates when it loads the cookie.\n"+ColorReset, flag.Path, value)
+		case flag.Time != nil:
+			fmt.Printf("ℹ️  %s (%s) is %s, i.e. %s.\n", flag.Path, flag.Reason, value, flag.Time.Format(time.RFC3339))
+		default:
+			fmt.Printf("ℹ️  %s (%s) is %s.\n", flag.Path, flag.Reason, value)
+		}
+	}
+
+	if decoder == monster.DecoderDjango || decoder == monster.DecoderFlask {
+		if signedAt, ok := cookie.SignedAt(); ok {
+			fmt.Println("ℹ️  It was signed at", signedAt.Format(time.RFC3339)+".")
+		}
+	}
+}
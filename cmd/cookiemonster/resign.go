@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/iangcarroll/cookiemonster/pkg/monster"
+)
+
+var errNotJSONObject = errors.New("the payload is not a JSON object")
+
+// Runs `cookiemonster resign`, which forges a cookie from a known secret
+// and an edited copy of the cookie's own payload.
+func resignMain(args []string) {
+	flags := flag.NewFlagSet("resign", flag.ExitOnError)
+
+	cookieFlag := flags.String("cookie", "", "Required. The cookie to forge a new one from.")
+	secretFlag := flags.String("secret", "", "The cookie's secret, e.g. one CookieMonster discovered; it may be a PEM-encoded key or a Laravel base64: key. Either this or -secret-file is required.")
+	secretFileFlag := flags.String("secret-file", "", "The path to the cookie's secret, instead of -secret.")
+	dataFlag := flags.String("data", "", "Optional. The new payload (usually JSON), instead of the cookie's own.")
+	dataFileFlag := flags.String("data-file", "", "Optional. The path to read the new payload from, instead of -data; use - for standard input.")
+	keepFlag := flags.Bool("keep-timestamp", false, "Optional. Signs the new cookie with the original's timestamp, rather than the current time, for Django and Flask, and keeps a Rails session's expiry.")
+	algorithmFlag := flags.String("algorithm", "", "Optional. The algorithm to sign with; the default is the cookie's original algorithm.")
+	downgradeFlag := flags.Bool("allow-downgrade", false, "Optional. Allows -algorithm to be weaker than the cookie's original algorithm.")
+	railsSaltFlag := flags.String("rails-salt", "", "Optional. The salt Rails derives the encrypted cookie key with; the default is \"authenticated encrypted cookie\".")
+	railsIterFlag := flags.Int("rails-iterations", 0, "Optional. The PBKDF2 iterations Rails derives the encrypted cookie key with; the default is 1000.")
+
+	var setFlags stringList
+	flags.Var(&setFlags, "set", "Optional. A `key=value` to change in the payload, which must be a JSON object; the value is parsed as JSON if it can be (e.g. true, 42 or {\"a\":1}) and is a string otherwise, and dots in the key reach into nested objects. It may be given more than once.")
+
+	flags.Parse(args)
+
+	if *cookieFlag == "" || (*secretFlag == "") == (*secretFileFlag == "") {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	secret := *secretFlag
+	if *secretFileFlag != "" {
+		contents, err := os.ReadFile(*secretFileFlag)
+		if err != nil {
+			failureMessage(fmt.Sprintf("Sorry, I could not read your secret. Error: %v", err))
+		}
+
+		secret = string(contents)
+	}
+
+	key, err := monster.ParseSecret(secret)
+	if err != nil {
+		failureMessage(fmt.Sprintf("Sorry, I could not parse your secret. Error: %v", err))
+	}
+
+	cookie, err := monster.New(*cookieFlag)
+	if err != nil {
+		failureMessage(fmt.Sprintf("Sorry, I could not decode this cookie. Error: %v", err))
+	}
+
+	cookie.SetRailsKeyDerivation(*railsSaltFlag, *railsIterFlag)
+
+	if !cookie.UnsignWithSecret(key) {
+		failureMessage("Sorry, that secret does not verify this cookie.")
+	}
+
+	_, _, decoder := cookie.Result()
+
+	data, err := templatePayload(cookie, decoder, *dataFlag, *dataFileFlag, setFlags)
+	if err != nil {
+		failureMessage(fmt.Sprintf("Sorry, I could not build the new payload. Error: %v", err))
+	}
+
+	// cookie-session's value needs its `.sig` cookie forged alongside it.
+	if cookies, err := cookie.ResignCookiesWith(data, monster.ResignOptions{Algorithm: *algorithmFlag, AllowDowngrade: *downgradeFlag, Key: key}); err == nil {
+		names := make([]string, 0, len(cookies))
+		for name := range cookies {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		for _, name := range names {
+			resignedMessage(name + "=" + cookies[name])
+		}
+
+		return
+	}
+
+	options := monster.ResignOptions{Algorithm: *algorithmFlag, AllowDowngrade: *downgradeFlag}
+	if !*keepFlag {
+		options.Timestamp = time.Now()
+	}
+
+	// Edited JSON is re-serialized the way Django and Flask would.
+	if decoder == monster.DecoderDjango || decoder == monster.DecoderFlask {
+		options.Reserialize = json.Valid([]byte(data))
+	}
+
+	resigned, err := cookie.ResignWithSecret(data, key, options)
+	if err != nil {
+		failureMessage(fmt.Sprintf("Sorry, I was unable to resign this cookie for you. It may not be supported for this decoder. Error: %v", err))
+	}
+
+	resignedMessage(resigned)
+}
+
+// Builds the payload to sign: the cookie's own unless `data` or `dataFile`
+// replaces it, with each of `sets` applied. Rails' envelope is kept around
+// an edited session, so its purpose and expiry still match.
+func templatePayload(cookie *monster.Cookie, decoder string, data string, dataFile string, sets []string) (string, error) {
+	var rails map[string]interface{}
+	var quoted bool
+
+	payload := []byte(data)
+	switch {
+	case dataFile == "-":
+		read, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", err
+		}
+
+		payload = bytes.TrimSpace(read)
+	case dataFile != "":
+		read, err := os.ReadFile(dataFile)
+		if err != nil {
+			return "", err
+		}
+
+		payload = bytes.TrimSpace(read)
+	case data == "":
+		original, ok := cookie.Payload(decoder)
+		if !ok {
+			return "", fmt.Errorf("the %s decoder has no payload to edit; pass -data", decoder)
+		}
+
+		payload = original
+		if message, envelope, ok := railsEnvelope(original); ok {
+			payload, rails = message, envelope
+
+			// Some apps serialize the session to JSON twice, leaving a
+			// string in the envelope.
+			var inner string
+			if json.Unmarshal(payload, &inner) == nil {
+				payload, quoted = []byte(inner), true
+			}
+		}
+	}
+
+	if len(sets) > 0 {
+		edited, err := setFields(payload, sets)
+		if err != nil {
+			return "", err
+		}
+
+		payload = edited
+	}
+
+	if rails != nil {
+		if quoted {
+			encoded, err := marshalPayload(string(payload))
+			if err != nil {
+				return "", err
+			}
+
+			payload = []byte(encoded)
+		}
+
+		rails["_rails"].(map[string]interface{})["message"] = base64.StdEncoding.EncodeToString(payload)
+		return marshalPayload(rails)
+	}
+
+	return string(payload), nil
+}
+
+// Returns the session inside Rails' `{"_rails": {"message": "..."}}`
+// envelope, along with the envelope itself.
+func railsEnvelope(plaintext []byte) ([]byte, map[string]interface{}, bool) {
+	var envelope map[string]interface{}
+	if json.Unmarshal(plaintext, &envelope) != nil {
+		return nil, nil, false
+	}
+
+	rails, ok := envelope["_rails"].(map[string]interface{})
+	if !ok {
+		return nil, nil, false
+	}
+
+	message, ok := rails["message"].(string)
+	if !ok {
+		return nil, nil, false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(message)
+	if err != nil {
+		return nil, nil, false
+	}
+
+	return decoded, envelope, true
+}
+
+// Applies each `key=value` of `sets` to the JSON object in `payload`.
+func setFields(payload []byte, sets []string) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+
+	var object map[string]interface{}
+	if err := decoder.Decode(&object); err != nil || object == nil {
+		return nil, errNotJSONObject
+	}
+
+	for _, set := range sets {
+		components := strings.SplitN(set, "=", 2)
+		if len(components) != 2 || components[0] == "" {
+			return nil, fmt.Errorf("%q is not a key=value", set)
+		}
+
+		// Anything that isn't valid JSON is taken as a string.
+		var value interface{} = components[1]
+		valueDecoder := json.NewDecoder(strings.NewReader(components[1]))
+		valueDecoder.UseNumber()
+
+		var parsed interface{}
+		if valueDecoder.Decode(&parsed) == nil && !valueDecoder.More() {
+			value = parsed
+		}
+
+		path := strings.Split(components[0], ".")
+		parent := object
+
+		for _, name := range path[:len(path)-1] {
+			child, ok := parent[name].(map[string]interface{})
+			if !ok {
+				child = make(map[string]interface{})
+				parent[name] = child
+			}
+
+			parent = child
+		}
+
+		parent[path[len(path)-1]] = value
+	}
+
+	out, err := marshalPayload(object)
+	return []byte(out), err
+}
+
+// Encodes `value` compactly, without escaping HTML characters as
+// `json.Marshal` does, since no framework would.
+func marshalPayload(value interface{}) (string, error) {
+	var out bytes.Buffer
+
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+
+	if err := encoder.Encode(value); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(out.String(), "\n"), nil
+}
@@ -2,12 +2,28 @@ package main
 
 import (
 	"encoding/base64"
+	"strings"
 	"unicode"
+
+	"github.com/iangcarroll/cookiemonster/pkg/monster"
 )
 
+// A flag which may be given more than once, keeping each value in order.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
 func isASCII(s string) bool {
 	for i := 0; i < len(s); i++ {
-		if s[i] > unicode.MaxASCII {
+		// Control characters (e.g. the NULs of a UTF-16 key) garble output too.
+		if s[i] > unicode.MaxASCII || !unicode.IsPrint(rune(s[i])) {
 			return false
 		}
 	}
@@ -17,3 +33,14 @@ func isASCII(s string) bool {
 func base64Key(k []byte) string {
 	return base64.StdEncoding.EncodeToString(k)
 }
+
+// Reports whether `decoder` (e.g. `monster.DecoderSpring`) decoded the cookie.
+func wasDecodedBy(cookie *monster.Cookie, decoder string) bool {
+	for _, fields := range cookie.DecodedFields() {
+		if fields.Decoder == decoder {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,55 @@
+package base62
+
+import (
+	"errors"
+	"strings"
+)
+
+// The alphabet Django's `signing` module uses for its timestamps.
+const alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+var (
+	ErrInvalid = errors.New("invalid base62 value")
+)
+
+// Encodes `n` in base62. Zero is "0".
+func Encode(n uint64) string {
+	if n == 0 {
+		return alphabet[:1]
+	}
+
+	var buf [11]byte
+	i := len(buf)
+
+	for n > 0 {
+		i--
+		buf[i] = alphabet[n%62]
+		n /= 62
+	}
+
+	return string(buf[i:])
+}
+
+// Decodes a base62 value, failing if it is empty, has characters outside
+// the alphabet, or doesn't fit in a `uint64`.
+func Decode(s string) (uint64, error) {
+	if len(s) == 0 {
+		return 0, ErrInvalid
+	}
+
+	var value uint64
+	for _, r := range s {
+		digit := strings.IndexRune(alphabet, r)
+		if digit < 0 {
+			return 0, ErrInvalid
+		}
+
+		if value > (^uint64(0)-uint64(digit))/62 {
+			return 0, ErrInvalid
+		}
+
+		value = value*62 + uint64(digit)
+	}
+
+	return value, nil
+}
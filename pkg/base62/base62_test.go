@@ -0,0 +1,41 @@
+package base62
+
+import (
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	vectors := map[uint64]string{
+		0:          "0",
+		61:         "z",
+		62:         "10",
+		1635757200: "1mhTAe",
+		^uint64(0): "LygHa16AHYF",
+	}
+
+	for n, encoded := range vectors {
+		if out := Encode(n); out != encoded {
+			t.Errorf("encoded %d as %s, wanted %s", n, out, encoded)
+		}
+
+		if out, err := Decode(encoded); err != nil || out != n {
+			t.Errorf("decoded %s as %d, wanted %d (%v)", encoded, out, n, err)
+		}
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	for _, s := range []string{"", "1mh-Ae", "LygHa16AHYG", "100000000000"} {
+		if _, err := Decode(s); err != ErrInvalid {
+			t.Errorf("decoded invalid value %q", s)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for n := uint64(1); n < 1<<63; n = n*7 + 3 {
+		if out, err := Decode(Encode(n)); err != nil || out != n {
+			t.Errorf("round-tripped %d as %d (%v)", n, out, err)
+		}
+	}
+}
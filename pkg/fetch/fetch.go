@@ -0,0 +1,194 @@
+// Package fetch requests a target URL, optionally after logging in, and
+// collects the cookies it sets, for `monster` to decode and unsign.
+package fetch
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+
+	"github.com/iangcarroll/cookiemonster/pkg/monster"
+)
+
+var (
+	ErrTooManyRedirects = errors.New("stopped after too many redirects")
+)
+
+// How many redirects `Cookies()` follows by default, as `net/http` does.
+const defaultMaxRedirects = 10
+
+// How `Cookies()` requests the target.
+type Options struct {
+	// Sent with every request, e.g. `Authorization` or `User-Agent`.
+	Headers http.Header
+
+	// If set, `LoginForm` is posted here first, and whatever cookies the
+	// login sets are sent with the request for the target. Cookies set
+	// while logging in are collected too.
+	LoginURL  string
+	LoginForm url.Values
+
+	// How many redirects each request may follow; the default is 10, and a
+	// negative value follows none.
+	MaxRedirects int
+
+	// The client requests are made with; its `Jar` and `CheckRedirect` are
+	// replaced. The default is a copy of `http.DefaultClient`.
+	Client *http.Client
+}
+
+// Requests `target`, following redirects, and returns every cookie set by
+// each response along the way, in the order they were set, labelled with
+// the URL that set them. We read `Set-Cookie` headers ourselves rather than
+// use `http.Response.Cookies`, since it drops values it considers invalid.
+func Cookies(target string, options Options) ([]monster.NamedCookie, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{}
+	if options.Client != nil {
+		*client = *options.Client
+	}
+
+	client.Jar = jar
+
+	// We follow redirects ourselves to see the cookies set on each hop.
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	f := &fetcher{client: client, options: options}
+
+	if options.LoginURL != "" {
+		if err := f.do(http.MethodPost, options.LoginURL, options.LoginForm); err != nil {
+			return nil, fmt.Errorf("logging in: %w", err)
+		}
+	}
+
+	if err := f.do(http.MethodGet, target, nil); err != nil {
+		return nil, err
+	}
+
+	return f.cookies, nil
+}
+
+type fetcher struct {
+	client  *http.Client
+	options Options
+	cookies []monster.NamedCookie
+}
+
+// Makes a request and follows its redirects, collecting cookies.
+func (f *fetcher) do(method string, target string, form url.Values) error {
+	maxRedirects := f.options.MaxRedirects
+	if maxRedirects == 0 {
+		maxRedirects = defaultMaxRedirects
+	}
+
+	var host string
+
+	for redirects := 0; ; redirects++ {
+		req, err := newRequest(method, target, form)
+		if err != nil {
+			return err
+		}
+
+		if redirects == 0 {
+			host = req.URL.Host
+		}
+
+		// The caller's headers are often credentials, so as `net/http` does
+		// for its sensitive headers, they aren't sent to other hosts the
+		// target redirects to.
+		if strings.EqualFold(req.URL.Host, host) {
+			for name, values := range f.options.Headers {
+				req.Header[name] = values
+			}
+		}
+
+		resp, err := f.client.Do(req)
+		if err != nil {
+			return err
+		}
+
+		resp.Body.Close()
+
+		for _, header := range resp.Header.Values("Set-Cookie") {
+			if cookie, ok := parseSetCookie(header); ok {
+				cookie.URL = req.URL.String()
+				cookie.Host = req.URL.Hostname()
+				f.cookies = append(f.cookies, cookie)
+			}
+		}
+
+		if resp.StatusCode < 300 || resp.StatusCode > 399 {
+			return nil
+		}
+
+		location, err := resp.Location()
+		if errors.Is(err, http.ErrNoLocation) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if maxRedirects < 0 {
+			return nil
+		} else if redirects >= maxRedirects {
+			return ErrTooManyRedirects
+		}
+
+		// As browsers do, a redirect after a POST is fetched with a GET,
+		// except for the two codes which preserve the method.
+		if resp.StatusCode != http.StatusTemporaryRedirect && resp.StatusCode != http.StatusPermanentRedirect {
+			method, form = http.MethodGet, nil
+		}
+
+		target = location.String()
+	}
+}
+
+func newRequest(method string, target string, form url.Values) (*http.Request, error) {
+	if form == nil {
+		return http.NewRequest(method, target, nil)
+	}
+
+	req, err := http.NewRequest(method, target, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req, nil
+}
+
+// Returns the name and value of a `Set-Cookie` header, ignoring its
+// attributes.
+func parseSetCookie(header string) (monster.NamedCookie, bool) {
+	pair := strings.SplitN(header, ";", 2)[0]
+
+	// Values may contain `=`, e.g. base64 padding, so we only split once.
+	components := strings.SplitN(pair, "=", 2)
+	if len(components) != 2 {
+		return monster.NamedCookie{}, false
+	}
+
+	name := strings.TrimSpace(components[0])
+	if name == "" {
+		return monster.NamedCookie{}, false
+	}
+
+	// Quoted values are allowed, though rare. An empty value deletes the
+	// cookie, so there's nothing to decode.
+	value := strings.Trim(strings.TrimSpace(components[1]), `"`)
+	if value == "" {
+		return monster.NamedCookie{}, false
+	}
+
+	return monster.NamedCookie{Name: name, Value: value}, true
+}
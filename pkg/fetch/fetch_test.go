@@ -0,0 +1,125 @@
+package fetch
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCookies(t *testing.T) {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.FormValue("user") != "admin" {
+			http.Error(w, "bad login", http.StatusForbidden)
+			return
+		}
+
+		// `net/http` would drop the second value, since it has a space.
+		w.Header().Add("Set-Cookie", "session=eyJ1c2VyIjoiYWRtaW4ifQ==; Path=/; HttpOnly")
+		w.Header().Add("Set-Cookie", "odd=has space; Path=/")
+		http.Redirect(w, r, "/home", http.StatusFound)
+	})
+
+	mux.HandleFunc("/home", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "not redirected with a GET", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/account", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := r.Cookie("session"); err != nil || r.Header.Get("X-Test") != "yes" {
+			http.Error(w, "not logged in", http.StatusForbidden)
+			return
+		}
+
+		w.Header().Add("Set-Cookie", "csrf=; Max-Age=0")
+		http.Redirect(w, r, "/account/settings", http.StatusFound)
+	})
+
+	mux.HandleFunc("/account/settings", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Set-Cookie", `prefs="dark"; Path=/account`)
+	})
+
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	cookies, err := Cookies(server.URL+"/account", Options{
+		Headers:   http.Header{"X-Test": {"yes"}},
+		LoginURL:  server.URL + "/login",
+		LoginForm: url.Values{"user": {"admin"}},
+	})
+
+	if err != nil {
+		t.Fatalf("could not fetch the cookies: %v", err)
+	}
+
+	if len(cookies) != 3 {
+		t.Fatalf("found %d cookies instead of 3: %+v", len(cookies), cookies)
+	}
+
+	if cookies[0].Name != "session" || cookies[0].Value != "eyJ1c2VyIjoiYWRtaW4ifQ==" || cookies[0].URL != server.URL+"/login" {
+		t.Errorf("unexpected cookie %+v", cookies[0])
+	}
+
+	if cookies[1].Value != "has space" {
+		t.Errorf("dropped the invalid value: %+v", cookies[1])
+	}
+
+	if cookies[2].Name != "prefs" || cookies[2].Value != "dark" || cookies[2].URL != server.URL+"/account/settings" {
+		t.Errorf("unexpected cookie %+v", cookies[2])
+	}
+
+	if _, err := Cookies(server.URL+"/loop", Options{MaxRedirects: 3}); !errors.Is(err, ErrTooManyRedirects) {
+		t.Errorf("followed a redirect loop: %v", err)
+	}
+
+	if _, err := Cookies(server.URL+"/loop", Options{MaxRedirects: -1}); err != nil {
+		t.Errorf("followed a redirect when told not to: %v", err)
+	}
+}
+
+func TestCookiesCrossHostRedirect(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The jar may send cookies the first host set, since they don't
+		// care about ports, but never the caller's.
+		if r.Header.Get("Authorization") != "" || strings.Contains(r.Header.Get("Cookie"), "session=") {
+			http.Error(w, "leaked the caller's headers", http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Add("Set-Cookie", "other=1")
+	}))
+	defer other.Close()
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "missing the caller's headers", http.StatusForbidden)
+			return
+		}
+
+		w.Header().Add("Set-Cookie", "target=1")
+		http.Redirect(w, r, other.URL+"/landing", http.StatusFound)
+	}))
+	defer target.Close()
+
+	cookies, err := Cookies(target.URL, Options{
+		Headers: http.Header{"Authorization": {"Bearer secret"}, "Cookie": {"session=abc"}},
+	})
+
+	if err != nil {
+		t.Fatalf("could not fetch the cookies: %v", err)
+	}
+
+	// The second host only sets its cookie if it saw none of the headers.
+	if len(cookies) != 2 || cookies[0].Name != "target" || cookies[1].Name != "other" {
+		t.Errorf("unexpected cookies %+v", cookies)
+	}
+}
@@ -0,0 +1,349 @@
+package monster
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+type aspnetCoreParsedData struct {
+	data     string
+	payload  []byte
+	keyID    string
+	encoding string
+
+	parsed bool
+}
+
+func (d *aspnetCoreParsedData) String() string {
+	if !d.parsed {
+		return "Unparsed data"
+	}
+
+	return fmt.Sprintf("Data: %s\nEncoding: %s\nKey ID: %s\nProtected payload: %d bytes\n", d.data, d.encoding, d.keyID, len(d.payload))
+}
+
+func (d *aspnetCoreParsedData) serialized(c *Cookie) []byte {
+	// The ticket is encrypted.
+	return nil
+}
+
+func (d *aspnetCoreParsedData) fields() parsedFields {
+	// Which bytes are the tag depends on the key's algorithm, which only
+	// verifying finds; HMAC-SHA256 is ASP.NET Core's default.
+	return parsedFields{data: d.data, algorithm: "sha256"}
+}
+
+func (d *aspnetCoreParsedData) clone() decoderData {
+	copied := *d
+	return &copied
+}
+
+// ASP.NET Core's Data Protection payloads are a magic header and the ID of
+// the key ring key that protected them, followed by the encrypted data. See
+// https://learn.microsoft.com/aspnet/core/security/data-protection/implementation/authenticated-encryption-details.
+const (
+	aspnetCoreDecoder = "aspnetcore"
+
+	aspnetCoreMagic             = "\x09\xf0\xc9\xf0"
+	aspnetCoreHeaderLength      = len(aspnetCoreMagic) + 16
+	aspnetCoreKeyModifierLength = 16
+
+	aspnetCoreGCMNonceLength = 12
+	aspnetCoreGCMTagLength   = 16
+
+	// The shortest payload is GCM-encrypted empty data.
+	aspnetCoreMinLength = aspnetCoreHeaderLength + aspnetCoreKeyModifierLength + aspnetCoreGCMNonceLength + aspnetCoreGCMTagLength
+
+	// The purpose the cookie authentication handler protects tickets for,
+	// followed by the scheme name and a version.
+	aspnetCoreCookiesPurpose = "Microsoft.AspNetCore.Authentication.Cookies.CookieAuthenticationMiddleware"
+)
+
+// An algorithm a key ring key may be configured with, along with the
+// context header its subkeys are derived with, which depends only on it.
+type aspnetCoreAlgorithm struct {
+	keyLength int
+
+	// HMAC validates CBC payloads, while GCM authenticates itself.
+	gcm        bool
+	validation string
+
+	contextHeader []byte
+}
+
+var (
+	// The algorithms `aspnetCoreUnsign()` tries, starting with the default.
+	aspnetCoreAlgorithms = []*aspnetCoreAlgorithm{
+		newASPNETCoreAlgorithm(32, false, "sha256"), // AES_256_CBC with HMACSHA256
+		newASPNETCoreAlgorithm(32, true, ""),        // AES_256_GCM
+		newASPNETCoreAlgorithm(16, false, "sha256"), // AES_128_CBC with HMACSHA256
+		newASPNETCoreAlgorithm(32, false, "sha512"), // AES_256_CBC with HMACSHA512
+	}
+
+	// The purposes of the Data Protection cookies apps most often set: the
+	// authentication cookies of ASP.NET Core Identity and of the cookie
+	// handler's default scheme, and the antiforgery, session and TempData
+	// cookies.
+	aspnetCoreDefaultPurposes = [][]string{
+		{aspnetCoreCookiesPurpose, "Identity.Application", "v2"},
+		{aspnetCoreCookiesPurpose, "Cookies", "v2"},
+		{"Microsoft.AspNetCore.Antiforgery.AntiforgeryToken.v1"},
+		{"SessionMiddleware"},
+		{"Microsoft.AspNetCore.Mvc.ViewFeatures.CookieTempDataProviderToken.v1"},
+	}
+)
+
+func newASPNETCoreAlgorithm(keyLength int, gcm bool, validation string) *aspnetCoreAlgorithm {
+	algorithm := &aspnetCoreAlgorithm{keyLength: keyLength, gcm: gcm, validation: validation}
+	algorithm.contextHeader = algorithm.newContextHeader()
+
+	return algorithm
+}
+
+// Describes the algorithm, and proves it with keys derived from nothing,
+// so that subkeys for one algorithm are useless with another.
+func (a *aspnetCoreAlgorithm) newContextHeader() []byte {
+	header := []byte{0, 0}
+	sizes := []int{a.keyLength, aes.BlockSize, 0, 0}
+
+	if a.gcm {
+		header[1] = 1
+		sizes = []int{a.keyLength, aspnetCoreGCMNonceLength, aspnetCoreGCMTagLength, aspnetCoreGCMTagLength}
+	} else {
+		digestLength := algorithmDigestLength[a.validation]
+		sizes[2], sizes[3] = digestLength, digestLength
+	}
+
+	for _, size := range sizes {
+		header = appendUint32(header, uint32(size))
+	}
+
+	if a.gcm {
+		block, _ := aes.NewCipher(sp800108(nil, nil, nil, a.keyLength))
+		gcm, _ := cipher.NewGCM(block)
+
+		return append(header, gcm.Seal(nil, make([]byte, aspnetCoreGCMNonceLength), nil, nil)...)
+	}
+
+	keys := sp800108(nil, nil, nil, a.keyLength+sizes[2])
+	block, _ := aes.NewCipher(keys[:a.keyLength])
+
+	// CBC-encrypting nothing leaves one block of padding.
+	padding := bytes.Repeat([]byte{aes.BlockSize}, aes.BlockSize)
+	cipher.NewCBCEncrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(padding, padding)
+
+	header = append(header, padding...)
+	return append(header, hashAlgorithms[a.validation].hmac(keys[a.keyLength:], nil)...)
+}
+
+// Recognizes payloads from ASP.NET Core's Data Protection, which protects
+// its authentication, antiforgery, session and TempData cookies.
+func aspnetCoreDecode(c *Cookie) bool {
+	if len(c.raw) < aspnetCoreMinLength {
+		return false
+	}
+
+	var parsedData aspnetCoreParsedData
+
+	payload, encoding, ok := c.decodeTolerant(c.raw, func(decoded []byte) bool {
+		return len(decoded) >= aspnetCoreMinLength && string(decoded[:len(aspnetCoreMagic)]) == aspnetCoreMagic
+	})
+
+	if !ok {
+		return false
+	}
+
+	parsedData.data = c.raw
+	parsedData.payload = payload
+	parsedData.keyID = aspnetCoreGUID(payload[len(aspnetCoreMagic):aspnetCoreHeaderLength])
+	parsedData.encoding = encoding
+	parsedData.parsed = true
+	c.wasDecodedBy(aspnetCoreDecoder, &parsedData)
+
+	return true
+}
+
+// Formats a .NET `Guid` from its bytes, whose first three fields are
+// little-endian.
+func aspnetCoreGUID(b []byte) string {
+	return fmt.Sprintf("%08x-%04x-%04x-%x-%x", binary.LittleEndian.Uint32(b), binary.LittleEndian.Uint16(b[4:]), binary.LittleEndian.Uint16(b[6:]), b[8:10], b[10:16])
+}
+
+// Checks `secret` as the master key of the key ring key that protected the
+// payload, with each of the algorithms and purposes it could have used.
+func aspnetCoreUnsign(c *Cookie, secret []byte) bool {
+	parsedData := c.parsedDataFor(aspnetCoreDecoder).(*aspnetCoreParsedData)
+
+	purposes := aspnetCoreDefaultPurposes
+	if c.dataProtectionPurposes != nil {
+		purposes = [][]string{c.dataProtectionPurposes}
+	}
+
+	for _, purpose := range purposes {
+		aad := aspnetCoreAAD(parsedData.payload[:aspnetCoreHeaderLength], purpose)
+
+		for _, algorithm := range aspnetCoreAlgorithms {
+			if algorithm.verify(parsedData.payload[aspnetCoreHeaderLength:], aad, secret) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// The additional authenticated data, which binds a payload to its key and
+// purposes: the header, the number of purposes, and each purpose as .NET's
+// `BinaryWriter` writes strings, behind a 7-bit encoded length.
+func aspnetCoreAAD(header []byte, purposes []string) []byte {
+	aad := appendUint32(append([]byte{}, header...), uint32(len(purposes)))
+
+	for _, purpose := range purposes {
+		length := uint(len(purpose))
+		for ; length >= 0x80; length >>= 7 {
+			aad = append(aad, byte(length)|0x80)
+		}
+
+		aad = append(append(aad, byte(length)), purpose...)
+	}
+
+	return aad
+}
+
+// Reports whether `masterKey` protected `protected`, the payload after its
+// header, with this algorithm. Each payload has a random key modifier, from
+// which its subkeys are derived along with the AAD.
+func (a *aspnetCoreAlgorithm) verify(protected []byte, aad []byte, masterKey []byte) bool {
+	if len(protected) < aspnetCoreKeyModifierLength {
+		return false
+	}
+
+	keyModifier, body := protected[:aspnetCoreKeyModifierLength], protected[aspnetCoreKeyModifierLength:]
+	context := append(append([]byte{}, a.contextHeader...), keyModifier...)
+
+	if a.gcm {
+		if len(body) < aspnetCoreGCMNonceLength+aspnetCoreGCMTagLength {
+			return false
+		}
+
+		block, err := aes.NewCipher(sp800108(masterKey, aad, context, a.keyLength))
+		if err != nil {
+			return false
+		}
+
+		gcm, err := cipher.NewGCM(block)
+		if err != nil {
+			return false
+		}
+
+		_, err = gcm.Open(nil, body[:aspnetCoreGCMNonceLength], body[aspnetCoreGCMNonceLength:], nil)
+		return err == nil
+	}
+
+	// A CBC body is the IV, at least one block of ciphertext, and the MAC of
+	// both.
+	digestLength := algorithmDigestLength[a.validation]
+	encrypted := len(body) - digestLength
+	if encrypted < 2*aes.BlockSize || encrypted%aes.BlockSize != 0 {
+		return false
+	}
+
+	subkeys := sp800108(masterKey, aad, context, a.keyLength+digestLength)
+	computedMAC := hashAlgorithms[a.validation].hmac(subkeys[a.keyLength:], body[:encrypted])
+
+	return hmac.Equal(body[encrypted:], computedMAC)
+}
+
+// Appends `n` in big-endian order.
+func appendUint32(b []byte, n uint32) []byte {
+	return append(b, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+}
+
+// The NIST SP 800-108 KDF in counter mode, with HMAC-SHA512 as its PRF.
+func sp800108(key []byte, label []byte, context []byte, length int) []byte {
+	input := make([]byte, 0, 4+len(label)+1+len(context)+4)
+	input = append(input, 0, 0, 0, 0)
+	input = append(input, label...)
+	input = append(input, 0)
+	input = append(input, context...)
+	input = appendUint32(input, uint32(length*8))
+
+	var output []byte
+	for i := uint32(1); len(output) < length; i++ {
+		binary.BigEndian.PutUint32(input, i)
+
+		mac := hmac.New(sha512.New, key)
+		mac.Write(input)
+		output = mac.Sum(output)
+	}
+
+	return output[:length]
+}
+
+// Sets the purposes this cookie's Data Protection protector was created
+// with, e.g. `CreateProtector("MyApp.Tokens", "v1")`, instead of trying the
+// ones common cookies use.
+func (c *Cookie) SetDataProtectionPurposes(purposes ...string) {
+	c.dataProtectionPurposes = purposes
+}
+
+type dataProtectionKeyRing struct {
+	Keys []dataProtectionKey `xml:"key"`
+}
+
+type dataProtectionKey struct {
+	ID        string `xml:"id,attr"`
+	MasterKey string `xml:"descriptor>descriptor>masterKey>value"`
+}
+
+// Returns the master keys in an ASP.NET Core Data Protection key ring, to
+// try as candidate secrets. This reads a single key's XML file, as found in
+// the key ring directory, or several `<key>` elements inside any root
+// element. Keys encrypted at rest (e.g. with DPAPI or a certificate) are
+// skipped, since decrypting them needs the machine they were made on.
+func KeysFromDataProtectionXML(r io.Reader) ([][]byte, error) {
+	contents, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var ring dataProtectionKeyRing
+	if err := xml.Unmarshal(contents, &ring); err != nil {
+		return nil, err
+	}
+
+	// A lone key is its own root, rather than a child of one.
+	if len(ring.Keys) == 0 {
+		var key dataProtectionKey
+		if err := xml.Unmarshal(contents, &key); err != nil {
+			return nil, err
+		}
+
+		ring.Keys = append(ring.Keys, key)
+	}
+
+	var keys [][]byte
+
+	for _, key := range ring.Keys {
+		if key.MasterKey == "" {
+			continue
+		}
+
+		masterKey, err := base64.StdEncoding.DecodeString(key.MasterKey)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, masterKey)
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,114 @@
+package monster
+
+import (
+	"strings"
+	"testing"
+)
+
+const dataProtectionKeyRingFixture = `<?xml version="1.0" encoding="utf-8"?>
+<repository>
+  <key id="0c3f1c4e-2a39-4a18-9a0e-5d4b3f0e8a11" version="1">
+    <descriptor deserializerType="Microsoft.AspNetCore.DataProtection.AuthenticatedEncryption.ConfigurationModel.AuthenticatedEncryptorDescriptorDeserializer, Microsoft.AspNetCore.DataProtection">
+      <descriptor>
+        <encryption algorithm="AES_256_CBC" />
+        <validation algorithm="HMACSHA256" />
+        <encryptedSecret decryptorType="Microsoft.AspNetCore.DataProtection.XmlEncryption.DpapiXmlDecryptor, Microsoft.AspNetCore.DataProtection">
+          <encryptedKey>
+            <value>AQAAANCMnd8BFdERjHoAwE/Cl+s=</value>
+          </encryptedKey>
+        </encryptedSecret>
+      </descriptor>
+    </descriptor>
+  </key>
+  <key id="80732141-ec8f-4b80-af9c-c4d2d1ff8901" version="1">
+    <creationDate>2023-11-01T09:00:00.0000000Z</creationDate>
+    <activationDate>2023-11-01T09:00:00.0000000Z</activationDate>
+    <expirationDate>2024-01-30T09:00:00.0000000Z</expirationDate>
+    <descriptor deserializerType="Microsoft.AspNetCore.DataProtection.AuthenticatedEncryption.ConfigurationModel.AuthenticatedEncryptorDescriptorDeserializer, Microsoft.AspNetCore.DataProtection">
+      <descriptor>
+        <encryption algorithm="AES_256_GCM" />
+        <masterKey p4:requiresEncryption="true" xmlns:p4="http://schemas.asp.net/2015/03/dataProtection">
+          <!-- Warning: the key below is in an unencrypted form. -->
+          <value>YS1zaXh0eS1mb3VyLWJ5dGUtbWFzdGVyLWtleS1mcm9tLWEtZGF0YS1wcm90ZWN0aW9uLWtleS1yaW5nLXhtbA==</value>
+        </masterKey>
+      </descriptor>
+    </descriptor>
+  </key>
+</repository>`
+
+func TestUnsignASPNETCore(t *testing.T) {
+	// An `Identity.Application` cookie, protected with the default
+	// AES-256-CBC and HMAC-SHA256.
+	c := NewCookie("CfDJ8EEhc4CP7IBLr5zE0tH_iQFrZXktbW9kaWZpZXItMTZiaW5pdGlhbGl6YXRpb24tdvkdajh98k6JTOTsab4fs01djh9FmX1hnRaFEld4SlCb2luT5BX48VmwM8_k5MgrPQ")
+	if !c.Decode() {
+		t.Fatalf("could not decode the Data Protection cookie")
+	}
+
+	if keyID := c.parsedDataFor(aspnetCoreDecoder).(*aspnetCoreParsedData).keyID; keyID != "80732141-ec8f-4b80-af9c-c4d2d1ff8901" {
+		t.Errorf("decoded key ID %s", keyID)
+	}
+
+	if c.UnsignWithSecret([]byte("wrong")) {
+		t.Errorf("unsigned the Data Protection cookie with the wrong key")
+	}
+
+	if !c.UnsignWithSecret([]byte("changeme")) {
+		t.Fatalf("could not unsign the Data Protection cookie")
+	}
+
+	if _, _, decoder := c.Result(); decoder != DecoderASPNETCore {
+		t.Errorf("unsigned by %s", decoder)
+	}
+}
+
+func TestUnsignASPNETCoreKeyRing(t *testing.T) {
+	keys, err := KeysFromDataProtectionXML(strings.NewReader(dataProtectionKeyRingFixture))
+	if err != nil {
+		t.Fatalf("could not read the key ring: %v", err)
+	}
+
+	// The DPAPI-encrypted key is skipped.
+	if len(keys) != 1 || len(keys[0]) != 64 {
+		t.Fatalf("read %d keys from the key ring", len(keys))
+	}
+
+	wl := NewWordlist()
+	if err := wl.LoadFromArray(keys); err != nil {
+		t.Errorf("could not LoadFromArray")
+	}
+
+	// A `Cookies` scheme cookie protected with AES-256-GCM.
+	c := NewCookie("CfDJ8EEhc4CP7IBLr5zE0tH_iQFrZXktbW9kaWZpZXItMTZidHdlbHZlLW5vbmNl_5KrOKnuRs2YsC2qYfoCTvPlySN3Yqub3rOS9-I")
+	if !c.Decode() {
+		t.Fatalf("could not decode the Data Protection cookie")
+	}
+
+	if _, success := c.Unsign(wl, 1); !success {
+		t.Errorf("could not unsign the Data Protection cookie with its key ring")
+	}
+
+	// A lone key's file is read too.
+	start, end := strings.Index(dataProtectionKeyRingFixture, `<key id="8`), strings.LastIndex(dataProtectionKeyRingFixture, "</key>")
+	if keys, err := KeysFromDataProtectionXML(strings.NewReader(dataProtectionKeyRingFixture[start : end+len("</key>")])); err != nil || len(keys) != 1 {
+		t.Errorf("read %d keys from a lone key, with error %v", len(keys), err)
+	}
+}
+
+func TestUnsignASPNETCorePurposes(t *testing.T) {
+	// Protected by `CreateProtector("MyApp.Tokens", "v1")`.
+	raw := "CfDJ8EEhc4CP7IBLr5zE0tH_iQFrZXktbW9kaWZpZXItMTZiaW5pdGlhbGl6YXRpb24tdiAZMlSu2djCLsjnFG2kDIkBY0frj2Lk2-n_OPMW2Q8OBWM93d_Pj-P8zfOcpAMA8w"
+
+	c := NewCookie(raw)
+	if !c.Decode() {
+		t.Fatalf("could not decode the Data Protection cookie")
+	}
+
+	if c.UnsignWithSecret([]byte("changeme")) {
+		t.Errorf("unsigned a cookie with custom purposes using the default ones")
+	}
+
+	c.SetDataProtectionPurposes("MyApp.Tokens", "v1")
+	if !c.UnsignWithSecret([]byte("changeme")) {
+		t.Errorf("could not unsign a cookie with its custom purposes")
+	}
+}
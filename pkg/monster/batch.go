@@ -0,0 +1,286 @@
+package monster
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// A cookie for `BatchUnsign()` to check.
+type CookieInput struct {
+	// The cookie, in any form `NewCookie()` accepts.
+	Value string
+
+	// The cookie's name and where it came from (e.g. a URL or a line
+	// number), for reporting; neither is needed to unsign it.
+	Name   string
+	Source string
+}
+
+// What `BatchUnsign()` found out about one of its inputs.
+type BatchResult struct {
+	Input CookieInput
+
+	// The decoded cookie, which identical inputs share.
+	Cookie *Cookie
+
+	Decoded bool
+	Found   bool
+
+	// Only set if the secret was found.
+	Result UnsignResult
+
+	// Set if an earlier input had the same value, so this one wasn't
+	// checked again.
+	Duplicate bool
+}
+
+// A summary of a `BatchUnsign()` run.
+type BatchSummary struct {
+	Cookies  int
+	Unique   int
+	Decoded  int
+	Unsigned int
+
+	// Each secret that was found, in the order it was first found.
+	Secrets []BatchSecret
+}
+
+// A secret found by `BatchUnsign()`, and how many of its unique cookies it
+// unsigned.
+type BatchSecret struct {
+	Secret  []byte
+	Decoder string
+	Cookies int
+}
+
+// Decodes and brute-forces each of `inputs` with `wl`, and returns a result
+// for each in the same order. Inputs with the same value are only checked
+// once. The options apply to every cookie.
+//
+// Every cookie is tried with each candidate secret in turn, so the keys
+// Django and Flask derive from a secret are derived once for all of the
+// cookies that share a salt and algorithm, which cookies from one app do.
+func BatchUnsign(inputs []CookieInput, wl *Wordlist, concurrencyLimit uint64, opts ...UnsignOption) []BatchResult {
+	results := make([]BatchResult, len(inputs))
+	seen := make(map[string]int, len(inputs))
+
+	var decoded []*Cookie
+	for i, input := range inputs {
+		if _, ok := seen[input.Value]; ok {
+			continue
+		}
+
+		seen[input.Value] = i
+		results[i] = BatchResult{Input: input, Cookie: NewCookie(input.Value)}
+
+		if results[i].Decoded = results[i].Cookie.Decode(); results[i].Decoded {
+			decoded = append(decoded, results[i].Cookie)
+		}
+	}
+
+	unsignTogether(decoded, wl, concurrencyLimit, opts)
+
+	for i, input := range inputs {
+		if first := seen[input.Value]; first != i {
+			results[i] = results[first]
+			results[i].Input = input
+			results[i].Duplicate = true
+
+			if results[i].Found {
+				results[i].Result.Source = input.Source
+			}
+
+			continue
+		}
+
+		if results[i].Decoded {
+			results[i].Result, results[i].Found = results[i].Cookie.ResultFor(input.Source)
+		}
+	}
+
+	return results
+}
+
+// Brute-forces `cookies` with `wl` like `Unsign()`, but tries each candidate
+// on every cookie not yet unsigned before moving on to the next, sharing
+// the keys derived from it. `WithAutoTune()` and `WithTruncatedSecrets()`
+// need a run to themselves, so with those (or just one cookie) each cookie
+// is unsigned in turn.
+func unsignTogether(cookies []*Cookie, wl *Wordlist, concurrencyLimit uint64, opts []UnsignOption) {
+	options := newUnsignOptions(opts)
+	if options.autoTune || options.truncatedMinLength > 0 || len(cookies) == 1 {
+		for _, c := range cookies {
+			c.Unsign(wl, concurrencyLimit, opts...)
+		}
+
+		return
+	}
+
+	var pending []*Cookie
+	var plans []unsignPlan
+
+	for _, c := range cookies {
+		_, plan := c.prepareUnsign(opts)
+		defer c.progress.finish()
+
+		if !plan.any() {
+			continue
+		}
+
+		defer c.rememberSecret(options)
+		c.trySecretCache(options, plan)
+
+		if !c.wasUnsigned() {
+			pending, plans = append(pending, c), append(plans, plan)
+		}
+	}
+
+	if len(pending) == 0 {
+		return
+	}
+
+	// The budget is per cookie, so each is tried with the same candidates
+	// it would be alone.
+	budget := &candidateBudget{max: options.maxCandidates}
+	entries := budget.limit(wl.Entries())
+
+	i := 0
+	bruteForceUntil(func() ([]byte, bool) {
+		if i == len(entries) {
+			return nil, false
+		}
+
+		i++
+		return entries[i-1], true
+	}, concurrencyLimit, func(entry []byte, keys *derivedKeys) {
+		// Every cookie shares the options, so the transformed key too.
+		key := pending[0].transformSecret(entry)
+
+		for j, c := range pending {
+			if !c.wasUnsigned() {
+				c.tryKey(plans[j], key, entry, keys)
+			}
+		}
+	}, func() bool {
+		for _, c := range pending {
+			if !c.wasUnsigned() {
+				return false
+			}
+		}
+
+		return true
+	})
+
+	for _, c := range pending {
+		c.limitReached = !c.wasUnsigned() && budget.exhausted()
+	}
+}
+
+// Counts the cookies each secret in `results` unsigned, ignoring duplicate
+// inputs.
+func SummarizeBatch(results []BatchResult) BatchSummary {
+	summary := BatchSummary{Cookies: len(results)}
+	found := make(map[string]int)
+
+	for _, result := range results {
+		if result.Duplicate {
+			continue
+		}
+
+		summary.Unique++
+
+		if result.Decoded {
+			summary.Decoded++
+		}
+
+		if !result.Found {
+			continue
+		}
+
+		summary.Unsigned++
+
+		key := result.Result.Decoder + "\x00" + string(result.Result.Secret)
+		if i, ok := found[key]; ok {
+			summary.Secrets[i].Cookies++
+			continue
+		}
+
+		found[key] = len(summary.Secrets)
+		summary.Secrets = append(summary.Secrets, BatchSecret{Secret: result.Result.Secret, Decoder: result.Result.Decoder, Cookies: 1})
+	}
+
+	return summary
+}
+
+// Turns cookies read from a capture (see `CookiesFromBurpXML()` and
+// `CookiesFromHAR()`) into inputs. A cookie-session value and its `.sig`
+// cookie sent to the same URL become one input, since neither can be
+// unsigned alone.
+func InputsFromNamedCookies(cookies []NamedCookie) []CookieInput {
+	sent := make(map[string]map[string]string)
+	for _, cookie := range cookies {
+		if sent[cookie.URL] == nil {
+			sent[cookie.URL] = make(map[string]string)
+		}
+
+		sent[cookie.URL][cookie.Name] = cookie.Value
+	}
+
+	var inputs []CookieInput
+
+	for _, cookie := range cookies {
+		source := cookie.URL
+		if source == "" {
+			source = cookie.Host
+		}
+
+		if name := strings.TrimSuffix(cookie.Name, expressSignatureSuffix); name != cookie.Name {
+			// We pair this with its value cookie instead.
+			if _, ok := sent[cookie.URL][name]; ok {
+				continue
+			}
+		}
+
+		// ALB's shards are joined into one cookie, reported at the first.
+		if strings.HasPrefix(cookie.Name, albCookieName) {
+			if cookie.Name != albCookieName && cookie.Name != albCookieName+"-0" {
+				continue
+			}
+
+			raw, _ := albAssemble(sent[cookie.URL])
+			inputs = append(inputs, CookieInput{Value: raw, Name: cookie.Name, Source: source})
+			continue
+		}
+
+		input := CookieInput{Value: cookie.Value, Name: cookie.Name, Source: source}
+		if signature, ok := sent[cookie.URL][cookie.Name+expressSignatureSuffix]; ok {
+			input.Value = cookie.Name + "=" + cookie.Value + expressSeparator + signature
+		}
+
+		inputs = append(inputs, input)
+	}
+
+	return inputs
+}
+
+// Reads one cookie per line from `r`, skipping blank lines. Each is labelled
+// with its line number.
+func InputsFromLines(r io.Reader) ([]CookieInput, error) {
+	var inputs []CookieInput
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxWordlistLine)
+
+	for line := 1; scanner.Scan(); line++ {
+		value := strings.TrimSpace(scanner.Text())
+		if value == "" {
+			continue
+		}
+
+		inputs = append(inputs, CookieInput{Value: value, Source: "line " + strconv.Itoa(line)})
+	}
+
+	return inputs, scanner.Err()
+}
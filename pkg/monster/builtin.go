@@ -0,0 +1,107 @@
+package monster
+
+import (
+	"time"
+)
+
+// A cookie format built into the package. Everything `Decode()`,
+// `Unsign()` and `Resign()` need to know about one is in its entry in
+// `builtins`, and what it parsed answers for itself through `decoderData`,
+// so a format can't be wired into some of them and missed by the rest.
+type builtin struct {
+	name string
+
+	// What the format splits cookies on, if anything.
+	separator string
+
+	// Nil for formats `decode()` doesn't try in turn: ALB's cookie is only
+	// recognized by its name (see `valueMarkers`), and unsigned cookies are
+	// only tried once nothing else, registered decoders included, accepts
+	// the cookie.
+	decode func(c *Cookie) bool
+
+	// Reports whether `key` signed the cookie, trying whatever else the
+	// plan asks for; nil for formats with no secret to find.
+	unsign func(c *Cookie, key []byte, plan *unsignPlan, keys *derivedKeys) bool
+
+	// Reports whether `unsign` has anything to try keys against, for
+	// formats which need more than the cookie; nil if the cookie is enough.
+	ready func(c *Cookie) bool
+
+	// The key `Result()` reports once `unsign` accepts `key`, if it isn't
+	// `key` itself.
+	reportedKey func(key []byte) []byte
+
+	// Nil for formats which can't be resigned.
+	resign resignFunc
+}
+
+// Resigns the cookie with `data` and `key`; `compression` only applies to
+// formats which support it, and `timestamp` (unless it's zero) to those which
+// sign one.
+type resignFunc func(c *Cookie, data string, key []byte, algorithm string, compression Compression, timestamp time.Time) (string, error)
+
+var (
+	// In the order `decode()` tries them, which is also the order keys are
+	// tried in.
+	builtins []builtin
+
+	builtinsByName = make(map[string]*builtin)
+
+	// The decoders which unsign cookies, in the order `Decode()` runs them.
+	signingDecoders []string
+)
+
+// The table refers to decoders which refer back to it, so it's filled in
+// here rather than where it's declared.
+func init() {
+	builtins = []builtin{
+		{name: djangoDecoder, separator: djangoSeparator, decode: djangoDecode, unsign: djangoUnsignPlanned, resign: djangoResign},
+		{name: flaskDecoder, separator: flaskSeparator, decode: flaskDecode, unsign: withDerivedKeys(flaskUnsignWith), resign: flaskResign},
+		{name: jwtDecoder, separator: jwtSeparator, decode: jwtDecode, unsign: jwtUnsignPlanned, resign: withoutTimestamp(jwtResign)},
+		{name: rackDecoder, separator: rackSeparator, decode: rackDecode, unsign: withDerivedKeys(rackUnsign)},
+		{name: expressDecoder, separator: expressSeparator, decode: expressDecode, unsign: withDerivedKeys(expressUnsign), resign: withoutTimestamp(expressResign)},
+		{name: laravelDecoder, decode: laravelDecode, unsign: withKey(laravelUnsign), reportedKey: laravelAppKey},
+		{name: connectDecoder, separator: connectSeparator, decode: connectDecode, unsign: withDerivedKeys(connectUnsign), resign: withoutTimestamp(connectResign)},
+		{name: envelopeDecoder, decode: envelopeDecode, unsign: withDerivedKeys(envelopeUnsign)},
+		{name: aspnetCoreDecoder, decode: aspnetCoreDecode, unsign: withKey(aspnetCoreUnsign)},
+		{name: railsDecoder, decode: railsDecode, unsign: railsUnsignPlanned, resign: railsResign},
+		{name: playDecoder, separator: playSeparator, decode: playDecode, unsign: withDerivedKeys(playUnsign), resign: withoutTimestamp(playResign)},
+		{name: springDecoder, separator: springSeparator, decode: springDecode, unsign: withKey(springUnsign), ready: springReady, resign: withoutTimestamp(springResign)},
+		{name: yiiDecoder, decode: yiiDecode, unsign: withDerivedKeys(yiiUnsign), resign: withoutTimestamp(yiiResign)},
+		{name: cakephpDecoder, decode: cakephpDecode, unsign: withKey(cakephpUnsign), resign: withoutTimestamp(cakephpResign)},
+		{name: hashHMACDecoder, separator: hashHMACSeparator, decode: hashHMACDecode, unsign: withDerivedKeys(hashHMACUnsign), resign: withoutTimestamp(hashHMACResign)},
+		{name: detachedDecoder, decode: detachedDecode, unsign: detachedUnsignPlanned},
+		{name: albDecoder},
+		{name: unsignedDecoder},
+	}
+
+	for i := range builtins {
+		builtinsByName[builtins[i].name] = &builtins[i]
+
+		if builtins[i].unsign != nil {
+			signingDecoders = append(signingDecoders, builtins[i].name)
+		}
+	}
+}
+
+// Adapts an unsigner which only needs the key.
+func withKey(unsign func(c *Cookie, key []byte) bool) func(c *Cookie, key []byte, plan *unsignPlan, keys *derivedKeys) bool {
+	return func(c *Cookie, key []byte, plan *unsignPlan, keys *derivedKeys) bool {
+		return unsign(c, key)
+	}
+}
+
+// Adapts an unsigner which shares the keys it derives; see `derivedKeys`.
+func withDerivedKeys(unsign func(c *Cookie, key []byte, keys *derivedKeys) bool) func(c *Cookie, key []byte, plan *unsignPlan, keys *derivedKeys) bool {
+	return func(c *Cookie, key []byte, plan *unsignPlan, keys *derivedKeys) bool {
+		return unsign(c, key, keys)
+	}
+}
+
+// Adapts a resigner for a format with no timestamp or compression.
+func withoutTimestamp(resign func(c *Cookie, data string, key []byte, algorithm string) (string, error)) resignFunc {
+	return func(c *Cookie, data string, key []byte, algorithm string, compression Compression, timestamp time.Time) (string, error) {
+		return resign(c, data, key, algorithm)
+	}
+}
@@ -0,0 +1,112 @@
+package monster
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// A cookie found in a capture, along with where it was sent.
+type NamedCookie struct {
+	Name  string
+	Value string
+
+	// The URL of the request the cookie was sent with.
+	URL string
+
+	// The domain the cookie was stored for, when it came from a browser's
+	// cookie store rather than a request.
+	Host string
+}
+
+type burpItems struct {
+	Items []burpItem `xml:"item"`
+}
+
+type burpItem struct {
+	URL     string      `xml:"url"`
+	Request burpRequest `xml:"request"`
+}
+
+type burpRequest struct {
+	Base64 bool   `xml:"base64,attr"`
+	Data   string `xml:",chardata"`
+}
+
+// Returns every cookie sent in the requests of a Burp Suite "Save items"
+// XML export, in the order they appear. Requests may be saved either raw or
+// base64-encoded.
+func CookiesFromBurpXML(r io.Reader) ([]NamedCookie, error) {
+	var items burpItems
+	if err := xml.NewDecoder(r).Decode(&items); err != nil {
+		return nil, err
+	}
+
+	var cookies []NamedCookie
+
+	for _, item := range items.Items {
+		request := item.Request.Data
+
+		if item.Request.Base64 {
+			decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(request))
+			if err != nil {
+				return nil, err
+			}
+
+			request = string(decoded)
+		}
+
+		for _, cookie := range requestCookies(request) {
+			cookie.URL = strings.TrimSpace(item.URL)
+			cookies = append(cookies, cookie)
+		}
+	}
+
+	return cookies, nil
+}
+
+// Parses the `Cookie` headers of a raw HTTP request. We don't use
+// `net/http` for this, since it drops values it considers invalid, and
+// those are often exactly the interesting ones.
+func requestCookies(request string) (cookies []NamedCookie) {
+	for _, line := range strings.Split(request, "\n") {
+		line = strings.TrimRight(line, "\r")
+
+		// The headers end at the first empty line.
+		if line == "" {
+			break
+		}
+
+		header := strings.SplitN(line, ":", 2)
+		if len(header) != 2 || !strings.EqualFold(strings.TrimSpace(header[0]), "cookie") {
+			continue
+		}
+
+		cookies = append(cookies, ParseCookieString(header[1])...)
+	}
+
+	return cookies
+}
+
+// Parses a `Cookie` header value, or a cookie string dumped by some other
+// tool, into its name and value pairs. This tolerates missing spaces after
+// `;`, trailing and doubled `;`, and pairs with no name, which are skipped.
+func ParseCookieString(s string) (cookies []NamedCookie) {
+	for _, pair := range strings.Split(s, ";") {
+		// Values may contain `=`, e.g. base64 padding, so we only split once.
+		components := strings.SplitN(pair, "=", 2)
+		if len(components) != 2 {
+			continue
+		}
+
+		name := strings.TrimSpace(components[0])
+		if name == "" {
+			continue
+		}
+
+		cookies = append(cookies, NamedCookie{Name: name, Value: strings.TrimSpace(components[1])})
+	}
+
+	return cookies
+}
@@ -0,0 +1,64 @@
+package monster
+
+import (
+	"strings"
+	"testing"
+)
+
+const burpFixture = `<?xml version="1.0"?>
+<!DOCTYPE items [
+<!ELEMENT items (item*)>
+]>
+<items burpVersion="2023.1" exportTime="Wed Nov 01 09:00:00 UTC 2023">
+  <item>
+    <time>Wed Nov 01 09:00:00 UTC 2023</time>
+    <url><![CDATA[https://example.com/account]]></url>
+    <host ip="93.184.216.34">example.com</host>
+    <method><![CDATA[GET]]></method>
+    <request base64="true"><![CDATA[R0VUIC9hY2NvdW50IEhUVFAvMS4xDQpIb3N0OiBleGFtcGxlLmNvbQ0KQ29va2llOiBjc3JmdG9rZW49YWJjOyBzZXNzaW9uaWQ9ZXlKZllYVjBhRjkxYzJWeVgybGtJam9pTWlJc0ltbHpYM04wWVdabUlqcG1ZV3h6WlN3aVkyRnlkQ0k2V3pFc01sMTk6MW1oVEFlOm5TcGJVMkl1UzNLMm9rRXVxQk5CSGlnYk1XY3NMZEpfbUVSUUxnem9URG8NCg0K]]></request>
+    <status>200</status>
+    <response base64="true"><![CDATA[SFRUUC8xLjEgMjAwIE9LDQoNCg==]]></response>
+  </item>
+  <item>
+    <url><![CDATA[https://example.com/health]]></url>
+    <request base64="false"><![CDATA[GET /health HTTP/1.1
+Host: example.com
+
+]]></request>
+  </item>
+</items>`
+
+func TestCookiesFromBurpXML(t *testing.T) {
+	cookies, err := CookiesFromBurpXML(strings.NewReader(burpFixture))
+	if err != nil {
+		t.Fatalf("could not parse the Burp export: %v", err)
+	}
+
+	if len(cookies) != 2 {
+		t.Fatalf("found %d cookies instead of 2", len(cookies))
+	}
+
+	session := cookies[1]
+	if session.Name != "sessionid" || session.URL != "https://example.com/account" {
+		t.Errorf("unexpected cookie %+v", session)
+	}
+
+	if !NewCookie(session.Value).Decode() {
+		t.Errorf("could not decode the session cookie from the Burp export")
+	}
+}
+
+func TestParseCookieString(t *testing.T) {
+	cookies := ParseCookieString(" a=1;b=2;; =orphan; c= padded== ;flag;")
+
+	want := []NamedCookie{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}, {Name: "c", Value: "padded=="}}
+	if len(cookies) != len(want) {
+		t.Fatalf("parsed %d cookies instead of %d: %+v", len(cookies), len(want), cookies)
+	}
+
+	for i := range want {
+		if cookies[i] != want[i] {
+			t.Errorf("cookie %d is %+v, wanted %+v", i, cookies[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package monster
+
+import (
+	"container/list"
+	"strconv"
+	"sync"
+)
+
+const (
+	defaultDecodeCacheSize = 1024
+)
+
+var (
+	// Shared by every `Cookie`, so tools which see the same raw cookie many
+	// times only parse it once; see `SetDecodeCacheSize()`.
+	decodes = newDecodeCache(defaultDecodeCacheSize)
+)
+
+// A least-recently-used cache of `Decode()` results.
+type decodeCache struct {
+	mutex    sync.Mutex
+	capacity int
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+type decodeCacheEntry struct {
+	key          string
+	raw          string
+	wasUnwrapped bool
+	decodedBy    map[string]decoderData
+	diagnostics  []string
+}
+
+func newDecodeCache(capacity int) *decodeCache {
+	return &decodeCache{capacity: capacity, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// Sets how many decoded cookies are cached, evicting the least recently
+// used if there are already more; zero disables the cache.
+func SetDecodeCacheSize(size int) {
+	decodes.mutex.Lock()
+	defer decodes.mutex.Unlock()
+
+	decodes.capacity = size
+	decodes.evict()
+}
+
+func (d *decodeCache) get(key string) (decodeCacheEntry, bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	element, ok := d.entries[key]
+	if !ok {
+		return decodeCacheEntry{}, false
+	}
+
+	d.order.MoveToFront(element)
+	entry := element.Value.(decodeCacheEntry)
+	entry.decodedBy = cloneDecodedBy(entry.decodedBy)
+
+	return entry, true
+}
+
+func (d *decodeCache) put(entry decodeCacheEntry) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.capacity <= 0 {
+		return
+	}
+
+	entry.decodedBy = cloneDecodedBy(entry.decodedBy)
+
+	if element, ok := d.entries[entry.key]; ok {
+		element.Value = entry
+		d.order.MoveToFront(element)
+		return
+	}
+
+	d.entries[entry.key] = d.order.PushFront(entry)
+	d.evict()
+}
+
+// Drops the least recently used entries until we're within capacity.
+func (d *decodeCache) evict() {
+	for d.order.Len() > d.capacity && d.order.Len() > 0 {
+		oldest := d.order.Back()
+		d.order.Remove(oldest)
+		delete(d.entries, oldest.Value.(decodeCacheEntry).key)
+	}
+}
+
+// Everything besides the raw value that changes what `Decode()` produces.
+func (c *Cookie) decodeCacheKey() string {
+	_, generation := customDecoders()
+	return c.raw + "\x00" + c.paddingChars + "\x00" + c.detachedSignature + "\x00" + strconv.Itoa(generation)
+}
+
+// Copies each decoder's parsed data, since unsigning can modify it (e.g.
+// recording which Django salt matched) and cached results are shared.
+// The slices and maps inside are never modified after decoding, so they
+// needn't be copied.
+func cloneDecodedBy(decodedBy map[string]decoderData) map[string]decoderData {
+	cloned := make(map[string]decoderData, len(decodedBy))
+
+	for decoder, parsedData := range decodedBy {
+		cloned[decoder] = parsedData.clone()
+	}
+
+	return cloned
+}
@@ -0,0 +1,79 @@
+package monster
+
+import "testing"
+
+func TestDecodeCache(t *testing.T) {
+	defer SetDecodeCacheSize(defaultDecodeCacheSize)
+	SetDecodeCacheSize(0)
+	SetDecodeCacheSize(2)
+
+	raw := "eyJzZWxmdGVzdCI6dHJ1ZX0:1mhTAe:ITvvu5K3UcFMu1q-MATldqm3Egk"
+
+	first := NewCookie(raw)
+	first.Decode()
+
+	second := NewCookie(raw)
+	if !second.Decode() || second.String() != first.String() {
+		t.Errorf("cached decode differs from the original")
+	}
+
+	// Each cookie gets its own copy, so changing one leaves the other alone.
+	second.parsedDataFor(djangoDecoder).(*djangoParsedData).algorithm = "sha512"
+	if algorithm := first.signatureAlgorithm(djangoDecoder); algorithm != "sha1" {
+		t.Errorf("changing a cached cookie changed another to %s", algorithm)
+	}
+
+	third := NewCookie(raw)
+	third.Decode()
+
+	if algorithm := third.signatureAlgorithm(djangoDecoder); algorithm != "sha1" {
+		t.Errorf("changing a cached cookie changed the cache to %s", algorithm)
+	}
+
+	// Failures are cached too.
+	if NewCookie("not a cookie").Decode() || NewCookie("not a cookie").Decode() {
+		t.Errorf("decoded an invalid cookie")
+	}
+
+	// With room for two, a third evicts the least recently used.
+	NewCookie(selfTestFixtures[flaskDecoder][0].cookie).Decode()
+
+	if _, ok := decodes.get(NewCookie(raw).decodeCacheKey()); ok {
+		t.Errorf("the least recently used cookie was not evicted")
+	}
+
+	if _, ok := decodes.get(NewCookie("not a cookie").decodeCacheKey()); !ok {
+		t.Errorf("a recently used cookie was evicted")
+	}
+
+	// Cookies whose other inputs differ are cached separately.
+	detached := NewDetachedCookie("user=42&role=admin", "0ed97d7bcb24d142653cd50dbbc16c786d89bf31527ab989ddb1ceaa1772aaab")
+	if !detached.Decode() || !detached.hasParsedDataFor(detachedDecoder) {
+		t.Errorf("could not decode a detached cookie")
+	}
+
+	if NewCookie("user=42&role=admin").Decode() {
+		t.Errorf("a detached decode was reused for a cookie without a signature")
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	defer SetDecodeCacheSize(defaultDecodeCacheSize)
+
+	raw := ".eJyrVopPLC3JiC8tTi2Kz0xRslIyUtJRSk4sKlGyijbUoSqMrQUA9S8YsA:1mhTAe:TdRXvd5RfNOrqhJrFcTQJTCNjv6LpKp-dyAZzIxrlno"
+
+	for _, size := range []int{0, defaultDecodeCacheSize} {
+		name := "uncached"
+		if size > 0 {
+			name = "cached"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			SetDecodeCacheSize(size)
+
+			for i := 0; i < b.N; i++ {
+				NewCookie(raw).Decode()
+			}
+		})
+	}
+}
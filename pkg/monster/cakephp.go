@@ -0,0 +1,204 @@
+package monster
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+var (
+	ErrNotCakePHPCookie = errors.New("the cookie was not unsigned as an encrypted CakePHP cookie")
+)
+
+type cakephpParsedData struct {
+	data       string
+	mac        string
+	decodedMAC []byte
+
+	// The IV followed by the AES-256-CBC ciphertext, which the MAC covers.
+	ciphertext []byte
+
+	// Whether the cookie was URL-encoded, as PHP's `setcookie()` sends it.
+	escaped bool
+
+	parsed bool
+}
+
+func (d *cakephpParsedData) String() string {
+	if !d.parsed {
+		return "Unparsed data"
+	}
+
+	return fmt.Sprintf("Data: %s\nMAC: %s\nIV: %x\nCiphertext: %d bytes\nCipher: aes-256-cbc\n", d.data, d.mac, d.ciphertext[:aes.BlockSize], len(d.ciphertext)-aes.BlockSize)
+}
+
+// The value is encrypted, so it's only available once the cookie has been
+// unsigned.
+func (d *cakephpParsedData) serialized(c *Cookie) []byte {
+	value, err := c.CakePHPValue()
+	if err != nil {
+		return nil
+	}
+
+	return value
+}
+
+func (d *cakephpParsedData) fields() parsedFields {
+	return parsedFields{data: d.data, signature: d.mac, decodedSignature: d.decodedMAC, algorithm: "sha256"}
+}
+
+func (d *cakephpParsedData) clone() decoderData {
+	copied := *d
+	return &copied
+}
+
+// CakePHP's `CookieComponent`, `EncryptedCookieMiddleware` and `Cookie` class
+// encrypt cookies with `Security::encrypt()`, keyed with `Security.salt` by
+// default: the value is `Q2FrZQ==.` (the base64 of "Cake") followed by the
+// base64 of the hex HMAC-SHA256 of the IV and ciphertext, then the IV and
+// ciphertext themselves. The key for both is derived from the salt, so the
+// MAC can be checked without decrypting anything.
+const (
+	cakephpDecoder = "cakephp"
+	cakephpPrefix  = "Q2FrZQ==."
+
+	cakephpKeyLength = 32
+)
+
+func cakephpDecode(c *Cookie) bool {
+	rawData := c.raw
+	var parsedData cakephpParsedData
+
+	if strings.Contains(rawData, "%") {
+		unescaped, err := url.QueryUnescape(rawData)
+		if err != nil {
+			return false
+		}
+
+		rawData = unescaped
+		parsedData.escaped = true
+	}
+
+	if !strings.HasPrefix(rawData, cakephpPrefix) {
+		return false
+	}
+
+	parsedData.data = strings.TrimPrefix(rawData, cakephpPrefix)
+
+	decoded, err := base64.StdEncoding.DecodeString(parsedData.data)
+	if err != nil {
+		return false
+	}
+
+	macLength := hex.EncodedLen(sha256.Size)
+	ciphertextLength := len(decoded) - macLength
+
+	// There's always an IV and at least one padded block after the MAC.
+	if ciphertextLength < 2*aes.BlockSize || ciphertextLength%aes.BlockSize != 0 {
+		c.diagnose(cakephpDecoder, "%d bytes after the MAC is not an IV and whole AES blocks; likely truncated", ciphertextLength)
+		return false
+	}
+
+	parsedData.mac = string(decoded[:macLength])
+
+	decodedMAC, err := hex.DecodeString(parsedData.mac)
+	if err != nil {
+		return false
+	}
+
+	parsedData.decodedMAC = decodedMAC
+	parsedData.ciphertext = decoded[macLength:]
+	parsedData.parsed = true
+	c.wasDecodedBy(cakephpDecoder, &parsedData)
+
+	return true
+}
+
+// Derives the key `Security::encrypt()` uses from the key it's given and
+// its HMAC salt, which are both `Security.salt` for cookies: the first 32
+// characters of the hex SHA256 of the two together.
+func cakephpKey(salt []byte) []byte {
+	sum := sha256.Sum256(append(append([]byte{}, salt...), salt...))
+	return []byte(hex.EncodeToString(sum[:])[:cakephpKeyLength])
+}
+
+func cakephpUnsign(c *Cookie, secret []byte) bool {
+	parsedData := c.parsedDataFor(cakephpDecoder).(*cakephpParsedData)
+
+	computedMAC := hashAlgorithms["sha256"].hmac(cakephpKey(secret), parsedData.ciphertext)
+	return hmac.Equal(parsedData.decodedMAC, computedMAC)
+}
+
+// Returns the decrypted value of a CakePHP cookie once it's unsigned; arrays
+// are JSON, which CakePHP 3 and later encode them as.
+func (c *Cookie) CakePHPValue() ([]byte, error) {
+	success, key, decoder := c.Result()
+	if !success || decoder != cakephpDecoder {
+		return nil, ErrNotCakePHPCookie
+	}
+
+	parsedData := c.parsedDataFor(cakephpDecoder).(*cakephpParsedData)
+
+	block, err := aes.NewCipher(cakephpKey(key))
+	if err != nil {
+		return nil, err
+	}
+
+	iv, ciphertext := parsedData.ciphertext[:aes.BlockSize], parsedData.ciphertext[aes.BlockSize:]
+
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+
+	// OpenSSL pads with PKCS#7, but CakePHP 2's mcrypt engine padded with
+	// zero bytes.
+	if padding := int(plaintext[len(plaintext)-1]); padding > 0 && padding <= aes.BlockSize && bytes.Equal(plaintext[len(plaintext)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return plaintext[:len(plaintext)-padding], nil
+	}
+
+	return bytes.TrimRight(plaintext, "\x00"), nil
+}
+
+// Encrypts new, unencoded `data` as `Security::encrypt()` does, under a
+// fresh IV. The URL encoding of the original cookie is kept.
+func cakephpResign(c *Cookie, data string, secret []byte, algorithm string) (string, error) {
+	parsedData := c.parsedDataFor(cakephpDecoder).(*cakephpParsedData)
+
+	if algorithm != "sha256" {
+		return "", ErrUnknownAlgorithm
+	}
+
+	key := cakephpKey(secret)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	padding := aes.BlockSize - len(data)%aes.BlockSize
+	plaintext := append([]byte(data), bytes.Repeat([]byte{byte(padding)}, padding)...)
+
+	ciphertext := make([]byte, aes.BlockSize+len(plaintext))
+	if _, err := rand.Read(ciphertext[:aes.BlockSize]); err != nil {
+		return "", err
+	}
+
+	cipher.NewCBCEncrypter(block, ciphertext[:aes.BlockSize]).CryptBlocks(ciphertext[aes.BlockSize:], plaintext)
+
+	mac := hex.EncodeToString(hashAlgorithms["sha256"].hmac(key, ciphertext))
+	resigned := cakephpPrefix + base64.StdEncoding.EncodeToString(append([]byte(mac), ciphertext...))
+
+	if parsedData.escaped {
+		resigned = url.QueryEscape(resigned)
+	}
+
+	return resigned, nil
+}
@@ -0,0 +1,55 @@
+package monster
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeCakePHP(t *testing.T) {
+	c := NewCookie(selfTestFixtures[cakephpDecoder][0].cookie)
+	if !c.Decode() || !c.hasParsedDataFor(cakephpDecoder) {
+		t.Fatalf("could not decode the CakePHP cookie")
+	}
+
+	if _, err := c.CakePHPValue(); err != ErrNotCakePHPCookie {
+		t.Errorf("decrypted a cookie which was not unsigned: %v", err)
+	}
+
+	if !c.UnsignWithSecret([]byte("changeme")) {
+		t.Fatalf("could not unsign the CakePHP cookie")
+	}
+
+	if value, err := c.CakePHPValue(); err != nil || string(value) != `{"id":1,"role":"user"}` {
+		t.Errorf("unexpected value %s: %v", value, err)
+	}
+
+	// A MAC followed by too little to hold an IV and a block is truncated.
+	if c := NewCookie(cakephpPrefix + "ZTQ5NjZlM2ZkMDFkNzk3MzJjOWUzYTdhYmI2YmE0OTVmOThmYjA3NTlkYjBlNDMyYjhhMjFmNzRkODRjMjAzZA=="); c.Decode() || len(c.Diagnostics()) == 0 {
+		t.Errorf("expected a diagnostic for a truncated cookie")
+	}
+}
+
+func TestResignCakePHP(t *testing.T) {
+	c := NewCookie(selfTestFixtures[cakephpDecoder][0].cookie)
+	if !c.Decode() || !c.UnsignWithSecret([]byte("changeme")) {
+		t.Fatalf("could not unsign the CakePHP cookie")
+	}
+
+	resigned, err := c.Resign(`{"id":1,"role":"admin"}`)
+	if err != nil {
+		t.Fatalf("could not resign the CakePHP cookie: %v", err)
+	}
+
+	if !strings.HasPrefix(resigned, "Q2FrZQ%3D%3D.") {
+		t.Errorf("the resigned cookie lost its URL encoding: %s", resigned)
+	}
+
+	forged := NewCookie(resigned)
+	if !forged.Decode() || !forged.UnsignWithSecret([]byte("changeme")) {
+		t.Fatalf("the resigned cookie does not verify")
+	}
+
+	if value, err := forged.CakePHPValue(); err != nil || string(value) != `{"id":1,"role":"admin"}` {
+		t.Errorf("unexpected value %s: %v", value, err)
+	}
+}
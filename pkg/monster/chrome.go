@@ -0,0 +1,86 @@
+package monster
+
+import (
+	"errors"
+	"os"
+)
+
+var (
+	ErrNotChromeCookies = errors.New("not a Chrome cookie database")
+)
+
+// The columns of Chrome's `cookies` table we read.
+const (
+	chromeCookiesTable = "cookies"
+	chromeHostColumn   = "host_key"
+	chromeNameColumn   = "name"
+	chromeValueColumn  = "value"
+)
+
+// Returns every cookie in a Chrome (or other Chromium browser) `Cookies`
+// SQLite database, with the host each was stored for. The file is only
+// read, never written. Chrome usually encrypts values into the
+// `encrypted_value` column and leaves `value` empty; decrypting them needs
+// the OS keychain, so the database must already be decrypted, and cookies
+// without a plaintext value are skipped.
+func CookiesFromChromeSQLite(path string) ([]NamedCookie, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := openSQLite(data)
+	if err != nil {
+		return nil, err
+	}
+
+	root, columns, err := db.table(chromeCookiesTable)
+	if errors.Is(err, errNoSQLiteTable) {
+		return nil, ErrNotChromeCookies
+	} else if err != nil {
+		return nil, err
+	}
+
+	host, name, value := -1, -1, -1
+	for i, column := range columns {
+		switch column {
+		case chromeHostColumn:
+			host = i
+		case chromeNameColumn:
+			name = i
+		case chromeValueColumn:
+			value = i
+		}
+	}
+
+	if host < 0 || name < 0 || value < 0 {
+		return nil, ErrNotChromeCookies
+	}
+
+	var cookies []NamedCookie
+
+	err = db.rows(root, func(row []interface{}) bool {
+		// Columns added after a row was written are missing from it.
+		if value >= len(row) || sqliteText(row[value]) == "" {
+			return true
+		}
+
+		cookie := NamedCookie{Value: sqliteText(row[value])}
+		if name < len(row) {
+			cookie.Name = sqliteText(row[name])
+		}
+
+		if host < len(row) {
+			cookie.Host = sqliteText(row[host])
+		}
+
+		cookies = append(cookies, cookie)
+		return true
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cookies, nil
+}
@@ -0,0 +1,92 @@
+package monster
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCookiesFromChromeSQLite(t *testing.T) {
+	// Built by SQLite itself with Chrome's schema and 512-byte pages, so the
+	// table spans interior pages and the long cookie spills onto overflow
+	// pages.
+	cookies, err := CookiesFromChromeSQLite("testdata/chrome-cookies.sqlite")
+	if err != nil {
+		t.Fatalf("could not read the Chrome cookies: %v", err)
+	}
+
+	// The encrypted cookie has no plaintext value, so it's skipped.
+	if len(cookies) != 22 {
+		t.Fatalf("found %d cookies instead of 22", len(cookies))
+	}
+
+	session := cookies[0]
+	if session.Name != "sessionid" || session.Host != ".example.com" {
+		t.Errorf("unexpected cookie %+v", session)
+	}
+
+	if !NewCookie(session.Value).Decode() {
+		t.Errorf("could not decode the session cookie from the Chrome database")
+	}
+
+	if big := cookies[1]; big.Name != "big" || big.Value != strings.Repeat("x", 1500) {
+		t.Errorf("read the overflowing cookie %q as %d bytes", big.Name, len(big.Value))
+	}
+
+	if last := cookies[21]; last.Name != "n19" || last.Value != "value-19" || last.Host != "filler19.example.net" {
+		t.Errorf("unexpected last cookie %+v", last)
+	}
+}
+
+func TestCookiesFromChromeSQLiteInvalid(t *testing.T) {
+	if _, err := CookiesFromChromeSQLite("chrome.go"); !errors.Is(err, errInvalidSQLite) {
+		t.Errorf("read a non-SQLite file with error %v", err)
+	}
+}
+
+func TestSQLiteRevisitedPages(t *testing.T) {
+	data, err := os.ReadFile("testdata/chrome-cookies.sqlite")
+	if err != nil {
+		t.Fatalf("could not read the database: %v", err)
+	}
+
+	db, err := openSQLite(data)
+	if err != nil {
+		t.Fatalf("could not open the database: %v", err)
+	}
+
+	root, _, err := db.table("cookies")
+	if err != nil {
+		t.Fatalf("could not find the cookies table: %v", err)
+	}
+
+	page, _ := db.page(root)
+	if page[0] != sqliteTableInterior {
+		t.Fatalf("the cookies table's root is not an interior page")
+	}
+
+	// Point every child of the root at its first child, which depth alone
+	// doesn't catch, since the tree stays shallow.
+	cells := int(binary.BigEndian.Uint16(page[3:]))
+	first := binary.BigEndian.Uint32(page[binary.BigEndian.Uint16(page[12:]):])
+
+	for i := 1; i < cells; i++ {
+		binary.BigEndian.PutUint32(page[binary.BigEndian.Uint16(page[12+2*i:]):], first)
+	}
+
+	binary.BigEndian.PutUint32(page[8:], first)
+
+	if err := db.rows(root, func(row []interface{}) bool { return true }); !errors.Is(err, errInvalidSQLite) {
+		t.Errorf("read a child page more than once with error %v", err)
+	}
+}
+
+func TestSQLiteColumns(t *testing.T) {
+	columns := sqliteColumns(`CREATE TABLE "t"("a" INTEGER, b DECIMAL(10, 2) NOT NULL, [c] TEXT, PRIMARY KEY (a, b))`)
+
+	if strings.Join(columns, ",") != "a,b,c" {
+		t.Errorf("parsed columns %q", columns)
+	}
+}
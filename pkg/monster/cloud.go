@@ -0,0 +1,249 @@
+package monster
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The providers `CloudToken()` recognizes.
+const (
+	CloudFirebase = "firebase"
+	CloudIAP      = "gcp-iap"
+	CloudALB      = "aws-alb"
+)
+
+const (
+	albDecoder = "alb"
+
+	// AWS ALB's authentication session cookie; values over 4 KB are split
+	// into `-0`, `-1` and so on, which the load balancer joins in order.
+	albCookieName = "AWSELBAuthSessionCookie"
+
+	// The shortest encrypted session worth reporting.
+	albMinLength = 16
+
+	iapIssuer = "https://cloud.google.com/iap"
+
+	// ALB signs the user claims it forwards in `x-amzn-oidc-data` with
+	// ES256, naming itself in the header's `signer`.
+	albSignerPrefix = "arn:aws:elasticloadbalancing:"
+)
+
+var (
+	// The algorithms cloud providers sign with, for which there's no
+	// secret to brute-force.
+	asymmetricAlgorithms = map[string]bool{
+		firebaseAlgorithm: true,
+		"es256":           true,
+	}
+)
+
+// What we learned about a session issued by a cloud provider rather than
+// the app, which testers can't usually forge even with the right wordlist.
+type CloudToken struct {
+	// One of the `Cloud` constants.
+	Provider string
+
+	// What the token is, e.g. "session cookie".
+	Kind string
+
+	// The JOSE algorithm it's signed with, or empty if it's encrypted.
+	Algorithm string
+
+	Issuer   string
+	Subject  string
+	Audience string
+	KeyID    string
+
+	// When the token expires, or the zero time if it doesn't say.
+	Expiry time.Time
+
+	// Set when the token is encrypted, so only the provider can read it.
+	Encrypted bool
+
+	// Whether the app verifies the token itself, rather than the provider
+	// doing so; only then can algorithm confusion (e.g. `alg: none`, or
+	// HS256 with the provider's public key) apply.
+	AppVerified bool
+}
+
+type albParsedData struct {
+	name        string
+	data        string
+	decodedData []byte
+	cloud       CloudToken
+
+	parsed bool
+}
+
+func (d *albParsedData) String() string {
+	if !d.parsed {
+		return "Unparsed data"
+	}
+
+	return fmt.Sprintf("Name: %s\nData: %s\nEncrypted session: %d bytes\n", d.name, d.data, len(d.decodedData))
+}
+
+func (d *albParsedData) serialized(c *Cookie) []byte {
+	// The session is encrypted with a key only AWS has.
+	return nil
+}
+
+func (d *albParsedData) fields() parsedFields {
+	// ALB's sessions are encrypted, not signed.
+	return parsedFields{data: d.data}
+}
+
+func (d *albParsedData) clone() decoderData {
+	copied := *d
+	return &copied
+}
+
+// Recognizes ALB's authentication session cookie by its name, given as
+// `name=value` as `NewCookieFromMap()` assembles it. It's encrypted with a
+// key only the load balancer holds, so all we can report is its size.
+func albDecode(c *Cookie) bool {
+	components := strings.SplitN(c.raw, "=", 2)
+	if len(components) != 2 || !strings.HasPrefix(components[0], albCookieName) {
+		return false
+	}
+
+	var parsedData albParsedData
+	parsedData.name, parsedData.data = components[0], components[1]
+
+	decoded, err := base64.StdEncoding.DecodeString(parsedData.data)
+	if err != nil {
+		decoded, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(parsedData.data, "="))
+	}
+
+	if err != nil || len(decoded) < albMinLength {
+		return false
+	}
+
+	parsedData.decodedData = decoded
+	parsedData.cloud = CloudToken{Provider: CloudALB, Kind: "session cookie", Encrypted: true}
+	parsedData.parsed = true
+	c.wasDecodedBy(albDecoder, &parsedData)
+
+	return true
+}
+
+// Joins ALB's session cookie shards, in order, into the single value the
+// load balancer reads.
+func albAssemble(cookies map[string]string) (string, bool) {
+	if value, ok := cookies[albCookieName]; ok {
+		return albCookieName + "=" + value, true
+	}
+
+	var shards []int
+	for name := range cookies {
+		suffix := strings.TrimPrefix(name, albCookieName+"-")
+		if suffix == name {
+			continue
+		}
+
+		if shard, err := strconv.Atoi(suffix); err == nil && shard >= 0 {
+			shards = append(shards, shard)
+		}
+	}
+
+	if len(shards) == 0 {
+		return "", false
+	}
+
+	sort.Ints(shards)
+
+	value := ""
+	for _, shard := range shards {
+		value += cookies[albCookieName+"-"+strconv.Itoa(shard)]
+	}
+
+	return albCookieName + "=" + value, true
+}
+
+// Recognizes Google IAP's signed assertions and the user claims ALB
+// forwards, from a JWT's parsed header and decoded body. Returns nil if it's
+// any other JWT; Firebase tokens are recognized by `firebaseTokenFor()`.
+func cloudTokenFor(header map[string]interface{}, decodedBody []byte) *CloudToken {
+	var body struct {
+		Issuer   string      `json:"iss"`
+		Audience interface{} `json:"aud"`
+		Subject  string      `json:"sub"`
+		Expiry   json.Number `json:"exp"`
+	}
+
+	if header["alg"] != "ES256" || json.Unmarshal(decodedBody, &body) != nil {
+		return nil
+	}
+
+	token := &CloudToken{Algorithm: "ES256", Issuer: body.Issuer, Subject: body.Subject, AppVerified: true}
+	token.KeyID, _ = header["kid"].(string)
+	token.Audience, _ = body.Audience.(string)
+
+	expiry := body.Expiry
+
+	switch signer, _ := header["signer"].(string); {
+	case body.Issuer == iapIssuer:
+		token.Provider, token.Kind = CloudIAP, "assertion"
+	case strings.HasPrefix(signer, albSignerPrefix):
+		token.Provider, token.Kind, token.Audience = CloudALB, "user claims token", signer
+
+		// ALB puts its own expiry in the header; the body's is the IdP's.
+		if exp, ok := header["exp"].(float64); ok {
+			expiry = json.Number(strconv.FormatInt(int64(exp), 10))
+		}
+	default:
+		return nil
+	}
+
+	if seconds, err := expiry.Float64(); err == nil {
+		token.Expiry = time.Unix(int64(seconds), 0).UTC()
+	}
+
+	return token
+}
+
+// Describes a Firebase token as a `CloudToken`.
+func firebaseCloudToken(firebase *FirebaseToken, decodedBody []byte) *CloudToken {
+	token := &CloudToken{Provider: CloudFirebase, Kind: "ID token", Algorithm: "RS256", Subject: firebase.Subject, Audience: firebase.Project, KeyID: firebase.KeyID, AppVerified: true}
+	if firebase.Session {
+		token.Kind = "session cookie"
+	}
+
+	var body struct {
+		Issuer string      `json:"iss"`
+		Expiry json.Number `json:"exp"`
+	}
+
+	if json.Unmarshal(decodedBody, &body) == nil {
+		token.Issuer = body.Issuer
+
+		if seconds, err := body.Expiry.Float64(); err == nil {
+			token.Expiry = time.Unix(int64(seconds), 0).UTC()
+		}
+	}
+
+	return token
+}
+
+// Returns what this cookie is if a cloud provider issued it: a Firebase ID
+// token or session cookie, a Google IAP assertion, or an AWS ALB session
+// cookie or forwarded user claims.
+func (c *Cookie) CloudToken() (CloudToken, bool) {
+	if c.hasParsedDataFor(albDecoder) {
+		return c.parsedDataFor(albDecoder).(*albParsedData).cloud, true
+	}
+
+	if c.hasParsedDataFor(jwtDecoder) {
+		if cloud := c.parsedDataFor(jwtDecoder).(*jwtParsedData).cloud; cloud != nil {
+			return *cloud, true
+		}
+	}
+
+	return CloudToken{}, false
+}
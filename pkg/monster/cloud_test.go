@@ -0,0 +1,80 @@
+package monster
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// An ES256-shaped token, with a random 64-byte signature.
+func cloudTestToken(header string, body string) string {
+	signature := base64.RawURLEncoding.EncodeToString([]byte(strings.Repeat("\x5a", 64)))
+	return base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + base64.RawURLEncoding.EncodeToString([]byte(body)) + "." + signature
+}
+
+func TestDecodeIAP(t *testing.T) {
+	c := NewCookie(cloudTestToken(`{"alg":"ES256","kid":"0oeLcQ","typ":"JWT"}`, `{"aud":"/projects/123/apps/example","email":"user@example.com","exp":1700000600,"iat":1700000000,"iss":"https://cloud.google.com/iap","sub":"accounts.google.com:1234"}`))
+	if !c.Decode() {
+		t.Fatalf("could not decode an IAP assertion")
+	}
+
+	token, ok := c.CloudToken()
+	if !ok || token.Provider != CloudIAP || token.Audience != "/projects/123/apps/example" || token.Subject != "accounts.google.com:1234" || token.KeyID != "0oeLcQ" || token.Expiry.Unix() != 1700000600 || !token.AppVerified {
+		t.Errorf("unexpected IAP token %+v", token)
+	}
+
+	// Without recognizing it, we'd try the signature as HMAC-SHA512.
+	if algorithm := c.signatureAlgorithm(jwtDecoder); algorithm != "es256" || c.shouldUnsignWith(jwtDecoder, &unsignOptions{}) {
+		t.Errorf("would brute-force an ES256 token as %s", algorithm)
+	}
+}
+
+func TestDecodeALB(t *testing.T) {
+	// ALB pads its tokens, unlike other JWTs.
+	header := base64.URLEncoding.EncodeToString([]byte(`{"alg":"ES256","kid":"12345678-1234-1234-1234-123456789012","signer":"arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/example/50dc6c495c0c9188","iss":"https://idp.example.com","client":"client-id","exp":1700000120}`))
+	body := base64.URLEncoding.EncodeToString([]byte(`{"sub":"1234","email":"user@example.com","exp":1700003600,"iss":"https://idp.example.com"}`))
+	signature := base64.URLEncoding.EncodeToString([]byte(strings.Repeat("\x5a", 64)))
+
+	c := NewCookie(header + "." + body + "." + signature)
+	if !c.Decode() {
+		t.Fatalf("could not decode ALB's user claims")
+	}
+
+	if token, ok := c.CloudToken(); !ok || token.Provider != CloudALB || token.Encrypted || token.Expiry.Unix() != 1700000120 || !strings.HasPrefix(token.Audience, albSignerPrefix) {
+		t.Errorf("unexpected ALB token %+v", token)
+	}
+
+	// The session cookie itself is encrypted, and split once it's over 4 KB.
+	shard := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("\x01\x02\x03", 32)))
+
+	c, ok := NewCookieFromMap(map[string]string{albCookieName + "-1": shard[64:], albCookieName + "-0": shard[:64]})
+	if !ok || !c.Decode() {
+		t.Fatalf("could not decode ALB's session cookie")
+	}
+
+	if token, ok := c.CloudToken(); !ok || token.Provider != CloudALB || !token.Encrypted || token.AppVerified {
+		t.Errorf("unexpected ALB session %+v", token)
+	}
+
+	if fields := c.DecodedFields(); len(fields) != 1 || fields[0].Decoder != albDecoder || fields[0].Data != shard {
+		t.Errorf("expected the shards to be joined, got %+v", fields)
+	}
+
+	// There's nothing to verify or sign, but the data is still shown.
+	if data := c.renderData(); data.Decoder != albDecoder || data.Data != shard || data.Algorithm != "" {
+		t.Errorf("unexpected render data %+v", data)
+	}
+
+	if c.verifyWith(albDecoder, []byte("changeme")) {
+		t.Errorf("verified an ALB session, which AWS encrypts")
+	}
+
+	inputs := InputsFromNamedCookies([]NamedCookie{
+		{Name: albCookieName + "-0", Value: shard[:64], URL: "https://example.com/"},
+		{Name: albCookieName + "-1", Value: shard[64:], URL: "https://example.com/"},
+	})
+
+	if len(inputs) != 1 || inputs[0].Value != albCookieName+"="+shard {
+		t.Errorf("expected one joined input, got %+v", inputs)
+	}
+}
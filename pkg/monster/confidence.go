@@ -0,0 +1,218 @@
+package monster
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/iangcarroll/cookiemonster/pkg/timestamp"
+)
+
+// How likely it is that a decoder identified the cookie's format, as
+// ranked by `Matches()`.
+type DecoderMatch struct {
+	// One of the `Decoder` constants, or a registered decoder's name.
+	Decoder string
+
+	// From 0 to 1.
+	Confidence float64
+
+	// What raised or lowered the confidence, in the order they were found.
+	Signals []MatchSignal
+}
+
+// One piece of evidence for or against a `DecoderMatch`.
+type MatchSignal struct {
+	Reason string
+
+	// How much it changed the confidence; negative if it lowered it.
+	Weight float64
+}
+
+const (
+	// Where a decoder starts when its structural checks are all it has.
+	baseConfidence = 0.5
+
+	// Signatures with less entropy than this fraction of the most their
+	// length allows are unlikely to be digests.
+	minSignatureEntropy = 0.85
+)
+
+var (
+	// Decoders whose structural checks are distinctive (e.g. a magic
+	// header or a fixed JSON shape), or so generic that they accept almost
+	// anything, start away from `baseConfidence`.
+	decoderBaseConfidence = map[string]float64{
+		laravelDecoder:    0.85,
+		connectDecoder:    0.8,
+		aspnetCoreDecoder: 0.8,
+		albDecoder:        0.9,
+		cakephpDecoder:    0.85,
+		envelopeDecoder:   0.7,
+		railsDecoder:      0.65,
+		hashHMACDecoder:   0.4,
+		detachedDecoder:   0.3,
+		unsignedDecoder:   0.3,
+	}
+)
+
+func (m *DecoderMatch) signal(weight float64, reason string) {
+	m.Signals = append(m.Signals, MatchSignal{Reason: reason, Weight: weight})
+	m.Confidence += weight
+}
+
+// Returns every decoder that decoded the cookie, most likely first. A
+// cookie can pass the structural checks of several (anything shaped like
+// `a:b:c` looks like Django), so each is scored on signals specific to its
+// format, such as whether its timestamp is a plausible date, its payload
+// deserializes, its digest length is the framework's default, or it has a
+// known prefix. Equally likely decoders are sorted by name. To try keys
+// against only one of them, pass `WithDecoder()` to `Unsign()`.
+func (c *Cookie) Matches() []DecoderMatch {
+	c.mutex.RLock()
+	decoders := make([]string, 0, len(c.decodedBy))
+	for decoder := range c.decodedBy {
+		decoders = append(decoders, decoder)
+	}
+	c.mutex.RUnlock()
+
+	matches := make([]DecoderMatch, 0, len(decoders))
+	for _, decoder := range decoders {
+		matches = append(matches, c.matchFor(decoder))
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Confidence != matches[j].Confidence {
+			return matches[i].Confidence > matches[j].Confidence
+		}
+
+		return matches[i].Decoder < matches[j].Decoder
+	})
+
+	return matches
+}
+
+// Scores how likely it is that `decoder` identified the cookie's format.
+func (c *Cookie) matchFor(decoder string) DecoderMatch {
+	match := DecoderMatch{Decoder: decoder, Confidence: baseConfidence}
+	if base, ok := decoderBaseConfidence[decoder]; ok {
+		match.Confidence = base
+	}
+
+	switch parsedData := c.parsedDataFor(decoder).(type) {
+	case *djangoParsedData:
+		if parsedData.compressed {
+			match.signal(0.1, "compressed, with the leading dot Django marks it with")
+		}
+
+		if parsedData.timestamped {
+			if parsedData.hasSignedAt && !parsedData.signedAt.After(now().Add(time.Hour)) {
+				match.signal(0.2, "timestamp is a plausible date")
+			} else {
+				match.signal(-0.2, "timestamp is not a plausible date")
+			}
+		}
+
+		matchSerializer(&match, parsedData.decodedData, parsedData.serializer)
+
+		if parsedData.algorithm == "sha1" || parsedData.algorithm == "sha256" {
+			match.signal(0.05, "digest length is one Django signs with by default")
+		}
+	case *flaskParsedData:
+		if parsedData.compressed {
+			match.signal(0.1, "compressed, with the leading dot itsdangerous marks it with")
+		}
+
+		if signedAt, ok := flaskTimestamp(parsedData.timestamp); ok && !signedAt.After(now().Add(time.Hour)) {
+			match.signal(0.2, "timestamp is a plausible date")
+		} else {
+			match.signal(-0.3, "timestamp is not a plausible date")
+		}
+
+		matchSerializer(&match, parsedData.decodedData, parsedData.serializer)
+
+		if parsedData.algorithm == "sha1" {
+			match.signal(0.05, "digest length is the SHA-1 itsdangerous signs with by default")
+		}
+	case *jwtParsedData:
+		if _, ok := parsedData.joseHeader["alg"]; ok {
+			match.signal(0.3, "header names its algorithm")
+		} else {
+			match.signal(-0.3, "first segment is not a JOSE header")
+		}
+
+		if isJSONObject(parsedData.decodedBody) {
+			match.signal(0.1, "claims are a JSON object")
+		}
+	case *rackParsedData:
+		// Marshal dumps start with their format version, 4.8.
+		if bytes.HasPrefix(parsedData.serialized(c), []byte{4, 8}) {
+			match.signal(0.3, "payload is a Ruby Marshal dump")
+		} else {
+			match.signal(-0.2, "payload is not a Ruby Marshal dump")
+		}
+	case *expressParsedData:
+		if isJSONObject(parsedData.decodedData) {
+			match.signal(0.3, "session is base64 JSON after the cookie name")
+		}
+	case *playParsedData:
+		if parsedData.name != "" {
+			match.signal(0.3, "has Play's cookie name, "+parsedData.name)
+		}
+	case *springParsedData:
+		if parsedData.name != "" {
+			match.signal(0.3, "has Spring Security's cookie name, "+parsedData.name)
+		}
+
+		if expiry, ok := c.springExpiry(); ok && timestamp.Plausible(expiry) {
+			match.signal(0.2, "expiry is a plausible date in milliseconds")
+		} else {
+			match.signal(-0.2, "expiry is not a plausible date in milliseconds")
+		}
+	case *yiiParsedData:
+		// Yii 2 serializes each cookie's name along with its value.
+		if strings.HasPrefix(parsedData.data, "a:2:{i:0;s:") {
+			match.signal(0.2, "value is Yii 2's serialized name and value")
+		}
+	case *hashHMACParsedData:
+		if phpSerialized(parsedData.data) || isJSONObject([]byte(parsedData.data)) {
+			match.signal(0.1, "value is serialized PHP or JSON")
+		}
+	}
+
+	if entropy, maximum, ok := c.SignatureEntropy(decoder); ok && len(c.parsedDataFor(decoder).fields().decodedSignature) >= 16 {
+		if entropy < maximum*minSignatureEntropy {
+			match.signal(-0.2, "signature has too little entropy to be a digest")
+		}
+	}
+
+	switch {
+	case match.Confidence < 0:
+		match.Confidence = 0
+	case match.Confidence > 1:
+		match.Confidence = 1
+	}
+
+	return match
+}
+
+// Scores a Django or Flask payload by whether it deserializes.
+func matchSerializer(match *DecoderMatch, decodedData []byte, serializer string) {
+	switch {
+	case len(decodedData) == 0:
+		match.signal(-0.3, "payload is not URL-safe base64")
+	case serializer == serializerUnknown:
+		match.signal(-0.2, "payload does not deserialize")
+	case serializer == serializerJSON && !isJSONObject(decodedData):
+		// Sessions are objects; a bare number or string is likely chance.
+	default:
+		match.signal(0.2, "payload is a "+serializer+" session")
+	}
+}
+
+func isJSONObject(data []byte) bool {
+	var object map[string]json.RawMessage
+	return json.Unmarshal(data, &object) == nil && object != nil
+}
@@ -0,0 +1,60 @@
+package monster
+
+import "testing"
+
+// A Flask cookie whose first two segments also pass for a JWT's.
+const ambiguousFlask = "eyJhIjoiYiJ9.YX-skA.D0By6YsWkNcDZfs59oCAwN4I1yc"
+
+func TestMatches(t *testing.T) {
+	flask := NewCookie(ambiguousFlask)
+	flask.Decode()
+
+	matches := flask.Matches()
+	if len(matches) != 2 || matches[0].Decoder != flaskDecoder || matches[1].Decoder != jwtDecoder || matches[0].Confidence <= matches[1].Confidence {
+		t.Fatalf("unexpected matches for a Flask cookie: %+v", matches)
+	}
+
+	if len(matches[0].Signals) == 0 || len(matches[1].Signals) == 0 {
+		t.Errorf("matches have no signals: %+v", matches)
+	}
+
+	if data := flask.renderData(); data.Decoder != flaskDecoder {
+		t.Errorf("rendered the cookie as %s rather than its likeliest decoder", data.Decoder)
+	}
+
+	jwt := NewCookie(batchJWT)
+	jwt.Decode()
+
+	jwtMatches := jwt.Matches()
+	if len(jwtMatches) == 0 || jwtMatches[0].Decoder != jwtDecoder {
+		t.Errorf("unexpected matches for a JWT: %+v", jwtMatches)
+	}
+
+	for _, match := range append(matches, jwtMatches...) {
+		if match.Confidence < 0 || match.Confidence > 1 {
+			t.Errorf("confidence %f for %s is out of range", match.Confidence, match.Decoder)
+		}
+	}
+}
+
+func TestWithDecoder(t *testing.T) {
+	wl := NewWordlist()
+	if err := wl.LoadFromArray([][]byte{[]byte("changeme")}); err != nil {
+		t.Errorf("could not LoadFromArray")
+	}
+
+	c := NewCookie(ambiguousFlask)
+	c.Decode()
+
+	if _, success := c.Unsign(wl, 1, WithDecoder(jwtDecoder)); success {
+		t.Errorf("unsigned a Flask cookie as a JWT")
+	}
+
+	if _, success := c.Unsign(wl, 1, WithDecoder(flaskDecoder)); !success {
+		t.Errorf("could not unsign a Flask cookie with the flask decoder forced")
+	}
+
+	if _, _, decoder := c.Result(); decoder != flaskDecoder {
+		t.Errorf("unsigned by %s", decoder)
+	}
+}
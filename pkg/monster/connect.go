@@ -0,0 +1,137 @@
+package monster
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+type connectParsedData struct {
+	sessionID        string
+	signature        string
+	decodedSignature []byte
+	encoding         string
+
+	// Whether the value was URL-encoded, as browsers show it.
+	escaped bool
+
+	parsed bool
+}
+
+func (d *connectParsedData) String() string {
+	if !d.parsed {
+		return "Unparsed data"
+	}
+
+	return fmt.Sprintf("Session ID: %s\nSeparator: %s\nSignature: %s\nEncoding: %s\nAlgorithm: sha256\n", d.sessionID, connectSeparator, d.signature, d.encoding)
+}
+
+func (d *connectParsedData) serialized(c *Cookie) []byte {
+	// The session is kept on the server.
+	return nil
+}
+
+func (d *connectParsedData) fields() parsedFields {
+	return parsedFields{data: d.sessionID, signature: d.signature, decodedSignature: d.decodedSignature, algorithm: "sha256"}
+}
+
+func (d *connectParsedData) clone() decoderData {
+	copied := *d
+	return &copied
+}
+
+const (
+	connectDecoder   = "connect"
+	connectMinLength = 10
+
+	// express-session (and connect before it) marks signed values with this
+	// prefix, and separates the signature with the last dot.
+	connectPrefix    = `s:`
+	connectSeparator = `.`
+
+	// cookie-signature always uses HMAC-SHA256.
+	connectSignatureLength = 32
+)
+
+func connectDecode(c *Cookie) bool {
+	if len(c.raw) < connectMinLength {
+		return false
+	}
+
+	rawData := c.raw
+
+	// The value is usually URL-encoded when copied out of a browser, i.e.
+	// `s%3A...`. We avoid `QueryUnescape` since `+` is valid in the signature.
+	escaped := false
+	if unescaped, err := url.PathUnescape(rawData); err == nil {
+		escaped = unescaped != rawData
+		rawData = unescaped
+	}
+
+	// Without the prefix this is not a signed value.
+	if !strings.HasPrefix(rawData, connectPrefix) {
+		return false
+	}
+
+	rawData = strings.TrimPrefix(rawData, connectPrefix)
+
+	// Session IDs shouldn't contain dots, but the signature never does, so
+	// we split on the last one.
+	separatorIndex := strings.LastIndex(rawData, connectSeparator)
+	if separatorIndex <= 0 {
+		return false
+	}
+
+	var parsedData connectParsedData
+	parsedData.sessionID = rawData[:separatorIndex]
+	parsedData.signature = rawData[separatorIndex+1:]
+
+	// cookie-signature uses standard base64 without padding, but values are
+	// often re-encoded by other tools, so we accept any encoding that gives
+	// us a SHA256-sized signature.
+	decodedSignature, encoding, ok := c.decodeTolerant(parsedData.signature, func(decoded []byte) bool {
+		return len(decoded) == connectSignatureLength
+	})
+
+	if !ok {
+		c.undecodedSignature(connectDecoder, parsedData.signature)
+		return false
+	}
+
+	parsedData.encoding = encoding
+	parsedData.escaped = escaped
+	parsedData.decodedSignature = decodedSignature
+	parsedData.parsed = true
+	c.wasDecodedBy(connectDecoder, &parsedData)
+
+	return true
+}
+
+func connectUnsign(c *Cookie, secret []byte, keys *derivedKeys) bool {
+	// Only the session ID is signed; the `s:` prefix is not included.
+	parsedData := c.parsedDataFor(connectDecoder).(*connectParsedData)
+
+	// Derive the correct signature, if this was the correct secret key, and
+	// compare it to the one in the `Cookie`.
+	return keys.verify(secretKeyed("sha256"), secret, []byte(parsedData.sessionID), parsedData.decodedSignature)
+}
+
+// Resigns the cookie with a new, unencoded session ID, as express-session
+// would set it; the URL encoding of the original is kept.
+func connectResign(c *Cookie, sessionID string, secret []byte, algorithm string) (string, error) {
+	parsedData := c.parsedDataFor(connectDecoder).(*connectParsedData)
+
+	if algorithm != "sha256" {
+		return "", ErrUnknownAlgorithm
+	}
+
+	// cookie-signature strips the padding from standard base64.
+	resigned := connectPrefix + sessionID + connectSeparator + base64.RawStdEncoding.EncodeToString(sha256HMAC(secret, []byte(sessionID)))
+
+	if parsedData.escaped {
+		resigned = url.QueryEscape(resigned)
+	}
+
+	return resigned, nil
+}
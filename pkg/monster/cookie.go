@@ -1,126 +1,748 @@
 package monster
 
 import (
+	"context"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"net/url"
+	"runtime"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// The decoder names `Result()` and `DecodedFields()` report, for callers
+// which branch on the format a cookie turned out to be.
+const (
+	DecoderDjango     = djangoDecoder
+	DecoderFlask      = flaskDecoder
+	DecoderJWT        = jwtDecoder
+	DecoderRack       = rackDecoder
+	DecoderExpress    = expressDecoder
+	DecoderLaravel    = laravelDecoder
+	DecoderConnect    = connectDecoder
+	DecoderEnvelope   = envelopeDecoder
+	DecoderASPNETCore = aspnetCoreDecoder
+	DecoderRails      = railsDecoder
+	DecoderPlay       = playDecoder
+	DecoderSpring     = springDecoder
+	DecoderYii        = yiiDecoder
+	DecoderCakePHP    = cakephpDecoder
+	DecoderHashHMAC   = hashHMACDecoder
+	DecoderDetached   = detachedDecoder
+	DecoderALB        = albDecoder
+	DecoderUnsigned   = unsignedDecoder
+)
+
+const (
+	// The longest cookie `New()` accepts; browsers cap each cookie at 4 KB,
+	// and even joined shards (as ALB's are) come nowhere near this.
+	maxCookieLength = 64 << 10
+
+	// The most combinations of salts and KDFs `DetectConfig()` tries, so a
+	// few long lists can't keep it busy indefinitely.
+	maxConfigCombinations = 4096
+)
+
+var (
+	ErrNotUnsigned       = errors.New("cannot resign a cookie that was not unsigned")
+	ErrResignUnsupported = errors.New("this decoder does not support resigning")
+	ErrNotJSONSession    = errors.New("the cookie's session was not serialized as JSON")
+	ErrIncompatibleKey   = errors.New("the key cannot sign this cookie's format")
+	ErrNotDecoded        = errors.New("the cookie is not in a supported format")
+	ErrEmptyCookie       = errors.New("the cookie is empty")
+	ErrCookieTooLong     = errors.New("the cookie is longer than any browser would send")
+	ErrWrongSecret       = errors.New("the secret does not verify this cookie")
+
+	// Decoders which need several named cookies to verify a signature
+	// declare how to assemble them into the single raw value they decode.
+	multiCookieAssemblers = []func(cookies map[string]string) (string, bool){
+		expressAssemble,
+		albAssemble,
+	}
+
+	// Leading markers which only some frameworks put on their values, and
+	// the decoders that `Decode()` routes them to before trying the rest.
+	valueMarkers = []valueMarker{
+		{connectPrefix, []func(*Cookie) bool{connectDecode}},
+		{url.QueryEscape(connectPrefix), []func(*Cookie) bool{connectDecode}},
+
+		// Django and Flask mark compressed values with a leading dot.
+		{".", []func(*Cookie) bool{djangoDecode, flaskDecode}},
+
+		// ALB's session cookie is only recognizable by its name.
+		{albCookieName, []func(*Cookie) bool{albDecode}},
+
+		// As are Play's and Spring Security's, when given with theirs.
+		{playCookieNames[0] + "=", []func(*Cookie) bool{playDecode}},
+		{playCookieNames[1] + "=", []func(*Cookie) bool{playDecode}},
+		{springCookieName + "=", []func(*Cookie) bool{springDecode}},
+
+		// CakePHP prefixes every encrypted cookie with the base64 of "Cake".
+		{cakephpPrefix, []func(*Cookie) bool{cakephpDecode}},
+		{url.QueryEscape(cakephpPrefix), []func(*Cookie) bool{cakephpDecode}},
+	}
+)
+
+type valueMarker struct {
+	prefix   string
+	decoders []func(*Cookie) bool
+}
+
 // Returns a new `Cookie`, which must then be used with
 // `Decode()` and then `Unsign()`.
 func NewCookie(raw string) *Cookie {
-	return &Cookie{raw: raw, decodedBy: make(map[string]interface{})}
+	return &Cookie{raw: raw, decodedBy: make(map[string]decoderData)}
+}
+
+// Returns a new `Cookie` from a set of named cookies, for frameworks which
+// spread a signed value across several of them (e.g. Express's `.sig`
+// cookie). This is false if no decoder recognizes the set.
+func NewCookieFromMap(cookies map[string]string) (*Cookie, bool) {
+	for _, assemble := range multiCookieAssemblers {
+		if raw, ok := assemble(cookies); ok {
+			return NewCookie(raw), true
+		}
+	}
+
+	return nil, false
+}
+
+// Returns a decoded `Cookie` for `raw`, ready for `Unsign()`, or an error if
+// it's empty, implausibly long, or in no supported format; in that case the
+// error includes the cookie's `Diagnostics()`, if any. This is the entry
+// point for embedding the package in scanners and pipelines: it and the
+// methods it enables return errors rather than panicking on any input, and
+// compare signatures in constant time.
+func New(raw string) (*Cookie, error) {
+	switch {
+	case raw == "":
+		return nil, ErrEmptyCookie
+	case len(raw) > maxCookieLength:
+		return nil, ErrCookieTooLong
+	}
+
+	c := NewCookie(raw)
+	if !c.Decode() {
+		if diagnostics := c.Diagnostics(); len(diagnostics) > 0 {
+			return nil, fmt.Errorf("%w: %s", ErrNotDecoded, strings.Join(diagnostics, "; "))
+		}
+
+		return nil, ErrNotDecoded
+	}
+
+	return c, nil
 }
 
 // Decodes a `Cookie` into its components, trying all of the
-// available decoders. Decode is not thread-safe.
+// available decoders. Results are cached by the raw value, so decoding the
+// same cookie again is cheap; see `SetDecodeCacheSize()`. Decode is not
+// thread-safe.
 func (c *Cookie) Decode() (success bool) {
-	if djangoDecode(c) {
-		success = true
-	}
+	key := c.decodeCacheKey()
 
-	if flaskDecode(c) {
-		success = true
+	if cached, ok := decodes.get(key); ok {
+		c.mutex.Lock()
+		c.raw, c.wasUnwrapped, c.decodedBy, c.diagnostics = cached.raw, cached.wasUnwrapped, cached.decodedBy, cached.diagnostics
+		c.mutex.Unlock()
+
+		return len(cached.decodedBy) > 0
 	}
 
-	if jwtDecode(c) {
-		success = true
+	success = c.decode()
+
+	c.mutex.RLock()
+	decodes.put(decodeCacheEntry{key: key, raw: c.raw, wasUnwrapped: c.wasUnwrapped, decodedBy: c.decodedBy, diagnostics: c.diagnostics})
+	c.mutex.RUnlock()
+
+	return success
+}
+
+func (c *Cookie) decode() (success bool) {
+	// A known marker means we only try the decoders it belongs to, so other
+	// formats can't also claim the cookie by coincidence.
+	if c.decodeMarked() {
+		return true
 	}
 
-	if rackDecode(c) {
-		success = true
+	for _, b := range builtins {
+		if b.decode != nil && b.decode(c) {
+			success = true
+		}
 	}
 
-	if expressDecode(c) {
+	if c.customDecode() {
 		success = true
 	}
 
-	if laravelDecode(c) {
+	// This must run last, since it only accepts what nothing else did.
+	if unsignedDecode(c) {
 		success = true
 	}
 
 	if !success && c.unwrap() {
-		return c.Decode()
+		return c.decode()
 	}
 
 	return success
 }
 
+// Like `Unsign()`, but checks a single known secret instead of a wordlist.
+func (c *Cookie) UnsignWithSecret(secret []byte, opts ...UnsignOption) bool {
+	wl := NewWordlist()
+	wl.LoadFromArray([][]byte{secret})
+
+	_, success := c.Unsign(wl, 1, opts...)
+	return success
+}
+
 // Uses the decoded data from `Decode()` to attempt to unsign the cookie
-// with a given wordlist. Unsign is not thread-safe.
-func (c *Cookie) Unsign(wl *Wordlist, concurrencyLimit uint64) (key []byte, success bool) {
-	shouldUseDjango := c.hasParsedDataFor(djangoDecoder)
-	shouldUseFlask := c.hasParsedDataFor(flaskDecoder)
-	shouldUseJwt := c.hasParsedDataFor(jwtDecoder)
-	shouldUseRack := c.hasParsedDataFor(rackDecoder)
-	shouldUseExpress := c.hasParsedDataFor(expressDecoder)
-	shouldUseLaravel := c.hasParsedDataFor(laravelDecoder)
+// with a given wordlist, spread across `concurrencyLimit` workers (the
+// number of CPUs if it's zero). Unsign is not thread-safe.
+func (c *Cookie) Unsign(wl *Wordlist, concurrencyLimit uint64, opts ...UnsignOption) (key []byte, success bool) {
+	options, plan := c.prepareUnsign(opts)
+	defer c.progress.finish()
 
 	// This looks a bit silly right now, but as we add more decoders, this
 	// should be here to ensure we don't do pointless work.
-	if !shouldUseDjango && !shouldUseFlask && !shouldUseJwt && !shouldUseRack && !shouldUseExpress && !shouldUseLaravel {
+	if !plan.any() {
 		return nil, false
 	}
 
-	var wg sync.WaitGroup
+	defer c.rememberSecret(options)
+	c.trySecretCache(options, plan)
 
-	// We use a limiter (abusing channels) to throttle the amount of
-	// goroutines we run at a time.
-	limiter := newLimiter(concurrencyLimit)
+	budget := &candidateBudget{max: options.maxCandidates}
+	entries := budget.limit(wl.Entries())
+	attempt := func(entry []byte, keys *derivedKeys) {
+		c.tryKey(plan, c.transformSecret(entry), entry, keys)
+	}
 
-	for _, entry := range wl.Entries() {
-		wg.Add(1)
-		limiter.Add()
+	if options.autoTune && !c.wasUnsigned() {
+		concurrencyLimit, entries = c.autoTune(entries, concurrencyLimit, attempt)
+	}
 
-		go func(entry []byte) {
-			defer wg.Done()
-			defer limiter.Done()
+	if !c.wasUnsigned() {
+		c.bruteForce(entries, concurrencyLimit, attempt)
+	}
 
-			if shouldUseDjango && djangoUnsign(c, entry) {
-				c.wasUnsignedBy(djangoDecoder, entry)
-			}
+	// Some misconfigured apps only use the first N bytes of a longer secret,
+	// so if the full entries failed we can optionally try their prefixes.
+	if !c.wasUnsigned() && options.truncatedMinLength > 0 && !budget.exhausted() {
+		c.bruteForce(wl.Entries(), concurrencyLimit, func(entry []byte, keys *derivedKeys) {
+			for length := len(entry) - 1; length >= options.truncatedMinLength; length-- {
+				if c.wasUnsigned() || !budget.take() {
+					return
+				}
 
-			if shouldUseFlask && flaskUnsign(c, entry) {
-				c.wasUnsignedBy(flaskDecoder, entry)
+				c.tryKey(plan, c.transformSecret(entry[:length]), entry, keys)
 			}
+		})
+	}
+
+	c.limitReached = !c.wasUnsigned() && budget.exhausted()
+
+	return c.unsignedKey, c.wasUnsigned()
+}
+
+// Like `Unsign()`, but reads a base64-encoded wordlist from `r` one line at
+// a time instead of loading it, for lists too big to hold in memory; see
+// `OpenWordlist()`. `WithAutoTune()` and `WithTruncatedSecrets()` need the
+// whole list up front, so they have no effect here.
+func (c *Cookie) UnsignReader(r io.Reader, concurrencyLimit uint64, opts ...UnsignOption) (key []byte, success bool, err error) {
+	options, plan := c.prepareUnsign(opts)
+	defer c.progress.finish()
+
+	if !plan.any() {
+		return nil, false, nil
+	}
+
+	defer c.rememberSecret(options)
+	c.trySecretCache(options, plan)
+
+	budget := &candidateBudget{max: options.maxCandidates}
+	scanner := newWordlistScanner(r)
+
+	if !c.wasUnsigned() {
+		c.bruteForceFrom(func() ([]byte, bool) {
+			entry, ok := scanner.next()
+			return entry, ok && budget.take()
+		}, concurrencyLimit, func(entry []byte, keys *derivedKeys) {
+			c.tryKey(plan, c.transformSecret(entry), entry, keys)
+		})
+	}
+
+	c.limitReached = !c.wasUnsigned() && budget.exhausted()
+
+	return c.unsignedKey, c.wasUnsigned(), scanner.err
+}
 
-			if shouldUseJwt && jwtUnsign(c, entry) {
-				c.wasUnsignedBy(jwtDecoder, entry)
+// Decodes `raw`, brute-forces its secret with the candidates received from
+// `secrets` until one is the key or the channel is closed, and, if the key
+// was found and `newData` isn't nil, resigns the cookie with it: the whole
+// usual workflow in one call. Once the key is found, `secrets` is no longer
+// read, so senders mustn't block on it forever.
+func Crack(raw string, secrets <-chan []byte, newData *string) (CrackResult, error) {
+	c := NewCookie(raw)
+	if !c.Decode() {
+		return CrackResult{}, ErrNotDecoded
+	}
+
+	_, plan := c.prepareUnsign(nil)
+	defer c.progress.finish()
+
+	if plan.any() {
+		c.bruteForceFrom(func() ([]byte, bool) {
+			if c.wasUnsigned() {
+				return nil, false
 			}
 
-			if shouldUseRack && rackUnsign(c, entry) {
-				c.wasUnsignedBy(rackDecoder, entry)
+			secret, ok := <-secrets
+			return secret, ok
+		}, 0, func(entry []byte, keys *derivedKeys) {
+			c.tryKey(plan, c.transformSecret(entry), entry, keys)
+		})
+	}
+
+	result := CrackResult{Cookie: c}
+	result.Found, result.Key, result.Decoder = c.Result()
+
+	if !result.Found || newData == nil {
+		return result, nil
+	}
+
+	resigned, err := c.Resign(*newData)
+	if err != nil {
+		return result, err
+	}
+
+	result.Resigned = resigned
+	return result, nil
+}
+
+// Applies `opts` for a run of `Unsign()`, and plans which decoders to try.
+func (c *Cookie) prepareUnsign(opts []UnsignOption) (*unsignOptions, unsignPlan) {
+	options := newUnsignOptions(opts)
+	c.logSink = options.logSink
+	c.limitReached = false
+	c.secretTransform = options.transform
+	c.djangoSalts = options.djangoSalts
+	c.progress.start()
+
+	if c.hasParsedDataFor(djangoDecoder) {
+		atomic.StoreInt32(&c.parsedDataFor(djangoDecoder).(*djangoParsedData).matchedSalt, 0)
+	}
+
+	if c.hasParsedDataFor(springDecoder) {
+		atomic.StoreInt32(&c.parsedDataFor(springDecoder).(*springParsedData).matchedPassword, 0)
+	}
+
+	plan := unsignPlan{
+		custom: c.customDecodedBy(options),
+
+		canonicalJSON: options.canonicalJSON,
+		kdf:           options.kdf,
+		keyID:         options.keyID,
+		cookieName:    options.cookieName,
+	}
+
+	if c.hasParsedDataFor(djangoDecoder) {
+		plan.djangoSalts = c.djangoSaltCandidates(c.parsedDataFor(djangoDecoder).(*djangoParsedData))
+	}
+
+	if c.hasParsedDataFor(railsDecoder) {
+		plan.railsDigests = railsAllowedDigests(options)
+	}
+
+	for i := range builtins {
+		b := &builtins[i]
+		if b.unsign != nil && c.shouldUnsignWith(b.name, options) && (b.ready == nil || b.ready(c)) {
+			plan.builtins = append(plan.builtins, b)
+		}
+	}
+
+	return options, plan
+}
+
+// Reports how many candidate keys the current or last run of `Unsign()`,
+// `UnsignReader()` or `Crack()` has tried, and how quickly. It is safe to
+// call from another goroutine while the run is going, e.g. to show a
+// progress bar for a large wordlist.
+func (c *Cookie) Progress() Progress {
+	return c.progress.snapshot()
+}
+
+// Reports whether the last `Unsign()` gave up because it reached the cap
+// set by `WithMaxCandidates()`, rather than running out of candidates.
+func (c *Cookie) LimitReached() bool {
+	return c.limitReached
+}
+
+// Reports whether `Unsign()` should try keys against `decoder`, which
+// requires its parsed data and a decoder and algorithm the options allow.
+func (c *Cookie) shouldUnsignWith(decoder string, options *unsignOptions) bool {
+	if !c.hasParsedDataFor(decoder) || !options.allowsDecoder(decoder) {
+		return false
+	}
+
+	// There's no secret to find for tokens cloud providers sign with their
+	// private keys.
+	algorithm := c.signatureAlgorithm(decoder)
+	if asymmetricAlgorithms[algorithm] {
+		return false
+	}
+
+	// Rails may have derived its key with any of its digests.
+	if decoder == railsDecoder {
+		return len(railsAllowedDigests(options)) > 0
+	}
+
+	return options.allowsAlgorithm(algorithm)
+}
+
+// Returns the HMAC algorithm `decoder` found this cookie's signature to use,
+// or an empty string if it has none or didn't decode the cookie.
+func (c *Cookie) signatureAlgorithm(decoder string) string {
+	if !c.hasParsedDataFor(decoder) {
+		return ""
+	}
+
+	return c.parsedDataFor(decoder).fields().algorithm
+}
+
+// Finds which combination of Django `salts` and `kdfs` produced a Django
+// cookie's signature, given its `secret`, to reverse engineer a nonstandard
+// signer. The algorithm is the one the signature's length gives, since no
+// other could produce it. Each KDF is applied to the secret before Django
+// derives its key; a nil KDF uses the secret as is. Empty lists default to
+// the default salt (`""`) and no KDF. At most `maxConfigCombinations` are
+// tried. DetectConfig is not thread-safe.
+func (c *Cookie) DetectConfig(secret []byte, salts []string, kdfs []KDF) (Config, bool) {
+	if !c.hasParsedDataFor(djangoDecoder) {
+		return Config{}, false
+	}
+
+	if len(salts) == 0 {
+		salts = []string{""}
+	}
+
+	if len(kdfs) == 0 {
+		kdfs = []KDF{nil}
+	}
+
+	// We try the salts ourselves, one at a time. The salt an earlier
+	// `Unsign()` matched is an index into its own candidates, so it's put
+	// back along with them.
+	parsedData := c.parsedDataFor(djangoDecoder).(*djangoParsedData)
+	originalSalt, originalSalts := c.djangoSaltOverride, c.djangoSalts
+	originalMatch := atomic.LoadInt32(&parsedData.matchedSalt)
+	c.djangoSalts = nil
+
+	defer func() {
+		c.djangoSaltOverride = originalSalt
+		c.djangoSalts = originalSalts
+		atomic.StoreInt32(&parsedData.matchedSalt, originalMatch)
+	}()
+
+	algorithm := c.signatureAlgorithm(djangoDecoder)
+
+	tried := 0
+	for _, kdf := range kdfs {
+		key := secret
+		if kdf != nil {
+			key = kdf.Derive(secret, algorithm)
+		}
+
+		for _, salt := range salts {
+			if tried++; tried > maxConfigCombinations {
+				return Config{}, false
 			}
 
-			if shouldUseExpress && expressUnsign(c, entry) {
-				c.wasUnsignedBy(expressDecoder, entry)
+			c.djangoSaltOverride = salt
+
+			if djangoUnsign(c, key) {
+				return Config{Algorithm: algorithm, Salt: salt, KDF: kdf}, true
 			}
+		}
+	}
+
+	return Config{}, false
+}
+
+// Reports whether `secret` verifies this cookie's signature with `decoder`.
+func (c *Cookie) verifyWith(decoder string, secret []byte) bool {
+	if b, ok := builtinsByName[decoder]; ok {
+		return b.unsign != nil && c.hasParsedDataFor(decoder) && b.unsign(c, secret, &unsignPlan{}, nil)
+	}
+
+	if parsedData, ok := c.customParsedDataFor(decoder); ok {
+		return parsedData.decoder.Unsign(c, parsedData.decoder.Precompute(secret))
+	}
+
+	return false
+}
+
+// Runs `attempt` over every entry with a pool of `workers` goroutines (the
+// number of CPUs if it's zero), and waits for them to finish. Once a key is
+// found, the remaining entries are skipped.
+func (c *Cookie) bruteForce(entries [][]byte, workers uint64, attempt func(entry []byte, keys *derivedKeys)) {
+	i := 0
 
-			if shouldUseLaravel && laravelUnsign(c, entry) {
-				c.wasUnsignedBy(laravelDecoder, entry)
+	c.bruteForceFrom(func() ([]byte, bool) {
+		if i == len(entries) {
+			return nil, false
+		}
+
+		i++
+		return entries[i-1], true
+	}, workers, attempt)
+}
+
+// Like `bruteForce()`, but takes entries from `next` until it returns false,
+// so they needn't all be in memory.
+func (c *Cookie) bruteForceFrom(next func() ([]byte, bool), workers uint64, attempt func(entry []byte, keys *derivedKeys)) {
+	bruteForceUntil(next, workers, attempt, c.wasUnsigned)
+}
+
+// Runs `attempt` over the entries from `next` with a pool of `workers`
+// goroutines (the number of CPUs if it's zero) until they run out or `done`
+// reports there's nothing left to find. Each worker hands `attempt` its own
+// `derivedKeys`.
+func bruteForceUntil(next func() ([]byte, bool), workers uint64, attempt func(entry []byte, keys *derivedKeys), done func() bool) {
+	if workers == 0 {
+		workers = uint64(runtime.NumCPU())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	candidates := make(chan []byte, workers)
+
+	var wg sync.WaitGroup
+	for i := uint64(0); i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			// Each worker keeps what it derived from its last candidate.
+			keys := &derivedKeys{}
+
+			for entry := range candidates {
+				attempt(entry, keys)
+
+				if done() {
+					cancel()
+				}
 			}
-		}(entry)
+		}()
+	}
+
+feed:
+	for entry, ok := next(); ok; entry, ok = next() {
+		select {
+		case candidates <- entry:
+		case <-ctx.Done():
+			break feed
+		}
 	}
 
+	close(candidates)
 	wg.Wait()
-	return c.unsignedKey, c.wasUnsigned()
 }
 
-func (c *Cookie) Resign(data string) string {
+// Tries `key` against every decoder in `plan`. The `entry` is the wordlist
+// entry `key` came from, which differs from `key` when it was truncated.
+// What's derived from `key` is shared through `keys`, if it isn't nil, with
+// the other layouts and cookies it's tried on.
+func (c *Cookie) tryKey(plan unsignPlan, key []byte, entry []byte, keys *derivedKeys) {
+	c.progress.tried()
+	keys.use(key)
+
+	for _, b := range plan.builtins {
+		if b.unsign(c, key, &plan, keys) {
+			reported := key
+			if b.reportedKey != nil {
+				reported = b.reportedKey(key)
+			}
+
+			c.wasUnsignedBy(b.name, reported, entry)
+		}
+	}
+
+	for _, registered := range plan.custom {
+		if registered.decoder.Unsign(c, keys.precompute(registered, key)) {
+			c.wasUnsignedBy(registered.name, key, entry)
+		}
+	}
+}
+
+// Resigns the cookie with `data`, using the key discovered by `Unsign()`.
+func (c *Cookie) Resign(data string) (string, error) {
+	return c.ResignWith(data, ResignOptions{})
+}
+
+// Resigns the cookie with `data` like `Resign()`, but allows choosing the
+// algorithm. Signing with a weaker algorithm than the original is refused
+// unless `AllowDowngrade` is set, so that a cookie isn't weakened by mistake.
+func (c *Cookie) ResignWith(data string, options ResignOptions) (string, error) {
 	c.unsignedMutex.RLock()
 	defer c.unsignedMutex.RUnlock()
 
 	if len(c.unsignedBy) == 0 {
-		panic("cannot resign a cookie that was not unsigned")
+		return "", ErrNotUnsigned
+	}
+
+	algorithm, err := resignAlgorithm(c.signatureAlgorithm(c.unsignedBy), options)
+	if err != nil {
+		return "", err
+	}
+
+	key := c.unsignedKey
+	if options.Key != nil {
+		key = options.Key
+	}
+
+	if err := c.checkResignKey(c.unsignedBy, key); err != nil {
+		return "", err
+	}
+
+	if options.Reserialize {
+		if data, err = c.reserialize(c.unsignedBy, data); err != nil {
+			return "", err
+		}
+	}
+
+	return c.resignWith(c.unsignedBy, data, key, algorithm, options.Compression, options.Timestamp)
+}
+
+// Resigns the cookie with `data` like `ResignWith()`, but with a secret
+// that's already known rather than one `Unsign()` discovered. The secret is
+// checked first, so the new cookie is signed the way the original was
+// (e.g. with the Django salt that matched); if it doesn't verify the
+// cookie, this returns `ErrWrongSecret`, even if `Unsign()` already found
+// another.
+func (c *Cookie) ResignWithSecret(data string, secret []byte, options ResignOptions) (string, error) {
+	if success, _, decoder := c.Result(); success {
+		if !c.verifyWith(decoder, secret) {
+			return "", ErrWrongSecret
+		}
+	} else if !c.UnsignWithSecret(secret) {
+		return "", ErrWrongSecret
+	}
+
+	options.Key = secret
+	return c.ResignWith(data, options)
+}
+
+// Ensures `key` could sign a cookie for `decoder`. HMAC takes keys of any
+// length, so only an empty key is wrong there, but encrypted formats need a
+// key of exactly their cipher's size.
+func (c *Cookie) checkResignKey(decoder string, key []byte) error {
+	if len(key) == 0 {
+		return fmt.Errorf("%w: it is empty", ErrIncompatibleKey)
+	}
+
+	if parsedData, ok := c.parsedDataFor(decoder).(*laravelParsedData); ok {
+		cipher := laravelCipher(parsedData, c.unsignedKey)
+		if expected := laravelKeyLength[cipher]; len(key) != expected {
+			return fmt.Errorf("%w: %s needs a %d byte key, not %d", ErrIncompatibleKey, cipher, expected, len(key))
+		}
+	}
+
+	return nil
+}
+
+// Re-serializes edited JSON in `decoder`'s canonical form.
+func (c *Cookie) reserialize(decoder string, data string) (string, error) {
+	switch decoder {
+	case djangoDecoder:
+		return djangoSerializeJSON(c, data)
+	case flaskDecoder:
+		return flaskSerializeJSON(c, data)
+	default:
+		return "", ErrResignUnsupported
+	}
+}
+
+// Returns the components `Resign()` would use to resign the cookie with
+// `data`, without assembling them.
+func (c *Cookie) PreviewResign(data string) (ResignPreview, error) {
+	c.unsignedMutex.RLock()
+	defer c.unsignedMutex.RUnlock()
+
+	if len(c.unsignedBy) == 0 {
+		return ResignPreview{}, ErrNotUnsigned
 	}
 
 	switch c.unsignedBy {
 	case djangoDecoder:
-		return djangoResign(c, data, c.unsignedKey)
+		return djangoPreviewResign(c, data, c.unsignedKey, c.signatureAlgorithm(djangoDecoder), CompressionOriginal, time.Time{})
 	default:
-		return ""
+		return ResignPreview{}, ErrResignUnsupported
+	}
+}
+
+// Resigns `data` with `key` and `algorithm` using the given decoder;
+// `compression` only applies to decoders which support it, and `timestamp`
+// (unless it's zero) to those which sign one.
+func (c *Cookie) resignWith(decoder string, data string, key []byte, algorithm string, compression Compression, timestamp time.Time) (string, error) {
+	if b, ok := builtinsByName[decoder]; ok {
+		if b.resign == nil {
+			return "", ErrResignUnsupported
+		}
+
+		return b.resign(c, data, key, algorithm, compression, timestamp)
+	}
+
+	if parsedData, ok := c.customParsedDataFor(decoder); ok {
+		return parsedData.decoder.Resign(c, data, key)
 	}
+
+	return "", ErrResignUnsupported
+}
+
+// Resigns the cookie with `data` for frameworks that keep the signature in
+// a separate cookie, returning each cookie by name.
+func (c *Cookie) ResignCookies(data string) (map[string]string, error) {
+	return c.ResignCookiesWith(data, ResignOptions{})
+}
+
+// Resigns the cookie with `data` like `ResignCookies()`, but with the
+// algorithm and key `options` chooses, as `ResignWith()` allows; its other
+// options don't apply to these frameworks.
+func (c *Cookie) ResignCookiesWith(data string, options ResignOptions) (map[string]string, error) {
+	c.unsignedMutex.RLock()
+	defer c.unsignedMutex.RUnlock()
+
+	if len(c.unsignedBy) == 0 {
+		return nil, ErrNotUnsigned
+	}
+
+	if c.unsignedBy != expressDecoder {
+		return nil, ErrResignUnsupported
+	}
+
+	algorithm, err := resignAlgorithm(c.signatureAlgorithm(expressDecoder), options)
+	if err != nil {
+		return nil, err
+	}
+
+	key := c.unsignedKey
+	if options.Key != nil {
+		key = options.Key
+	}
+
+	if err := c.checkResignKey(expressDecoder, key); err != nil {
+		return nil, err
+	}
+
+	return expressResignCookies(c, data, key, algorithm)
 }
 
 // Returns debug information from decoders.
@@ -130,34 +752,58 @@ func (c *Cookie) String() (out string) {
 
 	out += "\n"
 
-	if val, ok := c.decodedBy[djangoDecoder]; ok {
-		out += "Decoder django reports:\n" + val.(*djangoParsedData).String() + "\n"
+	for _, b := range builtins {
+		if val, ok := c.decodedBy[b.name]; ok {
+			out += "Decoder " + b.name + " reports:\n" + val.String() + "\n"
+		}
 	}
 
-	if val, ok := c.decodedBy[flaskDecoder]; ok {
-		out += "Decoder flask reports:\n" + val.(*flaskParsedData).String() + "\n"
+	decoders, _ := customDecoders()
+	for _, registered := range decoders {
+		if val, ok := c.decodedBy[registered.name].(*customParsedData); ok {
+			out += "Decoder " + registered.name + " reports:\n" + val.String() + "\n"
+		}
 	}
 
-	if val, ok := c.decodedBy[jwtDecoder]; ok {
-		out += "Decoder jwt reports:\n" + val.(*jwtParsedData).String() + "\n"
-	}
+	return out + signatureEntropyReport(c.decodedBy)
+}
 
-	if val, ok := c.decodedBy[rackDecoder]; ok {
-		out += "Decoder rack reports:\n" + val.(*rackParsedData).String() + "\n"
+// Shows the entropy of each decoder's signature, so a misparse stands out.
+func signatureEntropyReport(decodedBy map[string]decoderData) (out string) {
+	decoders := make([]string, 0, len(decodedBy))
+	for decoder := range decodedBy {
+		decoders = append(decoders, decoder)
 	}
 
-	if val, ok := c.decodedBy[expressDecoder]; ok {
-		out += "Decoder express reports:\n" + val.(*expressParsedData).String() + "\n"
-	}
+	sort.Strings(decoders)
 
-	if val, ok := c.decodedBy[laravelDecoder]; ok {
-		out += "Decoder laravel reports:\n" + val.(*laravelParsedData).String() + "\n"
+	for _, decoder := range decoders {
+		if signature := decodedBy[decoder].fields().decodedSignature; len(signature) > 0 {
+			out += fmt.Sprintf("Signature entropy (%s): %.2f bits per byte, of at most %.2f for %d bytes\n", decoder, shannonEntropy(signature), maxShannonEntropy(len(signature)), len(signature))
+		}
 	}
 
 	return out
 }
 
-// Returns the key and decoder if the cookie was decoded.
+// Returns the separator each decoder that decoded this cookie split it on,
+// keyed by decoder name.
+func (c *Cookie) Separators() map[string]string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	separators := make(map[string]string)
+	for decoder := range c.decodedBy {
+		if b, ok := builtinsByName[decoder]; ok && b.separator != "" {
+			separators[decoder] = b.separator
+		}
+	}
+
+	return separators
+}
+
+// Returns the key and decoder (one of the `Decoder` constants) if the
+// cookie was unsigned.
 func (c *Cookie) Result() (success bool, key []byte, decoder string) {
 	c.unsignedMutex.RLock()
 	defer c.unsignedMutex.RUnlock()
@@ -169,23 +815,89 @@ func (c *Cookie) Result() (success bool, key []byte, decoder string) {
 	return true, c.unsignedKey, c.unsignedBy
 }
 
-func (c *Cookie) wasDecodedBy(decoder string, data interface{}) {
+// Returns the wordlist entry the key was found in, and whether the key is
+// only a truncated prefix of it (see `WithTruncatedSecrets()`).
+func (c *Cookie) KeySource() (entry []byte, truncated bool) {
+	c.unsignedMutex.RLock()
+	defer c.unsignedMutex.RUnlock()
+
+	// Laravel keys are found from their `.env` form, which isn't truncation.
+	full := c.transformSecret(c.unsignedEntry)
+	if c.unsignedBy == laravelDecoder {
+		full = laravelAppKey(full)
+	}
+
+	return c.unsignedEntry, len(c.unsignedKey) != len(full)
+}
+
+// Applies the `WithSecretTransform()` transform, if there is one.
+func (c *Cookie) transformSecret(secret []byte) []byte {
+	if c.secretTransform == nil {
+		return secret
+	}
+
+	return c.secretTransform(secret)
+}
+
+func (c *Cookie) wasDecodedBy(decoder string, data decoderData) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
 	c.decodedBy[decoder] = data
 }
 
-func (c *Cookie) wasUnsignedBy(decoder string, key []byte) {
-	c.unsignedMutex.Lock()
-	defer c.unsignedMutex.Unlock()
+// Records why `decoder` declined the cookie even though it recognized its
+// structure, so a cookie nothing decodes needn't fail silently.
+func (c *Cookie) diagnose(decoder string, format string, args ...interface{}) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.diagnostics = append(c.diagnostics, decoder+": "+fmt.Sprintf(format, args...))
+}
 
-	if len(c.unsignedBy) > 0 {
-		fmt.Println("Unusual circumstance of a Cookie being unsigned multiple times")
+// For decoders which tell the algorithm from the signature's length.
+func (c *Cookie) unknownSignatureLength(decoder string, length int) {
+	c.diagnose(decoder, "signature length %d matches no known algorithm; likely wrong encoding or truncated", length)
+}
+
+// For decoders which accept a signature in any encoding giving a length
+// they know: reports the length it decodes to, hex first, or that it isn't
+// in any encoding we know.
+func (c *Cookie) undecodedSignature(decoder string, signature string) {
+	if decoded, err := hex.DecodeString(signature); err == nil {
+		c.unknownSignatureLength(decoder, len(decoded))
+	} else if decoded, _, ok := c.decodeTolerant(signature, func([]byte) bool { return true }); ok {
+		c.unknownSignatureLength(decoder, len(decoded))
+	} else {
+		c.diagnose(decoder, "signature is not in any encoding we know")
 	}
+}
+
+// Returns why decoders which recognized the cookie's structure still
+// declined it after `Decode()`, e.g. because its signature was truncated.
+// They're most useful when nothing decoded the cookie, as a hint to try
+// another encoding.
+func (c *Cookie) Diagnostics() []string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return append([]string{}, c.diagnostics...)
+}
 
+func (c *Cookie) wasUnsignedBy(decoder string, key []byte, entry []byte) {
+	c.unsignedMutex.Lock()
+	wasAlreadyUnsigned := len(c.unsignedBy) > 0
 	c.unsignedBy = decoder
 	c.unsignedKey = key
+	c.unsignedEntry = entry
+	c.unsignedMutex.Unlock()
+
+	// We log without holding the lock, since sinks are allowed to block.
+	if wasAlreadyUnsigned {
+		c.log(LogEvent{Kind: LogWarning, Decoder: decoder, Message: "Unusual circumstance of a Cookie being unsigned multiple times"})
+	}
+
+	c.log(LogEvent{Kind: LogFound, Decoder: decoder, Key: key, Message: "Discovered the key for this cookie with the " + decoder + " decoder"})
 }
 
 func (c *Cookie) wasUnsigned() bool {
@@ -195,15 +907,13 @@ func (c *Cookie) wasUnsigned() bool {
 	return len(c.unsignedBy) > 0
 }
 
-func (c *Cookie) parsedDataFor(decoder string) interface{} {
+// Returns nil if `decoder` didn't decode the cookie, so callers which
+// type-assert the result must check `hasParsedDataFor()` first.
+func (c *Cookie) parsedDataFor(decoder string) decoderData {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
 
-	if val, ok := c.decodedBy[decoder]; ok {
-		return val
-	}
-
-	panic("We needed parsed data for " + decoder + " but did not have it.")
+	return c.decodedBy[decoder]
 }
 
 func (c *Cookie) hasParsedDataFor(decoder string) bool {
@@ -214,6 +924,26 @@ func (c *Cookie) hasParsedDataFor(decoder string) bool {
 	return exists
 }
 
+// Runs the decoders for the first of `valueMarkers` the cookie starts with,
+// returning false if it has none or they all fail.
+func (c *Cookie) decodeMarked() (success bool) {
+	for _, marker := range valueMarkers {
+		if !strings.HasPrefix(c.raw, marker.prefix) {
+			continue
+		}
+
+		for _, decode := range marker.decoders {
+			if decode(c) {
+				success = true
+			}
+		}
+
+		return success
+	}
+
+	return false
+}
+
 // If we couldn't initially decode this cookie, try unwrapping it from
 // URL-encoding and base64-encoding. This is not thread-safe.
 func (c *Cookie) unwrap() (success bool) {
@@ -0,0 +1,155 @@
+package monster
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+const (
+	encodingHex       = "hex"
+	encodingBase64URL = "base64url"
+	encodingBase64    = "base64"
+	encodingBase58    = "base58"
+	encodingOther     = "other"
+
+	// Reported when a cookie contains none of the known separators.
+	corpusNoSeparator = "none"
+)
+
+var (
+	// The separators we look for, in the order we prefer them. Multi-byte
+	// and rarer separators come first, since `.` appears in lots of formats
+	// that primarily split on something else.
+	corpusSeparators = []string{rackSeparator, expressSeparator, "|", djangoSeparator, jwtSeparator}
+)
+
+// Summarizes the structure of a set of cookies, which helps identify the
+// common format of cookies that no decoder understands.
+type CorpusReport struct {
+	// How many cookies were analyzed.
+	Total int
+
+	// How many cookies split on each separator, or "none".
+	Separators map[string]int
+
+	// How many cookies had each number of segments.
+	SegmentCounts map[int]int
+
+	// How many segments, across every cookie, were in each encoding.
+	SegmentEncodings map[string]int
+
+	// How many cookies had a final segment decoding to each byte length.
+	// Only cookies with more than one segment are counted.
+	SignatureLengths map[int]int
+}
+
+// Analyzes the separators, segment counts, segment encodings and signature
+// lengths across `raws`.
+func CorpusAnalysis(raws []string) CorpusReport {
+	report := CorpusReport{
+		Separators:       make(map[string]int),
+		SegmentCounts:    make(map[int]int),
+		SegmentEncodings: make(map[string]int),
+		SignatureLengths: make(map[int]int),
+	}
+
+	for _, raw := range raws {
+		report.Total++
+
+		separator := corpusSeparatorFor(raw)
+		report.Separators[separator]++
+
+		segments := []string{raw}
+		if separator != corpusNoSeparator {
+			segments = strings.Split(raw, separator)
+		}
+
+		report.SegmentCounts[len(segments)]++
+
+		for _, segment := range segments {
+			report.SegmentEncodings[classifyEncoding(segment)]++
+		}
+
+		if len(segments) > 1 {
+			if signature, ok := decodeSegment(segments[len(segments)-1]); ok {
+				report.SignatureLengths[len(signature)]++
+			}
+		}
+	}
+
+	return report
+}
+
+func corpusSeparatorFor(raw string) string {
+	for _, separator := range corpusSeparators {
+		if strings.Contains(raw, separator) {
+			return separator
+		}
+	}
+
+	return corpusNoSeparator
+}
+
+// Determines the most specific encoding a segment is valid in. Hex is
+// checked first since every hex string is also valid base64, and base58
+// last since most base58 strings are too.
+func classifyEncoding(segment string) string {
+	if len(segment) == 0 {
+		return encodingOther
+	}
+
+	if _, err := hex.DecodeString(segment); err == nil {
+		return encodingHex
+	}
+
+	if _, err := base64.RawURLEncoding.DecodeString(segment); err == nil {
+		return encodingBase64URL
+	}
+
+	if _, err := base64.URLEncoding.DecodeString(segment); err == nil {
+		return encodingBase64URL
+	}
+
+	if _, err := base64.StdEncoding.DecodeString(segment); err == nil {
+		return encodingBase64
+	}
+
+	if _, err := base64.RawStdEncoding.DecodeString(segment); err == nil {
+		return encodingBase64
+	}
+
+	if _, err := base58Decode(segment); err == nil {
+		return encodingBase58
+	}
+
+	return encodingOther
+}
+
+// Decodes a segment with whichever encoding `classifyEncoding` picks.
+func decodeSegment(segment string) ([]byte, bool) {
+	switch classifyEncoding(segment) {
+	case encodingHex:
+		decoded, err := hex.DecodeString(segment)
+		return decoded, err == nil
+	case encodingBase64URL:
+		if decoded, err := base64.RawURLEncoding.DecodeString(segment); err == nil {
+			return decoded, true
+		}
+
+		decoded, err := base64.URLEncoding.DecodeString(segment)
+		return decoded, err == nil
+	case encodingBase64:
+		if decoded, err := base64.StdEncoding.DecodeString(segment); err == nil {
+			return decoded, true
+		}
+
+		decoded, err := base64.RawStdEncoding.DecodeString(segment)
+		return decoded, err == nil
+	case encodingBase58:
+		decoded, err := base58Decode(segment)
+		return decoded, err == nil
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,102 @@
+package monster
+
+// Secrets that frameworks, their tutorials, templates and popular apps ship
+// with, which end up in production far more often than they should. Each
+// decoder also gets `commonDefaultSecrets`.
+var defaultSecrets = map[string][]string{
+	djangoDecoder: {
+		// cookiecutter-django's fallback when the environment doesn't set one.
+		"!!!SET DJANGO_SECRET_KEY!!!",
+		"django-insecure-change-me",
+		"django-insecure-secret-key",
+	},
+	flaskDecoder: {
+		// The Flask tutorial, its predecessor, and Flask Web Development.
+		"dev",
+		"development key",
+		"hard to guess string",
+
+		// The Flask Mega-Tutorial.
+		"you-will-never-guess",
+		"super secret key",
+		"secret key",
+
+		// Apache Superset's old and current defaults, and Airflow's.
+		"\x02\x01thisismyscretkey\x01\x02\\e\\y\\y\\h",
+		"CHANGE_ME_TO_A_COMPLEX_RANDOM_SECRET",
+		"temporary_key",
+	},
+	jwtDecoder: {
+		// jwt.io's example, and the README of node-jsonwebtoken.
+		"your-256-bit-secret",
+		"shhhhh",
+		"your-secret-key",
+		"jwt_secret",
+		"secretkey",
+	},
+	expressDecoder: {
+		// The express-session and cookie-session examples.
+		"keyboard cat",
+		"key1",
+		"key2",
+	},
+	connectDecoder: {
+		"keyboard cat",
+	},
+	rackDecoder: {
+		// The `Rack::Session::Cookie` example.
+		"change_me",
+	},
+	laravelDecoder: {
+		// The `APP_KEY` of Laravel 5's `.env.example`.
+		"SomeRandomString",
+	},
+	cakephpDecoder: {
+		// The `Security.salt` of CakePHP 2's `core.php`, and the placeholder
+		// CakePHP 3 and later ship until the installer replaces it.
+		"DYhG93b0qyJfIxfs2guVoUubWwvniR2G0FgaC9mi",
+		"__SALT__",
+	},
+	springDecoder: {
+		// Baeldung's remember-me tutorial, which most examples copy.
+		"uniqueAndSecret",
+	},
+	// Rails has none: `rails new` generates each app's `secret_key_base`,
+	// and development and test environments derive or generate their own
+	// per app, so no value is shared between apps.
+}
+
+// Tried for every decoder.
+var commonDefaultSecrets = []string{"changeme", "change_me", "secret", "password", "CHANGEME", "SECRET_KEY"}
+
+// Returns the embedded default secrets for `decoders` (e.g. `DecoderFlask`),
+// or for every decoder if none are given, without repeats. These are worth
+// trying before any wordlist, since a known default key is the most common
+// find.
+func DefaultWordlist(decoders ...string) *Wordlist {
+	if len(decoders) == 0 {
+		decoders = signingDecoders
+	}
+
+	var entries [][]byte
+	seen := make(map[string]bool)
+
+	add := func(secrets []string) {
+		for _, secret := range secrets {
+			if !seen[secret] {
+				seen[secret] = true
+				entries = append(entries, []byte(secret))
+			}
+		}
+	}
+
+	for _, decoder := range decoders {
+		add(defaultSecrets[decoder])
+	}
+
+	add(commonDefaultSecrets)
+
+	wl := NewWordlist()
+	wl.LoadFromArray(entries)
+	return wl
+}
@@ -0,0 +1,34 @@
+package monster
+
+import "testing"
+
+func TestDefaultWordlist(t *testing.T) {
+	counts := make(map[string]int)
+	for _, entry := range DefaultWordlist(DecoderFlask).Entries() {
+		counts[string(entry)]++
+	}
+
+	if counts["dev"] != 1 || counts["changeme"] != 1 || counts["keyboard cat"] != 0 {
+		t.Errorf("unexpected Flask defaults %v", counts)
+	}
+
+	counts = make(map[string]int)
+	for _, entry := range DefaultWordlist().Entries() {
+		counts[string(entry)]++
+	}
+
+	// Express and connect share this one.
+	if counts["keyboard cat"] != 1 || counts["dev"] != 1 {
+		t.Errorf("unexpected defaults %v", counts)
+	}
+
+	// Signed with the Flask tutorial's `dev`.
+	c := NewCookie("eyJ1c2VyIjoiYWRtaW4ifQ.ZVPxAA.U1VCv--TmKZfpC9P89KgvH3J8uQ")
+	if !c.Decode() {
+		t.Fatalf("could not decode the Flask cookie")
+	}
+
+	if key, success := c.Unsign(DefaultWordlist(DecoderFlask), 1); !success || string(key) != "dev" {
+		t.Errorf("did not find the default secret")
+	}
+}
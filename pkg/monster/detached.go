@@ -0,0 +1,319 @@
+package monster
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync/atomic"
+)
+
+type detachedParsedData struct {
+	data             string
+	signature        string
+	decodedSignature []byte
+	encoding         string
+	algorithm        string
+
+	// The query parameter the signature was in, for `value?sig=...` cookies.
+	trailerKey string
+
+	// Signatures after the first, for schemes which keep signing with old
+	// keys during a rotation, e.g. `sig=primary,previous`.
+	rotated []detachedSignature
+
+	// One more than the index of the signature a secret verified, set
+	// atomically since it's written while brute-forcing.
+	matched int32
+
+	// The canonical form of `data`, if it is JSON which isn't already
+	// canonical; see `WithCanonicalJSON()`.
+	canonicalData string
+
+	parsed bool
+}
+
+func (d *detachedParsedData) String() string {
+	if !d.parsed {
+		return "Unparsed data"
+	}
+
+	out := fmt.Sprintf("Data: %s\nSignature: %s\nEncoding: %s\nAlgorithm: %s\n", d.data, d.signature, d.encoding, d.algorithm)
+
+	if d.trailerKey != "" {
+		out += fmt.Sprintf("Signature parameter: %s\n", d.trailerKey)
+	}
+
+	for i, signature := range d.rotated {
+		out += fmt.Sprintf("Signature %d: %s (%s, %s)\n", i+2, signature.signature, signature.encoding, signature.algorithm)
+	}
+
+	if d.canonicalData != "" {
+		out += fmt.Sprintf("Canonical JSON: %s\n", d.canonicalData)
+	}
+
+	return out
+}
+
+func (d *detachedParsedData) serialized(c *Cookie) []byte {
+	return []byte(d.data)
+}
+
+func (d *detachedParsedData) fields() parsedFields {
+	return parsedFields{data: d.data, signature: d.signature, decodedSignature: d.decodedSignature, algorithm: d.algorithm}
+}
+
+func (d *detachedParsedData) clone() decoderData {
+	copied := *d
+	copied.matched = 0
+	return &copied
+}
+
+// One of several signatures on a detached cookie.
+type detachedSignature struct {
+	signature        string
+	decodedSignature []byte
+	encoding         string
+	algorithm        string
+}
+
+const (
+	detachedDecoder = "detached"
+
+	// Separates the signatures of a signature chain.
+	detachedSignatureSeparator = ","
+)
+
+var (
+	// The query parameters a `value?sig=...` trailer may carry the signature
+	// in, in order of preference.
+	detachedTrailerKeys = []string{"sig", "signature", "hmac", "mac"}
+)
+
+// Returns a new `Cookie` for schemes which send the signature separately
+// from the value, e.g. in an `X-Signature` header. The signature may be in
+// any encoding `decodeTolerant()` understands, and may be prefixed with its
+// algorithm like `sha256=...`.
+func NewDetachedCookie(value string, signature string) *Cookie {
+	c := NewCookie(value)
+	c.detachedSignature = signature
+	return c
+}
+
+func detachedDecode(c *Cookie) bool {
+	var parsedData detachedParsedData
+	parsedData.data = c.raw
+	parsedData.signature = c.detachedSignature
+
+	// Without a detached signature, the value may still carry one in a
+	// query-like trailer.
+	if parsedData.signature == "" {
+		data, key, signature, ok := splitSignatureTrailer(c.raw)
+		if !ok {
+			return false
+		}
+
+		parsedData.data = data
+		parsedData.trailerKey = key
+		parsedData.signature = signature
+	}
+
+	// The first signature is the primary; any after it are rotations.
+	signatures := strings.Split(parsedData.signature, detachedSignatureSeparator)
+	for i := range signatures {
+		signature := strings.TrimSpace(signatures[i])
+
+		decodedSignature, encoding, ok := c.decodeDetachedSignature(signature)
+		if !ok {
+			return false
+		}
+
+		if i == 0 {
+			parsedData.signature = signature
+			parsedData.encoding = encoding
+			parsedData.decodedSignature = decodedSignature
+			parsedData.algorithm = djangoAlgorithmLength[len(decodedSignature)]
+			continue
+		}
+
+		parsedData.rotated = append(parsedData.rotated, detachedSignature{
+			signature:        signature,
+			decodedSignature: decodedSignature,
+			encoding:         encoding,
+			algorithm:        djangoAlgorithmLength[len(decodedSignature)],
+		})
+	}
+
+	if canonical, ok := canonicalJSON([]byte(parsedData.data)); ok && string(canonical) != parsedData.data {
+		parsedData.canonicalData = string(canonical)
+	}
+
+	parsedData.parsed = true
+	c.wasDecodedBy(detachedDecoder, &parsedData)
+
+	return true
+}
+
+// Decodes one detached signature, returning the encoding it was in.
+func (c *Cookie) decodeDetachedSignature(signature string) ([]byte, string, bool) {
+	// Strip an algorithm prefix, but still let the length decide.
+	for algorithm := range algorithmDigestLength {
+		signature = strings.TrimPrefix(signature, algorithm+"=")
+	}
+
+	plausible := func(decoded []byte) bool {
+		_, ok := djangoAlgorithmLength[len(decoded)]
+		return ok
+	}
+
+	// Detached signatures are almost always hex, and a hex SHA256 signature
+	// is also valid base64 for a SHA384-sized one, so hex has to go first.
+	if decodedSignature, err := hex.DecodeString(signature); err == nil && plausible(decodedSignature) {
+		return decodedSignature, "hex", true
+	}
+
+	if decodedSignature, encoding, ok := c.decodeTolerant(signature, plausible); ok {
+		return decodedSignature, encoding, true
+	}
+
+	c.undecodedSignature(detachedDecoder, signature)
+	return nil, "", false
+}
+
+// Splits `value?sig=...` into the signed value, the parameter name, and the
+// signature. Other parameters in the trailer are ignored.
+func splitSignatureTrailer(raw string) (data string, key string, signature string, ok bool) {
+	index := strings.LastIndex(raw, "?")
+	if index < 1 {
+		return "", "", "", false
+	}
+
+	params, err := url.ParseQuery(raw[index+1:])
+	if err != nil {
+		return "", "", "", false
+	}
+
+	for _, key := range detachedTrailerKeys {
+		if signature := params.Get(key); signature != "" {
+			return raw[:index], key, signature, true
+		}
+	}
+
+	return "", "", "", false
+}
+
+func detachedUnsign(c *Cookie, secret []byte, keys detachedKeys) bool {
+	// The value itself is signed, with no key derivation.
+	parsedData := c.parsedDataFor(detachedDecoder).(*detachedParsedData)
+	return detachedVerify(parsedData, secret, []byte(parsedData.data), keys)
+}
+
+// Like `detachedUnsign()`, but derives the key with the plan's KDF, if it
+// has one, and also tries the canonical and named forms it asks for.
+func detachedUnsignPlanned(c *Cookie, secret []byte, plan *unsignPlan, keys *derivedKeys) bool {
+	shared := detachedKeys{keys: keys}
+	if plan.kdf != nil {
+		shared.derived = true
+		secret = plan.kdf.Derive(secret, c.signatureAlgorithm(detachedDecoder))
+	}
+
+	return detachedUnsign(c, secret, shared) ||
+		plan.canonicalJSON && detachedUnsignCanonical(c, secret, shared) ||
+		plan.cookieName != "" && detachedUnsignNamed(c, secret, plan.cookieName, shared)
+}
+
+// Like `detachedUnsign()`, but for apps which sign the canonical form of a
+// JSON value rather than the bytes they send.
+func detachedUnsignCanonical(c *Cookie, secret []byte, keys detachedKeys) bool {
+	parsedData := c.parsedDataFor(detachedDecoder).(*detachedParsedData)
+	if parsedData.canonicalData == "" {
+		return false
+	}
+
+	return detachedVerify(parsedData, secret, []byte(parsedData.canonicalData), keys)
+}
+
+// Like `detachedUnsign()`, but for schemes which sign the cookie's `name`
+// along with its value.
+func detachedUnsignNamed(c *Cookie, secret []byte, name string, keys detachedKeys) bool {
+	parsedData := c.parsedDataFor(detachedDecoder).(*detachedParsedData)
+
+	return detachedVerify(parsedData, secret, []byte(name+"="+parsedData.data), keys) ||
+		detachedVerify(parsedData, secret, []byte(name+parsedData.data), keys)
+}
+
+// Reports whether `secret` produced any of the cookie's signatures, and
+// records which one it was.
+func detachedVerify(parsedData *detachedParsedData, secret []byte, toBeSigned []byte, keys detachedKeys) bool {
+	if keys.verify(parsedData.algorithm, secret, toBeSigned, parsedData.decodedSignature) {
+		atomic.StoreInt32(&parsedData.matched, 1)
+		return true
+	}
+
+	for i, signature := range parsedData.rotated {
+		if keys.verify(signature.algorithm, secret, toBeSigned, signature.decodedSignature) {
+			atomic.StoreInt32(&parsedData.matched, int32(i+2))
+			return true
+		}
+	}
+
+	return false
+}
+
+// Shares the HMACs a detached cookie's signatures are checked with, whose
+// key is the secret itself unless the plan's KDF derived it.
+type detachedKeys struct {
+	keys    *derivedKeys
+	derived bool
+}
+
+func (k detachedKeys) verify(algorithm string, key []byte, toBeSigned []byte, signature []byte) bool {
+	derivation := secretKeyed(algorithm)
+	if k.derived {
+		derivation.decoder = detachedDecoder
+	}
+
+	return k.keys.verify(derivation, key, toBeSigned, signature)
+}
+
+// Returns which of a detached cookie's signatures the discovered key
+// verified, counting from zero for the primary, for signature chains.
+func (c *Cookie) MatchedSignature() (int, bool) {
+	if !c.wasUnsigned() || !c.hasParsedDataFor(detachedDecoder) {
+		return 0, false
+	}
+
+	parsedData := c.parsedDataFor(detachedDecoder).(*detachedParsedData)
+	matched := atomic.LoadInt32(&parsedData.matched)
+
+	return int(matched) - 1, matched > 0
+}
+
+// Returns `data` as canonical JSON: object keys sorted, no whitespace, and
+// numbers kept exactly as they were written.
+func canonicalJSON(data []byte) ([]byte, bool) {
+	if !json.Valid(data) {
+		return nil, false
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, false
+	}
+
+	// `json.Marshal` would escape HTML characters, which signers don't.
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+
+	if err := encoder.Encode(value); err != nil {
+		return nil, false
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), true
+}
@@ -2,18 +2,44 @@ package monster
 
 import (
 	"bytes"
+	"compress/zlib"
+	"crypto/hmac"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/iangcarroll/cookiemonster/pkg/base62"
+	"github.com/iangcarroll/cookiemonster/pkg/timestamp"
 )
 
 type djangoParsedData struct {
 	data             string
+	decodedData      []byte
+	serializer       string
 	timestamp        string
 	signature        string
 	decodedSignature []byte
 	algorithm        string
 
+	nestedJWTs []nestedJWT
+
+	// Plain `Signer` values have no timestamp, unlike `TimestampSigner`.
+	timestamped bool
+
+	// Which of `djangoSaltCandidates()` derived the key, counting from one,
+	// once a secret has verified the cookie. It is accessed atomically.
+	matchedSalt int32
+
+	// When the timestamp says the cookie was signed, if it parsed.
+	signedAt    time.Time
+	hasSignedAt bool
+
 	compressed bool
 	parsed     bool
 }
@@ -23,7 +49,49 @@ func (d *djangoParsedData) String() string {
 		return "Unparsed data"
 	}
 
-	return fmt.Sprintf("Compressed: %t\nData: %s\nTimestamp: %s\nSignature: %s\nAlgorithm: %s\n", d.compressed, d.data, d.timestamp, d.signature, d.algorithm)
+	return fmt.Sprintf("Compressed: %t\nData: %s\nSerializer: %s\nDecoded data: %s\nTimestamp: %s\nSeparator: %s\nSignature: %s\nAlgorithm: %s\n", d.compressed, d.data, d.serializer, d.displayDecodedData(), d.displayTimestamp(), djangoSeparator, d.signature, d.algorithm) + nestedJWTsString(d.nestedJWTs)
+}
+
+func (d *djangoParsedData) serialized(c *Cookie) []byte {
+	return d.decodedData
+}
+
+func (d *djangoParsedData) fields() parsedFields {
+	return parsedFields{data: d.data, timestamp: d.timestamp, signature: d.signature, decodedSignature: d.decodedSignature, algorithm: d.algorithm, compressed: d.compressed}
+}
+
+func (d *djangoParsedData) clone() decoderData {
+	copied := *d
+	copied.matchedSalt = 0
+	return &copied
+}
+
+func (d *djangoParsedData) displayTimestamp() string {
+	if !d.timestamped {
+		return "none (signed by Signer rather than TimestampSigner)"
+	}
+
+	if d.timestamp == "" {
+		return "none (empty)"
+	}
+
+	return displayTimestamp(d.timestamp)
+}
+
+// Shows the session in the most readable form its serializer allows.
+func (d *djangoParsedData) displayDecodedData() string {
+	switch d.serializer {
+	case serializerMsgpack:
+		if value, err := msgpackDecode(d.decodedData); err == nil {
+			if rendered, err := json.Marshal(value); err == nil {
+				return string(rendered)
+			}
+		}
+	case serializerUnknown:
+		return "\n" + hex.Dump(d.decodedData)
+	}
+
+	return displayBytes(d.decodedData)
 }
 
 const (
@@ -32,6 +100,9 @@ const (
 
 	djangoSeparator = `:`
 	djangoSalt      = `django.contrib.sessions.backends.signed_cookiessigner`
+
+	// The salt a plain `Signer()` uses when it isn't given one.
+	djangoSignerSalt = `django.core.signing.Signersigner`
 )
 
 var (
@@ -58,16 +129,26 @@ func djangoDecode(c *Cookie) bool {
 	}
 
 	// Break the cookie out into the session data, timestamp, and signature,
-	// in that order. Note that we assume the use of `TimestampSigner`.
+	// in that order. `TimestampSigner` values have all three, while plain
+	// `Signer` values are just the data and signature.
 	components := strings.Split(rawData, djangoSeparator)
-	if len(components) != 3 {
+	switch len(components) {
+	case 3:
+		parsedData.data = components[0]
+		parsedData.timestamp = components[1]
+		parsedData.signature = components[2]
+		parsedData.timestamped = true
+
+		// An unparseable timestamp is only shown raw; the signature still
+		// decides whether this is a Django cookie.
+		parsedData.signedAt, _, parsedData.hasSignedAt = timestamp.Parse(parsedData.timestamp)
+	case 2:
+		parsedData.data = components[0]
+		parsedData.signature = components[1]
+	default:
 		return false
 	}
 
-	parsedData.data = components[0]
-	parsedData.timestamp = components[1]
-	parsedData.signature = components[2]
-
 	// Django encodes the signature with URL-safe base64
 	// without padding, so we must use `RawURLEncoding`.
 	decodedSignature, err := base64.RawURLEncoding.DecodeString(parsedData.signature)
@@ -76,10 +157,26 @@ func djangoDecode(c *Cookie) bool {
 	}
 
 	// Determine the algorithm from the digest length, or give up if we can't
-	// figure it out.
+	// figure it out. We only say why if the body decodes, since otherwise
+	// this is unlikely to be a Django cookie at all.
 	if alg, ok := djangoAlgorithmLength[len(decodedSignature)]; ok {
 		parsedData.algorithm = alg
 	} else {
+		if _, ok := flaskDecodeData(parsedData.data, parsedData.compressed); ok {
+			c.unknownSignatureLength(djangoDecoder, len(decodedSignature))
+		}
+
+		return false
+	}
+
+	// Django uses the same encoding for the data, and compresses it with
+	// zlib just like itsdangerous. A compressed body that doesn't inflate
+	// isn't a Django cookie, but we tolerate other data we can't decode.
+	if decodedData, ok := flaskDecodeData(parsedData.data, parsedData.compressed); ok {
+		parsedData.decodedData = decodedData
+		parsedData.serializer = sessionSerializer(decodedData)
+		parsedData.nestedJWTs = findNestedJWTs(decodedData)
+	} else if parsedData.compressed {
 		return false
 	}
 
@@ -91,89 +188,237 @@ func djangoDecode(c *Cookie) bool {
 }
 
 func djangoUnsign(c *Cookie, secret []byte) bool {
+	return djangoUnsignWith(c, secret, nil, nil)
+}
+
+// Like `djangoUnsign()`, but with the salts `prepareUnsign()` worked out
+// for the run, and sharing the keys derived from `secret` through `keys`.
+func djangoUnsignPlanned(c *Cookie, secret []byte, plan *unsignPlan, keys *derivedKeys) bool {
+	return djangoUnsignWith(c, secret, plan.djangoSalts, keys)
+}
+
+// Tries `secret` with each of `salts`, or of `djangoSaltCandidates()` if
+// it's nil, reusing any keys already in `keys` that were derived from
+// `secret` for another cookie.
+func djangoUnsignWith(c *Cookie, secret []byte, salts []string, keys *derivedKeys) bool {
 	// We need to extract `toBeSigned` to prepare what we'll be signing.
 	parsedData := c.parsedDataFor(djangoDecoder).(*djangoParsedData)
-	toBeSigned := parsedData.data + djangoSeparator + parsedData.timestamp
+	toBeSigned := parsedData.signingInput(parsedData.data)
 
-	switch parsedData.algorithm {
-	case "sha1":
-		// Django forces us to derive a key for HMAC-ing.
-		derivedKey := sha1Digest(djangoSalt + string(secret))
+	// Django signs compressed data along with its leading dot.
+	if parsedData.compressed {
+		toBeSigned = "." + toBeSigned
+	}
 
-		// Derive the correct signature, if this was the correct secret key.
-		computedSignature := sha1HMAC(derivedKey, []byte(toBeSigned))
+	algorithm, ok := verifyingAlgorithm(parsedData.algorithm)
+	if !ok {
+		return false
+	}
 
-		// Compare this signature to the one in the `Cookie`.
-		return bytes.Compare(parsedData.decodedSignature, computedSignature) == 0
-	case "sha256":
+	if salts == nil {
+		salts = c.djangoSaltCandidates(parsedData)
+	}
+
+	for i, salt := range salts {
 		// Django forces us to derive a key for HMAC-ing.
-		derivedKey := sha256Digest(djangoSalt + string(secret))
+		mac := keys.mac(derivation{djangoDecoder, parsedData.algorithm, salt, c.djangoSaltOrder}, algorithm.new, func() []byte {
+			return saltedDigest(algorithm, salt, secret, c.djangoSaltOrder)
+		})
+
+		// Derive the correct signature, if this was the correct secret key,
+		// and compare it to the one in the `Cookie`.
+		mac.Write([]byte(toBeSigned))
+		if hmac.Equal(parsedData.decodedSignature, mac.Sum(nil)) {
+			atomic.StoreInt32(&parsedData.matchedSalt, int32(i+1))
+			return true
+		}
+	}
 
-		// Derive the correct signature, if this was the correct secret key.
-		computedSignature := sha256HMAC(derivedKey, []byte(toBeSigned))
+	return false
+}
 
-		// Compare this signature to the one in the `Cookie`.
-		return bytes.Compare(parsedData.decodedSignature, computedSignature) == 0
-	case "sha384":
-		// Django forces us to derive a key for HMAC-ing.
-		derivedKey := sha384Digest(djangoSalt + string(secret))
+func djangoResign(c *Cookie, data string, secret []byte, algorithm string, compression Compression, timestamp time.Time) (string, error) {
+	preview, err := djangoPreviewResign(c, data, secret, algorithm, compression, timestamp)
+	if err != nil {
+		return "", err
+	}
 
-		// Derive the correct signature, if this was the correct secret key.
-		computedSignature := sha384HMAC(derivedKey, []byte(toBeSigned))
+	return preview.ToBeSigned + djangoSeparator + preview.Signature, nil
+}
 
-		// Compare this signature to the one in the `Cookie`.
-		return bytes.Compare(parsedData.decodedSignature, computedSignature) == 0
-	case "sha512":
-		// Django forces us to derive a key for HMAC-ing.
-		derivedKey := sha512Digest(djangoSalt + string(secret))
+func djangoPreviewResign(c *Cookie, data string, secret []byte, algorithm string, compression Compression, signedAt time.Time) (ResignPreview, error) {
+	// We need to extract `toBeSigned` to prepare what we'll be signing.
+	parsedData := c.parsedDataFor(djangoDecoder).(*djangoParsedData)
 
-		// Derive the correct signature, if this was the correct secret key.
-		computedSignature := sha512HMAC(derivedKey, []byte(toBeSigned))
+	// We need to assemble the TBS string with new data. By default, we match
+	// the original cookie's compression, since the server may expect it.
+	// Plain `Signer` values are often raw strings rather than base64, and
+	// we keep them that way.
+	var encodedData string
+	if !parsedData.timestamped && parsedData.decodedData == nil {
+		encodedData = data
+	} else if compression.enabled(parsedData.compressed) {
+		compressed, err := djangoCompress([]byte(data))
+		if err != nil {
+			return ResignPreview{}, err
+		}
+
+		encodedData = "." + base64.RawURLEncoding.EncodeToString(compressed)
+	} else {
+		encodedData = base64.RawURLEncoding.EncodeToString([]byte(data))
+	}
+
+	timestamp := parsedData.timestamp
+	if !signedAt.IsZero() {
+		timestamp = base62.Encode(uint64(signedAt.Unix()))
+	}
+
+	toBeSigned := parsedData.signingInputAt(encodedData, timestamp)
+
+	hashAlgorithm, ok := hashAlgorithms[algorithm]
+	if !ok {
+		return ResignPreview{}, ErrUnknownAlgorithm
+	}
+
+	// Django forces us to derive a key for HMAC-ing.
+	derivedKey := saltedDigest(hashAlgorithm, c.djangoSalt(parsedData), secret, c.djangoSaltOrder)
+	computedSignature := hashAlgorithm.hmac(derivedKey, []byte(toBeSigned))
+
+	if err := checkSignatureLength(algorithm, computedSignature); err != nil {
+		return ResignPreview{}, err
+	}
+
+	return ResignPreview{
+		Decoder:     djangoDecoder,
+		Algorithm:   algorithm,
+		Data:        data,
+		EncodedData: encodedData,
+		Timestamp:   timestamp,
+		ToBeSigned:  toBeSigned,
+		Signature:   base64.RawURLEncoding.EncodeToString(computedSignature),
+	}, nil
+}
+
+// Sets the salt Django derives this cookie's signing key with, for cookies
+// other than sessions (e.g. the messages framework, or a custom `Signer`).
+// It's used verbatim, so include the `signer` suffix Django appends, as in
+// `django.contrib.messagessigner`. The default is the session salt, or
+// `Signer`'s own default for values without a timestamp.
+func (c *Cookie) SetDjangoSalt(salt string) {
+	c.djangoSaltOverride = salt
+}
+
+// Sets whether the salt goes before the secret when deriving this cookie's
+// signing key, as Django does by default, or after it, as some custom
+// signers do.
+func (c *Cookie) SetDjangoSaltOrder(order SaltOrder) {
+	c.djangoSaltOrder = order
+}
+
+// Returns the salt Django derived the key with: the one that matched, once
+// the cookie is unsigned, or the usual one until then.
+func (c *Cookie) djangoSalt(parsedData *djangoParsedData) string {
+	salts := c.djangoSaltCandidates(parsedData)
+	if matched := int(atomic.LoadInt32(&parsedData.matchedSalt)); matched > 0 && matched <= len(salts) {
+		return salts[matched-1]
+	}
+
+	return salts[0]
+}
+
+// Returns the salts to try: the usual one, then those of `WithDjangoSalts()`.
+func (c *Cookie) djangoSaltCandidates(parsedData *djangoParsedData) []string {
+	return append([]string{c.defaultDjangoSalt(parsedData)}, c.djangoSalts...)
+}
+
+// Reports the salt the Django key was derived with, once the cookie is
+// unsigned, which may be one given to `WithDjangoSalts()`.
+func (c *Cookie) DjangoSalt() (string, bool) {
+	if success, _, decoder := c.Result(); !success || decoder != djangoDecoder {
+		return "", false
+	}
 
-		// Compare this signature to the one in the `Cookie`.
-		return bytes.Compare(parsedData.decodedSignature, computedSignature) == 0
+	return c.djangoSalt(c.parsedDataFor(djangoDecoder).(*djangoParsedData)), true
+}
+
+func (c *Cookie) defaultDjangoSalt(parsedData *djangoParsedData) string {
+	switch {
+	case c.djangoSaltOverride != "":
+		return c.djangoSaltOverride
+	case !parsedData.timestamped:
+		return djangoSignerSalt
 	default:
-		panic("unknown algorithm")
+		return djangoSalt
 	}
 }
 
-func djangoResign(c *Cookie, data string, secret []byte) string {
-	// We need to extract `toBeSigned` to prepare what we'll be signing.
+// Returns what Django signs for `data`: the data and, for `TimestampSigner`
+// values, the timestamp.
+func (d *djangoParsedData) signingInput(data string) string {
+	return d.signingInputAt(data, d.timestamp)
+}
+
+// Like `signingInput()`, but with a new `timestamp` in place of the
+// original's.
+func (d *djangoParsedData) signingInputAt(data string, timestamp string) string {
+	if !d.timestamped {
+		return data
+	}
+
+	return data + djangoSeparator + timestamp
+}
+
+// Re-serializes edited JSON the way Django's `JSONSerializer` does, with
+// `json.dumps(separators=(",", ":"))`: compact, and with non-ASCII
+// characters escaped, since `ensure_ascii` is on by default.
+func djangoSerializeJSON(c *Cookie, data string) (string, error) {
 	parsedData := c.parsedDataFor(djangoDecoder).(*djangoParsedData)
 
-	// We need to assemble the TBS string with new data.
-	toBeSigned := base64.RawURLEncoding.EncodeToString([]byte(data)) + djangoSeparator + parsedData.timestamp
+	// We can't tell the serializer of data we couldn't decode, so allow it.
+	if parsedData.serializer != "" && parsedData.serializer != serializerJSON {
+		return "", ErrNotJSONSession
+	}
 
-	switch parsedData.algorithm {
-	case "sha1":
-		// Django forces us to derive a key for HMAC-ing.
-		derivedKey := sha1Digest(djangoSalt + string(secret))
+	return pythonJSONDumps(data)
+}
 
-		// Derive the correct signature, if this was the correct secret key.
-		computedSignature := sha1HMAC(derivedKey, []byte(toBeSigned))
-		return toBeSigned + djangoSeparator + base64.RawURLEncoding.EncodeToString(computedSignature)
-	case "sha256":
-		// Django forces us to derive a key for HMAC-ing.
-		derivedKey := sha256Digest(djangoSalt + string(secret))
+// Reformats JSON as Python's `json.dumps(separators=(",", ":"))` would.
+func pythonJSONDumps(data string) (string, error) {
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, []byte(data)); err != nil {
+		return "", err
+	}
 
-		// Derive the correct signature, if this was the correct secret key.
-		computedSignature := sha256HMAC(derivedKey, []byte(toBeSigned))
-		return toBeSigned + djangoSeparator + base64.RawURLEncoding.EncodeToString(computedSignature)
-	case "sha384":
-		// Django forces us to derive a key for HMAC-ing.
-		derivedKey := sha384Digest(djangoSalt + string(secret))
+	// Valid JSON only has non-ASCII characters inside strings, so we can
+	// escape them wherever they are.
+	var out strings.Builder
+	for _, r := range compacted.String() {
+		switch {
+		case r < utf8.RuneSelf:
+			out.WriteRune(r)
+		case r > 0xffff:
+			r1, r2 := utf16.EncodeRune(r)
+			fmt.Fprintf(&out, "\\u%04x\\u%04x", r1, r2)
+		default:
+			fmt.Fprintf(&out, "\\u%04x", r)
+		}
+	}
 
-		// Derive the correct signature, if this was the correct secret key.
-		computedSignature := sha384HMAC(derivedKey, []byte(toBeSigned))
-		return toBeSigned + djangoSeparator + base64.RawURLEncoding.EncodeToString(computedSignature)
-	case "sha512":
-		// Django forces us to derive a key for HMAC-ing.
-		derivedKey := sha512Digest(djangoSalt + string(secret))
+	return out.String(), nil
+}
 
-		// Derive the correct signature, if this was the correct secret key.
-		computedSignature := sha512HMAC(derivedKey, []byte(toBeSigned))
-		return toBeSigned + djangoSeparator + base64.RawURLEncoding.EncodeToString(computedSignature)
-	default:
-		panic("unknown algorithm")
+// Compresses data the way Django does, with zlib.
+func djangoCompress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	writer := zlib.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
 	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
 }
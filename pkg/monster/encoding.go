@@ -0,0 +1,120 @@
+package monster
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+const (
+	// The Bitcoin alphabet, which omits characters that are easy to confuse.
+	base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+	// What some broken encoders replace base64's `=` padding with.
+	defaultPaddingChars = "~."
+)
+
+type tolerantEncoding struct {
+	name   string
+	decode func(string) ([]byte, error)
+}
+
+var (
+	errInvalidBase58 = errors.New("invalid base58 character")
+
+	// The encodings `decodeTolerant()` tries, in priority order. URL-safe
+	// base64 is the most common in cookies, and hex and base58 are tried last
+	// since most of their strings are also valid base64.
+	tolerantEncodings = []tolerantEncoding{
+		{"base64url-raw", base64.RawURLEncoding.DecodeString},
+		{"base64url", base64.URLEncoding.DecodeString},
+		{"base64-raw", base64.RawStdEncoding.DecodeString},
+		{"base64", base64.StdEncoding.DecodeString},
+		{"hex", hex.DecodeString},
+		{"base58", base58Decode},
+	}
+)
+
+// Decodes `s` with the first encoding in `tolerantEncodings` that both
+// succeeds and produces a result `plausible` accepts, and reports which
+// encoding that was. This keeps tolerant decoding predictable when a value
+// is valid in several encodings.
+func decodeTolerant(s string, plausible func([]byte) bool) (decoded []byte, encoding string, ok bool) {
+	for _, candidate := range tolerantEncodings {
+		if decoded, err := candidate.decode(s); err == nil && plausible(decoded) {
+			return decoded, candidate.name, true
+		}
+	}
+
+	return nil, "", false
+}
+
+// Like `decodeTolerant()`, but if `s` ends in one of the cookie's padding
+// characters (see `SetPaddingChars()`), we also try it with those mapped
+// back to `=`.
+func (c *Cookie) decodeTolerant(s string, plausible func([]byte) bool) (decoded []byte, encoding string, ok bool) {
+	if decoded, encoding, ok = decodeTolerant(s, plausible); ok {
+		return decoded, encoding, ok
+	}
+
+	paddingChars := c.paddingChars
+	if paddingChars == "" {
+		paddingChars = defaultPaddingChars
+	}
+
+	for _, padding := range paddingChars {
+		if trimmed := strings.TrimRight(s, string(padding)); trimmed != s {
+			normalized := trimmed + strings.Repeat("=", len(s)-len(trimmed))
+
+			if decoded, encoding, ok = decodeTolerant(normalized, plausible); ok {
+				return decoded, encoding, ok
+			}
+		}
+	}
+
+	return nil, "", false
+}
+
+// Sets the characters which may stand in for base64's `=` padding in this
+// cookie's values; the default is `~` and `.`. It must be called before
+// `Decode()`.
+func (c *Cookie) SetPaddingChars(chars string) {
+	c.paddingChars = chars
+}
+
+// Decodes a base58 string, where each leading `1` is a leading zero byte.
+func base58Decode(s string) ([]byte, error) {
+	var decoded []byte
+
+	for _, r := range s {
+		digit := strings.IndexRune(base58Alphabet, r)
+		if digit < 0 {
+			return nil, errInvalidBase58
+		}
+
+		// Multiply the little-endian result so far by 58 and add the digit.
+		carry := digit
+		for i := range decoded {
+			carry += int(decoded[i]) * 58
+			decoded[i] = byte(carry)
+			carry >>= 8
+		}
+
+		for carry > 0 {
+			decoded = append(decoded, byte(carry))
+			carry >>= 8
+		}
+	}
+
+	for i := 0; i < len(s) && s[i] == base58Alphabet[0]; i++ {
+		decoded = append(decoded, 0)
+	}
+
+	// Flip the result to big-endian.
+	for i, j := 0, len(decoded)-1; i < j; i, j = i+1, j-1 {
+		decoded[i], decoded[j] = decoded[j], decoded[i]
+	}
+
+	return decoded, nil
+}
@@ -0,0 +1,77 @@
+package monster
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecodeTolerantPriority(t *testing.T) {
+	// The same express-session signature, in every encoding we accept.
+	vectors := map[string]string{
+		"v0zdThIZPjhrseFVs3pF8HpwpWVH1uo_XpEGyEDfKrI":                      "base64url-raw",
+		"v0zdThIZPjhrseFVs3pF8HpwpWVH1uo_XpEGyEDfKrI=":                     "base64url",
+		"v0zdThIZPjhrseFVs3pF8HpwpWVH1uo/XpEGyEDfKrI":                      "base64-raw",
+		"v0zdThIZPjhrseFVs3pF8HpwpWVH1uo/XpEGyEDfKrI=":                     "base64",
+		"bf4cdd4e12193e386bb1e155b37a45f07a70a56547d6ea3f5e9106c840df2ab2": "hex",
+		"DskrH4TVM3T21NmxLsTnLudmoCSCLQ7LTmeSceCJ7dXw":                     "base58",
+	}
+
+	wl := NewWordlist()
+	if err := wl.LoadFromArray([][]byte{[]byte("keyboard cat")}); err != nil {
+		t.Errorf("could not LoadFromArray")
+	}
+
+	for signature, expected := range vectors {
+		c := NewCookie("s:Ws4JzQSJbQ4ZBqDKoJ5xhrilD6Z2Wyso." + signature)
+		if !c.Decode() || !c.hasParsedDataFor(connectDecoder) {
+			t.Errorf("cannot decode connect cookie with %s signature", expected)
+			continue
+		}
+
+		if encoding := c.parsedDataFor(connectDecoder).(*connectParsedData).encoding; encoding != expected {
+			t.Errorf("expected %s encoding, got %s", expected, encoding)
+		}
+
+		if _, success := c.Unsign(wl, 100); !success {
+			t.Errorf("could not unsign a %s signature", expected)
+		}
+	}
+}
+
+func TestBase58Decode(t *testing.T) {
+	decoded, err := base58Decode("115T")
+	if err != nil || !bytes.Equal(decoded, []byte{0, 0, 1, 2}) {
+		t.Errorf("unexpected base58 decoding %v: %v", decoded, err)
+	}
+
+	// Zero, capital O, capital I and lowercase L are not in the alphabet.
+	for _, invalid := range []string{"10", "1O", "1I", "1l"} {
+		if _, err := base58Decode(invalid); err == nil {
+			t.Errorf("decoded invalid base58 %q", invalid)
+		}
+	}
+}
+
+func TestDecodeTolerantPadding(t *testing.T) {
+	wl := NewWordlist()
+	if err := wl.LoadFromArray([][]byte{[]byte("changeme")}); err != nil {
+		t.Errorf("could not LoadFromArray")
+	}
+
+	// A base64 HMAC-SHA256 of "user=42" whose `=` padding became `~`.
+	c := NewDetachedCookie("user=42", "j7YrpL98v1CKAZrfmxYtNVskk3J4ge7ukCbPlob+fEI~")
+	if !c.Decode() {
+		t.Fatalf("could not decode a signature with `~` padding")
+	}
+
+	if _, success := c.Unsign(wl, 100); !success {
+		t.Errorf("could not unsign a signature with `~` padding")
+	}
+
+	custom := NewDetachedCookie("user=42", "j7YrpL98v1CKAZrfmxYtNVskk3J4ge7ukCbPlob+fEI*")
+	custom.SetPaddingChars("*")
+
+	if !custom.Decode() {
+		t.Errorf("could not decode a signature with custom padding")
+	}
+}
@@ -0,0 +1,134 @@
+package monster
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+type envelopeParsedData struct {
+	data             string
+	payload          []byte
+	decodedPayload   map[string]interface{}
+	decodedSignature []byte
+	algorithm        string
+	encoding         string
+
+	parsed bool
+}
+
+func (d *envelopeParsedData) String() string {
+	if !d.parsed {
+		return "Unparsed data"
+	}
+
+	decoded, err := json.Marshal(d.decodedPayload)
+	if err != nil {
+		decoded = []byte(fmt.Sprint(d.decodedPayload))
+	}
+
+	return fmt.Sprintf("Data: %s\nEncoding: %s\nDecoded payload: %s\nSignature: %x\nAlgorithm: %s\n", d.data, d.encoding, decoded, d.decodedSignature, d.algorithm)
+}
+
+func (d *envelopeParsedData) serialized(c *Cookie) []byte {
+	if decoded, err := json.Marshal(d.decodedPayload); err == nil {
+		return decoded
+	}
+
+	return nil
+}
+
+func (d *envelopeParsedData) fields() parsedFields {
+	return parsedFields{data: d.data, signature: hex.EncodeToString(d.decodedSignature), decodedSignature: d.decodedSignature, algorithm: d.algorithm}
+}
+
+func (d *envelopeParsedData) clone() decoderData {
+	copied := *d
+	return &copied
+}
+
+const (
+	envelopeDecoder   = "envelope"
+	envelopeMinLength = 30
+
+	// The MessagePack `bin 8`, `bin 16` and `bin 32` tags.
+	msgpackBinFirstTag = 0xc4
+	msgpackBinLastTag  = 0xc6
+)
+
+var (
+	envelopeAlgorithmLength = map[int]string{
+		20: "sha1",
+		32: "sha256",
+		48: "sha384",
+		64: "sha512",
+	}
+)
+
+// Splits a binary envelope into its MessagePack map, the exact bytes that
+// encoded it, and the HMAC which follows it as a MessagePack `bin`. Since
+// every MessagePack value carries its own length, the two need no separator.
+func envelopeSplit(envelope []byte) (payload []byte, decodedPayload map[string]interface{}, signature []byte, ok bool) {
+	value, n, err := msgpackDecodePrefix(envelope)
+	if err != nil {
+		return nil, nil, nil, false
+	}
+
+	decodedPayload, ok = value.(map[string]interface{})
+	if !ok || n >= len(envelope) {
+		return nil, nil, nil, false
+	}
+
+	// Extensions also decode to bytes, so we check the tag ourselves.
+	if tag := envelope[n]; tag < msgpackBinFirstTag || tag > msgpackBinLastTag {
+		return nil, nil, nil, false
+	}
+
+	trailer, err := msgpackDecode(envelope[n:])
+	if err != nil {
+		return nil, nil, nil, false
+	}
+
+	signature = trailer.([]byte)
+	if _, ok := envelopeAlgorithmLength[len(signature)]; !ok {
+		return nil, nil, nil, false
+	}
+
+	return envelope[:n], decodedPayload, signature, true
+}
+
+// Recognizes base64-encoded envelopes holding a MessagePack map followed by
+// an HMAC of the map's bytes, as some frameworks pack their sessions.
+func envelopeDecode(c *Cookie) bool {
+	if len(c.raw) < envelopeMinLength {
+		return false
+	}
+
+	var parsedData envelopeParsedData
+
+	envelope, encoding, ok := c.decodeTolerant(c.raw, func(decoded []byte) bool {
+		_, _, _, ok := envelopeSplit(decoded)
+		return ok
+	})
+
+	if !ok {
+		return false
+	}
+
+	parsedData.payload, parsedData.decodedPayload, parsedData.decodedSignature, _ = envelopeSplit(envelope)
+	parsedData.algorithm = envelopeAlgorithmLength[len(parsedData.decodedSignature)]
+	parsedData.data = c.raw
+	parsedData.encoding = encoding
+	parsedData.parsed = true
+	c.wasDecodedBy(envelopeDecoder, &parsedData)
+
+	return true
+}
+
+func envelopeUnsign(c *Cookie, secret []byte, keys *derivedKeys) bool {
+	parsedData := c.parsedDataFor(envelopeDecoder).(*envelopeParsedData)
+
+	// Derive the correct signature, if this was the correct secret key, and
+	// compare it to the one in the envelope.
+	return keys.verify(secretKeyed(parsedData.algorithm), secret, parsedData.payload, parsedData.decodedSignature)
+}
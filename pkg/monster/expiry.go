@@ -0,0 +1,132 @@
+package monster
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"github.com/iangcarroll/cookiemonster/pkg/timestamp"
+)
+
+// Returns the `exp` field embedded in the cookie's signed data, for signers
+// which put the expiry inside the payload rather than alongside it, or the
+// expiry a Spring Security remember-me cookie is signed with. It may be Unix
+// seconds or milliseconds, or a string in any format `timestamp.Parse()`
+// accepts.
+func (c *Cookie) EmbeddedExpiry() (expiry time.Time, ok bool) {
+	// Spring Security's remember-me cookies have nothing but their expiry.
+	if expiry, ok := c.springExpiry(); ok {
+		return expiry, true
+	}
+
+	// Once unsigned, we know which decoder's payload is the signed one.
+	var payload []byte
+	if success, _, decoder := c.Result(); success {
+		payload, ok = c.Payload(decoder)
+	} else {
+		payload, ok = c.sessionPayload()
+	}
+
+	if !ok {
+		return time.Time{}, false
+	}
+
+	var session struct {
+		Expiry interface{} `json:"exp"`
+	}
+
+	if err := json.Unmarshal(payload, &session); err != nil {
+		return time.Time{}, false
+	}
+
+	switch exp := session.Expiry.(type) {
+	case float64:
+		return timestamp.FromUnix(exp)
+	case string:
+		if expiry, _, ok := timestamp.Parse(exp); ok {
+			return expiry, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// Reports whether the cookie is valid at `now`: it must have been unsigned,
+// and if its signed data embeds an `exp`, that must not have passed.
+func (c *Cookie) ValidAt(now time.Time) bool {
+	if !c.wasUnsigned() {
+		return false
+	}
+
+	if expiry, ok := c.EmbeddedExpiry(); ok {
+		return now.Before(expiry)
+	}
+
+	return true
+}
+
+// Like `ValidAt()`, as of now.
+func (c *Cookie) Valid() bool {
+	return c.ValidAt(now())
+}
+
+// Returns when the cookie was signed, for decoders which timestamp it.
+func (c *Cookie) SignedAt() (signedAt time.Time, ok bool) {
+	if c.hasParsedDataFor(djangoDecoder) {
+		parsedData := c.parsedDataFor(djangoDecoder).(*djangoParsedData)
+		if parsedData.hasSignedAt {
+			return parsedData.signedAt, true
+		}
+	}
+
+	if c.hasParsedDataFor(flaskDecoder) {
+		parsedData := c.parsedDataFor(flaskDecoder).(*flaskParsedData)
+		if signedAt, ok = flaskTimestamp(parsedData.timestamp); ok {
+			return signedAt, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// Reports whether a server enforcing `maxAge` (e.g. Django's
+// `SESSION_COOKIE_AGE`) would have expired the cookie by now, either
+// because it was signed too long ago or because its embedded `exp` passed.
+func (c *Cookie) Expired(maxAge time.Duration) bool {
+	current := now()
+
+	if signedAt, ok := c.SignedAt(); ok && current.Sub(signedAt) > maxAge {
+		return true
+	}
+
+	if expiry, ok := c.EmbeddedExpiry(); ok {
+		return !current.Before(expiry)
+	}
+
+	return false
+}
+
+// Decodes an itsdangerous timestamp, which is a big-endian integer of Unix
+// seconds encoded with URL-safe base64.
+func flaskTimestamp(raw string) (time.Time, bool) {
+	decoded, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil || len(decoded) == 0 || len(decoded) > 8 {
+		return time.Time{}, false
+	}
+
+	padded := make([]byte, 8)
+	copy(padded[8-len(decoded):], decoded)
+
+	t := time.Unix(int64(binary.BigEndian.Uint64(padded)), 0).UTC()
+	return t, timestamp.Plausible(t)
+}
+
+// Encodes `t` as itsdangerous does, without leading zero bytes.
+func flaskEncodeTimestamp(t time.Time) string {
+	encoded := make([]byte, 8)
+	binary.BigEndian.PutUint64(encoded, uint64(t.Unix()))
+
+	return base64.RawURLEncoding.EncodeToString(bytes.TrimLeft(encoded, "\x00"))
+}
@@ -0,0 +1,138 @@
+package monster
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"unicode"
+)
+
+const (
+	secretEncodingText = "text"
+	secretEncodingHex  = "hex"
+)
+
+// A secret recovered by `Unsign()`, for cataloguing; see `WriteCSV()`.
+type UnsignResult struct {
+	// Where the cookie came from, e.g. a URL or the cookie itself.
+	Source    string
+	Decoder   string
+	Algorithm string
+	Secret    []byte
+
+	// The timestamp the cookie was signed with, if its format has one, and
+	// its decoded payload, if the decoder could decode it.
+	Timestamp      string
+	DecodedPayload []byte
+}
+
+// Returns the result of a successful `Unsign()`, labelled with `source`.
+func (c *Cookie) ResultFor(source string) (UnsignResult, bool) {
+	success, key, decoder := c.Result()
+	if !success {
+		return UnsignResult{}, false
+	}
+
+	fields := c.decodedFieldsFor(decoder)
+
+	// Encrypted sessions can only be decoded now that we have the key.
+	payload, _ := c.Payload(decoder)
+
+	return UnsignResult{
+		Source:         source,
+		Decoder:        decoder,
+		Algorithm:      c.signatureAlgorithm(decoder),
+		Secret:         key,
+		Timestamp:      fields.Timestamp,
+		DecodedPayload: payload,
+	}, true
+}
+
+// Returns the secret as text if it is printable ASCII, or as hex otherwise,
+// along with which one was used.
+func (r UnsignResult) encodedSecret() (string, string) {
+	for _, b := range r.Secret {
+		if b > unicode.MaxASCII || !unicode.IsPrint(rune(b)) {
+			return hex.EncodeToString(r.Secret), secretEncodingHex
+		}
+	}
+
+	return string(r.Secret), secretEncodingText
+}
+
+// Writes `results` as CSV with a header row. Secrets which aren't printable
+// ASCII are hex-encoded, as the `secret_encoding` column records.
+func WriteCSV(w io.Writer, results []UnsignResult) error {
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write([]string{"source", "decoder", "algorithm", "secret", "secret_encoding"}); err != nil {
+		return err
+	}
+
+	for _, result := range results {
+		secret, encoding := result.encodedSecret()
+
+		if err := writer.Write([]string{result.Source, result.Decoder, result.Algorithm, secret, encoding}); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+type keePassFile struct {
+	XMLName xml.Name     `xml:"KeePassFile"`
+	Group   keePassGroup `xml:"Root>Group"`
+}
+
+type keePassGroup struct {
+	Name    string         `xml:"Name"`
+	Entries []keePassEntry `xml:"Entry"`
+}
+
+type keePassEntry struct {
+	Strings []keePassString `xml:"String"`
+}
+
+type keePassString struct {
+	Key   string       `xml:"Key"`
+	Value keePassValue `xml:"Value"`
+}
+
+type keePassValue struct {
+	Protect string `xml:"ProtectInMemory,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// Writes `results` as KeePass 2 XML, which KeePass and KeePassXC can
+// import, with one entry per secret in a "CookieMonster" group.
+func WriteKeePassXML(w io.Writer, results []UnsignResult) error {
+	file := keePassFile{Group: keePassGroup{Name: "CookieMonster"}}
+
+	for _, result := range results {
+		secret, encoding := result.encodedSecret()
+
+		file.Group.Entries = append(file.Group.Entries, keePassEntry{Strings: []keePassString{
+			{Key: "Title", Value: keePassValue{Text: result.Decoder + " secret"}},
+			{Key: "URL", Value: keePassValue{Text: result.Source}},
+			{Key: "Password", Value: keePassValue{Protect: "True", Text: secret}},
+			{Key: "Notes", Value: keePassValue{Text: "Algorithm: " + result.Algorithm + "\nEncoding: " + encoding}},
+		}})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "\t")
+
+	if err := encoder.Encode(file); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
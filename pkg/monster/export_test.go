@@ -0,0 +1,81 @@
+package monster
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteCSV(t *testing.T) {
+	results := []UnsignResult{
+		{Source: "https://example.com, login", Decoder: djangoDecoder, Algorithm: "sha256", Secret: []byte("changeme")},
+		{Source: "api", Decoder: rackDecoder, Algorithm: "sha1", Secret: []byte{0x00, 0xff, '"'}},
+	}
+
+	var out bytes.Buffer
+	if err := WriteCSV(&out, results); err != nil {
+		t.Fatalf("could not write CSV: %v", err)
+	}
+
+	expected := "source,decoder,algorithm,secret,secret_encoding\n" +
+		"\"https://example.com, login\",django,sha256,changeme,text\n" +
+		"api,rack,sha1,00ff22,hex\n"
+
+	if out.String() != expected {
+		t.Errorf("unexpected CSV output:\n%s", out.String())
+	}
+}
+
+func TestWriteKeePassXML(t *testing.T) {
+	results := []UnsignResult{
+		{Source: "https://example.com/?a=1&b=2", Decoder: flaskDecoder, Algorithm: "sha1", Secret: []byte("<secret>")},
+	}
+
+	var out bytes.Buffer
+	if err := WriteKeePassXML(&out, results); err != nil {
+		t.Fatalf("could not write KeePass XML: %v", err)
+	}
+
+	for _, expected := range []string{
+		"<KeePassFile>",
+		`<Value ProtectInMemory="True">&lt;secret&gt;</Value>`,
+		"<Value>https://example.com/?a=1&amp;b=2</Value>",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("KeePass XML is missing %s:\n%s", expected, out.String())
+		}
+	}
+}
+
+func TestResultFor(t *testing.T) {
+	wl := NewWordlist()
+	if err := wl.LoadFromArray([][]byte{[]byte("super secret")}); err != nil {
+		t.Errorf("could not LoadFromArray")
+	}
+
+	c := NewCookie("BAhJIgl0ZXN0BjoGRVQ=--8c5ae09ed57f1e933cc466f5b99ea636d1fc31a2")
+	c.Decode()
+
+	if _, ok := c.ResultFor("capture"); ok {
+		t.Errorf("returned a result before unsigning")
+	}
+
+	c.Unsign(wl, 100)
+
+	result, ok := c.ResultFor("capture")
+	if !ok || result.Source != "capture" || result.Decoder != rackDecoder || result.Algorithm != "sha1" || string(result.Secret) != "super secret" {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	django := NewCookie(selfTestFixtures[djangoDecoder][0].cookie)
+	django.Decode()
+
+	if !django.UnsignWithSecret([]byte("changeme")) {
+		t.Fatalf("could not unsign the Django cookie")
+	}
+
+	result, _ = django.ResultFor("capture")
+	if result.Timestamp != "1mhTAe" || string(result.DecodedPayload) != `{"selftest":true}` {
+		t.Errorf("unexpected timestamp and payload %+v", result)
+	}
+}
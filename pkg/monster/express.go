@@ -1,14 +1,16 @@
 package monster
 
 import (
-	"bytes"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 )
 
 type expressParsedData struct {
 	data             string
+	decodedData      []byte
 	signature        string
 	decodedSignature []byte
 	algorithm        string
@@ -21,7 +23,20 @@ func (d *expressParsedData) String() string {
 		return "Unparsed data"
 	}
 
-	return fmt.Sprintf("Data: %s\nSignature: %s\nAlgorithm: %s\n", d.data, d.signature, d.algorithm)
+	return fmt.Sprintf("Data: %s\nDecoded data: %s\nSeparator: %s\nSignature: %s\nAlgorithm: %s\n", d.data, displayBytes(d.decodedData), expressSeparator, d.signature, d.algorithm)
+}
+
+func (d *expressParsedData) serialized(c *Cookie) []byte {
+	return d.decodedData
+}
+
+func (d *expressParsedData) fields() parsedFields {
+	return parsedFields{data: d.data, signature: d.signature, decodedSignature: d.decodedSignature, algorithm: d.algorithm}
+}
+
+func (d *expressParsedData) clone() decoderData {
+	copied := *d
+	return &copied
 }
 
 const (
@@ -32,9 +47,14 @@ const (
 	// cookie, which is rather annoying. We use a distinct separator and
 	// ask users to manually assemble this.
 	expressSeparator = `^`
+
+	// The signature cookie shares the value cookie's name, with this suffix.
+	expressSignatureSuffix = `.sig`
 )
 
 var (
+	errExpressMissingName = errors.New("express cookies must include the cookie name (i.e. `name=value`) to be resigned")
+
 	expressAlgorithmLength = map[int]string{
 		20: "sha1",
 		32: "sha256",
@@ -60,6 +80,13 @@ func expressDecode(c *Cookie) bool {
 	parsedData.data = components[0]
 	parsedData.signature = components[1]
 
+	// cookie-session stores the session as base64 JSON, after the name.
+	if value := strings.SplitN(parsedData.data, "=", 2); len(value) == 2 {
+		if decoded, err := base64.StdEncoding.DecodeString(value[1]); err == nil {
+			parsedData.decodedData = decoded
+		}
+	}
+
 	// Express encodes the signature with URL-safe base64
 	// without padding, so we must use `RawURLEncoding`.
 	decodedSignature, err := base64.RawURLEncoding.DecodeString(parsedData.signature)
@@ -72,6 +99,7 @@ func expressDecode(c *Cookie) bool {
 	if alg, ok := expressAlgorithmLength[len(decodedSignature)]; ok {
 		parsedData.algorithm = alg
 	} else {
+		c.unknownSignatureLength(expressDecoder, len(decodedSignature))
 		return false
 	}
 
@@ -82,37 +110,90 @@ func expressDecode(c *Cookie) bool {
 	return true
 }
 
-func expressUnsign(c *Cookie, secret []byte) bool {
+func expressUnsign(c *Cookie, secret []byte, keys *derivedKeys) bool {
 	// We need to extract `toBeSigned` to prepare what we'll be signing.
 	parsedData := c.parsedDataFor(expressDecoder).(*expressParsedData)
 	toBeSigned := parsedData.data
 
-	switch parsedData.algorithm {
-	case "sha1":
-		// Derive the correct signature, if this was the correct secret key.
-		computedSignature := sha1HMAC(secret, []byte(toBeSigned))
-
-		// Compare this signature to the one in the `Cookie`.
-		return bytes.Compare(parsedData.decodedSignature, computedSignature) == 0
-	case "sha256":
-		// Derive the correct signature, if this was the correct secret key.
-		computedSignature := sha256HMAC(secret, []byte(toBeSigned))
-
-		// Compare this signature to the one in the `Cookie`.
-		return bytes.Compare(parsedData.decodedSignature, computedSignature) == 0
-	case "sha384":
-		// Derive the correct signature, if this was the correct secret key.
-		computedSignature := sha384HMAC(secret, []byte(toBeSigned))
-
-		// Compare this signature to the one in the `Cookie`.
-		return bytes.Compare(parsedData.decodedSignature, computedSignature) == 0
-	case "sha512":
-		// Derive the correct signature, if this was the correct secret key.
-		computedSignature := sha512HMAC(secret, []byte(toBeSigned))
-
-		// Compare this signature to the one in the `Cookie`.
-		return bytes.Compare(parsedData.decodedSignature, computedSignature) == 0
-	default:
-		panic("unknown algorithm")
+	// Derive the correct signature, if this was the correct secret key, and
+	// compare it to the one in the `Cookie`.
+	return keys.verify(secretKeyed(parsedData.algorithm), secret, []byte(toBeSigned), parsedData.decodedSignature)
+}
+
+// Assembles a `name=value^signature` cookie from a value cookie and its
+// companion `.sig` cookie. If several pairs are present, the first name in
+// sorted order wins so the result is stable.
+func expressAssemble(cookies map[string]string) (string, bool) {
+	names := make([]string, 0, len(cookies))
+	for name := range cookies {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		if strings.HasSuffix(name, expressSignatureSuffix) {
+			continue
+		}
+
+		if signature, ok := cookies[name+expressSignatureSuffix]; ok {
+			return name + "=" + cookies[name] + expressSeparator + signature, true
+		}
+	}
+
+	return "", false
+}
+
+// Resigns the cookie with new, unencoded `data`, in the same single-value
+// form that `NewCookie` accepts.
+func expressResign(c *Cookie, data string, secret []byte, algorithm string) (string, error) {
+	name, value, signature, err := expressSign(c, data, secret, algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	return name + "=" + value + expressSeparator + signature, nil
+}
+
+// Resigns the cookie with new, unencoded `data`, returning both the value
+// cookie and its `.sig` cookie by name, ready to be set separately.
+func expressResignCookies(c *Cookie, data string, secret []byte, algorithm string) (map[string]string, error) {
+	name, value, signature, err := expressSign(c, data, secret, algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{
+		name:                          value,
+		name + expressSignatureSuffix: signature,
+	}, nil
+}
+
+func expressSign(c *Cookie, data string, secret []byte, algorithm string) (name string, value string, signature string, err error) {
+	parsedData := c.parsedDataFor(expressDecoder).(*expressParsedData)
+
+	// Keygrip signs `name=value`, so we need the name from the original.
+	components := strings.SplitN(parsedData.data, "=", 2)
+	if len(components) != 2 || components[0] == "" {
+		return "", "", "", errExpressMissingName
+	}
+
+	name = components[0]
+
+	// cookie-session encodes the session with padded, standard base64.
+	value = base64.StdEncoding.EncodeToString([]byte(data))
+	toBeSigned := name + "=" + value
+
+	hashAlgorithm, ok := hashAlgorithms[algorithm]
+	if !ok {
+		return "", "", "", ErrUnknownAlgorithm
 	}
+
+	computedSignature := hashAlgorithm.hmac(secret, []byte(toBeSigned))
+
+	if err := checkSignatureLength(algorithm, computedSignature); err != nil {
+		return "", "", "", err
+	}
+
+	return name, value, base64.RawURLEncoding.EncodeToString(computedSignature), nil
 }
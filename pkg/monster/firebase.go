@@ -0,0 +1,179 @@
+package monster
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	// Firebase tokens are signed by Google with RS256, so there's no secret
+	// to brute-force; we only ever verify them against Google's keys.
+	firebaseAlgorithm = "rs256"
+
+	firebaseIDTokenIssuer = "securetoken.google.com"
+	firebaseSessionIssuer = "session.firebase.google.com"
+
+	// Google publishes the certificates for ID tokens and session cookies
+	// separately, keyed by the `kid` in the token's header.
+	FirebaseIDTokenCertsURL = "https://www.googleapis.com/robot/v1/metadata/x509/securetoken@system.gserviceaccount.com"
+	FirebaseSessionCertsURL = "https://www.googleapis.com/identitytoolkit/v3/relyingparty/publicKeys"
+)
+
+var (
+	ErrNotFirebase        = errors.New("cookie is not a Firebase token")
+	ErrUnknownFirebaseKey = errors.New("no Google public key matches the token's key ID")
+)
+
+// What we learned about a Firebase ID token or session cookie.
+type FirebaseToken struct {
+	Project string
+	Subject string
+	KeyID   string
+
+	// Whether this is a session cookie rather than an ID token.
+	Session bool
+}
+
+// A source of Google's public keys for Firebase tokens; it's an interface
+// so that verification can be tested without the network.
+type FirebaseKeySource interface {
+	PublicKey(keyID string) (*rsa.PublicKey, error)
+}
+
+// Fetches Google's public certificates from `URL` (usually one of
+// `FirebaseIDTokenCertsURL` or `FirebaseSessionCertsURL`) on first use.
+type FirebaseCerts struct {
+	URL    string
+	Client *http.Client
+
+	once sync.Once
+	keys map[string]*rsa.PublicKey
+	err  error
+}
+
+func (f *FirebaseCerts) PublicKey(keyID string) (*rsa.PublicKey, error) {
+	f.once.Do(f.fetch)
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	key, ok := f.keys[keyID]
+	if !ok {
+		return nil, ErrUnknownFirebaseKey
+	}
+
+	return key, nil
+}
+
+func (f *FirebaseCerts) fetch() {
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Get(f.URL)
+	if err != nil {
+		f.err = err
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		f.err = fmt.Errorf("fetching Google's certificates: %s", resp.Status)
+		return
+	}
+
+	// The response maps each key ID to a PEM-encoded certificate.
+	var certs map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&certs); err != nil {
+		f.err = err
+		return
+	}
+
+	f.keys = make(map[string]*rsa.PublicKey, len(certs))
+	for keyID, certPEM := range certs {
+		block, _ := pem.Decode([]byte(certPEM))
+		if block == nil {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			continue
+		}
+
+		if key, ok := cert.PublicKey.(*rsa.PublicKey); ok {
+			f.keys[keyID] = key
+		}
+	}
+}
+
+// Recognizes a Firebase token from its parsed header and decoded body.
+// Returns nil if it's any other JWT.
+func firebaseTokenFor(header map[string]interface{}, decodedBody []byte) *FirebaseToken {
+	var body struct {
+		Issuer   string `json:"iss"`
+		Audience string `json:"aud"`
+		Subject  string `json:"sub"`
+	}
+
+	if header["alg"] != "RS256" || json.Unmarshal(decodedBody, &body) != nil {
+		return nil
+	}
+
+	keyID, _ := header["kid"].(string)
+	token := &FirebaseToken{Project: body.Audience, Subject: body.Subject, KeyID: keyID}
+
+	switch {
+	case strings.Contains(body.Issuer, firebaseIDTokenIssuer):
+	case strings.Contains(body.Issuer, firebaseSessionIssuer):
+		token.Session = true
+	default:
+		return nil
+	}
+
+	return token
+}
+
+// Returns the Firebase token this cookie contains, if it is one.
+func (c *Cookie) FirebaseToken() (FirebaseToken, bool) {
+	if !c.hasParsedDataFor(jwtDecoder) {
+		return FirebaseToken{}, false
+	}
+
+	parsedData := c.parsedDataFor(jwtDecoder).(*jwtParsedData)
+	if parsedData.firebase == nil {
+		return FirebaseToken{}, false
+	}
+
+	return *parsedData.firebase, true
+}
+
+// Verifies a Firebase token's RS256 signature against Google's public key
+// from `keys`. This doesn't check the token's expiry or audience.
+func (c *Cookie) VerifyFirebase(keys FirebaseKeySource) (bool, error) {
+	token, ok := c.FirebaseToken()
+	if !ok {
+		return false, ErrNotFirebase
+	}
+
+	key, err := keys.PublicKey(token.KeyID)
+	if err != nil {
+		return false, err
+	}
+
+	parsedData := c.parsedDataFor(jwtDecoder).(*jwtParsedData)
+	digest := sha256.Sum256([]byte(parsedData.header + jwtSeparator + parsedData.body))
+
+	return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], parsedData.decodedSignature) == nil, nil
+}
@@ -0,0 +1,78 @@
+package monster
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+)
+
+type staticFirebaseKeys map[string]*rsa.PublicKey
+
+func (k staticFirebaseKeys) PublicKey(keyID string) (*rsa.PublicKey, error) {
+	key, ok := k[keyID]
+	if !ok {
+		return nil, ErrUnknownFirebaseKey
+	}
+
+	return key, nil
+}
+
+// Signs a token shaped like a Firebase ID token with `key`.
+func firebaseTestToken(t *testing.T, key *rsa.PrivateKey) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","kid":"test-key","typ":"JWT"}`))
+	body := base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"https://securetoken.google.com/example-project","aud":"example-project","sub":"user-1234"}`))
+
+	digest := sha256.Sum256([]byte(header + "." + body))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("could not sign the test token: %v", err)
+	}
+
+	return header + "." + body + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestDecodeFirebase(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("could not generate a key: %v", err)
+	}
+
+	c := NewCookie(firebaseTestToken(t, key))
+	if !c.Decode() {
+		t.Fatalf("could not decode a Firebase token")
+	}
+
+	token, ok := c.FirebaseToken()
+	if !ok || token.Project != "example-project" || token.Subject != "user-1234" || token.Session {
+		t.Errorf("unexpected Firebase token %+v", token)
+	}
+
+	wl := NewWordlist()
+	if err := wl.LoadFromArray([][]byte{[]byte("changeme")}); err != nil {
+		t.Errorf("could not LoadFromArray")
+	}
+
+	if _, success := c.Unsign(wl, 100); success {
+		t.Errorf("brute-forced an RS256 token")
+	}
+
+	if verified, err := c.VerifyFirebase(staticFirebaseKeys{"test-key": &key.PublicKey}); !verified || err != nil {
+		t.Errorf("could not verify a genuine Firebase token: %v", err)
+	}
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("could not generate a key: %v", err)
+	}
+
+	if verified, _ := c.VerifyFirebase(staticFirebaseKeys{"test-key": &other.PublicKey}); verified {
+		t.Errorf("verified a Firebase token against the wrong key")
+	}
+
+	if _, err := c.VerifyFirebase(staticFirebaseKeys{}); err != ErrUnknownFirebaseKey {
+		t.Errorf("unexpected error for a missing key: %v", err)
+	}
+}
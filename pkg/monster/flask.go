@@ -2,18 +2,27 @@ package monster
 
 import (
 	"bytes"
+	"compress/zlib"
+	"crypto/hmac"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"io"
 	"strings"
+	"time"
 )
 
 type flaskParsedData struct {
 	data             string
+	decodedData      []byte
+	serializer       string
 	timestamp        string
 	signature        string
 	decodedSignature []byte
 	algorithm        string
 
+	nestedJWTs []nestedJWT
+
 	compressed bool
 	parsed     bool
 }
@@ -23,7 +32,20 @@ func (d *flaskParsedData) String() string {
 		return "Unparsed data"
 	}
 
-	return fmt.Sprintf("Compressed: %t\nData: %s\nTimestamp: %s\nSignature: %s\nAlgorithm: %s\n", d.compressed, d.data, d.timestamp, d.signature, d.algorithm)
+	return fmt.Sprintf("Compressed: %t\nData: %s\nSerializer: %s\nDecoded data: %s\nTimestamp: %s\nSeparator: %s\nSignature: %s\nAlgorithm: %s\n", d.compressed, d.data, d.serializer, displayBytes(d.decodedData), d.timestamp, flaskSeparator, d.signature, d.algorithm) + nestedJWTsString(d.nestedJWTs)
+}
+
+func (d *flaskParsedData) serialized(c *Cookie) []byte {
+	return d.decodedData
+}
+
+func (d *flaskParsedData) fields() parsedFields {
+	return parsedFields{data: d.data, timestamp: d.timestamp, signature: d.signature, decodedSignature: d.decodedSignature, algorithm: d.algorithm, compressed: d.compressed}
+}
+
+func (d *flaskParsedData) clone() decoderData {
+	copied := *d
+	return &copied
 }
 
 const (
@@ -68,6 +90,15 @@ func flaskDecode(c *Cookie) bool {
 	parsedData.timestamp = components[1]
 	parsedData.signature = components[2]
 
+	decodedData, ok := flaskDecodeData(parsedData.data, parsedData.compressed)
+	if !ok {
+		return false
+	}
+
+	parsedData.decodedData = decodedData
+	parsedData.serializer = sessionSerializer(decodedData)
+	parsedData.nestedJWTs = findNestedJWTs(decodedData)
+
 	// Flask encodes the signature with URL-safe base64
 	// without padding, so we must use `RawURLEncoding`.
 	decodedSignature, err := base64.RawURLEncoding.DecodeString(parsedData.signature)
@@ -80,6 +111,7 @@ func flaskDecode(c *Cookie) bool {
 	if alg, ok := flaskAlgorithmLength[len(decodedSignature)]; ok {
 		parsedData.algorithm = alg
 	} else {
+		c.unknownSignatureLength(flaskDecoder, len(decodedSignature))
 		return false
 	}
 
@@ -91,6 +123,12 @@ func flaskDecode(c *Cookie) bool {
 }
 
 func flaskUnsign(c *Cookie, secret []byte) bool {
+	return flaskUnsignWith(c, secret, nil)
+}
+
+// Like `flaskUnsign()`, but reuses any keys already in `keys` that were
+// derived from `secret` for another cookie.
+func flaskUnsignWith(c *Cookie, secret []byte, keys *derivedKeys) bool {
 	// We need to extract `toBeSigned` to prepare what we'll be signing.
 	parsedData := c.parsedDataFor(flaskDecoder).(*flaskParsedData)
 	toBeSigned := parsedData.data + flaskSeparator + parsedData.timestamp
@@ -101,44 +139,189 @@ func flaskUnsign(c *Cookie, secret []byte) bool {
 		toBeSigned = "." + toBeSigned
 	}
 
-	switch parsedData.algorithm {
-	case "sha1":
-		// Flask forces us to derive a key for HMAC-ing.
-		derivedKey := sha1HMAC(secret, []byte(flaskSalt))
+	algorithm, ok := verifyingAlgorithm(parsedData.algorithm)
+	if !ok {
+		return false
+	}
+
+	// Flask forces us to derive a key for HMAC-ing.
+	mac := keys.mac(derivation{decoder: flaskDecoder, algorithm: parsedData.algorithm, salt: flaskSalt}, algorithm.new, func() []byte {
+		return algorithm.hmac(secret, []byte(flaskSalt))
+	})
+
+	// Derive the correct signature, if this was the correct secret key, and
+	// compare it to the one in the `Cookie`.
+	mac.Write([]byte(toBeSigned))
+	return hmac.Equal(parsedData.decodedSignature, mac.Sum(nil))
+}
+
+// Resigns the cookie with new, unencoded `data`, keeping its timestamp
+// unless `signedAt` isn't zero. With `CompressionOriginal`, the data is
+// compressed if the original was.
+func flaskResign(c *Cookie, data string, secret []byte, algorithm string, compression Compression, signedAt time.Time) (string, error) {
+	parsedData := c.parsedDataFor(flaskDecoder).(*flaskParsedData)
+
+	var encodedData string
+	if compression.enabled(parsedData.compressed) {
+		compressed, err := djangoCompress([]byte(data))
+		if err != nil {
+			return "", err
+		}
+
+		encodedData = "." + base64.RawURLEncoding.EncodeToString(compressed)
+	} else {
+		encodedData = base64.RawURLEncoding.EncodeToString([]byte(data))
+	}
+
+	timestamp := parsedData.timestamp
+	if !signedAt.IsZero() {
+		timestamp = flaskEncodeTimestamp(signedAt)
+	}
 
-		// Derive the correct signature, if this was the correct secret key.
-		computedSignature := sha1HMAC(derivedKey, []byte(toBeSigned))
+	toBeSigned := encodedData + flaskSeparator + timestamp
 
-		// Compare this signature to the one in the `Cookie`.
-		return bytes.Compare(parsedData.decodedSignature, computedSignature) == 0
-	case "sha256":
-		// Flask forces us to derive a key for HMAC-ing.
-		derivedKey := sha256HMAC(secret, []byte(flaskSalt))
+	hashAlgorithm, ok := hashAlgorithms[algorithm]
+	if !ok {
+		return "", ErrUnknownAlgorithm
+	}
+
+	// itsdangerous derives its key from the secret and salt first.
+	computedSignature := hashAlgorithm.hmac(hashAlgorithm.hmac(secret, []byte(flaskSalt)), []byte(toBeSigned))
 
-		// Derive the correct signature, if this was the correct secret key.
-		computedSignature := sha256HMAC(derivedKey, []byte(toBeSigned))
+	if err := checkSignatureLength(algorithm, computedSignature); err != nil {
+		return "", err
+	}
 
-		// Compare this signature to the one in the `Cookie`.
-		return bytes.Compare(parsedData.decodedSignature, computedSignature) == 0
-	case "sha384":
-		// Flask forces us to derive a key for HMAC-ing.
-		derivedKey := sha384HMAC(secret, []byte(flaskSalt))
+	return toBeSigned + flaskSeparator + base64.RawURLEncoding.EncodeToString(computedSignature), nil
+}
 
-		// Derive the correct signature, if this was the correct secret key.
-		computedSignature := sha384HMAC(derivedKey, []byte(toBeSigned))
+// Returns the cookie's session with the tags Flask's `TaggedJSONSerializer`
+// wraps non-JSON values in removed: tuples become slices, bytes become
+// `[]byte`, and `Markup`, UUIDs and datetimes become their strings. Sessions
+// which weren't serialized as JSON, e.g. with pickle, return
+// `ErrNotJSONSession`.
+func (c *Cookie) FlaskSession() (map[string]interface{}, error) {
+	parsedData, ok := c.parsedDataFor(flaskDecoder).(*flaskParsedData)
+	if !ok {
+		return nil, ErrNotDecoded
+	}
 
-		// Compare this signature to the one in the `Cookie`.
-		return bytes.Compare(parsedData.decodedSignature, computedSignature) == 0
-	case "sha512":
-		// Flask forces us to derive a key for HMAC-ing.
-		derivedKey := sha512HMAC(secret, []byte(flaskSalt))
+	if parsedData.serializer != serializerJSON {
+		return nil, ErrNotJSONSession
+	}
 
-		// Derive the correct signature, if this was the correct secret key.
-		computedSignature := sha512HMAC(derivedKey, []byte(toBeSigned))
+	var value interface{}
+	if err := json.Unmarshal(parsedData.decodedData, &value); err != nil {
+		return nil, err
+	}
 
-		// Compare this signature to the one in the `Cookie`.
-		return bytes.Compare(parsedData.decodedSignature, computedSignature) == 0
+	session, ok := flaskUntag(value).(map[string]interface{})
+	if !ok {
+		return nil, ErrNotJSONSession
+	}
+
+	return session, nil
+}
+
+// Reverses `TaggedJSONSerializer.tag()`, which replaces each value JSON
+// can't hold with an object of one key naming its type. Like Flask's
+// `object_hook`, this works from the innermost values out.
+func flaskUntag(value interface{}) interface{} {
+	switch value := value.(type) {
+	case []interface{}:
+		for i, element := range value {
+			value[i] = flaskUntag(element)
+		}
+
+		return value
+	case map[string]interface{}:
+		for key, element := range value {
+			value[key] = flaskUntag(element)
+		}
+
+		if len(value) != 1 {
+			return value
+		}
+
+		for tag, tagged := range value {
+			if untagged, ok := flaskUntagValue(tag, tagged); ok {
+				return untagged
+			}
+		}
+
+		return value
 	default:
-		panic("unknown algorithm")
+		return value
 	}
 }
+
+func flaskUntagValue(tag string, value interface{}) (interface{}, bool) {
+	switch tag {
+	case " t":
+		tuple, ok := value.([]interface{})
+		return tuple, ok
+	case " b":
+		encoded, ok := value.(string)
+		if !ok {
+			return nil, false
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		return decoded, err == nil
+	case " m", " u", " d":
+		text, ok := value.(string)
+		return text, ok
+	case " di":
+		// Dicts whose only key looks like a tag are escaped by suffixing it.
+		dict, ok := value.(map[string]interface{})
+		if !ok || len(dict) != 1 {
+			return nil, false
+		}
+
+		for key, element := range dict {
+			return map[string]interface{}{strings.TrimSuffix(key, "__"): element}, true
+		}
+	}
+
+	return nil, false
+}
+
+// Re-serializes edited JSON the way Flask's `TaggedJSONSerializer` does,
+// which dumps it compactly and ASCII-only, just like Django.
+func flaskSerializeJSON(c *Cookie, data string) (string, error) {
+	parsedData := c.parsedDataFor(flaskDecoder).(*flaskParsedData)
+
+	if parsedData.serializer != serializerJSON {
+		return "", ErrNotJSONSession
+	}
+
+	return pythonJSONDumps(data)
+}
+
+// Decodes the session payload. Flask only compresses when it makes the
+// payload smaller, so a payload which looks compressible may still be stored
+// as-is; like Django, we rely solely on the leading `.` to decide.
+func flaskDecodeData(data string, compressed bool) ([]byte, bool) {
+	decoded, err := base64.RawURLEncoding.DecodeString(data)
+	if err != nil {
+		return nil, false
+	}
+
+	if !compressed {
+		return decoded, true
+	}
+
+	reader, err := zlib.NewReader(bytes.NewReader(decoded))
+	if err != nil {
+		return nil, false
+	}
+
+	defer reader.Close()
+
+	decompressed, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, false
+	}
+
+	return decompressed, true
+}
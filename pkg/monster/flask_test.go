@@ -0,0 +1,125 @@
+package monster
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// A Flask cookie holding `session`, with a SHA1-length signature nothing
+// made; `FlaskSession()` only needs it to decode.
+func flaskCookieFor(session string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(session)) + ".YX-skA." + base64.RawURLEncoding.EncodeToString(make([]byte, 20))
+}
+
+func TestFlaskUntag(t *testing.T) {
+	tests := []struct {
+		name   string
+		tagged string
+		want   interface{}
+	}{
+		{"tuple", `{" t":[1,"a"]}`, []interface{}{float64(1), "a"}},
+		{"bytes", `{" b":"aGk="}`, []byte("hi")},
+		{"escaped dict", `{" di":{" t__":1}}`, map[string]interface{}{" t": float64(1)}},
+		{"uuid", `{" u":"4f1c2e0a5d6b4a7e9c3f1b2d3e4f5a6b"}`, "4f1c2e0a5d6b4a7e9c3f1b2d3e4f5a6b"},
+		{"markup", `{" m":"<b>hi</b>"}`, "<b>hi</b>"},
+		{"datetime", `{" d":"Sun, 31 Oct 2021 09:30:14 GMT"}`, "Sun, 31 Oct 2021 09:30:14 GMT"},
+
+		// Lists are JSON already, so Flask never tags them; a dict which
+		// only looks tagged is kept as it is.
+		{"list", `{" l":[1]}`, map[string]interface{}{" l": []interface{}{float64(1)}}},
+		{"invalid bytes", `{" b":"!"}`, map[string]interface{}{" b": "!"}},
+
+		// Tags are removed from the innermost values out.
+		{"nested", `{" t":[{" b":"aGk="},{" t":[]}]}`, []interface{}{[]byte("hi"), []interface{}{}}},
+	}
+
+	for _, test := range tests {
+		var value interface{}
+		if err := json.Unmarshal([]byte(test.tagged), &value); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		if got := flaskUntag(value); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: untagged %s as %#v, wanted %#v", test.name, test.tagged, got, test.want)
+		}
+	}
+}
+
+func TestFlaskSession(t *testing.T) {
+	c := NewCookie(flaskCookieFor(`{"cart":{" t":[1,2]},"_flashes":[{" t":["message","hi"]}],"csrf":{" b":"aGk="}}`))
+	if !c.Decode() {
+		t.Fatalf("could not decode the Flask cookie")
+	}
+
+	session, err := c.FlaskSession()
+	if err != nil {
+		t.Fatalf("could not read the session: %v", err)
+	}
+
+	want := map[string]interface{}{
+		"cart":     []interface{}{float64(1), float64(2)},
+		"_flashes": []interface{}{[]interface{}{"message", "hi"}},
+		"csrf":     []byte("hi"),
+	}
+
+	if !reflect.DeepEqual(session, want) {
+		t.Errorf("read the session as %#v, wanted %#v", session, want)
+	}
+
+	pickled := NewCookie(base64.RawURLEncoding.EncodeToString([]byte("\x80\x04}\x94.")) + ".YX-skA." + base64.RawURLEncoding.EncodeToString(make([]byte, 20)))
+	if !pickled.Decode() {
+		t.Fatalf("could not decode the pickled Flask cookie")
+	}
+
+	if _, err := pickled.FlaskSession(); !errors.Is(err, ErrNotJSONSession) {
+		t.Errorf("read a pickled session as JSON: %v", err)
+	}
+}
+
+func TestResignFlaskReserialize(t *testing.T) {
+	wl := NewWordlist()
+	if err := wl.LoadFromArray([][]byte{[]byte("changeme")}); err != nil {
+		t.Errorf("could not LoadFromArray")
+	}
+
+	c := NewCookie(selfTestFixtures[flaskDecoder][0].cookie)
+	c.Decode()
+
+	if _, success := c.Unsign(wl, 100); !success {
+		t.Fatalf("could not unsign")
+	}
+
+	// An edited session, pretty-printed, with non-ASCII text and a tag.
+	edited := "{\n  \"a\": \"José 🍪\",\n  \"cart\": {\" t\": [1, 2]}\n}"
+
+	resigned, err := c.ResignWith(edited, ResignOptions{Reserialize: true})
+	if err != nil {
+		t.Fatalf("could not resign: %v", err)
+	}
+
+	d := NewCookie(resigned)
+	if !d.Decode() {
+		t.Fatalf("could not decode the resigned cookie %s", resigned)
+	}
+
+	if _, success := d.Unsign(wl, 100); !success {
+		t.Errorf("resigned cookie does not verify")
+	}
+
+	// Compact and ASCII-only, as `TaggedJSONSerializer.dumps()` writes it.
+	if data := d.parsedDataFor(flaskDecoder).(*flaskParsedData).decodedData; string(data) != `{"a":"Jos\u00e9 \ud83c\udf6a","cart":{" t":[1,2]}}` {
+		t.Errorf("resigned cookie has data %s", data)
+	}
+
+	session, err := d.FlaskSession()
+	if err != nil || session["a"] != "José 🍪" || !reflect.DeepEqual(session["cart"], []interface{}{float64(1), float64(2)}) {
+		t.Errorf("read the resigned session as %v (%v)", session, err)
+	}
+
+	if _, err := c.ResignWith("{not json", ResignOptions{Reserialize: true}); err == nil {
+		t.Errorf("reserialized invalid JSON")
+	}
+}
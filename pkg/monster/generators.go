@@ -0,0 +1,196 @@
+package monster
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/iangcarroll/cookiemonster/pkg/timestamp"
+)
+
+const (
+	uuidLength        = 32
+	uuidVersionOffset = 12
+)
+
+// Returns a new wordlist containing each UUID in `wl` in every string form
+// apps commonly store UUID secrets in: with and without dashes, in lower and
+// upper case, and wrapped in braces. Entries which aren't UUIDs are skipped,
+// as are UUIDs of a different `version` unless it is zero.
+func UUIDVariants(wl *Wordlist, version int) *Wordlist {
+	var entries [][]byte
+
+	for _, entry := range wl.Entries() {
+		dashless, ok := normalizeUUID(string(entry))
+		if !ok {
+			continue
+		}
+
+		if version != 0 && uuidVersion(dashless) != version {
+			continue
+		}
+
+		for _, variant := range uuidVariants(dashless) {
+			entries = append(entries, []byte(variant))
+		}
+	}
+
+	variants := NewWordlist()
+	variants.LoadFromArray(entries)
+	return variants
+}
+
+// Strips dashes, braces and a `urn:uuid:` prefix from a UUID and lowercases
+// it, reporting false if it isn't a UUID.
+func normalizeUUID(s string) (string, bool) {
+	s = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), "urn:uuid:")
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "{"), "}")
+
+	dashless := strings.ReplaceAll(s, "-", "")
+	if len(dashless) != uuidLength {
+		return "", false
+	}
+
+	// Dashes may only appear in the canonical positions.
+	if len(s) != uuidLength && s != uuidDashed(dashless) {
+		return "", false
+	}
+
+	if _, err := hex.DecodeString(dashless); err != nil {
+		return "", false
+	}
+
+	return dashless, true
+}
+
+func uuidVersion(dashless string) int {
+	version, _ := hex.DecodeString("0" + dashless[uuidVersionOffset:uuidVersionOffset+1])
+	return int(version[0])
+}
+
+func uuidDashed(dashless string) string {
+	return dashless[0:8] + "-" + dashless[8:12] + "-" + dashless[12:16] + "-" + dashless[16:20] + "-" + dashless[20:]
+}
+
+func uuidVariants(dashless string) []string {
+	dashed := uuidDashed(dashless)
+
+	return []string{
+		dashed,
+		strings.ToUpper(dashed),
+		dashless,
+		strings.ToUpper(dashless),
+		"{" + dashed + "}",
+		"{" + strings.ToUpper(dashed) + "}",
+	}
+}
+
+// Returns a new wordlist of secrets derived from the cookie's own decoded
+// fields, for apps whose secret leaks into the session or is a function of
+// it. Each session value and timestamp is tried as-is, reversed, in lower and
+// upper case, and as an MD5, SHA1 and SHA256 hex digest.
+func FieldCandidates(c *Cookie) *Wordlist {
+	var entries [][]byte
+	seen := make(map[string]bool)
+
+	for _, field := range c.fieldValues() {
+		for _, candidate := range fieldVariants(field) {
+			if candidate == "" || seen[candidate] {
+				continue
+			}
+
+			seen[candidate] = true
+			entries = append(entries, []byte(candidate))
+		}
+	}
+
+	candidates := NewWordlist()
+	candidates.LoadFromArray(entries)
+	return candidates
+}
+
+// Returns the scalar values in the cookie's session, and its timestamps in
+// both their raw and decimal Unix forms.
+func (c *Cookie) fieldValues() (values []string) {
+	if payload, ok := c.sessionPayload(); ok {
+		decoder := json.NewDecoder(bytes.NewReader(payload))
+		decoder.UseNumber()
+
+		var session interface{}
+		if decoder.Decode(&session) == nil {
+			walkJSONScalars(session, func(value string) {
+				values = append(values, value)
+			})
+		}
+	}
+
+	var timestamps []string
+	if c.hasParsedDataFor(djangoDecoder) {
+		timestamps = append(timestamps, c.parsedDataFor(djangoDecoder).(*djangoParsedData).timestamp)
+	}
+
+	if c.hasParsedDataFor(flaskDecoder) {
+		timestamps = append(timestamps, c.parsedDataFor(flaskDecoder).(*flaskParsedData).timestamp)
+	}
+
+	for _, raw := range timestamps {
+		values = append(values, raw)
+
+		if t, _, ok := timestamp.Parse(raw); ok {
+			values = append(values, strconv.FormatInt(t.Unix(), 10))
+		}
+	}
+
+	return values
+}
+
+// Visits every string, number and boolean in a decoded JSON value.
+func walkJSONScalars(value interface{}, visit func(value string)) {
+	switch v := value.(type) {
+	case string:
+		visit(v)
+	case json.Number:
+		visit(v.String())
+	case bool:
+		visit(strconv.FormatBool(v))
+	case []interface{}:
+		for _, element := range v {
+			walkJSONScalars(element, visit)
+		}
+	case map[string]interface{}:
+		// We sort the keys so candidates come out in a stable order.
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			walkJSONScalars(v[key], visit)
+		}
+	}
+}
+
+func fieldVariants(field string) []string {
+	runes := []rune(field)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+
+	md5Sum := md5.Sum([]byte(field))
+
+	return []string{
+		field,
+		string(runes),
+		strings.ToLower(field),
+		strings.ToUpper(field),
+		hex.EncodeToString(md5Sum[:]),
+		hex.EncodeToString(sha1Digest(field)),
+		hex.EncodeToString(sha256Digest(field)),
+	}
+}
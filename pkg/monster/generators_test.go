@@ -0,0 +1,77 @@
+package monster
+
+import "testing"
+
+func TestUUIDVariants(t *testing.T) {
+	wl := NewWordlist()
+	if err := wl.LoadFromArray([][]byte{
+		[]byte("9f1c2d3e-4b5a-46c7-8899-00aabbccddee"),
+		[]byte("not a uuid"),
+		// A version 1 UUID, which the version constraint excludes.
+		[]byte("6ba7b810-9dad-11d1-80b4-00c04fd430c8"),
+	}); err != nil {
+		t.Errorf("could not LoadFromArray")
+	}
+
+	variants := UUIDVariants(wl, 4)
+	if variants.Count() != 6 {
+		t.Errorf("expected 6 variants, got %d", variants.Count())
+	}
+
+	if UUIDVariants(wl, 0).Count() != 12 {
+		t.Errorf("expected variants of both uuids without a version constraint")
+	}
+
+	// This cookie was signed with the dashless, uppercase form.
+	c := NewCookie("BAhJIgl0ZXN0BjoGRVQ=--c6f3f43477b0cf654371347aa840e5fbf7c817db")
+	c.Decode()
+
+	key, success := c.Unsign(variants, 100)
+	if !success || string(key) != "9F1C2D3E4B5A46C7889900AABBCCDDEE" {
+		t.Errorf("could not recover the uuid secret: %q", key)
+	}
+}
+
+func TestNormalizeUUID(t *testing.T) {
+	for _, valid := range []string{
+		"9f1c2d3e-4b5a-46c7-8899-00aabbccddee",
+		"{9F1C2D3E-4B5A-46C7-8899-00AABBCCDDEE}",
+		"urn:uuid:9f1c2d3e-4b5a-46c7-8899-00aabbccddee",
+		"9f1c2d3e4b5a46c7889900aabbccddee",
+	} {
+		if dashless, ok := normalizeUUID(valid); !ok || dashless != "9f1c2d3e4b5a46c7889900aabbccddee" {
+			t.Errorf("could not normalize %q", valid)
+		}
+	}
+
+	for _, invalid := range []string{"9f1c2d3e4b5a-46c7-8899-00aabbccddee", "9f1c2d3e-4b5a-46c7-8899-00aabbccddeg", "abc"} {
+		if _, ok := normalizeUUID(invalid); ok {
+			t.Errorf("normalized invalid uuid %q", invalid)
+		}
+	}
+}
+
+func TestFieldCandidates(t *testing.T) {
+	// A Django session for "wiener", signed with the username itself.
+	c := NewCookie("eyJ1c2VybmFtZSI6IndpZW5lciIsInVpZCI6N30:1mhTAe:uea1O1i9sb6Ipd6vA9PlLPv4XtoiL5l0Qba-6gROpVc")
+	c.Decode()
+
+	candidates := FieldCandidates(c)
+
+	expected := map[string]bool{"7": false, "wiener": false, "reneiw": false, "WIENER": false, "1mhTAe": false, "1635757200": false}
+	for _, entry := range candidates.Entries() {
+		if _, ok := expected[string(entry)]; ok {
+			expected[string(entry)] = true
+		}
+	}
+
+	for candidate, found := range expected {
+		if !found {
+			t.Errorf("did not derive the candidate %s", candidate)
+		}
+	}
+
+	if key, success := c.Unsign(candidates, 100); !success || string(key) != "wiener" {
+		t.Errorf("could not unsign with a secret derived from the username")
+	}
+}
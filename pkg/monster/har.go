@@ -0,0 +1,63 @@
+package monster
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+type harLog struct {
+	Log struct {
+		Entries []struct {
+			Request harRequest `json:"request"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+type harRequest struct {
+	URL     string      `json:"url"`
+	Cookies []harRecord `json:"cookies"`
+	Headers []harRecord `json:"headers"`
+}
+
+type harRecord struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Returns every cookie sent in the requests of a HAR file, as browsers'
+// developer tools and most proxies export them, in the order they appear.
+func CookiesFromHAR(r io.Reader) ([]NamedCookie, error) {
+	var har harLog
+	if err := json.NewDecoder(r).Decode(&har); err != nil {
+		return nil, err
+	}
+
+	var cookies []NamedCookie
+
+	for _, entry := range har.Log.Entries {
+		request := entry.Request
+
+		// Some exporters leave `cookies` empty, so we fall back to the
+		// headers they were sent in.
+		found := make([]NamedCookie, 0, len(request.Cookies))
+		for _, cookie := range request.Cookies {
+			found = append(found, NamedCookie{Name: cookie.Name, Value: cookie.Value})
+		}
+
+		if len(found) == 0 {
+			for _, header := range request.Headers {
+				if strings.EqualFold(header.Name, "cookie") {
+					found = append(found, ParseCookieString(header.Value)...)
+				}
+			}
+		}
+
+		for _, cookie := range found {
+			cookie.URL = request.URL
+			cookies = append(cookies, cookie)
+		}
+	}
+
+	return cookies, nil
+}
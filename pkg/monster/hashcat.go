@@ -0,0 +1,211 @@
+package monster
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrNoHashcatMode      = errors.New("hashcat has no mode for this cookie's signature")
+	ErrHashcatSaltTooLong = errors.New("the signed data is longer than hashcat allows a salt to be")
+)
+
+// A cookie's signature as a line of a hashcat hash file, for cracking on
+// GPUs; see `HashcatHashes()`.
+type HashcatHash struct {
+	Decoder string
+
+	// hashcat's `-m`, e.g. 1450 for HMAC-SHA256 keyed with the password.
+	Mode int
+
+	// The line for the hash file.
+	Hash string
+
+	// Whether the salt in `Hash` is hex, which hashcat needs `--hex-salt`
+	// to be told.
+	HexSalt bool
+
+	// The salt and digest separately, since hashcat may write the salt back
+	// to its potfile in either encoding.
+	salt   []byte
+	digest string
+}
+
+const (
+	hashcatModeJWT = 16500
+
+	// hashcat's pure kernels take salts of up to 256 bytes.
+	hashcatMaxSalt = 256
+
+	// hashcat writes cracked passwords which aren't printable as
+	// `$HEX[...]`.
+	hashcatHexPrefix = "$HEX["
+	hashcatHexSuffix = "]"
+)
+
+var (
+	// The modes for an HMAC keyed with the password and signing the salt.
+	// There isn't one for HMAC-SHA384.
+	hashcatHMACModes = map[string]int{
+		"sha1":   150,
+		"sha256": 1450,
+		"sha512": 1750,
+	}
+)
+
+// Returns the arguments to pass hashcat before the hash file and wordlist.
+func (h HashcatHash) Args() []string {
+	args := []string{"-m", strconv.Itoa(h.Mode)}
+	if h.HexSalt {
+		args = append(args, "--hex-salt")
+	}
+
+	return args
+}
+
+// The ways hashcat may write the hash at the start of a potfile line.
+func (h HashcatHash) prefixes() []string {
+	if h.salt == nil {
+		return []string{h.Hash + ":"}
+	}
+
+	return []string{h.Hash + ":", h.digest + ":" + string(h.salt) + ":"}
+}
+
+// Returns the signature of every decoder that decoded the cookie and that
+// hashcat can crack, most likely first as `Matches()` ranks them. JWTs use
+// hashcat's JWT mode; Rack, Express, express-session, Play, Yii, hash_hmac,
+// MessagePack envelopes and detached signatures are HMACs keyed with the
+// secret itself, so they use its HMAC modes with the signed data as the
+// salt. Django and Flask are missing because they derive their key from
+// the secret before signing, which no hashcat mode does.
+func (c *Cookie) HashcatHashes() []HashcatHash {
+	var hashes []HashcatHash
+
+	for _, match := range c.Matches() {
+		if hash, err := c.HashcatHashFor(match.Decoder); err == nil {
+			hashes = append(hashes, hash)
+		}
+	}
+
+	return hashes
+}
+
+// Returns `decoder`'s signature as a hashcat hash, or `ErrNoHashcatMode` if
+// hashcat can't crack it.
+func (c *Cookie) HashcatHashFor(decoder string) (HashcatHash, error) {
+	if !c.hasParsedDataFor(decoder) {
+		return HashcatHash{}, ErrNoHashcatMode
+	}
+
+	var algorithm string
+	var data, signature []byte
+
+	switch parsedData := c.parsedDataFor(decoder).(type) {
+	case *jwtParsedData:
+		// Tokens with a JOSE header are what hashcat's JWT mode expects;
+		// other dotted values are just signed with the same algorithm.
+		if _, ok := joseHMACAlgorithm(parsedData.joseHeader); ok {
+			token := parsedData.header + jwtSeparator + parsedData.body + jwtSeparator + parsedData.signature
+			return HashcatHash{Decoder: decoder, Mode: hashcatModeJWT, Hash: token}, nil
+		}
+
+		algorithm, data, signature = parsedData.algorithm, []byte(parsedData.header+jwtSeparator+parsedData.body), parsedData.decodedSignature
+	case *rackParsedData:
+		algorithm, data, signature = parsedData.algorithm, []byte(parsedData.data), parsedData.decodedSignature
+	case *expressParsedData:
+		algorithm, data, signature = parsedData.algorithm, []byte(parsedData.data), parsedData.decodedSignature
+	case *connectParsedData:
+		algorithm, data, signature = "sha256", []byte(parsedData.sessionID), parsedData.decodedSignature
+	case *playParsedData:
+		algorithm, data, signature = parsedData.algorithm, []byte(parsedData.data), parsedData.decodedSignature
+	case *yiiParsedData:
+		algorithm, data, signature = parsedData.algorithm, []byte(parsedData.data), parsedData.decodedSignature
+	case *hashHMACParsedData:
+		algorithm, data, signature = parsedData.algorithm, []byte(parsedData.data), parsedData.decodedSignature
+	case *envelopeParsedData:
+		algorithm, data, signature = parsedData.algorithm, parsedData.payload, parsedData.decodedSignature
+	case *detachedParsedData:
+		// Only the primary signature; rotated ones need a hash each.
+		algorithm, data, signature = parsedData.algorithm, []byte(parsedData.data), parsedData.decodedSignature
+	default:
+		return HashcatHash{}, ErrNoHashcatMode
+	}
+
+	mode, ok := hashcatHMACModes[algorithm]
+	if !ok {
+		return HashcatHash{}, ErrNoHashcatMode
+	}
+
+	if len(data) > hashcatMaxSalt {
+		return HashcatHash{}, ErrHashcatSaltTooLong
+	}
+
+	// The data may hold colons or unprintable bytes, so the salt is always
+	// hex.
+	digest := hex.EncodeToString(signature)
+	return HashcatHash{
+		Decoder: decoder,
+		Mode:    mode,
+		Hash:    digest + ":" + hex.EncodeToString(data),
+		HexSalt: true,
+		salt:    data,
+		digest:  digest,
+	}, nil
+}
+
+// Returns the passwords in a hashcat potfile which cracked any of `hashes`,
+// to try as candidate secrets with `Unsign()`. Passwords hashcat wrote as
+// `$HEX[...]` are decoded.
+func SecretsFromPotfile(r io.Reader, hashes []HashcatHash) ([][]byte, error) {
+	var prefixes []string
+	for _, hash := range hashes {
+		prefixes = append(prefixes, hash.prefixes()...)
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxWordlistLine)
+
+	var secrets [][]byte
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		prefix, ok := potfilePrefix(line, prefixes)
+		if !ok {
+			continue
+		}
+
+		secret, err := potfilePassword(line[len(prefix):])
+		if err != nil {
+			return nil, err
+		}
+
+		secrets = append(secrets, secret)
+	}
+
+	return secrets, scanner.Err()
+}
+
+// hashcat lowercases the hex it writes, so hashes are compared without case.
+func potfilePrefix(line string, prefixes []string) (string, bool) {
+	for _, prefix := range prefixes {
+		if len(line) >= len(prefix) && strings.EqualFold(line[:len(prefix)], prefix) {
+			return prefix, true
+		}
+	}
+
+	return "", false
+}
+
+func potfilePassword(password string) ([]byte, error) {
+	if strings.HasPrefix(password, hashcatHexPrefix) && strings.HasSuffix(password, hashcatHexSuffix) {
+		return hex.DecodeString(password[len(hashcatHexPrefix) : len(password)-len(hashcatHexSuffix)])
+	}
+
+	return []byte(password), nil
+}
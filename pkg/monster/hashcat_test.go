@@ -0,0 +1,88 @@
+package monster
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestHashcatHashes(t *testing.T) {
+	jwt := selfTestFixtures[jwtDecoder][0].cookie
+
+	c := NewCookie(jwt)
+	if !c.Decode() {
+		t.Fatalf("could not decode the JWT")
+	}
+
+	// The JWT also looks like Flask, which hashcat can't crack.
+	hashes := c.HashcatHashes()
+	if len(hashes) != 1 || hashes[0].Mode != hashcatModeJWT || hashes[0].Hash != jwt || hashes[0].HexSalt {
+		t.Fatalf("unexpected hashes %+v", hashes)
+	}
+
+	if _, err := c.HashcatHashFor(flaskDecoder); err != ErrNoHashcatMode {
+		t.Errorf("expected ErrNoHashcatMode for Flask, got %v", err)
+	}
+
+	c = NewCookie(selfTestFixtures[hashHMACDecoder][0].cookie)
+	if !c.Decode() {
+		t.Fatalf("could not decode the hash_hmac cookie")
+	}
+
+	hash, err := c.HashcatHashFor(hashHMACDecoder)
+	if err != nil {
+		t.Fatalf("could not export the hash_hmac cookie: %v", err)
+	}
+
+	expected := "94ea50c6800a906cc56eee899e4d1845c6054e7310688ab96645eb2785d70515:" + hex.EncodeToString([]byte("user=42&role=user"))
+	if hash.Mode != 1450 || hash.Hash != expected || strings.Join(hash.Args(), " ") != "-m 1450 --hex-salt" {
+		t.Errorf("unexpected hash %+v", hash)
+	}
+
+	long := NewCookie(strings.Repeat("a", hashcatMaxSalt+1) + "|" + hex.EncodeToString(sha256HMAC([]byte("changeme"), []byte(strings.Repeat("a", hashcatMaxSalt+1)))))
+	if !long.Decode() {
+		t.Fatalf("could not decode the long hash_hmac cookie")
+	}
+
+	if _, err := long.HashcatHashFor(hashHMACDecoder); err != ErrHashcatSaltTooLong {
+		t.Errorf("expected ErrHashcatSaltTooLong, got %v", err)
+	}
+}
+
+func TestSecretsFromPotfile(t *testing.T) {
+	c := NewCookie(selfTestFixtures[hashHMACDecoder][0].cookie)
+	if !c.Decode() {
+		t.Fatalf("could not decode the hash_hmac cookie")
+	}
+
+	hashes := c.HashcatHashes()
+	if len(hashes) != 1 {
+		t.Fatalf("unexpected hashes %+v", hashes)
+	}
+
+	potfile := strings.Join([]string{
+		"5f4dcc3b5aa765d61d8327deb882cf99:password",
+		strings.ToUpper(hashes[0].Hash) + ":change:me",
+		"94ea50c6800a906cc56eee899e4d1845c6054e7310688ab96645eb2785d70515:user=42&role=user:$HEX[6368616e67656d65]",
+	}, "\n")
+
+	secrets, err := SecretsFromPotfile(strings.NewReader(potfile), hashes)
+	if err != nil {
+		t.Fatalf("could not read the potfile: %v", err)
+	}
+
+	if len(secrets) != 2 || string(secrets[0]) != "change:me" || string(secrets[1]) != "changeme" {
+		t.Fatalf("unexpected secrets %q", secrets)
+	}
+
+	wl := NewWordlist()
+	wl.LoadFromArray(secrets)
+
+	if _, ok := c.Unsign(wl, 1); !ok {
+		t.Errorf("the potfile's secrets did not unsign the cookie")
+	}
+
+	if _, err := SecretsFromPotfile(strings.NewReader(hashes[0].Hash+":$HEX[zz]"), hashes); err == nil {
+		t.Errorf("expected an error for a malformed $HEX password")
+	}
+}
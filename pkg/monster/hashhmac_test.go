@@ -0,0 +1,30 @@
+package monster
+
+import (
+	"testing"
+)
+
+func TestResignHashHMAC(t *testing.T) {
+	c := NewCookie(selfTestFixtures[hashHMACDecoder][0].cookie)
+	if !c.Decode() || !c.UnsignWithSecret([]byte("changeme")) {
+		t.Fatalf("could not unsign the hash_hmac cookie")
+	}
+
+	if algorithm := c.signatureAlgorithm(hashHMACDecoder); algorithm != "sha256" {
+		t.Errorf("expected sha256, got %s", algorithm)
+	}
+
+	resigned, err := c.Resign("user=1&role=admin")
+	if err != nil {
+		t.Fatalf("could not resign the hash_hmac cookie: %v", err)
+	}
+
+	if resigned != "user=1&role=admin|c15a6df184dee6f79449913f81e52e94a0bb1a10cdcc6fe64666de89c86b19d9" {
+		t.Errorf("unexpected resigned cookie %s", resigned)
+	}
+
+	// Uppercase hex isn't what `hash_hmac()` writes.
+	if c := NewCookie("user=42&role=user|94EA50C6800A906CC56EEE899E4D1845C6054E7310688AB96645EB2785D70515"); c.Decode() && c.hasParsedDataFor(hashHMACDecoder) {
+		t.Errorf("decoded uppercase hex as a hash_hmac signature")
+	}
+}
@@ -5,84 +5,164 @@ import (
 	"crypto/sha1"
 	"crypto/sha256"
 	"crypto/sha512"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"sync"
 )
 
-func sha1Digest(data string) []byte {
-	h := sha1.New()
+var (
+	ErrUnknownAlgorithm   = errors.New("unknown algorithm")
+	ErrAlgorithmDowngrade = errors.New("refusing to resign with a weaker algorithm than the original without AllowDowngrade")
+
+	// The hash functions behind each algorithm we support, so decoders can
+	// look them up rather than switching over algorithm names.
+	hashAlgorithms = map[string]hashAlgorithm{
+		"sha1":   {new: sha1.New, hmac: sha1HMAC, pool: newHashPool(sha1.New)},
+		"sha256": {new: sha256.New, hmac: sha256HMAC, pool: newHashPool(sha256.New)},
+		"sha384": {new: sha512.New384, hmac: sha384HMAC, pool: newHashPool(sha512.New384)},
+		"sha512": {new: sha512.New, hmac: sha512HMAC, pool: newHashPool(sha512.New)},
+	}
 
-	if writtenLength, err := h.Write([]byte(data)); err != nil || writtenLength != len(data) {
-		panic("sha1Digest could not properly write data")
+	// The digest length, in bytes, of each algorithm we support.
+	algorithmDigestLength = map[string]int{
+		"sha1":   20,
+		"sha256": 32,
+		"sha384": 48,
+		"sha512": 64,
 	}
+)
 
-	return h.Sum(nil)
+type hashAlgorithm struct {
+	new  func() hash.Hash
+	hmac func(key []byte, data []byte) []byte
+
+	// Unkeyed hashes to reuse rather than allocate for each candidate
+	// secret; see `saltedDigest()`.
+	pool *sync.Pool
 }
 
-func sha1HMAC(key []byte, data []byte) []byte {
-	h := hmac.New(sha1.New, []byte(key))
+func newHashPool(newHash func() hash.Hash) *sync.Pool {
+	return &sync.Pool{New: func() interface{} { return newHash() }}
+}
+
+// Picks the algorithm to resign with given the cookie's `original` one. We
+// rank algorithms by digest length, which matches their relative strength.
+func resignAlgorithm(original string, options ResignOptions) (string, error) {
+	if options.Algorithm == "" {
+		return original, nil
+	}
 
-	if writtenLength, err := h.Write(data); err != nil || writtenLength != len(data) {
-		panic("sha1HMAC could not properly write data")
+	requestedLength, ok := algorithmDigestLength[options.Algorithm]
+	if !ok {
+		return "", ErrUnknownAlgorithm
 	}
 
+	if requestedLength < algorithmDigestLength[original] && !options.AllowDowngrade {
+		return "", ErrAlgorithmDowngrade
+	}
+
+	return options.Algorithm, nil
+}
+
+// Returns the algorithm to verify a signature with, given the one its
+// decoder found. Decoders only accept algorithms we know, so a miss is a
+// bug, but it shouldn't take down a long batch run; callers fail closed,
+// reporting that the secret didn't verify.
+func verifyingAlgorithm(name string) (hashAlgorithm, bool) {
+	algorithm, ok := hashAlgorithms[name]
+	return algorithm, ok
+}
+
+// Ensures a computed signature is the right length for its declared
+// algorithm. A mismatch means a helper was wired to the wrong algorithm, and
+// the resulting cookie would never be accepted.
+func checkSignatureLength(algorithm string, signature []byte) error {
+	expected, ok := algorithmDigestLength[algorithm]
+	if !ok {
+		return ErrUnknownAlgorithm
+	}
+
+	if len(signature) != expected {
+		return fmt.Errorf("computed a %d byte signature for %s, which should be %d bytes", len(signature), algorithm, expected)
+	}
+
+	return nil
+}
+
+// Hashes `salt` and `secret` in `order` without concatenating them, since
+// this runs once per candidate secret when deriving Django's keys.
+func saltedDigest(algorithm hashAlgorithm, salt string, secret []byte, order SaltOrder) []byte {
+	h := algorithm.pool.Get().(hash.Hash)
+	defer algorithm.pool.Put(h)
+
+	h.Reset()
+
+	if order == SaltSuffix {
+		h.Write(secret)
+		io.WriteString(h, salt)
+	} else {
+		io.WriteString(h, salt)
+		h.Write(secret)
+	}
+
+	return h.Sum(nil)
+}
+
+// Writes to a `hash.Hash` never fail, so these needn't check.
+func sha1Digest(data string) []byte {
+	h := sha1.New()
+	io.WriteString(h, data)
+
+	return h.Sum(nil)
+}
+
+func sha1HMAC(key []byte, data []byte) []byte {
+	h := hmac.New(sha1.New, key)
+	h.Write(data)
+
 	return h.Sum(nil)
 }
 
 func sha256Digest(data string) []byte {
 	h := sha256.New()
-
-	if writtenLength, err := h.Write([]byte(data)); err != nil || writtenLength != len(data) {
-		panic("sha256Digest could not properly write data")
-	}
+	io.WriteString(h, data)
 
 	return h.Sum(nil)
 }
 
 func sha256HMAC(key []byte, data []byte) []byte {
-	h := hmac.New(sha256.New, []byte(key))
-
-	if writtenLength, err := h.Write(data); err != nil || writtenLength != len(data) {
-		panic("sha256HMAC could not properly write data")
-	}
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
 
 	return h.Sum(nil)
 }
 
 func sha384Digest(data string) []byte {
 	h := sha512.New384()
-
-	if writtenLength, err := h.Write([]byte(data)); err != nil || writtenLength != len(data) {
-		panic("sha384Digest could not properly write data")
-	}
+	io.WriteString(h, data)
 
 	return h.Sum(nil)
 }
 
 func sha384HMAC(key []byte, data []byte) []byte {
-	h := hmac.New(sha512.New384, []byte(key))
-
-	if writtenLength, err := h.Write(data); err != nil || writtenLength != len(data) {
-		panic("sha384HMAC could not properly write data")
-	}
+	h := hmac.New(sha512.New384, key)
+	h.Write(data)
 
 	return h.Sum(nil)
 }
 
 func sha512Digest(data string) []byte {
 	h := sha512.New()
-
-	if writtenLength, err := h.Write([]byte(data)); err != nil || writtenLength != len(data) {
-		panic("sha512Digest could not properly write data")
-	}
+	io.WriteString(h, data)
 
 	return h.Sum(nil)
 }
 
 func sha512HMAC(key []byte, data []byte) []byte {
-	h := hmac.New(sha512.New, []byte(key))
-
-	if writtenLength, err := h.Write(data); err != nil || writtenLength != len(data) {
-		panic("sha512HMAC could not properly write data")
-	}
+	h := hmac.New(sha512.New, key)
+	h.Write(data)
 
 	return h.Sum(nil)
 }
@@ -0,0 +1,94 @@
+package monster
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"fmt"
+	"testing"
+)
+
+func TestCheckSignatureLength(t *testing.T) {
+	helpers := map[string]func([]byte, []byte) []byte{
+		"sha1":   sha1HMAC,
+		"sha256": sha256HMAC,
+		"sha384": sha384HMAC,
+		"sha512": sha512HMAC,
+	}
+
+	// Every helper must only satisfy its own algorithm, so wiring e.g.
+	// `sha1HMAC` into the sha256 branch of a resign is caught.
+	for algorithm := range helpers {
+		for helperAlgorithm, helper := range helpers {
+			err := checkSignatureLength(algorithm, helper([]byte("key"), []byte("data")))
+
+			if algorithm == helperAlgorithm && err != nil {
+				t.Errorf("%s rejected its own signature: %v", algorithm, err)
+			}
+
+			if algorithm != helperAlgorithm && err == nil {
+				t.Errorf("%s accepted a %s signature", algorithm, helperAlgorithm)
+			}
+		}
+	}
+
+	if err := checkSignatureLength("md4", nil); err != ErrUnknownAlgorithm {
+		t.Errorf("accepted an unknown algorithm")
+	}
+}
+
+// Keeps the compiler from optimizing away the comparisons below.
+var benchmarkEqual bool
+
+// Compares the cost of `bytes.Compare` and `hmac.Equal` on signatures of
+// each length we verify, alongside the HMAC each comparison follows. The
+// comparison should be noise next to the HMAC, whichever is used.
+func BenchmarkCompareSignatures(b *testing.B) {
+	helpers := map[string]func([]byte, []byte) []byte{
+		"sha1":   sha1HMAC,
+		"sha256": sha256HMAC,
+		"sha384": sha384HMAC,
+		"sha512": sha512HMAC,
+	}
+
+	for _, algorithm := range []string{"sha1", "sha256", "sha384", "sha512"} {
+		helper := helpers[algorithm]
+		signature := helper([]byte("changeme"), []byte("data"))
+
+		// A wrong guess which only differs in the last byte is the slowest
+		// case for `bytes.Compare`, which stops at the first difference.
+		candidate := append([]byte(nil), signature...)
+		candidate[len(candidate)-1] ^= 1
+
+		b.Run(fmt.Sprintf("%s/bytes.Compare", algorithm), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				benchmarkEqual = bytes.Compare(signature, candidate) == 0
+			}
+		})
+
+		b.Run(fmt.Sprintf("%s/hmac.Equal", algorithm), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				benchmarkEqual = hmac.Equal(signature, candidate)
+			}
+		})
+
+		b.Run(fmt.Sprintf("%s/HMAC", algorithm), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				benchmarkEqual = len(helper([]byte("changeme"), []byte("data"))) == 0
+			}
+		})
+	}
+}
+
+// Measures the key derivation Django runs for every candidate secret, which
+// takes its hash from a pool rather than allocating one.
+func BenchmarkSaltedDigest(b *testing.B) {
+	for _, algorithm := range []string{"sha1", "sha256", "sha512"} {
+		b.Run(algorithm, func(b *testing.B) {
+			b.ReportAllocs()
+
+			for n := 0; n < b.N; n++ {
+				benchmarkEqual = len(saltedDigest(hashAlgorithms[algorithm], djangoSalt, []byte("changeme"), SaltPrefix)) == 0
+			}
+		})
+	}
+}
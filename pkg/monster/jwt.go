@@ -1,19 +1,32 @@
 package monster
 
 import (
-	"bytes"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 )
 
 type jwtParsedData struct {
 	header           string
+	decodedHeader    []byte
 	body             string
+	decodedBody      []byte
 	signature        string
 	decodedSignature []byte
 	algorithm        string
 
+	// The parsed header, if the first segment is one.
+	joseHeader map[string]interface{}
+
+	// Set for Firebase tokens, which Google signs with RS256.
+	firebase *FirebaseToken
+
+	// Set for tokens any cloud provider issued, including Firebase's.
+	cloud *CloudToken
+
 	parsed bool
 }
 
@@ -22,7 +35,35 @@ func (d *jwtParsedData) String() string {
 		return "Unparsed data"
 	}
 
-	return fmt.Sprintf("Header: %s\nBody: %s\nSignature: %s\nAlgorithm: %s\n", d.header, d.body, d.signature, d.algorithm)
+	out := fmt.Sprintf("Header: %s\nDecoded header: %s\nBody: %s\nDecoded body: %s\nSeparator: %s\nSignature: %s\nAlgorithm: %s\n", d.header, displayBytes(d.decodedHeader), d.body, displayBytes(d.decodedBody), jwtSeparator, d.signature, d.algorithm)
+
+	// A first segment which isn't a JOSE header is likely a key ID instead.
+	if d.joseHeader == nil {
+		out += fmt.Sprintf("Key ID: %s\n", displayBytes(d.decodedHeader))
+	}
+
+	out += jwtClaimTimes(d.decodedBody)
+
+	if d.firebase != nil {
+		out += fmt.Sprintf("Firebase project: %s\nFirebase subject: %s\nFirebase session cookie: %t\n", d.firebase.Project, d.firebase.Subject, d.firebase.Session)
+	} else if d.cloud != nil {
+		out += fmt.Sprintf("Cloud provider: %s\nCloud token: %s\nCloud audience: %s\n", d.cloud.Provider, d.cloud.Kind, d.cloud.Audience)
+	}
+
+	return out
+}
+
+func (d *jwtParsedData) serialized(c *Cookie) []byte {
+	return d.decodedBody
+}
+
+func (d *jwtParsedData) fields() parsedFields {
+	return parsedFields{data: d.header + jwtSeparator + d.body, signature: d.signature, decodedSignature: d.decodedSignature, algorithm: d.algorithm}
+}
+
+func (d *jwtParsedData) clone() decoderData {
+	copied := *d
+	return &copied
 }
 
 const (
@@ -33,6 +74,15 @@ const (
 )
 
 var (
+	errInvalidJOSEHeader = errors.New("not a JOSE header")
+
+	// The JOSE `alg` names of the HMAC algorithms we support.
+	joseHMACAlgorithms = map[string]string{
+		"HS256": "sha256",
+		"HS384": "sha384",
+		"HS512": "sha512",
+	}
+
 	jwtAlgorithmLength = map[int]string{
 		20: "sha1",
 		32: "sha256",
@@ -61,17 +111,32 @@ func jwtDecode(c *Cookie) bool {
 	parsedData.signature = components[2]
 
 	// JWTs encode the signature with URL-safe base64
-	// without padding, so we must use `RawURLEncoding`.
-	decodedSignature, err := base64.RawURLEncoding.DecodeString(parsedData.signature)
+	// without padding, so we must use `RawURLEncoding`. AWS ALB pads its
+	// tokens anyway, so we ignore any padding.
+	decodedSignature, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parsedData.signature, "="))
 	if err != nil {
 		return false
 	}
 
+	// The header and body are JSON, encoded the same way as the signature.
+	// We don't require them to decode, since we only need the signature.
+	parsedData.decodedHeader, _ = base64.RawURLEncoding.DecodeString(strings.TrimRight(parsedData.header, "="))
+	parsedData.decodedBody, _ = base64.RawURLEncoding.DecodeString(strings.TrimRight(parsedData.body, "="))
+
+	parsedData.joseHeader, _ = parseJOSEHeader(parsedData.header)
+
 	// Determine the algorithm from the digest length, or give up if we can't
-	// figure it out.
-	if alg, ok := jwtAlgorithmLength[len(decodedSignature)]; ok {
+	// figure it out. Cloud providers' tokens are the exception, since we
+	// recognize them from their claims.
+	if parsedData.firebase = firebaseTokenFor(parsedData.joseHeader, parsedData.decodedBody); parsedData.firebase != nil {
+		parsedData.algorithm = firebaseAlgorithm
+		parsedData.cloud = firebaseCloudToken(parsedData.firebase, parsedData.decodedBody)
+	} else if parsedData.cloud = cloudTokenFor(parsedData.joseHeader, parsedData.decodedBody); parsedData.cloud != nil {
+		parsedData.algorithm = strings.ToLower(parsedData.cloud.Algorithm)
+	} else if alg, ok := jwtAlgorithmLength[len(decodedSignature)]; ok {
 		parsedData.algorithm = alg
 	} else {
+		c.unknownSignatureLength(jwtDecoder, len(decodedSignature))
 		return false
 	}
 
@@ -82,37 +147,164 @@ func jwtDecode(c *Cookie) bool {
 	return true
 }
 
-func jwtUnsign(c *Cookie, secret []byte) bool {
+// Shows the registered time claims of a JWT body as RFC3339 alongside their
+// raw values, and whether the token is expired or not yet valid as of now.
+func jwtClaimTimes(decodedBody []byte) (out string) {
+	var claims struct {
+		Expiry    *json.Number `json:"exp"`
+		IssuedAt  *json.Number `json:"iat"`
+		NotBefore *json.Number `json:"nbf"`
+	}
+
+	if json.Unmarshal(decodedBody, &claims) != nil {
+		return ""
+	}
+
+	current := now()
+
+	show := func(label string, claim *json.Number, late func(time.Time) bool, lateNote string) {
+		if claim == nil {
+			return
+		}
+
+		seconds, err := claim.Float64()
+		if err != nil {
+			return
+		}
+
+		t := time.Unix(int64(seconds), 0).UTC()
+		out += fmt.Sprintf("%s: %s (%s)", label, claim.String(), t.Format(time.RFC3339))
+
+		if late != nil && late(t) {
+			out += " " + lateNote
+		}
+
+		out += "\n"
+	}
+
+	show("Expires", claims.Expiry, func(t time.Time) bool { return !current.Before(t) }, "[expired]")
+	show("Issued at", claims.IssuedAt, nil, "")
+	show("Not before", claims.NotBefore, current.Before, "[not yet valid]")
+
+	return out
+}
+
+// Parses a base64url-encoded JOSE header, which must be a JSON object with
+// an `alg`. Hybrid formats which borrow the JWT header but not the rest of
+// the format can use this to read the algorithm they declare.
+func parseJOSEHeader(seg string) (map[string]interface{}, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(seg, "="))
+	if err != nil {
+		return nil, err
+	}
+
+	var header map[string]interface{}
+	if err := json.Unmarshal(decoded, &header); err != nil {
+		return nil, err
+	}
+
+	if _, ok := header["alg"].(string); !ok {
+		return nil, errInvalidJOSEHeader
+	}
+
+	return header, nil
+}
+
+// Returns the HMAC algorithm a JOSE header declares, e.g. "sha256" for
+// `HS256`, or false if it isn't one we support.
+func joseHMACAlgorithm(header map[string]interface{}) (string, bool) {
+	alg, _ := header["alg"].(string)
+	algorithm, ok := joseHMACAlgorithms[alg]
+	return algorithm, ok
+}
+
+func jwtUnsign(c *Cookie, secret []byte, keys *derivedKeys) bool {
 	// We need to extract `toBeSigned` to prepare what we'll be signing.
 	parsedData := c.parsedDataFor(jwtDecoder).(*jwtParsedData)
 	toBeSigned := parsedData.header + jwtSeparator + parsedData.body
 
-	switch parsedData.algorithm {
-	case "sha1":
-		// Derive the correct signature, if this was the correct secret key.
-		computedSignature := sha1HMAC(secret, []byte(toBeSigned))
-
-		// Compare this signature to the one in the `Cookie`.
-		return bytes.Compare(parsedData.decodedSignature, computedSignature) == 0
-	case "sha256":
-		// Derive the correct signature, if this was the correct secret key.
-		computedSignature := sha256HMAC(secret, []byte(toBeSigned))
-
-		// Compare this signature to the one in the `Cookie`.
-		return bytes.Compare(parsedData.decodedSignature, computedSignature) == 0
-	case "sha384":
-		// Derive the correct signature, if this was the correct secret key.
-		computedSignature := sha384HMAC(secret, []byte(toBeSigned))
-
-		// Compare this signature to the one in the `Cookie`.
-		return bytes.Compare(parsedData.decodedSignature, computedSignature) == 0
-	case "sha512":
-		// Derive the correct signature, if this was the correct secret key.
-		computedSignature := sha512HMAC(secret, []byte(toBeSigned))
-
-		// Compare this signature to the one in the `Cookie`.
-		return bytes.Compare(parsedData.decodedSignature, computedSignature) == 0
-	default:
-		panic("unknown algorithm")
+	return jwtVerify(parsedData, secret, toBeSigned, keys)
+}
+
+// Like `jwtUnsign()`, but also tries the key ID layout if the plan asks for
+// it.
+func jwtUnsignPlanned(c *Cookie, secret []byte, plan *unsignPlan, keys *derivedKeys) bool {
+	return jwtUnsign(c, secret, keys) || plan.keyID && jwtUnsignKeyID(c, secret, keys)
+}
+
+// Like `jwtUnsign()`, but for `keyid.data.signature` cookies, where the
+// first segment is a key ID that isn't signed; see `WithKeyID()`.
+func jwtUnsignKeyID(c *Cookie, secret []byte, keys *derivedKeys) bool {
+	parsedData := c.parsedDataFor(jwtDecoder).(*jwtParsedData)
+	return jwtVerify(parsedData, secret, parsedData.body, keys)
+}
+
+// Resigns the token with new claims, `data`. The header is kept as it was,
+// unless it declares a different algorithm than `algorithm`, in which case
+// its `alg` is updated to match.
+func jwtResign(c *Cookie, data string, secret []byte, algorithm string) (string, error) {
+	parsedData := c.parsedDataFor(jwtDecoder).(*jwtParsedData)
+
+	hashAlgorithm, ok := hashAlgorithms[algorithm]
+	if !ok {
+		return "", ErrUnknownAlgorithm
+	}
+
+	header, err := jwtResignHeader(parsedData, algorithm)
+	if err != nil {
+		return "", err
 	}
+
+	body := base64.RawURLEncoding.EncodeToString([]byte(data))
+
+	// If the first segment isn't a header, it may be a key ID which isn't
+	// signed (see `WithKeyID()`); the original signature tells us which.
+	toBeSigned := header + jwtSeparator + body
+	if parsedData.joseHeader == nil && !jwtVerify(parsedData, secret, parsedData.header+jwtSeparator+parsedData.body, nil) {
+		toBeSigned = body
+	}
+
+	computedSignature := hashAlgorithm.hmac(secret, []byte(toBeSigned))
+	if err := checkSignatureLength(algorithm, computedSignature); err != nil {
+		return "", err
+	}
+
+	return header + jwtSeparator + body + jwtSeparator + base64.RawURLEncoding.EncodeToString(computedSignature), nil
+}
+
+// Returns the header segment for a token resigned with `algorithm`.
+func jwtResignHeader(parsedData *jwtParsedData, algorithm string) (string, error) {
+	declared, ok := joseHMACAlgorithm(parsedData.joseHeader)
+	if !ok || declared == algorithm {
+		return parsedData.header, nil
+	}
+
+	for alg, candidate := range joseHMACAlgorithms {
+		if candidate != algorithm {
+			continue
+		}
+
+		header := make(map[string]interface{}, len(parsedData.joseHeader))
+		for name, value := range parsedData.joseHeader {
+			header[name] = value
+		}
+
+		header["alg"] = alg
+
+		encoded, err := json.Marshal(header)
+		if err != nil {
+			return "", err
+		}
+
+		return base64.RawURLEncoding.EncodeToString(encoded), nil
+	}
+
+	// JOSE has no name for e.g. HMAC-SHA1.
+	return "", ErrUnknownAlgorithm
+}
+
+func jwtVerify(parsedData *jwtParsedData, secret []byte, toBeSigned string, keys *derivedKeys) bool {
+	// Derive the correct signature, if this was the correct secret key, and
+	// compare it to the one in the `Cookie`.
+	return keys.verify(secretKeyed(parsedData.algorithm), secret, []byte(toBeSigned), parsedData.decodedSignature)
 }
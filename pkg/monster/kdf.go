@@ -0,0 +1,94 @@
+package monster
+
+import (
+	"crypto/hmac"
+	"hash"
+)
+
+// A `KDF` derives the HMAC key from a candidate secret, for schemes which
+// don't sign with the secret directly. The key is derived with the same
+// hash `algorithm` as the signature, and is as long as its digest.
+type KDF interface {
+	Derive(secret []byte, algorithm string) []byte
+}
+
+// Standard HKDF (RFC 5869), which extracts a pseudorandom key from the
+// secret with `Salt` and then expands it with `Info`.
+type HKDF struct {
+	Salt []byte
+	Info []byte
+}
+
+func (k HKDF) Derive(secret []byte, algorithm string) []byte {
+	newHash := hashAlgorithms[algorithm].new
+
+	// An empty salt is treated as a digest's worth of zeroes.
+	salt := k.Salt
+	if len(salt) == 0 {
+		salt = make([]byte, newHash().Size())
+	}
+
+	extract := hmac.New(newHash, salt)
+	extract.Write(secret)
+
+	return hkdfExpand(newHash, extract.Sum(nil), k.Info)
+}
+
+// Only the expand step of HKDF, as AWS-style key schedules use, where the
+// secret itself is the pseudorandom key.
+type HKDFExpand struct {
+	Info []byte
+}
+
+func (k HKDFExpand) Derive(secret []byte, algorithm string) []byte {
+	return hkdfExpand(hashAlgorithms[algorithm].new, secret, k.Info)
+}
+
+// Expands `prk` into one digest's worth of key material. We never need more
+// than one block, since the key is only as long as the digest.
+func hkdfExpand(newHash func() hash.Hash, prk []byte, info []byte) []byte {
+	expand := hmac.New(newHash, prk)
+	expand.Write(info)
+	expand.Write([]byte{1})
+
+	return expand.Sum(nil)
+}
+
+// PBKDF2 (RFC 8018) with HMAC, as Rails' `KeyGenerator` derives its keys.
+func pbkdf2(newHash func() hash.Hash, password []byte, salt []byte, iterations int, keyLength int) []byte {
+	prf := hmac.New(newHash, password)
+
+	var key []byte
+	for block := uint32(1); len(key) < keyLength; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(appendUint32(nil, block))
+		u := prf.Sum(nil)
+
+		t := append([]byte{}, u...)
+		for n := 1; n < iterations; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+
+			for i := range t {
+				t[i] ^= u[i]
+			}
+		}
+
+		key = append(key, t...)
+	}
+
+	return key[:keyLength]
+}
+
+// A single salted hash of the secret, as Django derives its keys; `Order`
+// says which side of the secret the salt goes on.
+type SaltedHash struct {
+	Salt  []byte
+	Order SaltOrder
+}
+
+func (k SaltedHash) Derive(secret []byte, algorithm string) []byte {
+	return saltedDigest(hashAlgorithms[algorithm], string(k.Salt), secret, k.Order)
+}
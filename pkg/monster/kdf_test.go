@@ -0,0 +1,72 @@
+package monster
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestHKDF(t *testing.T) {
+	// The first block of RFC 5869's first test case.
+	secret := bytes.Repeat([]byte{0x0b}, 22)
+	salt, _ := hex.DecodeString("000102030405060708090a0b0c")
+	info, _ := hex.DecodeString("f0f1f2f3f4f5f6f7f8f9")
+
+	derived := HKDF{Salt: salt, Info: info}.Derive(secret, "sha256")
+	if hex.EncodeToString(derived) != "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf" {
+		t.Errorf("unexpected HKDF output %x", derived)
+	}
+}
+
+func TestPBKDF2(t *testing.T) {
+	// RFC 6070's third test case.
+	derived := pbkdf2(hashAlgorithms["sha1"].new, []byte("password"), []byte("salt"), 4096, 20)
+	if hex.EncodeToString(derived) != "4b007901b765489abead49d926f721d065a429c1" {
+		t.Errorf("unexpected PBKDF2 output %x", derived)
+	}
+
+	// Keys longer than one digest take several blocks.
+	if long := pbkdf2(hashAlgorithms["sha1"].new, []byte("password"), []byte("salt"), 1, 32); !bytes.HasPrefix(long, pbkdf2(hashAlgorithms["sha1"].new, []byte("password"), []byte("salt"), 1, 20)) || len(long) != 32 {
+		t.Errorf("unexpected multi-block PBKDF2 output %x", long)
+	}
+}
+
+func TestUnsignDetachedHKDFExpand(t *testing.T) {
+	wl := NewWordlist()
+	if err := wl.LoadFromArray([][]byte{[]byte("changeme")}); err != nil {
+		t.Errorf("could not LoadFromArray")
+	}
+
+	// Signed with a key from HKDF-Expand("changeme", "cookie-signing").
+	c := NewDetachedCookie("user=42", "e69c9bd607b512119813f711973f822d2a88fb8b598d4eb9425bb520ac0067f2")
+	c.Decode()
+
+	if _, success := c.Unsign(wl, 100, WithKDF(HKDF{Info: []byte("cookie-signing")})); success {
+		t.Errorf("unsigned with extract-and-expand HKDF")
+	}
+
+	key, success := c.Unsign(wl, 100, WithKDF(HKDFExpand{Info: []byte("cookie-signing")}))
+	if !success || string(key) != "changeme" {
+		t.Errorf("could not unsign with HKDF-Expand")
+	}
+}
+
+func TestUnsignDetachedSaltedHash(t *testing.T) {
+	wl := NewWordlist()
+	if err := wl.LoadFromArray([][]byte{[]byte("changeme")}); err != nil {
+		t.Errorf("could not LoadFromArray")
+	}
+
+	// Signed with a key of sha256("changeme" + "custom-salt").
+	c := NewDetachedCookie("user=42&role=admin", "d22db2189b6c9a712cf574f13a7cea4260eb41c6c64b550f10d343a9c230fbb8")
+	c.Decode()
+
+	if _, success := c.Unsign(wl, 100, WithKDF(SaltedHash{Salt: []byte("custom-salt")})); success {
+		t.Errorf("unsigned with the salt before the secret")
+	}
+
+	key, success := c.Unsign(wl, 100, WithKDF(SaltedHash{Salt: []byte("custom-salt"), Order: SaltSuffix}))
+	if !success || string(key) != "changeme" {
+		t.Errorf("could not unsign with the salt after the secret")
+	}
+}
@@ -0,0 +1,270 @@
+package monster
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+var (
+	ErrInvalidMask       = errors.New("invalid mask")
+	ErrKeyspaceTooLarge  = errors.New("the keyspace has more than 2^64 candidates")
+	ErrKeyspaceCancelled = errors.New("the keyspace run was stopped by its checkpoint")
+)
+
+const (
+	// How many candidates `UnsignKeyspace()` tries between checkpoints.
+	keyspaceChunkSize = 1 << 16
+
+	maskSpecial = " !\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+)
+
+// The built-in charsets a mask may use, as in hashcat.
+var maskCharsets = map[byte]string{
+	'l': "abcdefghijklmnopqrstuvwxyz",
+	'u': "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	'd': "0123456789",
+	'h': "0123456789abcdef",
+	'H': "0123456789ABCDEF",
+	's': maskSpecial,
+	'a': "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789" + maskSpecial,
+}
+
+// Every candidate secret matching a mask, in a fixed order, so that a run
+// can be resumed from the index of any candidate; see `UnsignKeyspace()`.
+type Keyspace struct {
+	// The charset of each position of the mask.
+	positions [][]byte
+
+	// The lengths to enumerate, shortest first, and how many candidates
+	// each has.
+	lengths []int
+	sizes   []uint64
+
+	size uint64
+}
+
+// Parses a hashcat-style mask, where `?l`, `?u`, `?d`, `?h`, `?H`, `?s` and
+// `?a` are lowercase letters, uppercase letters, digits, lowercase and
+// uppercase hex, symbols and all of those, `?b` is any byte, `??` is a
+// literal `?`, and `?1` to `?4` are the `customCharsets`. Any other byte is
+// itself. Custom charsets may use the built-in ones too, e.g. `?l?d_`.
+// Candidates are as long as the mask, or, if `minLength` is positive, as
+// long as each of its prefixes from `minLength` up.
+func NewKeyspace(mask string, minLength int, customCharsets ...string) (*Keyspace, error) {
+	if len(customCharsets) > 4 {
+		return nil, fmt.Errorf("%w: only four custom charsets are supported", ErrInvalidMask)
+	}
+
+	custom := make([][]byte, len(customCharsets))
+	for i, charset := range customCharsets {
+		expanded, err := expandCharset(charset)
+		if err != nil {
+			return nil, err
+		}
+
+		custom[i] = expanded
+	}
+
+	k := &Keyspace{}
+
+	for i := 0; i < len(mask); i++ {
+		if mask[i] != '?' {
+			k.positions = append(k.positions, []byte{mask[i]})
+			continue
+		}
+
+		if i++; i == len(mask) {
+			return nil, fmt.Errorf("%w: it ends with ?", ErrInvalidMask)
+		}
+
+		charset, err := maskCharset(mask[i], custom)
+		if err != nil {
+			return nil, err
+		}
+
+		k.positions = append(k.positions, charset)
+	}
+
+	if len(k.positions) == 0 {
+		return nil, fmt.Errorf("%w: it is empty", ErrInvalidMask)
+	}
+
+	if minLength <= 0 || minLength > len(k.positions) {
+		minLength = len(k.positions)
+	}
+
+	for length := minLength; length <= len(k.positions); length++ {
+		size := uint64(1)
+		for _, charset := range k.positions[:length] {
+			high, low := bits.Mul64(size, uint64(len(charset)))
+			if high != 0 {
+				return nil, ErrKeyspaceTooLarge
+			}
+
+			size = low
+		}
+
+		total, carry := bits.Add64(k.size, size, 0)
+		if carry != 0 {
+			return nil, ErrKeyspaceTooLarge
+		}
+
+		k.lengths = append(k.lengths, length)
+		k.sizes = append(k.sizes, size)
+		k.size = total
+	}
+
+	return k, nil
+}
+
+// Returns the charset `?c` stands for.
+func maskCharset(c byte, custom [][]byte) ([]byte, error) {
+	switch {
+	case c == '?':
+		return []byte{'?'}, nil
+	case c == 'b':
+		charset := make([]byte, 256)
+		for i := range charset {
+			charset[i] = byte(i)
+		}
+
+		return charset, nil
+	case c >= '1' && c <= '4':
+		if int(c-'1') >= len(custom) {
+			return nil, fmt.Errorf("%w: custom charset ?%c is not defined", ErrInvalidMask, c)
+		}
+
+		return custom[c-'1'], nil
+	}
+
+	if charset, ok := maskCharsets[c]; ok {
+		return []byte(charset), nil
+	}
+
+	return nil, fmt.Errorf("%w: unknown charset ?%c", ErrInvalidMask, c)
+}
+
+// Expands the built-in charsets in a custom charset, and removes repeated
+// bytes so that no candidate is tried twice.
+func expandCharset(charset string) ([]byte, error) {
+	var expanded []byte
+	var seen [256]bool
+
+	add := func(b byte) {
+		if !seen[b] {
+			seen[b] = true
+			expanded = append(expanded, b)
+		}
+	}
+
+	for i := 0; i < len(charset); i++ {
+		if charset[i] != '?' {
+			add(charset[i])
+			continue
+		}
+
+		if i++; i == len(charset) {
+			return nil, fmt.Errorf("%w: a custom charset ends with ?", ErrInvalidMask)
+		}
+
+		// Custom charsets can't refer to each other.
+		builtin, err := maskCharset(charset[i], nil)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, b := range builtin {
+			add(b)
+		}
+	}
+
+	if len(expanded) == 0 {
+		return nil, fmt.Errorf("%w: a custom charset is empty", ErrInvalidMask)
+	}
+
+	return expanded, nil
+}
+
+// Returns how many candidates the keyspace has.
+func (k *Keyspace) Size() uint64 {
+	return k.size
+}
+
+// Returns the candidate at `index`, counting from zero, reporting false if
+// it is past the end. The last position of the mask changes fastest.
+func (k *Keyspace) Candidate(index uint64) ([]byte, bool) {
+	for i, size := range k.sizes {
+		if index >= size {
+			index -= size
+			continue
+		}
+
+		candidate := make([]byte, k.lengths[i])
+		for position := len(candidate) - 1; position >= 0; position-- {
+			charset := k.positions[position]
+
+			candidate[position] = charset[index%uint64(len(charset))]
+			index /= uint64(len(charset))
+		}
+
+		return candidate, true
+	}
+
+	return nil, false
+}
+
+// Like `Unsign()`, but tries every candidate of `k` from index `start`
+// instead of a wordlist. After each batch of candidates, the function given
+// to `WithCheckpoint()` receives the index to resume from; if it returns
+// false, the run stops with `ErrKeyspaceCancelled`. The returned index is
+// where the run stopped, which is `k.Size()` if every candidate was tried.
+func (c *Cookie) UnsignKeyspace(k *Keyspace, start uint64, concurrencyLimit uint64, opts ...UnsignOption) (key []byte, success bool, next uint64, err error) {
+	options, plan := c.prepareUnsign(opts)
+	defer c.progress.finish()
+
+	if !plan.any() {
+		return nil, false, start, nil
+	}
+
+	defer c.rememberSecret(options)
+	c.trySecretCache(options, plan)
+
+	budget := &candidateBudget{max: options.maxCandidates}
+	attempt := func(entry []byte, keys *derivedKeys) {
+		c.tryKey(plan, c.transformSecret(entry), entry, keys)
+	}
+
+	next = start
+	for next < k.Size() && !c.wasUnsigned() && !budget.exhausted() {
+		end := next + keyspaceChunkSize
+		if end > k.Size() || end < next {
+			end = k.Size()
+		}
+
+		index := next
+		c.bruteForceFrom(func() ([]byte, bool) {
+			if index == end || !budget.take() {
+				return nil, false
+			}
+
+			candidate, _ := k.Candidate(index)
+			index++
+
+			return candidate, true
+		}, concurrencyLimit, attempt)
+
+		// Only a whole batch is done once its workers have finished, so this
+		// is where it's safe to resume from.
+		next = index
+
+		if options.checkpoint != nil && !c.wasUnsigned() && !options.checkpoint(next) {
+			err = ErrKeyspaceCancelled
+			break
+		}
+	}
+
+	c.limitReached = !c.wasUnsigned() && budget.exhausted()
+
+	return c.unsignedKey, c.wasUnsigned(), next, err
+}
@@ -0,0 +1,105 @@
+package monster
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestKeyspace(t *testing.T) {
+	k, err := NewKeyspace("?d?d", 1)
+	if err != nil {
+		t.Fatalf("could not parse the mask: %v", err)
+	}
+
+	if k.Size() != 110 {
+		t.Errorf("keyspace has %d candidates instead of 110", k.Size())
+	}
+
+	for index, want := range map[uint64]string{0: "0", 9: "9", 10: "00", 11: "01", 109: "99"} {
+		if candidate, ok := k.Candidate(index); !ok || string(candidate) != want {
+			t.Errorf("candidate %d is %q instead of %q", index, candidate, want)
+		}
+	}
+
+	if _, ok := k.Candidate(110); ok {
+		t.Errorf("returned a candidate past the end of the keyspace")
+	}
+
+	custom, err := NewKeyspace("x?1??", 0, "ab?d a")
+	if err != nil {
+		t.Fatalf("could not parse the mask: %v", err)
+	}
+
+	// The repeated `a` is only tried once.
+	if custom.Size() != 13 {
+		t.Errorf("keyspace has %d candidates instead of 13", custom.Size())
+	}
+
+	if candidate, _ := custom.Candidate(12); string(candidate) != "x ?" {
+		t.Errorf("the last candidate is %q", candidate)
+	}
+
+	for _, mask := range []string{"", "abc?", "?z", "?1", "?b?b?b?b?b?b?b?b?b"} {
+		if _, err := NewKeyspace(mask, 0); err == nil {
+			t.Errorf("accepted the invalid mask %q", mask)
+		}
+	}
+}
+
+func TestUnsignKeyspace(t *testing.T) {
+	c := NewCookie(batchJWT)
+	if !c.Decode() || !c.UnsignWithSecret([]byte("changeme")) {
+		t.Fatalf("could not unsign the JWT")
+	}
+
+	resigned, err := c.ResignWith(`{"sub":"keyspace"}`, ResignOptions{Key: []byte("zzzy")})
+	if err != nil {
+		t.Fatalf("could not resign the JWT: %v", err)
+	}
+
+	k, err := NewKeyspace("?l?l?l?l", 0)
+	if err != nil {
+		t.Fatalf("could not parse the mask: %v", err)
+	}
+
+	// Resuming from past the key shouldn't find it.
+	c = NewCookie(resigned)
+	c.Decode()
+
+	if _, success, next, err := c.UnsignKeyspace(k, k.Size()-1, 4); success || err != nil || next != k.Size() {
+		t.Errorf("unexpected result resuming past the key: %v %v %d", success, err, next)
+	}
+
+	k, err = NewKeyspace("?l?l?l?l", 3)
+	if err != nil {
+		t.Fatalf("could not parse the mask: %v", err)
+	}
+
+	// Stopping at the first checkpoint leaves the rest for later.
+	c = NewCookie(resigned)
+	c.Decode()
+
+	_, success, next, err := c.UnsignKeyspace(k, 0, 4, WithCheckpoint(func(uint64) bool { return false }))
+	if success || !errors.Is(err, ErrKeyspaceCancelled) || next != keyspaceChunkSize {
+		t.Fatalf("unexpected result stopping at a checkpoint: %v %v %d", success, err, next)
+	}
+
+	if progress := c.Progress(); progress.Tried != keyspaceChunkSize {
+		t.Errorf("tried %d candidates before the checkpoint", progress.Tried)
+	}
+
+	var checkpoints []uint64
+	key, success, _, err := c.UnsignKeyspace(k, next, 4, WithCheckpoint(func(next uint64) bool {
+		checkpoints = append(checkpoints, next)
+		return true
+	}))
+
+	if !success || err != nil || string(key) != "zzzy" {
+		t.Fatalf("did not find the key resuming from %d: %v", next, err)
+	}
+
+	// Every batch before the one with the key ends at a checkpoint.
+	if len(checkpoints) != int(k.Size()/keyspaceChunkSize)-1 || checkpoints[0] != 2*keyspaceChunkSize {
+		t.Errorf("unexpected checkpoints %v", checkpoints)
+	}
+}
@@ -2,6 +2,8 @@ package monster
 
 import (
 	"bytes"
+	"crypto/aes"
+	"crypto/hmac"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
@@ -30,6 +32,21 @@ func (d *laravelParsedData) String() string {
 	return fmt.Sprintf("Algorithm: %s\nIV: %s\nValue: %s\nMAC: %s\nTag: %s\n", d.algorithm, d.IV, d.Value, d.MAC, d.Tag)
 }
 
+func (d *laravelParsedData) serialized(c *Cookie) []byte {
+	// The session is encrypted.
+	return nil
+}
+
+func (d *laravelParsedData) fields() parsedFields {
+	// The algorithm we keep is the cipher; the MAC is always HMAC-SHA256.
+	return parsedFields{data: d.Value, signature: d.MAC, decodedSignature: d.decodedMAC, algorithm: "sha256"}
+}
+
+func (d *laravelParsedData) clone() decoderData {
+	copied := *d
+	return &copied
+}
+
 const (
 	laravelDecoder   = "laravel"
 	laravelMinLength = 10
@@ -40,6 +57,19 @@ const (
 	// Not yet supported.
 	laravelAESGCM128 = `aes-gcm-128`
 	laravelAESGCM256 = `aes-gcm-256`
+
+	// `APP_KEY`s are usually set base64-encoded, behind this prefix.
+	laravelKeyPrefix = `base64:`
+)
+
+var (
+	// The `APP_KEY` length, in bytes, each cipher needs.
+	laravelKeyLength = map[string]int{
+		laravelAESCBC128: 16,
+		laravelAESCBC256: 32,
+		laravelAESGCM128: 16,
+		laravelAESGCM256: 32,
+	}
 )
 
 func laravelDecode(c *Cookie) bool {
@@ -107,28 +137,52 @@ func laravelUnsign(c *Cookie, secret []byte) bool {
 
 	// When Laravel uses CBC mode, we can just check the MAC.
 	if x.algorithm == laravelAESCBC128 || x.algorithm == laravelAESCBC256 {
-		return laravelCheckMac([]byte(x.Value), x.IV, x.decodedMAC, secret)
+		return laravelCheckMac([]byte(x.Value), x.IV, x.decodedMAC, laravelAppKey(secret))
 	}
 
 	return false
 }
 
-// We can detect the algorithm just based on field length, because Laravel
-// does not include an explicit MAC for GCM, and len(IV) = cipher length.
+// Laravel does not include an explicit MAC for GCM, so a MAC means CBC. A
+// CBC IV is one AES block whichever key size is used, though, so the cookie
+// can't tell AES-128 from AES-256; we assume Laravel's default of AES-256
+// until the key says otherwise (see `laravelCipher()`).
 func laravelFindAlgorithm(parsedData *laravelParsedData) string {
-	if len(parsedData.decodedIV) == 8 && len(parsedData.MAC) == 64 {
+	if len(parsedData.decodedIV) == aes.BlockSize && len(parsedData.MAC) == 64 {
+		return laravelAESCBC256
+	}
+
+	return ""
+}
+
+// Returns the cipher a cookie was encrypted with, given the `key` that
+// verified it: the MAC is the same for either CBC key size, but Laravel
+// uses AES-128 with a 16 byte key.
+func laravelCipher(parsedData *laravelParsedData, key []byte) string {
+	if parsedData.algorithm == laravelAESCBC256 && len(key) == laravelKeyLength[laravelAESCBC128] {
 		return laravelAESCBC128
 	}
 
-	if len(parsedData.decodedIV) == 16 && len(parsedData.MAC) == 64 {
-		return laravelAESCBC256
+	return parsedData.algorithm
+}
+
+// Decodes an `APP_KEY` copied from a `.env` file, such as `base64:...`, to
+// the key Laravel actually uses; other secrets are returned as they are.
+func laravelAppKey(secret []byte) []byte {
+	if !bytes.HasPrefix(secret, []byte(laravelKeyPrefix)) {
+		return secret
 	}
 
-	return ""
+	key, err := base64.StdEncoding.DecodeString(string(secret[len(laravelKeyPrefix):]))
+	if err != nil {
+		return secret
+	}
+
+	return key
 }
 
 // Check the MAC for CBC, which is HMAC-SHA256(APP_KEY, IV || encryptedData)
 func laravelCheckMac(encryptedData []byte, iv string, mac []byte, key []byte) bool {
-	hmac := sha256HMAC(key, append([]byte(iv), encryptedData...))
-	return bytes.Compare(hmac, mac) == 0
+	computedMAC := sha256HMAC(key, append([]byte(iv), encryptedData...))
+	return hmac.Equal(computedMAC, mac)
 }
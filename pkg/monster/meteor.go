@@ -0,0 +1,21 @@
+package monster
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+)
+
+// Returns the `hashedToken` Meteor stores for a DDP resume `loginToken`.
+// Meteor hashes login tokens rather than signing them, so this is what
+// must be inserted into `services.resume.loginTokens` to forge a session.
+func MeteorHashToken(loginToken string) string {
+	digest := sha256.Sum256([]byte(loginToken))
+	return base64.StdEncoding.EncodeToString(digest[:])
+}
+
+// Reports whether `loginToken` matches a stored `hashedToken`, which needs
+// no secret since Meteor only hashes tokens.
+func VerifyMeteorToken(loginToken string, hashedToken string) bool {
+	return subtle.ConstantTimeCompare([]byte(MeteorHashToken(loginToken)), []byte(hashedToken)) == 1
+}
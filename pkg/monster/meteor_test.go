@@ -0,0 +1,20 @@
+package monster
+
+import "testing"
+
+func TestMeteorToken(t *testing.T) {
+	loginToken := "rZ7gW3fY1T9vN2bQ8xK4pL6mH0sJ5cD"
+	hashedToken := "o18UjR+B7ype6WT8sM8x41zodulhZsHxyKCYY0fauhs="
+
+	if MeteorHashToken(loginToken) != hashedToken {
+		t.Errorf("unexpected hash %s", MeteorHashToken(loginToken))
+	}
+
+	if !VerifyMeteorToken(loginToken, hashedToken) {
+		t.Errorf("could not match a login token to its hash")
+	}
+
+	if VerifyMeteorToken("someOtherToken", hashedToken) {
+		t.Errorf("matched the wrong login token")
+	}
+}
@@ -0,0 +1,233 @@
+package monster
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+var (
+	errInvalidMsgpack = errors.New("invalid MessagePack data")
+)
+
+// Decodes a single MessagePack value which must span all of `data`. Maps
+// become `map[string]interface{}` (non-string keys are formatted with
+// `fmt`) so the result can be shown as JSON; extension types are kept as
+// their raw bytes.
+func msgpackDecode(data []byte) (interface{}, error) {
+	value, n, err := msgpackDecodePrefix(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if n != len(data) {
+		return nil, errInvalidMsgpack
+	}
+
+	return value, nil
+}
+
+// Like `msgpackDecode()`, but only decodes the first value in `data` and
+// returns how many bytes it took, for formats which follow it with more.
+func msgpackDecodePrefix(data []byte) (interface{}, int, error) {
+	d := msgpackDecoder{data: data}
+
+	value, err := d.value(0)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return value, d.offset, nil
+}
+
+// We refuse to nest deeper than this, so garbage can't blow the stack.
+const msgpackMaxDepth = 64
+
+type msgpackDecoder struct {
+	data   []byte
+	offset int
+}
+
+func (d *msgpackDecoder) read(n int) ([]byte, error) {
+	if n < 0 || len(d.data)-d.offset < n {
+		return nil, errInvalidMsgpack
+	}
+
+	b := d.data[d.offset : d.offset+n]
+	d.offset += n
+
+	return b, nil
+}
+
+// Reads a big-endian unsigned integer of `size` bytes.
+func (d *msgpackDecoder) uint(size int) (uint64, error) {
+	b, err := d.read(size)
+	if err != nil {
+		return 0, err
+	}
+
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	default:
+		return binary.BigEndian.Uint64(b), nil
+	}
+}
+
+// Reads a length of `size` bytes, then that many bytes.
+func (d *msgpackDecoder) sized(size int) ([]byte, error) {
+	n, err := d.uint(size)
+	if err != nil {
+		return nil, err
+	}
+
+	if n > uint64(len(d.data)) {
+		return nil, errInvalidMsgpack
+	}
+
+	return d.read(int(n))
+}
+
+func (d *msgpackDecoder) value(depth int) (interface{}, error) {
+	if depth > msgpackMaxDepth {
+		return nil, errInvalidMsgpack
+	}
+
+	b, err := d.read(1)
+	if err != nil {
+		return nil, err
+	}
+
+	tag := b[0]
+
+	switch {
+	case tag <= 0x7f:
+		return int64(tag), nil
+	case tag >= 0xe0:
+		return int64(int8(tag)), nil
+	case tag&0xf0 == 0x80:
+		return d.mapOf(int(tag&0x0f), depth)
+	case tag&0xf0 == 0x90:
+		return d.arrayOf(int(tag&0x0f), depth)
+	case tag&0xe0 == 0xa0:
+		s, err := d.read(int(tag & 0x1f))
+		return string(s), err
+	}
+
+	switch tag {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		return d.sized(1 << (tag - 0xc4))
+	case 0xc7, 0xc8, 0xc9:
+		// Extensions are a length, a type byte, then the data.
+		n, err := d.uint(1 << (tag - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+
+		if n > uint64(len(d.data)) {
+			return nil, errInvalidMsgpack
+		}
+
+		return d.read(int(n) + 1)
+	case 0xca:
+		n, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(n))), err
+	case 0xcb:
+		n, err := d.uint(8)
+		return math.Float64frombits(n), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return d.uint(1 << (tag - 0xcc))
+	case 0xd0:
+		n, err := d.uint(1)
+		return int64(int8(n)), err
+	case 0xd1:
+		n, err := d.uint(2)
+		return int64(int16(n)), err
+	case 0xd2:
+		n, err := d.uint(4)
+		return int64(int32(n)), err
+	case 0xd3:
+		n, err := d.uint(8)
+		return int64(n), err
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		// Fixed extensions are a type byte, then 1 to 16 bytes of data.
+		return d.read(1<<(tag-0xd4) + 1)
+	case 0xd9, 0xda, 0xdb:
+		s, err := d.sized(1 << (tag - 0xd9))
+		return string(s), err
+	case 0xdc, 0xdd:
+		n, err := d.uint(2 << (tag - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+
+		return d.arrayOf(int(n), depth)
+	case 0xde, 0xdf:
+		n, err := d.uint(2 << (tag - 0xde))
+		if err != nil {
+			return nil, err
+		}
+
+		return d.mapOf(int(n), depth)
+	}
+
+	// Only 0xc1 is left, which MessagePack never uses.
+	return nil, errInvalidMsgpack
+}
+
+func (d *msgpackDecoder) arrayOf(n int, depth int) (interface{}, error) {
+	// Every element takes at least a byte, which bounds bogus lengths.
+	if n > len(d.data)-d.offset {
+		return nil, errInvalidMsgpack
+	}
+
+	values := make([]interface{}, n)
+	for i := range values {
+		value, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		values[i] = value
+	}
+
+	return values, nil
+}
+
+func (d *msgpackDecoder) mapOf(n int, depth int) (interface{}, error) {
+	if n > len(d.data)-d.offset {
+		return nil, errInvalidMsgpack
+	}
+
+	values := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		key, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		if s, ok := key.(string); ok {
+			values[s] = value
+		} else {
+			values[fmt.Sprint(key)] = value
+		}
+	}
+
+	return values, nil
+}
@@ -0,0 +1,76 @@
+package monster
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A JWT found inside a decoded session payload.
+type nestedJWT struct {
+	path string
+	data *jwtParsedData
+}
+
+// Looks through a decoded JSON session payload for string values which are
+// themselves JWTs, since apps often stash API tokens inside their session.
+func findNestedJWTs(payload []byte) []nestedJWT {
+	var decoded interface{}
+	if err := json.Unmarshal(payload, &decoded); err != nil {
+		return nil
+	}
+
+	var found []nestedJWT
+
+	walkJSONStrings(decoded, "", func(path string, value string) {
+		c := NewCookie(value)
+
+		if jwtDecode(c) {
+			found = append(found, nestedJWT{path, c.parsedDataFor(jwtDecoder).(*jwtParsedData)})
+		}
+	})
+
+	return found
+}
+
+// Calls `visit` with every string in a decoded JSON value and its path, in
+// a stable order.
+func walkJSONStrings(value interface{}, path string, visit func(path string, value string)) {
+	switch v := value.(type) {
+	case string:
+		visit(path, v)
+	case []interface{}:
+		for i, element := range v {
+			walkJSONStrings(element, fmt.Sprintf("%s[%d]", path, i), visit)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+
+			walkJSONStrings(v[key], childPath, visit)
+		}
+	}
+}
+
+func nestedJWTsString(nested []nestedJWT) (out string) {
+	for _, jwt := range nested {
+		out += "Nested JWT in " + jwt.path + ":\n"
+
+		for _, line := range strings.Split(strings.TrimSuffix(jwt.data.String(), "\n"), "\n") {
+			out += "  " + line + "\n"
+		}
+	}
+
+	return out
+}
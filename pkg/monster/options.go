@@ -0,0 +1,212 @@
+package monster
+
+import (
+	"encoding/binary"
+	"fmt"
+	"unicode/utf16"
+)
+
+// An `UnsignOption` changes how `Unsign()` searches for a key.
+type UnsignOption func(*unsignOptions)
+
+// A `SecretTransform` re-encodes a candidate secret before it is used; see
+// `WithSecretTransform()`.
+type SecretTransform func(secret []byte) []byte
+
+// Encodes a UTF-8 secret as UTF-16LE, as .NET's `Encoding.Unicode` does, for
+// signers which HMAC with the bytes of a .NET string.
+func UTF16LE(secret []byte) []byte {
+	units := utf16.Encode([]rune(string(secret)))
+	encoded := make([]byte, len(units)*2)
+
+	for i, unit := range units {
+		binary.LittleEndian.PutUint16(encoded[i*2:], unit)
+	}
+
+	return encoded
+}
+
+type unsignOptions struct {
+	truncatedMinLength int
+
+	// If set, only cookies signed with these algorithms are tried.
+	algorithms map[string]bool
+
+	autoTune      bool
+	logSink       LogSink
+	maxCandidates uint64
+	canonicalJSON bool
+	kdf           KDF
+	keyID         bool
+	transform     SecretTransform
+	cookieName    string
+	checkpoint    func(next uint64) bool
+	djangoSalts   []string
+
+	// If set, keys are only tried against this decoder.
+	decoder string
+
+	// The path of the `SecretCache` to try first and add to, if any.
+	secretCache string
+}
+
+func newUnsignOptions(opts []UnsignOption) *unsignOptions {
+	options := &unsignOptions{}
+
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	return options
+}
+
+// Makes `Unsign()` also try every prefix of each wordlist entry that is at
+// least `minLength` bytes long, once the full entries have all failed. This
+// finds apps that only use the first N bytes of a longer secret.
+func WithTruncatedSecrets(minLength int) UnsignOption {
+	return func(o *unsignOptions) {
+		if minLength < 1 {
+			minLength = 1
+		}
+
+		o.truncatedMinLength = minLength
+	}
+}
+
+// Makes `Unsign()` briefly benchmark a few worker counts on the start of
+// the wordlist, and use the fastest one for the rest in place of the
+// concurrency limit it was given. This is opt-in since it delays the start.
+func WithAutoTune() UnsignOption {
+	return func(o *unsignOptions) {
+		o.autoTune = true
+	}
+}
+
+// Stops `Unsign()` after it has tried `n` candidate keys, including any
+// truncated ones; `LimitReached()` then reports whether it stopped early.
+func WithMaxCandidates(n uint64) UnsignOption {
+	return func(o *unsignOptions) {
+		o.maxCandidates = n
+	}
+}
+
+// Makes `Unsign()` also verify detached JSON values after canonicalizing
+// them (sorting keys and stripping whitespace), for APIs which sign the
+// canonical form rather than the bytes they send.
+func WithCanonicalJSON() UnsignOption {
+	return func(o *unsignOptions) {
+		o.canonicalJSON = true
+	}
+}
+
+// Makes `Unsign()` derive the key for detached signatures from each
+// candidate secret with `kdf`, rather than signing with it directly.
+func WithKDF(kdf KDF) UnsignOption {
+	return func(o *unsignOptions) {
+		o.kdf = kdf
+	}
+}
+
+// Makes `Unsign()` also treat the first of three dot-separated segments as
+// an unsigned key ID, as Tornado and some JWS-like formats do, so that only
+// the data segment is verified.
+func WithKeyID() UnsignOption {
+	return func(o *unsignOptions) {
+		o.keyID = true
+	}
+}
+
+// Makes `Unsign()` also verify detached values with the cookie's `name`
+// in front, either as `name=value` like Keygrip or run together like
+// Tornado, for schemes which sign the name along with the value.
+func WithCookieName(name string) UnsignOption {
+	return func(o *unsignOptions) {
+		o.cookieName = name
+	}
+}
+
+// Makes `Unsign()` also try each of `salts` with every Django secret, after
+// the usual one, for values signed with a custom salt, e.g. by
+// `signing.dumps()` or `get_signed_cookie()`. As with `SetDjangoSalt()`,
+// salts are used verbatim, so include the `signer` suffix Django appends.
+// Once unsigned, `DjangoSalt()` reports which one matched, and resigning
+// uses it.
+func WithDjangoSalts(salts []string) UnsignOption {
+	return func(o *unsignOptions) {
+		o.djangoSalts = salts
+	}
+}
+
+// Makes `Unsign()` only try keys against `decoder` (one of the `Decoder`
+// constants, or a registered decoder's name), for cookies which several
+// decoders recognize when the format is already known; see `Matches()`.
+func WithDecoder(decoder string) UnsignOption {
+	return func(o *unsignOptions) {
+		o.decoder = decoder
+	}
+}
+
+// Makes `Unsign()` try the secrets in the `SecretCache` at `path` before
+// its candidates, those found for the cookie's decoders first, and add the
+// key to it once it's found; see `DefaultSecretCachePath()`. Errors reading
+// or writing the cache are logged as warnings, and don't stop the search.
+func WithSecretCache(path string) UnsignOption {
+	return func(o *unsignOptions) {
+		o.secretCache = path
+	}
+}
+
+// Makes `Unsign()` pass each candidate secret through `transform` before
+// signing with it, e.g. `UTF16LE` for .NET signers. The key `Unsign()`
+// returns is the transformed one, which is what resigning needs.
+func WithSecretTransform(transform SecretTransform) UnsignOption {
+	return func(o *unsignOptions) {
+		o.transform = transform
+	}
+}
+
+// Makes `UnsignKeyspace()` call `checkpoint` with the index to resume from
+// after each batch of candidates, e.g. to save it, and stop if it returns
+// false. It has no effect on wordlists.
+func WithCheckpoint(checkpoint func(next uint64) bool) UnsignOption {
+	return func(o *unsignOptions) {
+		o.checkpoint = checkpoint
+	}
+}
+
+// Sends the engine's found-key and warning messages to `sink` instead of
+// printing them.
+func WithLogSink(sink LogSink) UnsignOption {
+	return func(o *unsignOptions) {
+		o.logSink = sink
+	}
+}
+
+// Restricts `Unsign()` to cookies signed with one of `algorithms` (e.g.
+// "sha256"), which avoids wasted work and false positives when the
+// algorithm is already known. Unknown algorithm names are rejected.
+func WithAlgorithms(algorithms []string) (UnsignOption, error) {
+	allowed := make(map[string]bool, len(algorithms))
+
+	for _, algorithm := range algorithms {
+		if _, ok := algorithmDigestLength[algorithm]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownAlgorithm, algorithm)
+		}
+
+		allowed[algorithm] = true
+	}
+
+	return func(o *unsignOptions) {
+		o.algorithms = allowed
+	}, nil
+}
+
+// Reports whether `decoder` may be tried under these options.
+func (o *unsignOptions) allowsDecoder(decoder string) bool {
+	return o.decoder == "" || o.decoder == decoder
+}
+
+// Reports whether `algorithm` may be tried under these options.
+func (o *unsignOptions) allowsAlgorithm(algorithm string) bool {
+	return o.algorithms == nil || o.algorithms[algorithm]
+}
@@ -0,0 +1,107 @@
+package monster
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// An `Oracle` reports whether a live server accepts a cookie. This lets us
+// find keys for signers that we can resign for, but where testing candidates
+// offline is not possible (e.g. an unknown salt baked into the server). For
+// frameworks which keep the signature in a separate cookie, `cookie` is a
+// `Cookie` header holding each of them, e.g. `session=...; session.sig=...`.
+type Oracle interface {
+	Accepts(cookie string) (bool, error)
+}
+
+var (
+	ErrOracleUnsupported = errors.New("no decoder for this cookie supports resigning")
+)
+
+// Resigns `data` with every wordlist entry and asks `oracle` whether it
+// accepts the result, waiting at least `interval` between requests so we
+// don't hammer the server. The first accepted entry is the key. Errors from
+// the oracle stop the search.
+func (c *Cookie) UnsignWithOracle(wl *Wordlist, data string, oracle Oracle, interval time.Duration) (key []byte, success bool, err error) {
+	decoder := c.resignableDecoder()
+	if decoder == "" {
+		return nil, false, ErrOracleUnsupported
+	}
+
+	var throttle <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+
+	for i, entry := range wl.Entries() {
+		// The first request doesn't need to wait for anything.
+		if throttle != nil && i > 0 {
+			<-throttle
+		}
+
+		resigned, err := c.oracleCookie(decoder, data, entry)
+		if err != nil {
+			return nil, false, err
+		}
+
+		accepted, err := oracle.Accepts(resigned)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if accepted {
+			c.wasUnsignedBy(decoder, entry, entry)
+			return entry, true, nil
+		}
+	}
+
+	return nil, false, nil
+}
+
+// Returns the likeliest decoder, in `Matches()` order, that is able to
+// resign the cookie, or an empty string if there is none. We can't tell
+// whether a registered decoder's `Resign()` works until it's called, so
+// they're all assumed to.
+func (c *Cookie) resignableDecoder() string {
+	for _, match := range c.Matches() {
+		if b, ok := builtinsByName[match.Decoder]; ok {
+			if b.resign != nil {
+				return match.Decoder
+			}
+		} else if _, ok := c.customParsedDataFor(match.Decoder); ok {
+			return match.Decoder
+		}
+	}
+
+	return ""
+}
+
+// Resigns `data` with `key` the way `oracle` is sent it.
+func (c *Cookie) oracleCookie(decoder string, data string, key []byte) (string, error) {
+	if decoder != expressDecoder {
+		return c.resignWith(decoder, data, key, c.signatureAlgorithm(decoder), CompressionOriginal, time.Time{})
+	}
+
+	cookies, err := expressResignCookies(c, data, key, c.signatureAlgorithm(decoder))
+	if err != nil {
+		return "", err
+	}
+
+	names := make([]string, 0, len(cookies))
+	for name := range cookies {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, name+"="+cookies[name])
+	}
+
+	return strings.Join(pairs, "; "), nil
+}
@@ -0,0 +1,138 @@
+package monster
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Behaves like a server that signs sessions with `secret` using `decoder`,
+// or Django if it's empty.
+type fakeOracle struct {
+	decoder string
+	secret  []byte
+	calls   int
+	fail    bool
+}
+
+func (o *fakeOracle) Accepts(cookie string) (bool, error) {
+	o.calls++
+
+	if o.fail {
+		return false, errors.New("server unavailable")
+	}
+
+	decoder := o.decoder
+	if decoder == "" {
+		decoder = djangoDecoder
+	}
+
+	c := NewCookie(cookie)
+	if decoder == expressDecoder {
+		cookies := make(map[string]string)
+		for _, pair := range strings.Split(cookie, "; ") {
+			if components := strings.SplitN(pair, "=", 2); len(components) == 2 {
+				cookies[components[0]] = components[1]
+			}
+		}
+
+		var ok bool
+		if c, ok = NewCookieFromMap(cookies); !ok {
+			return false, nil
+		}
+	}
+
+	if !c.Decode() {
+		return false, nil
+	}
+
+	return c.verifyWith(decoder, o.secret), nil
+}
+
+func TestUnsignWithOracle(t *testing.T) {
+	wl := NewWordlist()
+	if err := wl.LoadFromArray([][]byte{[]byte("abc"), []byte("def"), []byte("changeme"), []byte("ghi")}); err != nil {
+		t.Errorf("could not LoadFromArray")
+	}
+
+	c := NewCookie("gAJ9cQFVBV9uZXh0cQJYAQAAAC9zLg:1mh2IM:rAOWFyG5ROIOxriY8pwm9jFma5w")
+	if !c.Decode() {
+		t.Errorf("cannot decode valid django cookie")
+	}
+
+	oracle := &fakeOracle{secret: []byte("changeme")}
+	key, success, err := c.UnsignWithOracle(wl, `{"user":"admin"}`, oracle, time.Millisecond)
+
+	if err != nil || !success {
+		t.Fatalf("oracle did not accept the correct key: %v", err)
+	}
+
+	if string(key) != "changeme" {
+		t.Errorf("unexpected key %q", key)
+	}
+
+	if oracle.calls != 3 {
+		t.Errorf("oracle was asked %d times instead of stopping at the key", oracle.calls)
+	}
+
+	if _, _, decoder := c.Result(); decoder != djangoDecoder {
+		t.Errorf("cookie was not marked as unsigned by django")
+	}
+}
+
+func TestUnsignWithOracleErrors(t *testing.T) {
+	wl := NewWordlist()
+	if err := wl.LoadFromArray([][]byte{[]byte("changeme")}); err != nil {
+		t.Errorf("could not LoadFromArray")
+	}
+
+	c := NewCookie("gAJ9cQFVBV9uZXh0cQJYAQAAAC9zLg:1mh2IM:rAOWFyG5ROIOxriY8pwm9jFma5w")
+	c.Decode()
+
+	if _, _, err := c.UnsignWithOracle(wl, "{}", &fakeOracle{fail: true}, 0); err == nil {
+		t.Errorf("oracle error was not returned")
+	}
+
+	rack := NewCookie("BAhJIgl0ZXN0BjoGRVQ=--8c5ae09ed57f1e933cc466f5b99ea636d1fc31a2")
+	rack.Decode()
+
+	if _, _, err := rack.UnsignWithOracle(wl, "{}", &fakeOracle{}, 0); err != ErrOracleUnsupported {
+		t.Errorf("expected ErrOracleUnsupported, got %v", err)
+	}
+}
+
+func TestUnsignWithOracleDecoders(t *testing.T) {
+	wl := NewWordlist()
+	if err := wl.LoadFromArray([][]byte{[]byte("abc"), []byte("changeme")}); err != nil {
+		t.Errorf("could not LoadFromArray")
+	}
+
+	tests := []struct {
+		decoder string
+		cookie  string
+		data    string
+	}{
+		{flaskDecoder, selfTestFixtures[flaskDecoder][0].cookie, `{"user":"admin"}`},
+		{jwtDecoder, selfTestFixtures[jwtDecoder][0].cookie, `{"sub":"admin"}`},
+		{expressDecoder, "session=eyJhbmltYWxzIjoibGlvbiJ9^Vf2INocdJIqKWVfYGhXwPhQZNFI", `{"animals":"tiger"}`},
+	}
+
+	for _, test := range tests {
+		c := NewCookie(test.cookie)
+		if !c.Decode() {
+			t.Fatalf("%s: could not decode the cookie", test.decoder)
+		}
+
+		oracle := &fakeOracle{decoder: test.decoder, secret: []byte("changeme")}
+		key, success, err := c.UnsignWithOracle(wl, test.data, oracle, 0)
+
+		if err != nil || !success || string(key) != "changeme" {
+			t.Errorf("%s: oracle did not accept the correct key: %q, %v", test.decoder, key, err)
+		}
+
+		if _, _, decoder := c.Result(); decoder != test.decoder {
+			t.Errorf("%s: cookie was marked as unsigned by %s", test.decoder, decoder)
+		}
+	}
+}
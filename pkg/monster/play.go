@@ -0,0 +1,190 @@
+package monster
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+type playParsedData struct {
+	// The cookie name, if the value was given as `PLAY_SESSION=...`.
+	name string
+
+	data             string
+	decodedData      []byte
+	signature        string
+	decodedSignature []byte
+	algorithm        string
+
+	parsed bool
+}
+
+func (d *playParsedData) String() string {
+	if !d.parsed {
+		return "Unparsed data"
+	}
+
+	return fmt.Sprintf("Signature: %s\nSeparator: %s\nData: %s\nDecoded data: %s\nAlgorithm: %s\n", d.signature, playSeparator, d.data, displayBytes(d.decodedData), d.algorithm)
+}
+
+func (d *playParsedData) serialized(c *Cookie) []byte {
+	return d.decodedData
+}
+
+func (d *playParsedData) fields() parsedFields {
+	return parsedFields{data: d.data, signature: d.signature, decodedSignature: d.decodedSignature, algorithm: d.algorithm}
+}
+
+func (d *playParsedData) clone() decoderData {
+	copied := *d
+	return &copied
+}
+
+// Play's legacy cookie format, used by Play 1 and by Play 2 before 2.6 (and
+// after, with `play.http.session.jwt` turned off), is the hex HMAC of the
+// URL-encoded session, a dash, then the session itself. Play 2.6 and later
+// sign JWTs with `play.http.secret.key` instead, which the jwt decoder
+// already handles.
+const (
+	playDecoder   = "play"
+	playMinLength = 42
+
+	playSeparator = `-`
+)
+
+var (
+	// Play only ever names its cookies these, so a leading name is kept for
+	// resigning rather than mistaken for data.
+	playCookieNames = []string{"PLAY_SESSION", "PLAY_FLASH"}
+
+	// Play 2 signs with HMAC-SHA1, but `play.http.secret` can configure
+	// another algorithm.
+	playAlgorithmLength = map[int]string{
+		20: "sha1",
+		32: "sha256",
+		64: "sha512",
+	}
+)
+
+func playDecode(c *Cookie) bool {
+	rawData := c.raw
+
+	var parsedData playParsedData
+	for _, name := range playCookieNames {
+		if strings.HasPrefix(rawData, name+"=") {
+			parsedData.name, rawData = name, strings.TrimPrefix(rawData, name+"=")
+			break
+		}
+	}
+
+	if len(rawData) < playMinLength {
+		return false
+	}
+
+	// The signature is hex, so it never contains the separator.
+	components := strings.SplitN(rawData, playSeparator, 2)
+	if len(components) != 2 || components[1] == "" {
+		return false
+	}
+
+	parsedData.signature, parsedData.data = components[0], components[1]
+
+	decodedSignature, err := hex.DecodeString(parsedData.signature)
+	if err != nil {
+		return false
+	}
+
+	alg, ok := playAlgorithmLength[len(decodedSignature)]
+	if !ok {
+		c.unknownSignatureLength(playDecoder, len(decodedSignature))
+		return false
+	}
+
+	session, ok := playSession(parsedData.data)
+	if !ok {
+		return false
+	}
+
+	parsedData.decodedData, _ = json.Marshal(session)
+	parsedData.decodedSignature = decodedSignature
+	parsedData.algorithm = alg
+	parsedData.parsed = true
+	c.wasDecodedBy(playDecoder, &parsedData)
+
+	return true
+}
+
+// Parses a Play session: Play 2 URL-encodes it as a form, while Play 1
+// wraps each `key:value` pair in NUL bytes before URL-encoding it.
+func playSession(data string) (map[string]string, bool) {
+	unescaped, err := url.QueryUnescape(data)
+	if err != nil {
+		return nil, false
+	}
+
+	session := make(map[string]string)
+
+	if strings.HasPrefix(unescaped, "\x00") {
+		for _, pair := range strings.Split(unescaped, "\x00") {
+			if pair == "" {
+				continue
+			}
+
+			components := strings.SplitN(pair, ":", 2)
+			if len(components) != 2 {
+				return nil, false
+			}
+
+			session[components[0]] = components[1]
+		}
+
+		return session, true
+	}
+
+	// Every pair must have a value, so arbitrary dashed strings aren't
+	// mistaken for sessions.
+	for _, pair := range strings.Split(data, "&") {
+		if !strings.Contains(pair, "=") {
+			return nil, false
+		}
+	}
+
+	values, err := url.ParseQuery(data)
+	if err != nil {
+		return nil, false
+	}
+
+	for key := range values {
+		session[key] = values.Get(key)
+	}
+
+	return session, true
+}
+
+func playUnsign(c *Cookie, secret []byte, keys *derivedKeys) bool {
+	parsedData := c.parsedDataFor(playDecoder).(*playParsedData)
+
+	// Play signs the session exactly as it appears in the cookie.
+	return keys.verify(secretKeyed(parsedData.algorithm), secret, []byte(parsedData.data), parsedData.decodedSignature)
+}
+
+// Resigns the cookie with a new session, given as Play would encode it
+// (e.g. `username=admin&role=admin`), keeping the cookie name if the
+// original had one.
+func playResign(c *Cookie, data string, secret []byte, algorithm string) (string, error) {
+	parsedData := c.parsedDataFor(playDecoder).(*playParsedData)
+
+	hashAlgorithm, ok := hashAlgorithms[algorithm]
+	if !ok {
+		return "", ErrUnknownAlgorithm
+	}
+
+	resigned := hex.EncodeToString(hashAlgorithm.hmac(secret, []byte(data))) + playSeparator + data
+	if parsedData.name != "" {
+		resigned = parsedData.name + "=" + resigned
+	}
+
+	return resigned, nil
+}
@@ -0,0 +1,50 @@
+package monster
+
+import (
+	"testing"
+)
+
+func TestDecodePlay(t *testing.T) {
+	// Play 1 wraps each pair in NUL bytes, and Play 2 encodes a form.
+	for _, test := range []struct {
+		raw     string
+		session string
+	}{
+		{"8fefe89b513ad24c6ea1e5d15db24e3caa21071e-%00username%3Aadmin%00%00role%3Auser%00", `{"role":"user","username":"admin"}`},
+		{"PLAY_SESSION=24aa3aeb87a8693c681a66aff676b6ed63bc0842-username=admin", `{"username":"admin"}`},
+	} {
+		c := NewCookie(test.raw)
+		if !c.Decode() || !c.hasParsedDataFor(playDecoder) {
+			t.Fatalf("could not decode %s", test.raw)
+		}
+
+		if payload, ok := c.Payload(playDecoder); !ok || string(payload) != test.session {
+			t.Errorf("expected the session %s, got %s", test.session, payload)
+		}
+
+		if !c.UnsignWithSecret([]byte("changeme")) {
+			t.Errorf("could not unsign %s", test.raw)
+		}
+	}
+
+	// Dashed values that aren't sessions shouldn't be mistaken for them.
+	if c := NewCookie("24aa3aeb87a8693c681a66aff676b6ed63bc0842-not-a-session"); c.Decode() && c.hasParsedDataFor(playDecoder) {
+		t.Errorf("decoded a value with no session as Play's")
+	}
+}
+
+func TestResignPlay(t *testing.T) {
+	c := NewCookie("PLAY_SESSION=24aa3aeb87a8693c681a66aff676b6ed63bc0842-username=admin")
+	if !c.Decode() || !c.UnsignWithSecret([]byte("changeme")) {
+		t.Fatalf("could not unsign the Play cookie")
+	}
+
+	resigned, err := c.Resign("username=admin&role=admin")
+	if err != nil {
+		t.Fatalf("could not resign the Play cookie: %v", err)
+	}
+
+	if resigned != "PLAY_SESSION=b041c5191dc3507cf10c340efe296c815a05123e-username=admin&role=admin" {
+		t.Errorf("unexpected resigned cookie %s", resigned)
+	}
+}
@@ -0,0 +1,114 @@
+package monster
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"hash"
+)
+
+// What a decoder derived an HMAC key from, besides the secret itself.
+// Cookies from the same app usually share all of it, so their keys only
+// need deriving once per candidate secret. Formats which key the HMAC with
+// the secret itself share `secretKeyed()`.
+type derivation struct {
+	decoder   string
+	algorithm string
+	salt      string
+	order     SaltOrder
+}
+
+type derivedMAC struct {
+	derivation derivation
+	mac        hash.Hash
+}
+
+// What a registered decoder's `Precompute()` returned for the secret.
+type precomputedKey struct {
+	decoder string
+	key     []byte
+}
+
+// The keyed HMACs decoders have derived from one candidate secret, which
+// are shared between the cookies and layouts the secret is tried on. Each
+// belongs to one worker, so it needs no locking.
+type derivedKeys struct {
+	secret      []byte
+	macs        []derivedMAC
+	precomputed []precomputedKey
+}
+
+// Returns the derivation of an HMAC with `algorithm` keyed with the secret
+// itself.
+func secretKeyed(algorithm string) derivation {
+	return derivation{algorithm: algorithm}
+}
+
+// Forgets what was derived from the previous secret if `secret` is another,
+// so a worker can keep its `derivedKeys` between candidates.
+func (keys *derivedKeys) use(secret []byte) {
+	if keys == nil || bytes.Equal(keys.secret, secret) {
+		return
+	}
+
+	keys.secret = append(keys.secret[:0], secret...)
+	keys.macs = keys.macs[:0]
+	keys.precomputed = keys.precomputed[:0]
+}
+
+// Returns an HMAC keyed with what `derive` returns for `derivation`. Only
+// the first call for each derivation derives and keys it; later ones just
+// `Reset()` it, which restores the keyed state without hashing the key
+// again. With nil `keys`, as when checking a single secret, it's derived
+// every time.
+func (keys *derivedKeys) mac(derivation derivation, newHash func() hash.Hash, derive func() []byte) hash.Hash {
+	if keys == nil {
+		return hmac.New(newHash, derive())
+	}
+
+	for _, derived := range keys.macs {
+		if derived.derivation == derivation {
+			derived.mac.Reset()
+			return derived.mac
+		}
+	}
+
+	mac := hmac.New(newHash, derive())
+	keys.macs = append(keys.macs, derivedMAC{derivation: derivation, mac: mac})
+
+	return mac
+}
+
+// Reports whether the HMAC `derivation` describes, keyed with `key`, signs
+// `data` as `signature`.
+func (keys *derivedKeys) verify(derivation derivation, key []byte, data []byte, signature []byte) bool {
+	algorithm, ok := verifyingAlgorithm(derivation.algorithm)
+	if !ok {
+		return false
+	}
+
+	mac := keys.mac(derivation, algorithm.new, func() []byte {
+		return key
+	})
+
+	mac.Write(data)
+	return hmac.Equal(signature, mac.Sum(nil))
+}
+
+// Returns what `registered` precomputes from `secret`, computing it only
+// once per secret.
+func (keys *derivedKeys) precompute(registered registeredDecoder, secret []byte) []byte {
+	if keys == nil {
+		return registered.decoder.Precompute(secret)
+	}
+
+	for _, precomputed := range keys.precomputed {
+		if precomputed.decoder == registered.name {
+			return precomputed.key
+		}
+	}
+
+	key := registered.decoder.Precompute(secret)
+	keys.precomputed = append(keys.precomputed, precomputedKey{decoder: registered.name, key: key})
+
+	return key
+}
@@ -1,9 +1,10 @@
 package monster
 
 import (
-	"bytes"
+	"encoding/base64"
 	"encoding/hex"
 	"fmt"
+	"net/url"
 	"strings"
 )
 
@@ -21,7 +22,31 @@ func (d *rackParsedData) String() string {
 		return "Unparsed data"
 	}
 
-	return fmt.Sprintf("Data: %s\nSignature: %s\nAlgorithm: %s\n", d.data, d.signature, d.algorithm)
+	return fmt.Sprintf("Data: %s\nSeparator: %s\nSignature: %s\nAlgorithm: %s\n", d.data, rackSeparator, d.signature, d.algorithm)
+}
+
+// Rack's session is a base64 Marshal dump, which the cookie URL-encodes.
+func (d *rackParsedData) serialized(c *Cookie) []byte {
+	data, err := url.QueryUnescape(d.data)
+	if err != nil {
+		return nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil
+	}
+
+	return decoded
+}
+
+func (d *rackParsedData) fields() parsedFields {
+	return parsedFields{data: d.data, signature: d.signature, decodedSignature: d.decodedSignature, algorithm: d.algorithm}
+}
+
+func (d *rackParsedData) clone() decoderData {
+	copied := *d
+	return &copied
 }
 
 const (
@@ -69,6 +94,7 @@ func rackDecode(c *Cookie) bool {
 	if alg, ok := rackAlgorithmLength[len(decodedSignature)]; ok {
 		parsedData.algorithm = alg
 	} else {
+		c.unknownSignatureLength(rackDecoder, len(decodedSignature))
 		return false
 	}
 
@@ -79,37 +105,12 @@ func rackDecode(c *Cookie) bool {
 	return true
 }
 
-func rackUnsign(c *Cookie, secret []byte) bool {
+func rackUnsign(c *Cookie, secret []byte, keys *derivedKeys) bool {
 	// We need to extract `toBeSigned` to prepare what we'll be signing.
 	parsedData := c.parsedDataFor(rackDecoder).(*rackParsedData)
 	toBeSigned := parsedData.data
 
-	switch parsedData.algorithm {
-	case "sha1":
-		// Derive the correct signature, if this was the correct secret key.
-		computedSignature := sha1HMAC(secret, []byte(toBeSigned))
-
-		// Compare this signature to the one in the `Cookie`.
-		return bytes.Compare(parsedData.decodedSignature, computedSignature) == 0
-	case "sha256":
-		// Derive the correct signature, if this was the correct secret key.
-		computedSignature := sha256HMAC(secret, []byte(toBeSigned))
-
-		// Compare this signature to the one in the `Cookie`.
-		return bytes.Compare(parsedData.decodedSignature, computedSignature) == 0
-	case "sha384":
-		// Derive the correct signature, if this was the correct secret key.
-		computedSignature := sha384HMAC(secret, []byte(toBeSigned))
-
-		// Compare this signature to the one in the `Cookie`.
-		return bytes.Compare(parsedData.decodedSignature, computedSignature) == 0
-	case "sha512":
-		// Derive the correct signature, if this was the correct secret key.
-		computedSignature := sha512HMAC(secret, []byte(toBeSigned))
-
-		// Compare this signature to the one in the `Cookie`.
-		return bytes.Compare(parsedData.decodedSignature, computedSignature) == 0
-	default:
-		panic("unknown algorithm")
-	}
+	// Derive the correct signature, if this was the correct secret key, and
+	// compare it to the one in the `Cookie`.
+	return keys.verify(secretKeyed(parsedData.algorithm), secret, []byte(toBeSigned), parsedData.decodedSignature)
 }
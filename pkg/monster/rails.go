@@ -0,0 +1,407 @@
+package monster
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrNotRailsCookie = errors.New("the cookie was not unsigned as an encrypted Rails cookie")
+	ErrNoCSRFToken    = errors.New("the Rails session has no _csrf_token")
+)
+
+type railsParsedData struct {
+	data       string
+	ciphertext []byte
+	iv         []byte
+	authTag    []byte
+
+	// Whether the cookie was URL-encoded, as Rack sets it.
+	escaped bool
+
+	// When we decoded the cookie, which is as close as we can get to when
+	// Rails encrypted it; see `railsMoveExpiry()`.
+	decodedAt time.Time
+
+	// Which of `railsDigests` derived the key, counting from one, once a
+	// secret has decrypted the cookie. It is accessed atomically.
+	matched int32
+
+	parsed bool
+}
+
+func (d *railsParsedData) String() string {
+	if !d.parsed {
+		return "Unparsed data"
+	}
+
+	return fmt.Sprintf("Data: %s\nSeparator: %s\nCiphertext: %d bytes\nIV: %x\nAuth tag: %x\nCipher: aes-256-gcm\n", d.data, railsSeparator, len(d.ciphertext), d.iv, d.authTag)
+}
+
+// The session is encrypted, so it's only available once the cookie has been
+// unsigned.
+func (d *railsParsedData) serialized(c *Cookie) []byte {
+	session, err := c.RailsSession()
+	if err != nil {
+		return nil
+	}
+
+	return session
+}
+
+func (d *railsParsedData) fields() parsedFields {
+	// Rails derives the key with this digest, rather than signing.
+	return parsedFields{data: d.data, signature: hex.EncodeToString(d.authTag), decodedSignature: d.authTag, algorithm: d.digest()}
+}
+
+func (d *railsParsedData) clone() decoderData {
+	copied := *d
+	copied.matched = 0
+
+	// A cookie decoded from the cache was only just captured, not when it
+	// was first decoded.
+	copied.decodedAt = now()
+	return &copied
+}
+
+// Rails 5.2 and later encrypt cookies with `ActiveSupport::MessageEncryptor`
+// as `ciphertext--iv--auth_tag`, each in base64, with a key derived from
+// `secret_key_base` by `ActiveSupport::KeyGenerator`.
+const (
+	railsDecoder   = "rails"
+	railsMinLength = 40
+
+	railsSeparator = `--`
+
+	railsIVLength      = 12
+	railsAuthTagLength = 16
+	railsKeyLength     = 32
+
+	// How `Time#iso8601(3)` formats the expiry in a session's envelope.
+	railsExpiryFormat = "2006-01-02T15:04:05.000Z07:00"
+
+	// The defaults of `config.action_dispatch.authenticated_encrypted_cookie_salt`
+	// and of the iterations `Rails.application.key_generator` uses.
+	railsDefaultSalt       = "authenticated encrypted cookie"
+	railsDefaultIterations = 1000
+)
+
+var (
+	// The digests `KeyGenerator` may derive keys with: SHA256 from Rails 7,
+	// and SHA1 before it, which upgraded apps often keep.
+	railsDigests = []string{"sha256", "sha1"}
+)
+
+func railsDecode(c *Cookie) bool {
+	if len(c.raw) < railsMinLength {
+		return false
+	}
+
+	rawData := c.raw
+	var parsedData railsParsedData
+
+	// Rack URL-encodes cookies, which would escape the base64 padding.
+	if strings.Contains(rawData, "%") {
+		unescaped, err := url.QueryUnescape(rawData)
+		if err != nil {
+			return false
+		}
+
+		rawData = unescaped
+		parsedData.escaped = true
+	}
+
+	components := strings.Split(rawData, railsSeparator)
+	if len(components) != 3 {
+		return false
+	}
+
+	var decoded [3][]byte
+	for i, component := range components {
+		value, err := base64.StdEncoding.DecodeString(component)
+		if err != nil || len(value) == 0 {
+			return false
+		}
+
+		decoded[i] = value
+	}
+
+	if len(decoded[1]) != railsIVLength || len(decoded[2]) != railsAuthTagLength {
+		return false
+	}
+
+	parsedData.data = rawData
+	parsedData.ciphertext = decoded[0]
+	parsedData.iv = decoded[1]
+	parsedData.authTag = decoded[2]
+	parsedData.decodedAt = now()
+	parsedData.parsed = true
+	c.wasDecodedBy(railsDecoder, &parsedData)
+
+	return true
+}
+
+// Like `railsUnsignWith()`, but with only the digests `prepareUnsign()`
+// found the options allow.
+func railsUnsignPlanned(c *Cookie, secret []byte, plan *unsignPlan, keys *derivedKeys) bool {
+	return railsUnsignWith(c, secret, plan.railsDigests)
+}
+
+// Checks `secret` as the app's `secret_key_base`, by deriving the cookie
+// key from it with each of `digests`, or of `railsDigests` if it's nil, and
+// seeing whether that decrypts the cookie.
+func railsUnsignWith(c *Cookie, secret []byte, digests map[string]bool) bool {
+	parsedData := c.parsedDataFor(railsDecoder).(*railsParsedData)
+
+	for i, digest := range railsDigests {
+		if digests != nil && !digests[digest] {
+			continue
+		}
+
+		if _, ok := railsDecrypt(parsedData, c.railsKey(secret, digest)); ok {
+			atomic.StoreInt32(&parsedData.matched, int32(i+1))
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the `railsDigests` the options allow the key to be derived with.
+// Until a key decrypts the cookie, it could have been either.
+func railsAllowedDigests(options *unsignOptions) map[string]bool {
+	digests := make(map[string]bool)
+	for _, digest := range railsDigests {
+		if options.allowsAlgorithm(digest) {
+			digests[digest] = true
+		}
+	}
+
+	return digests
+}
+
+// Returns the digest the key was derived with, once a secret has decrypted
+// the cookie; until then, we assume the current Rails default.
+func (d *railsParsedData) digest() string {
+	if matched := atomic.LoadInt32(&d.matched); matched > 0 {
+		return railsDigests[matched-1]
+	}
+
+	return railsDigests[0]
+}
+
+// Derives the cookie encryption key from `secretKeyBase` as
+// `KeyGenerator#generate_key` does, with PBKDF2.
+func (c *Cookie) railsKey(secretKeyBase []byte, digest string) []byte {
+	salt := c.railsSalt
+	if salt == "" {
+		salt = railsDefaultSalt
+	}
+
+	iterations := c.railsIterations
+	if iterations == 0 {
+		iterations = railsDefaultIterations
+	}
+
+	return pbkdf2(hashAlgorithms[digest].new, secretKeyBase, []byte(salt), iterations, railsKeyLength)
+}
+
+func railsDecrypt(parsedData *railsParsedData, key []byte) ([]byte, bool) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, false
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, false
+	}
+
+	sealed := append(append([]byte{}, parsedData.ciphertext...), parsedData.authTag...)
+	plaintext, err := gcm.Open(nil, parsedData.iv, sealed, nil)
+
+	return plaintext, err == nil
+}
+
+// Returns the decrypted contents of a Rails cookie once it's unsigned. From
+// Rails 5.2 to 7.0 this is a JSON envelope holding the base64 of the
+// serialized session in `_rails.message`, along with its expiry and purpose.
+func (c *Cookie) RailsSession() ([]byte, error) {
+	success, key, decoder := c.Result()
+	if !success || decoder != railsDecoder {
+		return nil, ErrNotRailsCookie
+	}
+
+	parsedData := c.parsedDataFor(railsDecoder).(*railsParsedData)
+
+	plaintext, ok := railsDecrypt(parsedData, c.railsKey(key, parsedData.digest()))
+	if !ok {
+		return nil, ErrNotRailsCookie
+	}
+
+	return plaintext, nil
+}
+
+// Returns the `_csrf_token` Rails keeps in the session for
+// `protect_from_forgery`, which forms and requests made with the session
+// have to send back, once the cookie is unsigned.
+func (c *Cookie) RailsCSRFToken() (string, error) {
+	plaintext, err := c.RailsSession()
+	if err != nil {
+		return "", err
+	}
+
+	var token string
+	if json.Unmarshal(railsSessionHash(plaintext)["_csrf_token"], &token) != nil || token == "" {
+		return "", ErrNoCSRFToken
+	}
+
+	return token, nil
+}
+
+// Returns the session hash in a decrypted Rails cookie: the base64
+// `_rails.message` of its envelope, which some apps serialize to JSON twice,
+// its `_rails.data` from Rails 7.1, or the plaintext itself for cookies
+// with no envelope. It's nil if the session isn't JSON.
+func railsSessionHash(plaintext []byte) map[string]json.RawMessage {
+	var envelope struct {
+		Rails *struct {
+			Message string          `json:"message"`
+			Data    json.RawMessage `json:"data"`
+		} `json:"_rails"`
+	}
+
+	if json.Unmarshal(plaintext, &envelope) != nil {
+		return nil
+	}
+
+	session := plaintext
+	if envelope.Rails != nil {
+		session = envelope.Rails.Data
+		if envelope.Rails.Message != "" {
+			decoded, err := base64.StdEncoding.DecodeString(envelope.Rails.Message)
+			if err != nil {
+				return nil
+			}
+
+			session = decoded
+		}
+	}
+
+	var inner string
+	if json.Unmarshal(session, &inner) == nil {
+		session = []byte(inner)
+	}
+
+	var hash map[string]json.RawMessage
+	if json.Unmarshal(session, &hash) != nil {
+		return nil
+	}
+
+	return hash
+}
+
+// Encrypts new, unencoded `data` with the key derived from `secret` using
+// `algorithm` as the PBKDF2 digest, under a fresh IV. The URL encoding of
+// the original cookie is kept. Unless `timestamp` is zero, the expiry in
+// the session's envelope is moved to match it; see `railsMoveExpiry()`.
+func railsResign(c *Cookie, data string, secret []byte, algorithm string, compression Compression, timestamp time.Time) (string, error) {
+	parsedData := c.parsedDataFor(railsDecoder).(*railsParsedData)
+
+	if algorithm != "sha256" && algorithm != "sha1" {
+		return "", ErrUnknownAlgorithm
+	}
+
+	if !timestamp.IsZero() {
+		moved, err := railsMoveExpiry(parsedData, data, timestamp)
+		if err != nil {
+			return "", err
+		}
+
+		data = moved
+	}
+
+	block, err := aes.NewCipher(c.railsKey(secret, algorithm))
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+
+	iv := make([]byte, railsIVLength)
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nil, iv, []byte(data), nil)
+	ciphertext, authTag := sealed[:len(sealed)-railsAuthTagLength], sealed[len(sealed)-railsAuthTagLength:]
+
+	resigned := strings.Join([]string{
+		base64.StdEncoding.EncodeToString(ciphertext),
+		base64.StdEncoding.EncodeToString(iv),
+		base64.StdEncoding.EncodeToString(authTag),
+	}, railsSeparator)
+
+	if parsedData.escaped {
+		resigned = url.QueryEscape(resigned)
+	}
+
+	return resigned, nil
+}
+
+// Moves the `_rails.exp` of the session envelope in `data`, so that the new
+// cookie expires as long after `timestamp` as the original did after it was
+// encrypted. Rails doesn't record when that was, so we take the original's
+// lifetime to be what it had left when we decoded it. Sessions with no
+// expiry, or which had already expired, are returned unchanged.
+func railsMoveExpiry(parsedData *railsParsedData, data string, timestamp time.Time) (string, error) {
+	var envelope, rails map[string]json.RawMessage
+	var exp string
+
+	if json.Unmarshal([]byte(data), &envelope) != nil || json.Unmarshal(envelope["_rails"], &rails) != nil || json.Unmarshal(rails["exp"], &exp) != nil {
+		return data, nil
+	}
+
+	expiry, err := time.Parse(time.RFC3339, exp)
+	if err != nil {
+		return data, nil
+	}
+
+	lifetime := expiry.Sub(parsedData.decodedAt)
+	if lifetime <= 0 {
+		return data, nil
+	}
+
+	if rails["exp"], err = json.Marshal(timestamp.Add(lifetime).UTC().Format(railsExpiryFormat)); err != nil {
+		return "", err
+	}
+
+	if envelope["_rails"], err = json.Marshal(rails); err != nil {
+		return "", err
+	}
+
+	moved, err := json.Marshal(envelope)
+	return string(moved), err
+}
+
+// Sets how this app's Rails key generator derives the cookie key from
+// `secret_key_base`: the salt (the default is "authenticated encrypted
+// cookie") and the PBKDF2 iterations (the default is 1000). Empty and zero
+// values keep the defaults.
+func (c *Cookie) SetRailsKeyDerivation(salt string, iterations int) {
+	c.railsSalt = salt
+	c.railsIterations = iterations
+}
@@ -0,0 +1,263 @@
+package monster
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const railsSessionFixture = `{"_rails":{"message":"IntcInNlc3Npb25faWRcIjpcIjRmMWNcIixcInVzZXJfaWRcIjoxfSI=","exp":null,"pur":"cookie._app_session"}}`
+
+func TestUnsignRails(t *testing.T) {
+	wl := NewWordlist()
+	if err := wl.LoadFromArray([][]byte{[]byte("wrong"), []byte("changeme")}); err != nil {
+		t.Errorf("could not LoadFromArray")
+	}
+
+	for i, digest := range []string{"sha256", "sha1"} {
+		c := NewCookie(selfTestFixtures[railsDecoder][i].cookie)
+		if !c.Decode() || !c.hasParsedDataFor(railsDecoder) {
+			t.Fatalf("could not decode the %s Rails cookie", digest)
+		}
+
+		if _, err := c.RailsSession(); !errors.Is(err, ErrNotRailsCookie) {
+			t.Errorf("decrypted the session before unsigning: %v", err)
+		}
+
+		if _, success := c.Unsign(wl, 100); !success {
+			t.Fatalf("could not unsign the %s Rails cookie", digest)
+		}
+
+		if algorithm := c.signatureAlgorithm(railsDecoder); algorithm != digest {
+			t.Errorf("derived the key with %s, not %s", algorithm, digest)
+		}
+
+		session, err := c.RailsSession()
+		if err != nil || string(session) != railsSessionFixture {
+			t.Errorf("decrypted %q (%v)", session, err)
+		}
+	}
+}
+
+func TestUnsignRailsWithAlgorithms(t *testing.T) {
+	wl := NewWordlist()
+	if err := wl.LoadFromArray([][]byte{[]byte("changeme")}); err != nil {
+		t.Errorf("could not LoadFromArray")
+	}
+
+	onlySHA256, _ := WithAlgorithms([]string{"sha256"})
+	onlySHA1, _ := WithAlgorithms([]string{"sha1"})
+
+	// Either digest is possible until a key is found, so each is only
+	// tried if it's allowed.
+	for i, digest := range []string{"sha256", "sha1"} {
+		for _, test := range []struct {
+			option  UnsignOption
+			allowed string
+		}{{onlySHA256, "sha256"}, {onlySHA1, "sha1"}} {
+			c := NewCookie(selfTestFixtures[railsDecoder][i].cookie)
+			c.Decode()
+
+			if _, success := c.Unsign(wl, 100, test.option); success != (digest == test.allowed) {
+				t.Errorf("unsigning the %s Rails cookie with only %s allowed returned %v", digest, test.allowed, success)
+			}
+		}
+	}
+}
+
+func TestRailsDecodedAtCached(t *testing.T) {
+	defer func(original func() time.Time) { now = original }(now)
+
+	first := time.Date(2021, time.November, 1, 9, 0, 0, 0, time.UTC)
+	now = func() time.Time { return first }
+
+	c := NewCookie(selfTestFixtures[railsDecoder][0].cookie)
+	c.Decode()
+
+	// Decoded again an hour later, from the cache.
+	now = func() time.Time { return first.Add(time.Hour) }
+
+	cached := NewCookie(selfTestFixtures[railsDecoder][0].cookie)
+	cached.Decode()
+
+	if decodedAt := cached.parsedDataFor(railsDecoder).(*railsParsedData).decodedAt; !decodedAt.Equal(first.Add(time.Hour)) {
+		t.Errorf("the cached cookie was decoded at %v", decodedAt)
+	}
+}
+
+func TestRailsCSRFToken(t *testing.T) {
+	wl := NewWordlist()
+	if err := wl.LoadFromArray([][]byte{[]byte("changeme")}); err != nil {
+		t.Errorf("could not LoadFromArray")
+	}
+
+	c := NewCookie(selfTestFixtures[railsDecoder][0].cookie)
+	c.Decode()
+
+	if _, err := c.RailsCSRFToken(); !errors.Is(err, ErrNotRailsCookie) {
+		t.Errorf("read the CSRF token before unsigning: %v", err)
+	}
+
+	if _, success := c.Unsign(wl, 100); !success {
+		t.Fatalf("could not unsign the Rails cookie")
+	}
+
+	if _, err := c.RailsCSRFToken(); !errors.Is(err, ErrNoCSRFToken) {
+		t.Errorf("found a CSRF token in a session without one: %v", err)
+	}
+
+	// The session in the envelope, serialized to JSON once and twice, and
+	// inline as Rails 7.1 has it.
+	for _, session := range []string{
+		`{"_rails":{"message":"eyJzZXNzaW9uX2lkIjoiNGYxYyIsIl9jc3JmX3Rva2VuIjoiUVpjOHNsZTZ1NCJ9","exp":null,"pur":"cookie._app_session"}}`,
+		`{"_rails":{"message":"IntcInNlc3Npb25faWRcIjpcIjRmMWNcIixcIl9jc3JmX3Rva2VuXCI6XCJRWmM4c2xlNnU0XCJ9Ig==","exp":null,"pur":"cookie._app_session"}}`,
+		`{"_rails":{"data":{"session_id":"4f1c","_csrf_token":"QZc8sle6u4"},"exp":null,"pur":"cookie._app_session"}}`,
+	} {
+		resigned, err := c.Resign(session)
+		if err != nil {
+			t.Fatalf("could not resign: %v", err)
+		}
+
+		resignedCookie := NewCookie(resigned)
+		resignedCookie.Decode()
+
+		if _, success := resignedCookie.Unsign(wl, 100); !success {
+			t.Fatalf("resigned cookie does not decrypt")
+		}
+
+		if token, err := resignedCookie.RailsCSRFToken(); err != nil || token != "QZc8sle6u4" {
+			t.Errorf("read the CSRF token of %s as %q (%v)", session, token, err)
+		}
+	}
+}
+
+func TestUnsignRailsCustomSalt(t *testing.T) {
+	wl := NewWordlist()
+	if err := wl.LoadFromArray([][]byte{[]byte("changeme")}); err != nil {
+		t.Errorf("could not LoadFromArray")
+	}
+
+	raw := "S6cIhhq%2BAVt65zimFYxSyHk6YsbjbAHtW5NPCEKvYyByvE0jGMBVNvl57X1AWsAAvVnsU0fskP1KdV3BcwNU02mYwBCAgbzSi9v5Io5SSAD%2FD1t9movEeZj4oHEIHaLywHX%2Br28mleS94V5BOIpFrpV0MwbZcKUS--Zml4ZWQtaXYtMTJi--7PgA2g%2BqtA8Upq0lN3PMxg%3D%3D"
+
+	c := NewCookie(raw)
+	c.Decode()
+
+	if _, success := c.Unsign(wl, 100); success {
+		t.Errorf("unsigned with the default salt")
+	}
+
+	c = NewCookie(raw)
+	c.SetRailsKeyDerivation("custom salt", 0)
+	c.Decode()
+
+	if _, success := c.Unsign(wl, 100); !success {
+		t.Errorf("could not unsign with a custom salt")
+	}
+}
+
+func TestResignRails(t *testing.T) {
+	wl := NewWordlist()
+	if err := wl.LoadFromArray([][]byte{[]byte("changeme")}); err != nil {
+		t.Errorf("could not LoadFromArray")
+	}
+
+	c := NewCookie(selfTestFixtures[railsDecoder][1].cookie)
+	c.Decode()
+
+	if _, success := c.Unsign(wl, 100); !success {
+		t.Fatalf("could not unsign the Rails cookie")
+	}
+
+	tampered := strings.Replace(railsSessionFixture, "cookie._app_session", "cookie._admin_session", 1)
+	resigned, err := c.Resign(tampered)
+	if err != nil {
+		t.Fatalf("could not resign: %v", err)
+	}
+
+	if !strings.Contains(resigned, "%3D") || strings.Contains(resigned, "=") {
+		t.Errorf("did not keep the URL encoding: %s", resigned)
+	}
+
+	resignedCookie := NewCookie(resigned)
+	resignedCookie.Decode()
+
+	if _, success := resignedCookie.Unsign(wl, 100); !success {
+		t.Fatalf("resigned cookie does not decrypt")
+	}
+
+	// The key is still derived with SHA1, like the original.
+	if algorithm := resignedCookie.signatureAlgorithm(railsDecoder); algorithm != "sha1" {
+		t.Errorf("resigned with %s", algorithm)
+	}
+
+	if session, err := resignedCookie.RailsSession(); err != nil || string(session) != tampered {
+		t.Errorf("resigned cookie holds %q (%v)", session, err)
+	}
+
+	if _, err := c.ResignWith(tampered, ResignOptions{Algorithm: "sha512"}); !errors.Is(err, ErrUnknownAlgorithm) {
+		t.Errorf("resigned with a digest Rails doesn't use: %v", err)
+	}
+}
+
+func TestResignRailsMovesExpiry(t *testing.T) {
+	defer func(original func() time.Time) { now = original }(now)
+
+	wl := NewWordlist()
+	if err := wl.LoadFromArray([][]byte{[]byte("changeme")}); err != nil {
+		t.Errorf("could not LoadFromArray")
+	}
+
+	c := NewCookie(selfTestFixtures[railsDecoder][0].cookie)
+	c.Decode()
+
+	if _, success := c.Unsign(wl, 100); !success {
+		t.Fatalf("could not unsign the Rails cookie")
+	}
+
+	// A session issued at 09:00 which expires an hour later.
+	issued := time.Date(2021, time.November, 1, 9, 0, 0, 0, time.UTC)
+	expiring := strings.Replace(railsSessionFixture, `"exp":null`, `"exp":"2021-11-01T10:00:00.000Z"`, 1)
+
+	original, err := c.Resign(expiring)
+	if err != nil {
+		t.Fatalf("could not resign: %v", err)
+	}
+
+	now = func() time.Time { return issued }
+
+	captured := NewCookie(original)
+	captured.Decode()
+
+	if _, success := captured.Unsign(wl, 100); !success {
+		t.Fatalf("could not unsign the expiring Rails cookie")
+	}
+
+	resigned, err := captured.ResignWith(expiring, ResignOptions{Timestamp: issued.Add(24 * time.Hour)})
+	if err != nil {
+		t.Fatalf("could not resign with a timestamp: %v", err)
+	}
+
+	resignedCookie := NewCookie(resigned)
+	resignedCookie.Decode()
+
+	if _, success := resignedCookie.Unsign(wl, 100); !success {
+		t.Fatalf("resigned cookie does not decrypt")
+	}
+
+	session, _ := resignedCookie.RailsSession()
+	if !strings.Contains(string(session), `"exp":"2021-11-02T10:00:00.000Z"`) || !strings.Contains(string(session), `"pur":"cookie._app_session"`) {
+		t.Errorf("expected the expiry a day later, got %s", session)
+	}
+
+	// Without a timestamp, the expiry stays as it was.
+	if kept, err := captured.Resign(expiring); err != nil {
+		t.Errorf("could not resign: %v", err)
+	} else if keptCookie := NewCookie(kept); keptCookie.Decode() {
+		keptCookie.Unsign(wl, 100)
+
+		if session, _ := keptCookie.RailsSession(); string(session) != expiring {
+			t.Errorf("changed the session to %s", session)
+		}
+	}
+}
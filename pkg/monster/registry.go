@@ -0,0 +1,195 @@
+package monster
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrDecoderExists  = errors.New("a decoder with this name already exists")
+	ErrInvalidDecoder = errors.New("a decoder needs a name and an implementation")
+)
+
+// A `Decoder` adds support for a cookie format without changing this
+// package; see `RegisterDecoder()`. Decode reports whether the cookie is in
+// the format, and may keep what it parsed with `SetDecoderData()`.
+// Precompute returns what Unsign needs from a candidate secret that doesn't
+// depend on the cookie, such as a key derived from it, and is only called
+// once per secret however many cookies it's tried on; decoders with nothing
+// to derive return `secret`. Unsign reports whether the secret, as
+// Precompute returned it, signed the cookie, and is called concurrently.
+// Resign returns the cookie with new, unencoded `data` signed with
+// `secret`, or `ErrResignUnsupported` if the format can't be resigned.
+type Decoder interface {
+	Decode(c *Cookie) bool
+	Precompute(secret []byte) []byte
+	Unsign(c *Cookie, secret []byte) bool
+	Resign(c *Cookie, data string, secret []byte) (string, error)
+}
+
+type registeredDecoder struct {
+	name    string
+	decoder Decoder
+}
+
+// What we keep for a format a registered decoder recognized.
+type customParsedData struct {
+	decoder Decoder
+	data    interface{}
+}
+
+func (d *customParsedData) String() string {
+	if stringer, ok := d.data.(fmt.Stringer); ok {
+		return stringer.String()
+	}
+
+	return fmt.Sprintf("Data: %v\n", d.data)
+}
+
+func (d *customParsedData) serialized(c *Cookie) []byte {
+	// Only the registered decoder knows what its data holds.
+	return nil
+}
+
+func (d *customParsedData) fields() parsedFields {
+	// Only the registered decoder knows what its data holds.
+	return parsedFields{}
+}
+
+func (d *customParsedData) clone() decoderData {
+	copied := *d
+	return &copied
+}
+
+var (
+	registryMutex sync.RWMutex
+
+	// In the order they were registered, which is the order they run in.
+	registeredDecoders []registeredDecoder
+
+	// Bumped on every registration, so cached decodes from before it are
+	// ignored.
+	registryGeneration int
+)
+
+// Adds a decoder for a custom cookie format, which `Decode()` tries after
+// the built-in ones, and which `Unsign()` and `Resign()` then use like any
+// other. Its `name` is what `Result()` reports, and must not be taken by a
+// built-in or already registered decoder.
+func RegisterDecoder(name string, d Decoder) error {
+	if name == "" || d == nil {
+		return ErrInvalidDecoder
+	}
+
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	if _, ok := builtinsByName[name]; ok {
+		return fmt.Errorf("%w: %s", ErrDecoderExists, name)
+	}
+
+	for _, registered := range registeredDecoders {
+		if registered.name == name {
+			return fmt.Errorf("%w: %s", ErrDecoderExists, name)
+		}
+	}
+
+	registeredDecoders = append(registeredDecoders, registeredDecoder{name: name, decoder: d})
+	registryGeneration++
+
+	return nil
+}
+
+// Returns the registered decoders, and the generation of the registry
+// they're from.
+func customDecoders() ([]registeredDecoder, int) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	return registeredDecoders, registryGeneration
+}
+
+// Runs every registered decoder over the cookie, reporting whether any
+// recognized it.
+func (c *Cookie) customDecode() (success bool) {
+	decoders, _ := customDecoders()
+
+	for _, registered := range decoders {
+		parsedData := &customParsedData{decoder: registered.decoder}
+
+		// The decoder may keep data while it runs, so it must be there.
+		c.wasDecodedBy(registered.name, parsedData)
+
+		if registered.decoder.Decode(c) {
+			success = true
+			continue
+		}
+
+		c.mutex.Lock()
+		delete(c.decodedBy, registered.name)
+		c.mutex.Unlock()
+	}
+
+	return success
+}
+
+// Returns the registered decoders which recognized the cookie and which
+// `options` allow.
+func (c *Cookie) customDecodedBy(options *unsignOptions) []registeredDecoder {
+	decoders, _ := customDecoders()
+
+	var decodedBy []registeredDecoder
+	for _, registered := range decoders {
+		if _, ok := c.customParsedDataFor(registered.name); ok && options.allowsDecoder(registered.name) {
+			decodedBy = append(decodedBy, registered)
+		}
+	}
+
+	return decodedBy
+}
+
+// Returns the parsed data of the registered decoder called `name`, if it
+// recognized the cookie.
+func (c *Cookie) customParsedDataFor(name string) (*customParsedData, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	parsedData, ok := c.decodedBy[name].(*customParsedData)
+	return parsedData, ok
+}
+
+// Returns the cookie's value, after any URL or base64 wrapping `Decode()`
+// removed, for registered decoders to parse.
+func (c *Cookie) Raw() string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.raw
+}
+
+// Keeps `data` for the registered decoder called `name`, which it can fetch
+// with `DecoderData()` when unsigning or resigning. Only a decoder's own
+// `Decode()` should call this, and `data` shouldn't change afterwards,
+// since decoded cookies are cached and shared.
+func (c *Cookie) SetDecoderData(name string, data interface{}) {
+	if parsedData, ok := c.customParsedDataFor(name); ok {
+		c.mutex.Lock()
+		parsedData.data = data
+		c.mutex.Unlock()
+	}
+}
+
+// Returns what the registered decoder called `name` kept with
+// `SetDecoderData()`, or nil.
+func (c *Cookie) DecoderData(name string) interface{} {
+	parsedData, ok := c.customParsedDataFor(name)
+	if !ok {
+		return nil
+	}
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return parsedData.data
+}
@@ -0,0 +1,100 @@
+package monster
+
+import (
+	"crypto/hmac"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// A made-up `acme:value:signature` format, signed with HMAC-SHA256.
+type acmeDecoder struct{}
+
+type acmeData struct {
+	value     string
+	signature []byte
+}
+
+func (acmeDecoder) Decode(c *Cookie) bool {
+	components := strings.Split(c.Raw(), ":")
+	if len(components) != 3 || components[0] != "acme" {
+		return false
+	}
+
+	signature, err := hex.DecodeString(components[2])
+	if err != nil {
+		return false
+	}
+
+	c.SetDecoderData("acme", &acmeData{value: components[1], signature: signature})
+	return true
+}
+
+func (acmeDecoder) Precompute(secret []byte) []byte {
+	return secret
+}
+
+func (acmeDecoder) Unsign(c *Cookie, secret []byte) bool {
+	data := c.DecoderData("acme").(*acmeData)
+	return hmac.Equal(data.signature, sha256HMAC(secret, []byte(data.value)))
+}
+
+func (acmeDecoder) Resign(c *Cookie, data string, secret []byte) (string, error) {
+	return "acme:" + data + ":" + hex.EncodeToString(sha256HMAC(secret, []byte(data))), nil
+}
+
+func TestRegisterDecoder(t *testing.T) {
+	if err := RegisterDecoder("acme", acmeDecoder{}); err != nil {
+		t.Fatalf("could not register: %v", err)
+	}
+
+	if err := RegisterDecoder("acme", acmeDecoder{}); !errors.Is(err, ErrDecoderExists) {
+		t.Errorf("registered a decoder twice: %v", err)
+	}
+
+	if err := RegisterDecoder(DecoderDjango, acmeDecoder{}); !errors.Is(err, ErrDecoderExists) {
+		t.Errorf("replaced a built-in decoder: %v", err)
+	}
+
+	wl := NewWordlist()
+	if err := wl.LoadFromArray([][]byte{[]byte("wrong"), []byte("changeme")}); err != nil {
+		t.Errorf("could not LoadFromArray")
+	}
+
+	raw, _ := acmeDecoder{}.Resign(nil, "user=42", []byte("changeme"))
+
+	c := NewCookie(raw)
+	if !c.Decode() {
+		t.Fatalf("could not decode with the registered decoder")
+	}
+
+	if data, ok := c.DecoderData("acme").(*acmeData); !ok || data.value != "user=42" {
+		t.Errorf("did not keep the decoder's data: %v", c.DecoderData("acme"))
+	}
+
+	if !strings.Contains(c.String(), "Decoder acme reports:") {
+		t.Errorf("did not report the registered decoder: %s", c.String())
+	}
+
+	if _, success := c.Unsign(wl, 100); !success {
+		t.Fatalf("could not unsign with the registered decoder")
+	}
+
+	if _, key, decoder := c.Result(); decoder != "acme" || string(key) != "changeme" {
+		t.Errorf("unsigned with %s and %s", decoder, key)
+	}
+
+	resigned, err := c.Resign("user=1")
+	if err != nil || resigned != "acme:user=1:"+hex.EncodeToString(sha256HMAC([]byte("changeme"), []byte("user=1"))) {
+		t.Errorf("resigned as %s (%v)", resigned, err)
+	}
+
+	// Other cookies are untouched by it.
+	other := NewCookie(selfTestFixtures[flaskDecoder][0].cookie)
+	other.Decode()
+
+	if other.DecoderData("acme") != nil {
+		t.Errorf("the registered decoder claimed a Flask cookie")
+	}
+}
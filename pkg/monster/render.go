@@ -0,0 +1,212 @@
+package monster
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// The fields `Render()` passes to templates.
+type RenderData struct {
+	Decoder   string
+	Data      string
+	Timestamp string
+	Algorithm string
+	Signature string
+
+	// Only set once `Unsign()` has found the secret.
+	Unsigned bool
+	Secret   string
+}
+
+// Renders `tmpl`, a `text/template`, with the cookie's `RenderData`. The
+// fields come from the decoder that unsigned the cookie, or otherwise from
+// the first decoder (by name) which decoded it.
+func (c *Cookie) Render(tmpl string) (string, error) {
+	parsed, err := template.New("cookie").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	if err := parsed.Execute(&out, c.renderData()); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
+
+func (c *Cookie) renderData() RenderData {
+	var data RenderData
+
+	if success, key, decoder := c.Result(); success {
+		data.Decoder = decoder
+		data.Unsigned = true
+		data.Secret = string(key)
+	} else {
+		// Until one unsigns it, the likeliest decoder speaks for the cookie.
+		matches := c.Matches()
+		if len(matches) == 0 {
+			return data
+		}
+
+		data.Decoder = matches[0].Decoder
+	}
+
+	fields := c.parsedDataFor(data.Decoder).fields()
+	data.Data, data.Timestamp, data.Algorithm, data.Signature = fields.data, fields.timestamp, fields.algorithm, fields.signature
+
+	return data
+}
+
+// Returns the result as a single tab-separated line, for grepping through
+// bulk runs: the decoder, the algorithm, the secret (hex-encoded unless it's
+// printable ASCII) and the `sub` claim of the signed data, with the last
+// two blank when unknown.
+func (c *Cookie) OneLine() string {
+	data := c.renderData()
+
+	var secret string
+	if data.Unsigned {
+		_, key, _ := c.Result()
+		secret, _ = UnsignResult{Secret: key}.encodedSecret()
+	}
+
+	return strings.Join([]string{data.Decoder, data.Algorithm, secret, oneLineField(c.subject(data.Decoder))}, "\t")
+}
+
+// Returns the `sub` claim from `decoder`'s payload, if it has one.
+func (c *Cookie) subject(decoder string) string {
+	payload, ok := c.Payload(decoder)
+	if !ok {
+		return ""
+	}
+
+	var claims struct {
+		Subject interface{} `json:"sub"`
+	}
+
+	if json.Unmarshal(payload, &claims) != nil || claims.Subject == nil {
+		return ""
+	}
+
+	return fmt.Sprint(claims.Subject)
+}
+
+// Replaces whitespace that would break a line apart.
+func oneLineField(field string) string {
+	return strings.NewReplacer("\t", " ", "\r", " ", "\n", " ").Replace(field)
+}
+
+// One decoder's view of a cookie, as `MarshalJSON()` emits it. Signatures
+// are hex-encoded, whatever encoding the cookie used.
+type DecodedFields struct {
+	Decoder    string `json:"decoder"`
+	Compressed bool   `json:"compressed"`
+	Data       string `json:"data"`
+	Timestamp  string `json:"timestamp"`
+	Signature  string `json:"signature"`
+	Algorithm  string `json:"algorithm"`
+
+	// The payload after decoding, e.g. a session's JSON, for decoders
+	// which can decode it without the secret.
+	DecodedData string `json:"decoded_data,omitempty"`
+}
+
+type cookieJSON struct {
+	Decoders []DecodedFields `json:"decoders"`
+
+	// Only set once `Unsign()` has found the secret.
+	Unsigned       bool   `json:"unsigned"`
+	UnsignedBy     string `json:"unsigned_by,omitempty"`
+	Algorithm      string `json:"algorithm,omitempty"`
+	Secret         string `json:"secret,omitempty"`
+	SecretEncoding string `json:"secret_encoding,omitempty"`
+}
+
+// Returns the fields of every decoder that decoded this cookie, sorted by
+// decoder name so the output is stable.
+func (c *Cookie) DecodedFields() []DecodedFields {
+	c.mutex.RLock()
+	decoders := make([]string, 0, len(c.decodedBy))
+	for decoder := range c.decodedBy {
+		decoders = append(decoders, decoder)
+	}
+	c.mutex.RUnlock()
+
+	sort.Strings(decoders)
+
+	fields := make([]DecodedFields, 0, len(decoders))
+	for _, decoder := range decoders {
+		fields = append(fields, c.decodedFieldsFor(decoder))
+	}
+
+	return fields
+}
+
+func (c *Cookie) decodedFieldsFor(decoder string) DecodedFields {
+	parsedData := c.parsedDataFor(decoder)
+	parsed := parsedData.fields()
+
+	fields := DecodedFields{
+		Decoder:    decoder,
+		Compressed: parsed.compressed,
+		Data:       parsed.data,
+		Timestamp:  parsed.timestamp,
+		Signature:  hex.EncodeToString(parsed.decodedSignature),
+		Algorithm:  parsed.algorithm,
+	}
+
+	if payload := parsedData.serialized(c); payload != nil {
+		fields.DecodedData = displayBytes(payload)
+	}
+
+	return fields
+}
+
+// Returns the serialized session `decoder` found in this cookie, for the
+// `payload` package to deserialize. Rack's Marshal dump is base64-decoded,
+// and Rails and CakePHP cookies are only available once they've been
+// unsigned, since they're encrypted.
+func (c *Cookie) Payload(decoder string) ([]byte, bool) {
+	if !c.hasParsedDataFor(decoder) {
+		return nil, false
+	}
+
+	payload := c.parsedDataFor(decoder).serialized(c)
+	return payload, len(payload) > 0
+}
+
+// Returns the Shannon entropy, in bits per byte, of `decoder`'s signature,
+// along with the most a signature of its length could have. HMAC output
+// should come close to that; much less suggests a truncated signature or a
+// misparse.
+func (c *Cookie) SignatureEntropy(decoder string) (entropy float64, maximum float64, ok bool) {
+	if !c.hasParsedDataFor(decoder) {
+		return 0, 0, false
+	}
+
+	signature := c.parsedDataFor(decoder).fields().decodedSignature
+	if len(signature) == 0 {
+		return 0, 0, false
+	}
+
+	return shannonEntropy(signature), maxShannonEntropy(len(signature)), true
+}
+
+// Emits the fields of every decoder that decoded the cookie and, once
+// `Unsign()` has succeeded, the secret (hex-encoded unless it's printable
+// ASCII, as `secret_encoding` records).
+func (c *Cookie) MarshalJSON() ([]byte, error) {
+	out := cookieJSON{Decoders: c.DecodedFields()}
+
+	if success, key, decoder := c.Result(); success {
+		out.Unsigned, out.UnsignedBy, out.Algorithm = true, decoder, c.signatureAlgorithm(decoder)
+		out.Secret, out.SecretEncoding = UnsignResult{Secret: key}.encodedSecret()
+	}
+
+	return json.Marshal(out)
+}
@@ -0,0 +1,428 @@
+package monster
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Resigns `data` twice with `options` and fails unless both results are
+// byte-identical, since HMAC is deterministic and any difference means
+// something like map iteration order is leaking into the output.
+func assertResignStable(t *testing.T, c *Cookie, data string, options ResignOptions) {
+	t.Helper()
+
+	first, err := c.ResignWith(data, options)
+	if err != nil {
+		t.Fatalf("could not resign with %+v: %v", options, err)
+	}
+
+	second, err := c.ResignWith(data, options)
+	if err != nil {
+		t.Fatalf("could not resign with %+v: %v", options, err)
+	}
+
+	if first != second {
+		t.Errorf("resigning with %+v is not stable: %s != %s", options, first, second)
+	}
+}
+
+func TestResignStableDjango(t *testing.T) {
+	wl := NewWordlist()
+	if err := wl.LoadFromArray([][]byte{[]byte("changeme")}); err != nil {
+		t.Errorf("could not LoadFromArray")
+	}
+
+	cookies := []string{
+		"eyJfYXV0aF91c2VyX2lkIjoiMiIsImlzX3N0YWZmIjpmYWxzZSwiY2FydCI6WzEsMl19:1mhTAe:nSpbU2IuS3K2okEuqBNBHigbMWcsLdJ_mERQLgzoTDo",
+		".eJyrVopPLC3JiC8tTi2Kz0xRslIyUtJRSk4sKlGyijbUoSqMrQUA9S8YsA:1mhTAe:TdRXvd5RfNOrqhJrFcTQJTCNjv6LpKp-dyAZzIxrlno",
+	}
+
+	for _, raw := range cookies {
+		c := NewCookie(raw)
+		c.Decode()
+
+		if _, success := c.Unsign(wl, 100); !success {
+			t.Fatalf("could not unsign %s", raw)
+		}
+
+		for algorithm := range algorithmDigestLength {
+			assertResignStable(t, c, `{"_auth_user_id":"1","cart":[3,2,1]}`, ResignOptions{Algorithm: algorithm, AllowDowngrade: true})
+		}
+	}
+}
+
+func TestResignStableExpress(t *testing.T) {
+	wl := NewWordlist()
+	if err := wl.LoadFromArray([][]byte{[]byte("changeme")}); err != nil {
+		t.Errorf("could not LoadFromArray")
+	}
+
+	c := NewCookie("session=eyJhbmltYWxzIjoibGlvbiJ9^Vf2INocdJIqKWVfYGhXwPhQZNFI")
+	c.Decode()
+
+	if _, success := c.Unsign(wl, 100); !success {
+		t.Fatalf("could not unsign an Express cookie")
+	}
+
+	assertResignStable(t, c, `{"animals":"tiger"}`, ResignOptions{})
+}
+
+func TestResignDjangoReserialize(t *testing.T) {
+	wl := NewWordlist()
+	if err := wl.LoadFromArray([][]byte{[]byte("changeme")}); err != nil {
+		t.Errorf("could not LoadFromArray")
+	}
+
+	c := NewCookie("eyJzZWxmdGVzdCI6dHJ1ZX0:1mhTAe:odbuGI4BA7DUKpUJZcLAmETuB0BCEtLuzH-GIev4Z2o")
+	c.Decode()
+
+	if _, success := c.Unsign(wl, 100); !success {
+		t.Fatalf("could not unsign")
+	}
+
+	// An edited session, pretty-printed and with non-ASCII text.
+	edited := "{\n  \"selftest\": false,\n  \"name\": \"José 🍪\"\n}"
+
+	// What Django signs for the same session, from `signing.dumps()`.
+	want := "eyJzZWxmdGVzdCI6ZmFsc2UsIm5hbWUiOiJKb3NcdTAwZTkgXHVkODNjXHVkZjZhIn0:1mhTAe:E9NZUOiSvHIL8FmQ0vh5zJAasJk-ZwBxjNWIUO2ToM8"
+
+	resigned, err := c.ResignWith(edited, ResignOptions{Reserialize: true})
+	if err != nil {
+		t.Fatalf("could not resign: %v", err)
+	}
+
+	if resigned != want {
+		t.Errorf("resigned cookie is %s, wanted %s", resigned, want)
+	}
+
+	if _, err := c.ResignWith("{not json", ResignOptions{Reserialize: true}); err == nil {
+		t.Errorf("reserialized invalid JSON")
+	}
+}
+
+func TestResignFlask(t *testing.T) {
+	wl := NewWordlist()
+	if err := wl.LoadFromArray([][]byte{[]byte("changeme")}); err != nil {
+		t.Errorf("could not LoadFromArray")
+	}
+
+	raw := "eyJhIjoiYiJ9.YX-skA.D0By6YsWkNcDZfs59oCAwN4I1yc"
+
+	c := NewCookie(raw)
+	c.Decode()
+
+	if _, success := c.Unsign(wl, 100); !success {
+		t.Fatalf("could not unsign a Flask cookie")
+	}
+
+	// Resigning the same session must reproduce the original cookie.
+	if resigned, err := c.Resign(`{"a":"b"}`); err != nil || resigned != raw {
+		t.Errorf("resigned cookie is %s, wanted %s (%v)", resigned, raw, err)
+	}
+
+	compressed, err := c.ResignWith(`{"a":"b","cart":[1,1,1,1,1,1,1,1,1,1,1,1]}`, ResignOptions{Compression: CompressionAlways})
+	if err != nil || !strings.HasPrefix(compressed, ".") {
+		t.Fatalf("did not compress the resigned cookie: %s %v", compressed, err)
+	}
+
+	resignedCookie := NewCookie(compressed)
+	resignedCookie.Decode()
+
+	if _, success := resignedCookie.Unsign(wl, 100); !success {
+		t.Errorf("resigned compressed cookie does not verify")
+	}
+
+	if data := resignedCookie.parsedDataFor(flaskDecoder).(*flaskParsedData).decodedData; string(data) != `{"a":"b","cart":[1,1,1,1,1,1,1,1,1,1,1,1]}` {
+		t.Errorf("resigned cookie has data %q", data)
+	}
+
+	for algorithm := range algorithmDigestLength {
+		assertResignStable(t, c, `{"a":"c"}`, ResignOptions{Algorithm: algorithm, AllowDowngrade: true})
+	}
+}
+
+func TestResignJWT(t *testing.T) {
+	raw := selfTestFixtures[jwtDecoder][0].cookie
+
+	c := NewCookie(raw)
+	c.Decode()
+
+	if !c.UnsignWithSecret([]byte("changeme")) {
+		t.Fatalf("could not unsign a JWT")
+	}
+
+	// Resigning the same claims must reproduce the original token.
+	if resigned, err := c.Resign(`{"sub":"1234567890","name":"John Doe","iat":1516239022}`); err != nil || resigned != raw {
+		t.Errorf("resigned token is %s, wanted %s (%v)", resigned, raw, err)
+	}
+
+	resigned, err := c.ResignWith(`{"sub":"admin"}`, ResignOptions{Algorithm: "sha512"})
+	if err != nil {
+		t.Fatalf("could not resign the JWT with HS512: %v", err)
+	}
+
+	resignedCookie := NewCookie(resigned)
+	resignedCookie.Decode()
+
+	parsedData := resignedCookie.parsedDataFor(jwtDecoder).(*jwtParsedData)
+	if alg := parsedData.joseHeader["alg"]; alg != "HS512" || string(parsedData.decodedBody) != `{"sub":"admin"}` {
+		t.Errorf("resigned token has alg %v and claims %s", alg, parsedData.decodedBody)
+	}
+
+	if !resignedCookie.UnsignWithSecret([]byte("changeme")) {
+		t.Errorf("resigned token does not verify")
+	}
+
+	// JOSE has no HMAC-SHA1 algorithm to declare.
+	if _, err := c.ResignWith(`{"sub":"admin"}`, ResignOptions{Algorithm: "sha1", AllowDowngrade: true}); !errors.Is(err, ErrUnknownAlgorithm) {
+		t.Errorf("resigned the JWT with HMAC-SHA1: %v", err)
+	}
+}
+
+func TestResignDjangoSignatures(t *testing.T) {
+	c := NewCookie("eyJzZWxmdGVzdCI6dHJ1ZX0:1mhTAe:odbuGI4BA7DUKpUJZcLAmETuB0BCEtLuzH-GIev4Z2o")
+	if !c.Decode() {
+		t.Fatalf("could not decode the Django cookie")
+	}
+
+	// Signatures Django itself produces for this data; they pin down each
+	// algorithm's entry in `hashAlgorithms`.
+	expected := map[string]string{
+		"sha1":   "SR1o6CT4YkfYf39Yx0JFmDPDG9w",
+		"sha256": "fniurSD-5OGLv4wybn4jrleUGLSO14WpRo4rFPO3nTM",
+		"sha384": "2ZnDYy-rZJyrtGkgX4GWB7XU6c4Lp7XTdcz6hzKRh_Nx9-rZSsKJ7F07kUiQV0gq",
+		"sha512": "zZLhuglIyA4Tzkr9p41CM6S6d5w4XgyjSo0vsyd28ftw0w6eXcJFa_hnIyrAfQhxQyzFm-_39NrsQE0u5qumVQ",
+	}
+
+	for algorithm, signature := range expected {
+		preview, err := djangoPreviewResign(c, `{"registry":true}`, []byte("changeme"), algorithm, CompressionOriginal, time.Time{})
+		if err != nil {
+			t.Errorf("could not resign with %s: %v", algorithm, err)
+			continue
+		}
+
+		if preview.Signature != signature {
+			t.Errorf("%s signature was %s, not %s", algorithm, preview.Signature, signature)
+		}
+	}
+
+	if _, err := djangoPreviewResign(c, `{"registry":true}`, []byte("changeme"), "md5", CompressionOriginal, time.Time{}); err != ErrUnknownAlgorithm {
+		t.Errorf("resigning with md5 returned %v, not ErrUnknownAlgorithm", err)
+	}
+}
+
+func TestResignFlaskSignatures(t *testing.T) {
+	c := NewCookie(selfTestFixtures[flaskDecoder][0].cookie)
+	if !c.Decode() {
+		t.Fatalf("could not decode the Flask cookie")
+	}
+
+	// Signatures itsdangerous produces for this data and timestamp.
+	expected := map[string]string{
+		"sha1":   "72gIeCtjZhAoddPwJwgUchzxce8",
+		"sha256": "E4kfHf5EQYxWlnUb5f0AHpLtauHkZ5HpKsUifG0PlgM",
+		"sha384": "C_5FnPqMnUWAU1yb-qE2269WOgiMdulF4AZD7efk8xB4Ki7cxBXFSPJ1ZVuBrdkn",
+		"sha512": "i-EsOYfY9X3Ni-YlA_kUFTjgs0pGsu3zhh_OJVPqFWw71epU7md68mWH7R2oEQ-JfNaU8Wik_7OOlYfOhBDx2Q",
+	}
+
+	for algorithm, signature := range expected {
+		resigned, err := flaskResign(c, `{"registry":true}`, []byte("changeme"), algorithm, CompressionOriginal, time.Time{})
+		if err != nil {
+			t.Errorf("could not resign with %s: %v", algorithm, err)
+			continue
+		}
+
+		if want := "eyJyZWdpc3RyeSI6dHJ1ZX0.YX-skA." + signature; resigned != want {
+			t.Errorf("%s cookie was %s, not %s", algorithm, resigned, want)
+		}
+	}
+
+	if _, err := flaskResign(c, `{"registry":true}`, []byte("changeme"), "md5", CompressionOriginal, time.Time{}); err != ErrUnknownAlgorithm {
+		t.Errorf("resigning with md5 returned %v, not ErrUnknownAlgorithm", err)
+	}
+}
+
+func TestResignExpressSignatures(t *testing.T) {
+	c := NewCookie("session=eyJhbmltYWxzIjoibGlvbiJ9^Vf2INocdJIqKWVfYGhXwPhQZNFI")
+	if !c.Decode() {
+		t.Fatalf("could not decode the Express cookie")
+	}
+
+	// Signatures keygrip produces for this data.
+	expected := map[string]string{
+		"sha1":   "cHrPeERK_nxbUF2-rFhyeRAnqAM",
+		"sha256": "xredyRbj9mADSXS9IFCaymLlnau2ruroELXeOOQRenI",
+		"sha384": "himLU78T8stlzz7ARtMpS37ejHbh7sRlozyGuF1oSOsCKoV9BAYQes1ccTLzhBcs",
+		"sha512": "-PrGEdy_-dfTOIerY9G-phwXrOSlAdPFjCwm8f7cKO5iUS2uWIfhdIW6YBKIbVI20GdS_zCWrGrqMGhHUlZrBQ",
+	}
+
+	for algorithm, signature := range expected {
+		_, value, computed, err := expressSign(c, `{"registry":true}`, []byte("changeme"), algorithm)
+		if err != nil {
+			t.Errorf("could not resign with %s: %v", algorithm, err)
+			continue
+		}
+
+		if value != "eyJyZWdpc3RyeSI6dHJ1ZX0=" || computed != signature {
+			t.Errorf("%s signed %s as %s, not %s", algorithm, value, computed, signature)
+		}
+	}
+
+	if _, _, _, err := expressSign(c, `{"registry":true}`, []byte("changeme"), "md5"); err != ErrUnknownAlgorithm {
+		t.Errorf("resigning with md5 returned %v, not ErrUnknownAlgorithm", err)
+	}
+}
+
+func TestResignIncompatibleKey(t *testing.T) {
+	for decoder, secret := range map[string]string{djangoDecoder: "changeme", laravelDecoder: "zseMzUq8M6oPB5xkPvIWddeepxzseJtN"} {
+		wl := NewWordlist()
+		if err := wl.LoadFromArray([][]byte{[]byte(secret)}); err != nil {
+			t.Errorf("could not LoadFromArray")
+		}
+
+		c := NewCookie(selfTestFixtures[decoder][0].cookie)
+		c.Decode()
+
+		if _, success := c.Unsign(wl, 100); !success {
+			t.Fatalf("could not unsign the %s fixture", decoder)
+		}
+
+		if _, err := c.ResignWith("{}", ResignOptions{Key: []byte{}}); !errors.Is(err, ErrIncompatibleKey) {
+			t.Errorf("%s resigned with an empty key: %v", decoder, err)
+		}
+	}
+
+	wl := NewWordlist()
+	if err := wl.LoadFromArray([][]byte{[]byte("zseMzUq8M6oPB5xkPvIWddeepxzseJtN")}); err != nil {
+		t.Errorf("could not LoadFromArray")
+	}
+
+	// Laravel's AES-256 needs a 32 byte key, even though its MAC doesn't.
+	c := NewCookie(selfTestFixtures[laravelDecoder][0].cookie)
+	c.Decode()
+	c.Unsign(wl, 100)
+
+	if _, err := c.ResignWith("{}", ResignOptions{Key: []byte("a-twenty-byte-secret")}); !errors.Is(err, ErrIncompatibleKey) {
+		t.Errorf("resigned Laravel with a 20 byte key: %v", err)
+	}
+
+	if _, err := c.ResignWith("{}", ResignOptions{}); !errors.Is(err, ErrResignUnsupported) {
+		t.Errorf("unexpected error resigning Laravel with its own key: %v", err)
+	}
+}
+
+func TestResignDjangoCompressedRoundTrip(t *testing.T) {
+	wl := NewWordlist()
+	if err := wl.LoadFromArray([][]byte{[]byte("changeme")}); err != nil {
+		t.Errorf("could not LoadFromArray")
+	}
+
+	original := ".eJyrVopPLC3JiC8tTi2Kz0xRslIyUtJRSk4sKlGyijbUoSqMrQUA9S8YsA:1mhTAe:TdRXvd5RfNOrqhJrFcTQJTCNjv6LpKp-dyAZzIxrlno"
+
+	c := NewCookie(original)
+	c.Decode()
+
+	if _, success := c.Unsign(wl, 100); !success {
+		t.Fatalf("could not unsign a compressed cookie")
+	}
+
+	data := string(c.parsedDataFor(djangoDecoder).(*djangoParsedData).decodedData)
+
+	resigned, err := c.Resign(data)
+	if err != nil {
+		t.Fatalf("could not resign: %v", err)
+	}
+
+	resignedCookie := NewCookie(resigned)
+	if !resignedCookie.Decode() || !resignedCookie.hasParsedDataFor(djangoDecoder) {
+		t.Fatalf("could not decode the resigned cookie: %s", resigned)
+	}
+
+	parsedData := resignedCookie.parsedDataFor(djangoDecoder).(*djangoParsedData)
+	if !parsedData.compressed || string(parsedData.decodedData) != data {
+		t.Errorf("resigned cookie does not carry the same compressed data: %s", resigned)
+	}
+
+	// The signature covers the compressed, encoded data with its dot.
+	if !djangoUnsign(resignedCookie, []byte("changeme")) {
+		t.Errorf("resigned compressed cookie does not verify under djangoUnsign")
+	}
+}
+
+func TestResignConnect(t *testing.T) {
+	raw := selfTestFixtures[connectDecoder][0].cookie
+
+	c := NewCookie(raw)
+	c.Decode()
+
+	if !c.UnsignWithSecret([]byte("keyboard cat")) {
+		t.Fatalf("could not unsign the express-session cookie")
+	}
+
+	// Resigning the same session ID must reproduce the original cookie.
+	if resigned, err := c.Resign("Ws4JzQSJbQ4ZBqDKoJ5xhrilD6Z2Wyso"); err != nil || resigned != raw {
+		t.Errorf("resigned cookie is %s, wanted %s (%v)", resigned, raw, err)
+	}
+
+	escaped := NewCookie(strings.Replace(strings.Replace(raw, ":", "%3A", 1), "/", "%2F", 1))
+	escaped.Decode()
+
+	if !escaped.UnsignWithSecret([]byte("keyboard cat")) {
+		t.Fatalf("could not unsign the URL-encoded cookie")
+	}
+
+	resigned, err := escaped.Resign("another-session")
+	if err != nil || !strings.HasPrefix(resigned, "s%3Aanother-session.") {
+		t.Fatalf("did not keep the URL encoding: %s (%v)", resigned, err)
+	}
+
+	resignedCookie := NewCookie(resigned)
+	resignedCookie.Decode()
+
+	if !resignedCookie.UnsignWithSecret([]byte("keyboard cat")) {
+		t.Errorf("resigned cookie does not verify")
+	}
+
+	if _, err := c.ResignWith("x", ResignOptions{Algorithm: "sha512"}); !errors.Is(err, ErrUnknownAlgorithm) {
+		t.Errorf("resigned with an algorithm cookie-signature doesn't use: %v", err)
+	}
+}
+
+func TestResignWithSecret(t *testing.T) {
+	signedAt := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+
+	for _, fixture := range []string{selfTestFixtures[djangoDecoder][0].cookie, selfTestFixtures[flaskDecoder][0].cookie} {
+		c := NewCookie(fixture)
+		if !c.Decode() {
+			t.Fatalf("could not decode %s", fixture)
+		}
+
+		if _, err := c.ResignWithSecret(`{"admin":true}`, []byte("wrong"), ResignOptions{}); err != ErrWrongSecret {
+			t.Errorf("expected ErrWrongSecret, got %v", err)
+		}
+
+		resigned, err := c.ResignWithSecret(`{"admin":true}`, []byte("changeme"), ResignOptions{Timestamp: signedAt})
+		if err != nil {
+			t.Fatalf("could not resign %s: %v", fixture, err)
+		}
+
+		// The new cookie must verify with the same secret, and be signed at
+		// the new time rather than the original's.
+		forged := NewCookie(resigned)
+		if !forged.Decode() || !forged.UnsignWithSecret([]byte("changeme")) {
+			t.Fatalf("could not unsign the forged cookie %s", resigned)
+		}
+
+		if at, ok := forged.SignedAt(); !ok || !at.Equal(signedAt) {
+			t.Errorf("expected %s to be signed at %v, got %v", resigned, signedAt, at)
+		}
+
+		// Once unsigned, another secret is still checked rather than
+		// trusted.
+		if _, err := c.ResignWithSecret(`{"admin":true}`, []byte("wrong"), ResignOptions{}); err != ErrWrongSecret {
+			t.Errorf("expected ErrWrongSecret after unsigning, got %v", err)
+		}
+	}
+}
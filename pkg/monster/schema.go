@@ -0,0 +1,108 @@
+package monster
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// Describes the fields found across the JSON sessions of many cookies from
+// the same app, which helps analysts understand its session model.
+type SchemaReport struct {
+	// How many of the cookies had a decoded JSON session.
+	Sessions int
+
+	// Each field by its dotted path, e.g. `prefs.theme`.
+	Fields map[string]*SchemaField
+}
+
+// Describes a single session field across cookies.
+type SchemaField struct {
+	// How many sessions contained this field.
+	Count int
+
+	// How many times the field held each JSON type, e.g. "string".
+	Types map[string]int
+}
+
+// Returns the field paths in the report, sorted.
+func (r SchemaReport) Paths() []string {
+	paths := make([]string, 0, len(r.Fields))
+	for path := range r.Fields {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+	return paths
+}
+
+// Aggregates the fields of every decoded JSON session in `cookies`. Each
+// cookie must already have been decoded; cookies without a JSON session are
+// skipped.
+func InferSessionSchema(cookies []*Cookie) SchemaReport {
+	report := SchemaReport{Fields: make(map[string]*SchemaField)}
+
+	for _, c := range cookies {
+		payload, ok := c.sessionPayload()
+		if !ok {
+			continue
+		}
+
+		var session map[string]interface{}
+		if err := json.Unmarshal(payload, &session); err != nil {
+			continue
+		}
+
+		report.Sessions++
+		report.addFields(session, "")
+	}
+
+	return report
+}
+
+func (r *SchemaReport) addFields(object map[string]interface{}, prefix string) {
+	for key, value := range object {
+		path := prefix + key
+
+		field, ok := r.Fields[path]
+		if !ok {
+			field = &SchemaField{Types: make(map[string]int)}
+			r.Fields[path] = field
+		}
+
+		field.Count++
+		field.Types[jsonTypeOf(value)]++
+
+		if child, ok := value.(map[string]interface{}); ok {
+			r.addFields(child, path+".")
+		}
+	}
+}
+
+func jsonTypeOf(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// Returns the decoded JSON session from the likeliest decoder that has
+// one.
+func (c *Cookie) sessionPayload() ([]byte, bool) {
+	for _, match := range c.Matches() {
+		if payload, ok := c.Payload(match.Decoder); ok && json.Valid(payload) {
+			return payload, true
+		}
+	}
+
+	return nil, false
+}
@@ -0,0 +1,53 @@
+package monster
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInferSessionSchema(t *testing.T) {
+	var cookies []*Cookie
+
+	for _, raw := range []string{
+		// {"_auth_user_id":"1","is_staff":true,"prefs":{"theme":"dark"}}
+		"eyJfYXV0aF91c2VyX2lkIjoiMSIsImlzX3N0YWZmIjp0cnVlLCJwcmVmcyI6eyJ0aGVtZSI6ImRhcmsifX0:1mhTAe:f34R73b_Cn3tyFtEk2_dnRK5Caq6tSeRgQDAjRj18dM",
+		// {"_auth_user_id":"2","is_staff":false,"cart":[1,2]}
+		"eyJfYXV0aF91c2VyX2lkIjoiMiIsImlzX3N0YWZmIjpmYWxzZSwiY2FydCI6WzEsMl19:1mhTAe:nSpbU2IuS3K2okEuqBNBHigbMWcsLdJ_mERQLgzoTDo",
+		// {"_auth_user_id":3,"prefs":{"theme":null}}
+		"eyJfYXV0aF91c2VyX2lkIjozLCJwcmVmcyI6eyJ0aGVtZSI6bnVsbH19:1mhTAe:MnQl3xeIaxzT3ioTZSFOWEosvMJyDmzlI9ePnowOdU0",
+		// Pickled, so it has no JSON session.
+		"gAJ9cQFVBV9uZXh0cQJYAQAAAC9zLg:1mh2IM:rAOWFyG5ROIOxriY8pwm9jFma5w",
+	} {
+		c := NewCookie(raw)
+		if !c.Decode() {
+			t.Fatalf("cannot decode valid django cookie")
+		}
+
+		cookies = append(cookies, c)
+	}
+
+	report := InferSessionSchema(cookies)
+
+	if report.Sessions != 3 {
+		t.Errorf("expected 3 sessions, got %d", report.Sessions)
+	}
+
+	expectedPaths := []string{"_auth_user_id", "cart", "is_staff", "prefs", "prefs.theme"}
+	if paths := report.Paths(); !reflect.DeepEqual(paths, expectedPaths) {
+		t.Errorf("unexpected paths %v", paths)
+	}
+
+	userID := report.Fields["_auth_user_id"]
+	if userID.Count != 3 || userID.Types["string"] != 2 || userID.Types["number"] != 1 {
+		t.Errorf("unexpected _auth_user_id field %+v", userID)
+	}
+
+	theme := report.Fields["prefs.theme"]
+	if theme.Count != 2 || theme.Types["string"] != 1 || theme.Types["null"] != 1 {
+		t.Errorf("unexpected prefs.theme field %+v", theme)
+	}
+
+	if report.Fields["cart"].Types["array"] != 1 || report.Fields["is_staff"].Types["boolean"] != 2 {
+		t.Errorf("unexpected cart or is_staff fields")
+	}
+}
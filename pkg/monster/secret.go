@@ -0,0 +1,39 @@
+package monster
+
+import (
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"strings"
+)
+
+var (
+	ErrNotPEMKey = errors.New("PEM block is not a key")
+)
+
+// Parses a secret the way users tend to have it, for checking a known key
+// rather than brute-forcing one. This understands PEM-encoded keys (any
+// `-----BEGIN ... KEY-----` block, whose contents are the raw key bytes)
+// and Laravel's `base64:`-prefixed `APP_KEY`; anything else is used as-is.
+func ParseSecret(secret string) ([]byte, error) {
+	trimmed := strings.TrimSpace(secret)
+
+	if strings.HasPrefix(trimmed, "-----BEGIN ") {
+		block, _ := pem.Decode([]byte(trimmed))
+		if block == nil {
+			return nil, ErrNotPEMKey
+		}
+
+		if !strings.HasSuffix(block.Type, "KEY") {
+			return nil, ErrNotPEMKey
+		}
+
+		return block.Bytes, nil
+	}
+
+	if strings.HasPrefix(trimmed, laravelKeyPrefix) {
+		return base64.StdEncoding.DecodeString(strings.TrimPrefix(trimmed, laravelKeyPrefix))
+	}
+
+	return []byte(secret), nil
+}
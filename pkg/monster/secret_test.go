@@ -0,0 +1,37 @@
+package monster
+
+import (
+	"testing"
+)
+
+func TestParseSecretPEM(t *testing.T) {
+	pemKey := "-----BEGIN SECRET KEY-----\nenNlTXpVcThNNm9QQjV4a1B2SVdkZGVlcHh6c2VKdE4=\n-----END SECRET KEY-----\n"
+
+	key, err := ParseSecret(pemKey)
+	if err != nil || string(key) != "zseMzUq8M6oPB5xkPvIWddeepxzseJtN" {
+		t.Fatalf("could not parse a PEM key: %q, %v", key, err)
+	}
+
+	// The parsed key must unsign a Laravel cookie encrypted with it.
+	c := NewCookie(selfTestFixtures[laravelDecoder][0].cookie)
+	c.Decode()
+
+	wl := NewWordlist()
+	wl.LoadFromArray([][]byte{key})
+
+	if _, success := c.Unsign(wl, 1); !success {
+		t.Errorf("could not unsign with the PEM key")
+	}
+
+	if key, err := ParseSecret("base64:enNlTXpVcThNNm9QQjV4a1B2SVdkZGVlcHh6c2VKdE4="); err != nil || string(key) != "zseMzUq8M6oPB5xkPvIWddeepxzseJtN" {
+		t.Errorf("could not parse a Laravel APP_KEY: %q, %v", key, err)
+	}
+
+	if key, _ := ParseSecret("changeme"); string(key) != "changeme" {
+		t.Errorf("changed a plain secret to %q", key)
+	}
+
+	if _, err := ParseSecret("-----BEGIN CERTIFICATE-----\nAAAA\n-----END CERTIFICATE-----\n"); err != ErrNotPEMKey {
+		t.Errorf("accepted a certificate as a key")
+	}
+}
@@ -0,0 +1,309 @@
+package monster
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// Where `DefaultSecretCachePath()` keeps the cache, under the home
+	// directory.
+	secretCacheDirectory = ".cookiemonster"
+	secretCacheFile      = "secrets.json"
+
+	// How much of the secret's SHA256 identifies it in the cache.
+	secretFingerprintLength = 8
+)
+
+var (
+	// Shared by every `Cookie` which uses the same path, so a batch run
+	// only reads the file once; see `WithSecretCache()`.
+	secretCaches      = make(map[string]*SecretCache)
+	secretCachesMutex sync.Mutex
+)
+
+// A file of the secrets `Unsign()` has found, to try first on new cookies,
+// since apps from the same organization often share one; see
+// `WithSecretCache()`. It is JSON, and holds the secrets themselves, so it
+// is only readable by its owner.
+type SecretCache struct {
+	path    string
+	mutex   sync.Mutex
+	entries []CachedSecret
+}
+
+// A secret in a `SecretCache`, for one decoder.
+type CachedSecret struct {
+	Decoder string `json:"decoder"`
+
+	// The start of the hex SHA256 of the secret, which identifies it
+	// without showing it.
+	Fingerprint string `json:"fingerprint"`
+	Secret      []byte `json:"secret"`
+
+	// How many times it unsigned a cookie, and when it first and last did.
+	Hits      int       `json:"hits"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// Returns `~/.cookiemonster/secrets.json`.
+func DefaultSecretCachePath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, secretCacheDirectory, secretCacheFile), nil
+}
+
+func secretFingerprint(secret []byte) string {
+	sum := sha256.Sum256(secret)
+	return hex.EncodeToString(sum[:secretFingerprintLength])
+}
+
+// Reads the cache at `path`; a cache which doesn't exist yet is empty, and
+// is created once a secret is added.
+func OpenSecretCache(path string) (*SecretCache, error) {
+	s := &SecretCache{path: path}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Returns the cache for `path` shared by `WithSecretCache()`.
+func sharedSecretCache(path string) (*SecretCache, error) {
+	secretCachesMutex.Lock()
+	defer secretCachesMutex.Unlock()
+
+	if s, ok := secretCaches[path]; ok {
+		return s, nil
+	}
+
+	s, err := OpenSecretCache(path)
+	if err != nil {
+		return nil, err
+	}
+
+	secretCaches[path] = s
+	return s, nil
+}
+
+func (s *SecretCache) load() error {
+	contents, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		s.entries = nil
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var entries []CachedSecret
+	if err := json.Unmarshal(contents, &entries); err != nil {
+		return err
+	}
+
+	s.entries = entries
+	return nil
+}
+
+// Writes the cache through a temporary file, so a run which is interrupted
+// can't leave it half-written.
+func (s *SecretCache) save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+
+	contents, err := json.MarshalIndent(s.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(append(contents, '\n')); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+
+	return os.Rename(file.Name(), s.path)
+}
+
+// Returns every cached secret, most used first.
+func (s *SecretCache) Entries() []CachedSecret {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entries := append([]CachedSecret{}, s.entries...)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Hits > entries[j].Hits
+	})
+
+	return entries
+}
+
+// Returns each distinct cached secret once, to try as candidates: those
+// found for any of `decoders` first, then the rest, each most used first.
+// The file is read again first, so secrets another `SecretCache` has since
+// purged aren't returned; if it can't be, the secrets already read are.
+func (s *SecretCache) Secrets(decoders ...string) [][]byte {
+	s.mutex.Lock()
+	s.load()
+	s.mutex.Unlock()
+
+	wanted := make(map[string]bool, len(decoders))
+	for _, decoder := range decoders {
+		wanted[decoder] = true
+	}
+
+	entries := s.Entries()
+	sort.SliceStable(entries, func(i, j int) bool {
+		return wanted[entries[i].Decoder] && !wanted[entries[j].Decoder]
+	})
+
+	seen := make(map[string]bool, len(entries))
+	var secrets [][]byte
+
+	for _, entry := range entries {
+		if !seen[entry.Fingerprint] {
+			seen[entry.Fingerprint] = true
+			secrets = append(secrets, entry.Secret)
+		}
+	}
+
+	return secrets
+}
+
+// Records that `secret` unsigned a cookie with `decoder`, and saves the
+// cache. The file is read again first, so runs sharing it don't undo each
+// other's additions.
+func (s *SecretCache) Add(decoder string, secret []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+
+	fingerprint, seenAt := secretFingerprint(secret), now()
+
+	found := false
+	for i := range s.entries {
+		if s.entries[i].Decoder == decoder && s.entries[i].Fingerprint == fingerprint {
+			s.entries[i].Hits++
+			s.entries[i].LastSeen = seenAt
+			found = true
+		}
+	}
+
+	if !found {
+		s.entries = append(s.entries, CachedSecret{
+			Decoder:     decoder,
+			Fingerprint: fingerprint,
+			Secret:      append([]byte{}, secret...),
+			Hits:        1,
+			FirstSeen:   seenAt,
+			LastSeen:    seenAt,
+		})
+	}
+
+	return s.save()
+}
+
+// Removes the secrets cached for any of `decoders`, or every secret if none
+// are given, and saves the cache; it returns how many were removed.
+func (s *SecretCache) Purge(decoders ...string) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.load(); err != nil {
+		return 0, err
+	}
+
+	purge := make(map[string]bool, len(decoders))
+	for _, decoder := range decoders {
+		purge[decoder] = true
+	}
+
+	var kept []CachedSecret
+	for _, entry := range s.entries {
+		if len(decoders) > 0 && !purge[entry.Decoder] {
+			kept = append(kept, entry)
+		}
+	}
+
+	purged := len(s.entries) - len(kept)
+	s.entries = kept
+
+	return purged, s.save()
+}
+
+// Tries the secrets in the `WithSecretCache()` cache, if there is one,
+// before `Unsign()` moves on to its candidates.
+func (c *Cookie) trySecretCache(options *unsignOptions, plan unsignPlan) {
+	if options.secretCache == "" {
+		return
+	}
+
+	s, err := sharedSecretCache(options.secretCache)
+	if err != nil {
+		c.log(LogEvent{Kind: LogWarning, Message: "Could not read the secret cache: " + err.Error()})
+		return
+	}
+
+	var decoders []string
+	for _, match := range c.Matches() {
+		decoders = append(decoders, match.Decoder)
+	}
+
+	// The cache holds keys as the app uses them, so they're not transformed
+	// again.
+	keys := &derivedKeys{}
+	for _, secret := range s.Secrets(decoders...) {
+		if c.wasUnsigned() {
+			return
+		}
+
+		c.tryKey(plan, secret, secret, keys)
+	}
+}
+
+// Adds the key `Unsign()` found, if it found one, to the `WithSecretCache()`
+// cache.
+func (c *Cookie) rememberSecret(options *unsignOptions) {
+	if options.secretCache == "" {
+		return
+	}
+
+	success, key, decoder := c.Result()
+	if !success {
+		return
+	}
+
+	s, err := sharedSecretCache(options.secretCache)
+	if err == nil {
+		err = s.Add(decoder, key)
+	}
+
+	if err != nil {
+		c.log(LogEvent{Kind: LogWarning, Decoder: decoder, Message: "Could not save the key to the secret cache: " + err.Error()})
+	}
+}
@@ -0,0 +1,74 @@
+package monster
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSecretCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache", "secrets.json")
+
+	wl := NewWordlist()
+	wl.LoadFromArray([][]byte{[]byte("wrong"), []byte("changeme")})
+
+	c := NewCookie(selfTestFixtures[hashHMACDecoder][0].cookie)
+	if !c.Decode() {
+		t.Fatalf("could not decode the first cookie")
+	}
+
+	if _, ok := c.Unsign(wl, 1, WithSecretCache(path)); !ok {
+		t.Fatalf("could not unsign the first cookie")
+	}
+
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("the cache was not written privately: %v", err)
+	}
+
+	// Another cookie signed with the same secret needs no wordlist at all.
+	other := NewCookie(selfTestFixtures[hashHMACDecoder][1].cookie)
+	if !other.Decode() {
+		t.Fatalf("could not decode the second cookie")
+	}
+
+	if key, ok := other.Unsign(NewWordlist(), 1, WithSecretCache(path)); !ok || string(key) != "changeme" {
+		t.Fatalf("the cached secret did not unsign the second cookie")
+	}
+
+	cache, err := OpenSecretCache(path)
+	if err != nil {
+		t.Fatalf("could not open the cache: %v", err)
+	}
+
+	entries := cache.Entries()
+	if len(entries) != 1 || entries[0].Decoder != hashHMACDecoder || entries[0].Hits != 2 || entries[0].Fingerprint != secretFingerprint([]byte("changeme")) {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+
+	if err := cache.Add(djangoDecoder, []byte("other")); err != nil {
+		t.Fatalf("could not add to the cache: %v", err)
+	}
+
+	if secrets := cache.Secrets(djangoDecoder); len(secrets) != 2 || string(secrets[0]) != "other" {
+		t.Errorf("expected the Django secret first, got %q", secrets)
+	}
+
+	if purged, err := cache.Purge(djangoDecoder); err != nil || purged != 1 || len(cache.Entries()) != 1 {
+		t.Errorf("expected to purge one secret, purged %d: %v", purged, err)
+	}
+
+	if purged, err := cache.Purge(); err != nil || purged != 1 || len(cache.Entries()) != 0 {
+		t.Errorf("expected to purge the last secret, purged %d: %v", purged, err)
+	}
+
+	// `Unsign()` shares the cache it read earlier, which mustn't still try
+	// the purged secret.
+	purged := NewCookie(selfTestFixtures[hashHMACDecoder][1].cookie)
+	if !purged.Decode() {
+		t.Fatalf("could not decode the third cookie")
+	}
+
+	if key, ok := purged.Unsign(NewWordlist(), 1, WithSecretCache(path)); ok {
+		t.Errorf("unsigned with the purged secret %q", key)
+	}
+}
@@ -0,0 +1,37 @@
+package monster
+
+import (
+	"encoding/json"
+)
+
+// The session serializers we can recognize from a decoded Django or Flask
+// session.
+const (
+	serializerJSON    = "json"
+	serializerPickle  = "pickle"
+	serializerMsgpack = "msgpack"
+	serializerUnknown = "unknown"
+)
+
+// Guesses which serializer produced a decoded session. Django and Flask
+// default to JSON, older Django apps use pickle, and custom serializers are
+// often MessagePack.
+func sessionSerializer(data []byte) string {
+	if json.Valid(data) {
+		return serializerJSON
+	}
+
+	// Pickles from protocol 2 onwards start with `PROTO` and end with `STOP`.
+	if len(data) > 2 && data[0] == 0x80 && data[len(data)-1] == '.' {
+		return serializerPickle
+	}
+
+	// Sessions are dictionaries, so we only accept a MessagePack map.
+	if value, err := msgpackDecode(data); err == nil {
+		if _, ok := value.(map[string]interface{}); ok {
+			return serializerMsgpack
+		}
+	}
+
+	return serializerUnknown
+}
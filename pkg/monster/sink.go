@@ -0,0 +1,59 @@
+package monster
+
+import (
+	"fmt"
+	"io"
+)
+
+const (
+	LogFound   = "found"
+	LogWarning = "warning"
+)
+
+// A message from the engine while it unsigns a cookie.
+type LogEvent struct {
+	// Either `LogFound` or `LogWarning`.
+	Kind    string
+	Decoder string
+	Message string
+
+	// The key that was found, for `LogFound` events.
+	Key []byte
+}
+
+// A `LogSink` receives the engine's messages, so that callers showing
+// progress can print them without the output interleaving. Sinks may be
+// called from several goroutines at once.
+type LogSink interface {
+	Log(event LogEvent)
+}
+
+// A `LogSink` which delivers events, in order, to a channel. The channel
+// must be drained while unsigning, or the engine will block.
+type ChannelSink chan LogEvent
+
+func (s ChannelSink) Log(event LogEvent) {
+	s <- event
+}
+
+// A `LogSink` which writes each event's message as a line to `Writer`.
+type WriterSink struct {
+	Writer io.Writer
+}
+
+func (s WriterSink) Log(event LogEvent) {
+	fmt.Fprintln(s.Writer, event.Message)
+}
+
+// Sends an event to the cookie's sink. Without one, we keep the original
+// behavior of printing warnings and nothing else.
+func (c *Cookie) log(event LogEvent) {
+	if c.logSink != nil {
+		c.logSink.Log(event)
+		return
+	}
+
+	if event.Kind == LogWarning {
+		fmt.Println(event.Message)
+	}
+}
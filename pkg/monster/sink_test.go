@@ -0,0 +1,50 @@
+package monster
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestChannelSinkOrder(t *testing.T) {
+	sink := make(ChannelSink, 3)
+	c := NewCookie("abc")
+	c.logSink = sink
+
+	c.wasUnsignedBy(rackDecoder, []byte("first"), []byte("first"))
+	c.wasUnsignedBy(djangoDecoder, []byte("second"), []byte("second"))
+	close(sink)
+
+	var kinds, decoders []string
+	for event := range sink {
+		kinds = append(kinds, event.Kind)
+		decoders = append(decoders, event.Decoder)
+	}
+
+	expectedKinds := []string{LogFound, LogWarning, LogFound}
+	expectedDecoders := []string{rackDecoder, djangoDecoder, djangoDecoder}
+
+	for i := range expectedKinds {
+		if i >= len(kinds) || kinds[i] != expectedKinds[i] || decoders[i] != expectedDecoders[i] {
+			t.Fatalf("events out of order: %v %v", kinds, decoders)
+		}
+	}
+}
+
+func TestUnsignWithLogSink(t *testing.T) {
+	wl := NewWordlist()
+	if err := wl.LoadFromArray([][]byte{[]byte("super secret")}); err != nil {
+		t.Errorf("could not LoadFromArray")
+	}
+
+	var out bytes.Buffer
+	c := NewCookie("BAhJIgl0ZXN0BjoGRVQ=--8c5ae09ed57f1e933cc466f5b99ea636d1fc31a2")
+	c.Decode()
+
+	if _, success := c.Unsign(wl, 100, WithLogSink(WriterSink{&out})); !success {
+		t.Errorf("could not unsign an unsignable cookie")
+	}
+
+	if out.String() != "Discovered the key for this cookie with the rack decoder\n" {
+		t.Errorf("unexpected log output %q", out.String())
+	}
+}
@@ -0,0 +1,260 @@
+package monster
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type springParsedData struct {
+	// The cookie name, if the value was given as `remember-me=...`.
+	name string
+
+	data             string
+	decodedData      []byte
+	encoding         string
+	username         string
+	expiry           string
+	signature        string
+	decodedSignature []byte
+	algorithm        string
+
+	// Spring Security 6 names the digest in the cookie; 5 and earlier
+	// always use MD5 and don't.
+	declaredAlgorithm string
+
+	// One more than the index of the password that verified the cookie, or
+	// zero before one has; see `SetRememberMePasswords()`.
+	matchedPassword int32
+
+	parsed bool
+}
+
+func (d *springParsedData) String() string {
+	if !d.parsed {
+		return "Unparsed data"
+	}
+
+	return fmt.Sprintf("Data: %s\nEncoding: %s\nUsername: %s\nExpiry: %s\nSignature: %s\nAlgorithm: %s\n", d.data, d.encoding, d.username, d.expiry, d.signature, d.algorithm)
+}
+
+func (d *springParsedData) serialized(c *Cookie) []byte {
+	return d.decodedData
+}
+
+func (d *springParsedData) fields() parsedFields {
+	return parsedFields{data: d.data, timestamp: d.expiry, signature: d.signature, decodedSignature: d.decodedSignature, algorithm: d.algorithm}
+}
+
+func (d *springParsedData) clone() decoderData {
+	copied := *d
+	copied.matchedPassword = 0
+	return &copied
+}
+
+// Spring Security's `TokenBasedRememberMeServices` cookies are the base64
+// of `username:expiry:signature`, where the signature is the hex digest of
+// `username:expiry:password:key`; Spring Security 6 puts the digest's name
+// before the signature. The password is the user's stored (usually hashed)
+// password, so the key can only be found once it's known.
+const (
+	springDecoder   = "spring"
+	springMinLength = 40
+
+	springSeparator  = `:`
+	springCookieName = "remember-me"
+)
+
+var (
+	// The digests Spring Security 6 names, and the one each stands for.
+	springDeclaredAlgorithms = map[string]string{
+		"MD5":    "md5",
+		"SHA256": "sha256",
+	}
+
+	springAlgorithmLength = map[int]string{
+		16: "md5",
+		32: "sha256",
+	}
+)
+
+func springDecode(c *Cookie) bool {
+	var parsedData springParsedData
+
+	rawData := c.raw
+	if strings.HasPrefix(rawData, springCookieName+"=") {
+		parsedData.name, rawData = springCookieName, strings.TrimPrefix(rawData, springCookieName+"=")
+	}
+
+	if len(rawData) < springMinLength {
+		return false
+	}
+
+	// Spring strips base64's padding, and the tokens are separated by colons.
+	decoded, encoding, ok := c.decodeTolerant(rawData, func(decoded []byte) bool {
+		tokens := strings.Split(string(decoded), springSeparator)
+		return len(tokens) == 3 || len(tokens) == 4
+	})
+
+	if !ok {
+		return false
+	}
+
+	tokens := strings.Split(string(decoded), springSeparator)
+
+	// Spring URL-encodes each token, so a username can't contain a colon.
+	username, err := url.QueryUnescape(tokens[0])
+	if err != nil {
+		return false
+	}
+
+	if _, err := strconv.ParseInt(tokens[1], 10, 64); err != nil {
+		return false
+	}
+
+	parsedData.username, parsedData.expiry, parsedData.signature = username, tokens[1], tokens[len(tokens)-1]
+
+	decodedSignature, err := hex.DecodeString(parsedData.signature)
+	if err != nil {
+		return false
+	}
+
+	alg, ok := springAlgorithmLength[len(decodedSignature)]
+	if !ok {
+		c.unknownSignatureLength(springDecoder, len(decodedSignature))
+		return false
+	}
+
+	if len(tokens) == 4 {
+		parsedData.declaredAlgorithm = tokens[2]
+		if declared, ok := springDeclaredAlgorithms[tokens[2]]; !ok || declared != alg {
+			return false
+		}
+	}
+
+	parsedData.data = rawData
+	parsedData.decodedData = decoded
+	parsedData.encoding = encoding
+	parsedData.decodedSignature = decodedSignature
+	parsedData.algorithm = alg
+	parsedData.parsed = true
+	c.wasDecodedBy(springDecoder, &parsedData)
+
+	return true
+}
+
+// Returns the hex digest Spring signs remember-me cookies with.
+func springSignature(algorithm string, username string, expiry string, password string, secret []byte) ([]byte, bool) {
+	message := []byte(username + springSeparator + expiry + springSeparator + password + springSeparator)
+	message = append(message, secret...)
+
+	var digest []byte
+	switch algorithm {
+	case "md5":
+		sum := md5.Sum(message)
+		digest = sum[:]
+	case "sha256":
+		sum := sha256.Sum256(message)
+		digest = sum[:]
+	default:
+		return nil, false
+	}
+
+	return []byte(hex.EncodeToString(digest)), true
+}
+
+// The signature covers the user's password hash as well as the key, so
+// there's nothing to try keys against without `SetRememberMePasswords()`.
+func springReady(c *Cookie) bool {
+	return len(c.rememberMePasswords) > 0
+}
+
+func springUnsign(c *Cookie, secret []byte) bool {
+	parsedData := c.parsedDataFor(springDecoder).(*springParsedData)
+
+	for i, password := range c.rememberMePasswords {
+		computedSignature, ok := springSignature(parsedData.algorithm, parsedData.username, parsedData.expiry, password, secret)
+		if !ok {
+			return false
+		}
+
+		// Spring compares the hex, which is always lowercase.
+		if hmac.Equal([]byte(parsedData.signature), computedSignature) {
+			atomic.CompareAndSwapInt32(&parsedData.matchedPassword, 0, int32(i+1))
+			return true
+		}
+	}
+
+	return false
+}
+
+// Resigns the cookie for `username:expiry`, or just a username to keep the
+// original expiry, with the password that verified it, keeping the cookie
+// name if the original had one.
+func springResign(c *Cookie, data string, secret []byte, algorithm string) (string, error) {
+	parsedData := c.parsedDataFor(springDecoder).(*springParsedData)
+
+	matched := atomic.LoadInt32(&parsedData.matchedPassword)
+	if matched == 0 {
+		return "", ErrNotUnsigned
+	}
+
+	username, expiry := data, parsedData.expiry
+	if separatorIndex := strings.LastIndex(data, springSeparator); separatorIndex >= 0 {
+		username, expiry = data[:separatorIndex], data[separatorIndex+1:]
+	}
+
+	signature, ok := springSignature(algorithm, username, expiry, c.rememberMePasswords[matched-1], secret)
+	if !ok {
+		return "", ErrUnknownAlgorithm
+	}
+
+	tokens := []string{url.QueryEscape(username), expiry}
+	if parsedData.declaredAlgorithm != "" {
+		for name, declared := range springDeclaredAlgorithms {
+			if declared == algorithm {
+				tokens = append(tokens, name)
+			}
+		}
+	}
+
+	tokens = append(tokens, string(signature))
+
+	resigned := base64.RawStdEncoding.EncodeToString([]byte(strings.Join(tokens, springSeparator)))
+	if parsedData.name != "" {
+		resigned = parsedData.name + "=" + resigned
+	}
+
+	return resigned, nil
+}
+
+// Returns when a remember-me cookie expires; Spring writes it in
+// milliseconds.
+func (c *Cookie) springExpiry() (time.Time, bool) {
+	if !c.hasParsedDataFor(springDecoder) {
+		return time.Time{}, false
+	}
+
+	milliseconds, err := strconv.ParseInt(c.parsedDataFor(springDecoder).(*springParsedData).expiry, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.Unix(0, milliseconds*int64(time.Millisecond)).UTC(), true
+}
+
+// Sets the stored passwords to try for a Spring Security remember-me
+// cookie, since each user's password is signed along with the key. They're
+// as the app stores them, e.g. `{bcrypt}$2a$10$...` or `{noop}password`.
+// Without any, the key can't be found. It must be called before `Unsign()`.
+func (c *Cookie) SetRememberMePasswords(passwords ...string) {
+	c.rememberMePasswords = passwords
+}
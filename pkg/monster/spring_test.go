@@ -0,0 +1,51 @@
+package monster
+
+import (
+	"testing"
+)
+
+const springTestCookie = "YWRtaW46MTkyNDk5MjAwMDAwMDpmZjNjZTg2NDBhZTVjOThiZGEzZTM2MTBhZTE4MzQxYQ"
+
+func TestDecodeSpring(t *testing.T) {
+	c := NewCookie(springCookieName + "=" + springTestCookie)
+	if !c.Decode() || !c.hasParsedDataFor(springDecoder) {
+		t.Fatalf("could not decode the remember-me cookie")
+	}
+
+	if expiry, ok := c.EmbeddedExpiry(); !ok || expiry.Unix() != 1924992000 {
+		t.Errorf("expected the cookie's expiry, got %v", expiry)
+	}
+
+	// The key can't be found without the user's password.
+	if c.UnsignWithSecret([]byte("changeme")) {
+		t.Errorf("unsigned the cookie without a password")
+	}
+
+	c.SetRememberMePasswords("{noop}wrong", "{noop}password")
+	if !c.UnsignWithSecret([]byte("changeme")) {
+		t.Fatalf("could not unsign the cookie with its password")
+	}
+}
+
+func TestResignSpring(t *testing.T) {
+	c := NewCookie(springTestCookie)
+	c.SetRememberMePasswords("{noop}password")
+
+	if !c.Decode() || !c.UnsignWithSecret([]byte("changeme")) {
+		t.Fatalf("could not unsign the remember-me cookie")
+	}
+
+	resigned, err := c.Resign("admin:1924992000001")
+	if err != nil {
+		t.Fatalf("could not resign the remember-me cookie: %v", err)
+	}
+
+	if resigned != "YWRtaW46MTkyNDk5MjAwMDAwMTpmMGU5NTJmODM5NTg1ZTYwYmNmOTNmYzY2MTg1OTUzMw" {
+		t.Errorf("unexpected resigned cookie %s", resigned)
+	}
+
+	// Leaving out the expiry keeps the original one.
+	if resigned, err := c.Resign("admin"); err != nil || resigned != springTestCookie {
+		t.Errorf("expected the original cookie back, got %s (%v)", resigned, err)
+	}
+}
@@ -0,0 +1,441 @@
+package monster
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"strings"
+)
+
+var (
+	errInvalidSQLite       = errors.New("invalid SQLite database")
+	errUnsupportedEncoding = errors.New("only UTF-8 SQLite databases are supported")
+	errNoSQLiteTable       = errors.New("no such SQLite table")
+)
+
+// Just enough of the SQLite file format (https://www.sqlite.org/fileformat.html)
+// to read every row of an ordinary table, so we needn't depend on a driver.
+// Indexes, `WITHOUT ROWID` tables and uncheckpointed WAL files are ignored.
+const (
+	sqliteMagic        = "SQLite format 3\x00"
+	sqliteHeaderLength = 100
+
+	// The encoding of text values, at this offset in the file header.
+	sqliteEncodingOffset = 56
+	sqliteEncodingUTF8   = 1
+
+	sqliteTableInterior = 0x05
+	sqliteTableLeaf     = 0x0d
+
+	// We refuse to descend deeper than this, which no real table comes near,
+	// so a corrupt file can't exhaust the stack.
+	sqliteMaxDepth = 64
+)
+
+type sqliteDatabase struct {
+	data       []byte
+	pageSize   int
+	usableSize int
+}
+
+// One pass over a table's pages. Each b-tree and overflow page of a valid
+// file belongs to one place in it, so a walk which reaches a page twice is
+// refused: however a corrupt file's pages refer to each other, at most the
+// pages in the file are read.
+type sqliteWalk struct {
+	db      *sqliteDatabase
+	visited map[uint32]bool
+}
+
+func openSQLite(data []byte) (*sqliteDatabase, error) {
+	if len(data) < sqliteHeaderLength || string(data[:len(sqliteMagic)]) != sqliteMagic {
+		return nil, errInvalidSQLite
+	}
+
+	// A page size of 1 means 65536, which doesn't fit in two bytes.
+	pageSize := int(binary.BigEndian.Uint16(data[16:18]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+
+	if pageSize < 512 || pageSize&(pageSize-1) != 0 {
+		return nil, errInvalidSQLite
+	}
+
+	if binary.BigEndian.Uint32(data[sqliteEncodingOffset:]) != sqliteEncodingUTF8 {
+		return nil, errUnsupportedEncoding
+	}
+
+	usableSize := pageSize - int(data[20])
+	if usableSize < 480 {
+		return nil, errInvalidSQLite
+	}
+
+	return &sqliteDatabase{data: data, pageSize: pageSize, usableSize: usableSize}, nil
+}
+
+// Returns page `number`, counting from 1 as SQLite does.
+func (db *sqliteDatabase) page(number uint32) ([]byte, error) {
+	if number == 0 || int64(number)*int64(db.pageSize) > int64(len(db.data)) {
+		return nil, errInvalidSQLite
+	}
+
+	start := int(number-1) * db.pageSize
+	return db.data[start : start+db.pageSize], nil
+}
+
+// Returns page `number` like `sqliteDatabase.page()`, unless the walk has
+// already read it.
+func (w *sqliteWalk) page(number uint32) ([]byte, error) {
+	if w.visited[number] {
+		return nil, errInvalidSQLite
+	}
+
+	page, err := w.db.page(number)
+	if err != nil {
+		return nil, err
+	}
+
+	w.visited[number] = true
+	return page, nil
+}
+
+// Finds the root page and column names of the table called `name`.
+func (db *sqliteDatabase) table(name string) (uint32, []string, error) {
+	var root uint32
+	var columns []string
+
+	// The schema is itself a table, rooted at the first page, whose rows are
+	// `(type, name, tbl_name, rootpage, sql)`.
+	err := db.rows(1, func(row []interface{}) bool {
+		if len(row) < 5 || row[0] != "table" || !strings.EqualFold(sqliteText(row[1]), name) {
+			return true
+		}
+
+		if page, ok := row[3].(int64); ok && page > 0 && page <= math.MaxUint32 {
+			root = uint32(page)
+			columns = sqliteColumns(sqliteText(row[4]))
+		}
+
+		return false
+	})
+
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if root == 0 {
+		return 0, nil, errNoSQLiteTable
+	}
+
+	return root, columns, nil
+}
+
+// Calls `visit` with each row of the table rooted at page `root`, in rowid
+// order, until it returns false.
+func (db *sqliteDatabase) rows(root uint32, visit func(row []interface{}) bool) error {
+	w := &sqliteWalk{db: db, visited: make(map[uint32]bool)}
+
+	_, err := w.walk(root, visit, 0)
+	return err
+}
+
+func (w *sqliteWalk) walk(number uint32, visit func(row []interface{}) bool, depth int) (bool, error) {
+	if depth > sqliteMaxDepth {
+		return false, errInvalidSQLite
+	}
+
+	page, err := w.page(number)
+	if err != nil {
+		return false, err
+	}
+
+	// The first page starts with the file header rather than its own.
+	header := 0
+	if number == 1 {
+		header = sqliteHeaderLength
+	}
+
+	if len(page) < header+12 {
+		return false, errInvalidSQLite
+	}
+
+	pageType := page[header]
+	cells := int(binary.BigEndian.Uint16(page[header+3:]))
+
+	pointers := header + 8
+	if pageType == sqliteTableInterior {
+		pointers = header + 12
+	} else if pageType != sqliteTableLeaf {
+		return false, errInvalidSQLite
+	}
+
+	if pointers+2*cells > len(page) {
+		return false, errInvalidSQLite
+	}
+
+	for i := 0; i < cells; i++ {
+		offset := int(binary.BigEndian.Uint16(page[pointers+2*i:]))
+
+		if pageType == sqliteTableInterior {
+			// Interior cells point to the child holding the rows before
+			// them, followed by the rowid they're keyed by.
+			if offset+4 > len(page) {
+				return false, errInvalidSQLite
+			}
+
+			more, err := w.walk(binary.BigEndian.Uint32(page[offset:]), visit, depth+1)
+			if !more || err != nil {
+				return false, err
+			}
+
+			continue
+		}
+
+		payload, err := w.payload(page, offset)
+		if err != nil {
+			return false, err
+		}
+
+		row, err := sqliteRecord(payload)
+		if err != nil {
+			return false, err
+		}
+
+		if !visit(row) {
+			return false, nil
+		}
+	}
+
+	if pageType == sqliteTableInterior {
+		return w.walk(binary.BigEndian.Uint32(page[header+8:]), visit, depth+1)
+	}
+
+	return true, nil
+}
+
+// Reads the payload of the table leaf cell at `offset`, following its
+// chain of overflow pages if it didn't fit on the page.
+func (w *sqliteWalk) payload(page []byte, offset int) ([]byte, error) {
+	db := w.db
+
+	if offset >= len(page) {
+		return nil, errInvalidSQLite
+	}
+
+	size, n := sqliteVarint(page[offset:])
+	if n == 0 || size > uint64(len(db.data)) {
+		return nil, errInvalidSQLite
+	}
+
+	offset += n
+
+	// Skip the rowid, which we don't need.
+	_, n = sqliteVarint(page[offset:])
+	if n == 0 {
+		return nil, errInvalidSQLite
+	}
+
+	offset += n
+
+	// How much of the payload is stored on the page, per the file format.
+	total := int(size)
+	local := total
+	if maxLocal := db.usableSize - 35; total > maxLocal {
+		minLocal := (db.usableSize-12)*32/255 - 23
+		local = minLocal + (total-minLocal)%(db.usableSize-4)
+
+		if local > maxLocal {
+			local = minLocal
+		}
+	}
+
+	if offset+local > len(page) {
+		return nil, errInvalidSQLite
+	}
+
+	payload := append([]byte{}, page[offset:offset+local]...)
+	if local == total {
+		return payload, nil
+	}
+
+	if offset+local+4 > len(page) {
+		return nil, errInvalidSQLite
+	}
+
+	// Each overflow page starts with the number of the next one.
+	next := binary.BigEndian.Uint32(page[offset+local:])
+	for len(payload) < total {
+		overflow, err := w.page(next)
+		if err != nil {
+			return nil, err
+		}
+
+		content := overflow[4:db.usableSize]
+		if remaining := total - len(payload); len(content) > remaining {
+			content = content[:remaining]
+		}
+
+		payload = append(payload, content...)
+		next = binary.BigEndian.Uint32(overflow)
+	}
+
+	return payload, nil
+}
+
+// Decodes a record: a header of each column's serial type, then the
+// columns themselves. Integers become `int64`, floats `float64`, text
+// `string`, blobs `[]byte` and NULLs `nil`.
+func sqliteRecord(payload []byte) ([]interface{}, error) {
+	headerSize, n := sqliteVarint(payload)
+	if n == 0 || headerSize < uint64(n) || headerSize > uint64(len(payload)) {
+		return nil, errInvalidSQLite
+	}
+
+	header := payload[n:headerSize]
+	body := payload[headerSize:]
+
+	var row []interface{}
+
+	for len(header) > 0 {
+		serialType, n := sqliteVarint(header)
+		if n == 0 {
+			return nil, errInvalidSQLite
+		}
+
+		header = header[n:]
+
+		var size int
+		switch {
+		case serialType >= 12 && serialType%2 == 0:
+			size = int((serialType - 12) / 2)
+		case serialType >= 13:
+			size = int((serialType - 13) / 2)
+		case serialType >= 1 && serialType <= 4:
+			size = int(serialType)
+		case serialType == 5:
+			size = 6
+		case serialType == 6 || serialType == 7:
+			size = 8
+		case serialType == 10 || serialType == 11:
+			return nil, errInvalidSQLite
+		}
+
+		if size < 0 || size > len(body) {
+			return nil, errInvalidSQLite
+		}
+
+		value := body[:size]
+		body = body[size:]
+
+		switch {
+		case serialType == 0:
+			row = append(row, nil)
+		case serialType == 7:
+			row = append(row, math.Float64frombits(binary.BigEndian.Uint64(value)))
+		case serialType == 8 || serialType == 9:
+			row = append(row, int64(serialType-8))
+		case serialType >= 12 && serialType%2 == 0:
+			row = append(row, append([]byte{}, value...))
+		case serialType >= 13:
+			row = append(row, string(value))
+		default:
+			row = append(row, sqliteInt(value))
+		}
+	}
+
+	return row, nil
+}
+
+// Reads a big-endian two's complement integer of up to eight bytes.
+func sqliteInt(b []byte) int64 {
+	var n int64
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		n = -1
+	}
+
+	for _, c := range b {
+		n = n<<8 | int64(c)
+	}
+
+	return n
+}
+
+// Reads SQLite's variable-length integers, which take up to nine bytes;
+// the ninth contributes all eight of its bits. Returns zero bytes read if
+// `b` ends first.
+func sqliteVarint(b []byte) (uint64, int) {
+	var n uint64
+
+	for i := 0; i < 9 && i < len(b); i++ {
+		if i == 8 {
+			return n<<8 | uint64(b[i]), 9
+		}
+
+		n = n<<7 | uint64(b[i]&0x7f)
+		if b[i]&0x80 == 0 {
+			return n, i + 1
+		}
+	}
+
+	return 0, 0
+}
+
+func sqliteText(value interface{}) string {
+	switch value := value.(type) {
+	case string:
+		return value
+	case []byte:
+		return string(value)
+	default:
+		return ""
+	}
+}
+
+// Extracts the column names, in order, from a `CREATE TABLE` statement.
+// Rows store their columns in this order, so it's all we need to read them.
+func sqliteColumns(sql string) []string {
+	start, end := strings.Index(sql, "("), strings.LastIndex(sql, ")")
+	if start < 0 || end < start {
+		return nil
+	}
+
+	var columns []string
+	var definitions []string
+
+	// Split the definitions on commas that aren't inside parentheses, as in
+	// `DECIMAL(10, 2)` or a `UNIQUE (a, b)` constraint.
+	depth, last := 0, start+1
+	for i := start + 1; i < end; i++ {
+		switch sql[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				definitions = append(definitions, sql[last:i])
+				last = i + 1
+			}
+		}
+	}
+
+	definitions = append(definitions, sql[last:end])
+
+	for _, definition := range definitions {
+		fields := strings.Fields(definition)
+		if len(fields) == 0 {
+			continue
+		}
+
+		// Table constraints come after every column.
+		switch strings.ToUpper(fields[0]) {
+		case "CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN":
+			return columns
+		}
+
+		columns = append(columns, strings.Trim(fields[0], "\"`[]"))
+	}
+
+	return columns
+}
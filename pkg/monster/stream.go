@@ -0,0 +1,65 @@
+package monster
+
+import (
+	"runtime"
+	"sync"
+)
+
+// The result of decoding one cookie from `DecodeStream()`.
+type DecodeOutcome struct {
+	// The position of the cookie in the input, since outcomes arrive in
+	// whatever order decoding finishes.
+	Index int
+	Raw   string
+
+	Cookie  *Cookie
+	Success bool
+}
+
+// Decodes each cookie received from `raws` concurrently, and sends each
+// outcome on the returned channel as soon as it is ready. The channel is
+// closed once `raws` is closed and every cookie has been decoded. Only a
+// bounded number of cookies are in flight at once, so memory use doesn't
+// grow with the input.
+func DecodeStream(raws <-chan string) <-chan DecodeOutcome {
+	type job struct {
+		index int
+		raw   string
+	}
+
+	workers := runtime.NumCPU()
+	jobs := make(chan job, workers)
+	outcomes := make(chan DecodeOutcome, workers)
+
+	// We number the cookies in the order they arrive.
+	go func() {
+		index := 0
+		for raw := range raws {
+			jobs <- job{index, raw}
+			index++
+		}
+
+		close(jobs)
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for job := range jobs {
+				c := NewCookie(job.raw)
+				outcomes <- DecodeOutcome{Index: job.index, Raw: job.raw, Cookie: c, Success: c.Decode()}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(outcomes)
+	}()
+
+	return outcomes
+}
@@ -0,0 +1,38 @@
+package monster
+
+import "testing"
+
+func TestDecodeStream(t *testing.T) {
+	raws := []string{
+		"BAhJIgl0ZXN0BjoGRVQ=--8c5ae09ed57f1e933cc466f5b99ea636d1fc31a2",
+		"not a cookie",
+		"eyJhIjoiYiJ9.YX-skA.D0By6YsWkNcDZfs59oCAwN4I1yc",
+		"gAJ9cQFVBV9uZXh0cQJYAQAAAC9zLg:1mh2IM:sxE7avlAbKalQJulWkiHNlUy_Bw",
+	}
+
+	input := make(chan string)
+	go func() {
+		for _, raw := range raws {
+			input <- raw
+		}
+
+		close(input)
+	}()
+
+	seen := make(map[int]bool)
+	for outcome := range DecodeStream(input) {
+		if seen[outcome.Index] || raws[outcome.Index] != outcome.Raw {
+			t.Errorf("unexpected outcome %+v", outcome)
+		}
+
+		seen[outcome.Index] = true
+
+		if outcome.Success != (outcome.Index != 1) {
+			t.Errorf("unexpected success %t for %s", outcome.Success, outcome.Raw)
+		}
+	}
+
+	if len(seen) != len(raws) {
+		t.Errorf("received %d outcomes instead of %d", len(seen), len(raws))
+	}
+}
@@ -0,0 +1,22 @@
+package monster
+
+import (
+	"time"
+
+	"github.com/iangcarroll/cookiemonster/pkg/timestamp"
+)
+
+var (
+	// The clock every expiry check reads, so that tests can stop it.
+	now = time.Now
+)
+
+// Formats a timestamp segment for display, with its parsed time if we can
+// understand it.
+func displayTimestamp(raw string) string {
+	if t, format, ok := timestamp.Parse(raw); ok {
+		return raw + " (" + t.Format(time.RFC3339) + ", " + format + ")"
+	}
+
+	return raw
+}
@@ -0,0 +1,45 @@
+package monster
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDisplayDjangoTimestamp(t *testing.T) {
+	c := NewCookie("gAJ9cQFVBV9uZXh0cQJYAQAAAC9zLg:1mh2IM:rAOWFyG5ROIOxriY8pwm9jFma5w")
+	c.Decode()
+
+	if !strings.Contains(c.String(), "Timestamp: 1mh2IM (2021-10-31T04:18:10Z, base62)\n") {
+		t.Errorf("django timestamp is not displayed as a time")
+	}
+}
+
+func TestDjangoSignedAt(t *testing.T) {
+	// Django's `TimestampSigner` counts from the Unix epoch.
+	c := NewCookie("eyJzZWxmdGVzdCI6dHJ1ZX0:1mhTAe:ITvvu5K3UcFMu1q-MATldqm3Egk")
+	c.Decode()
+
+	if signedAt, ok := c.SignedAt(); !ok || !signedAt.Equal(time.Date(2021, 11, 1, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("django timestamp was resolved to %v", signedAt)
+	}
+
+	// A timestamp we can't parse is shown raw without failing the decode.
+	garbled := NewCookie("eyJzZWxmdGVzdCI6dHJ1ZX0:not-base62:ITvvu5K3UcFMu1q-MATldqm3Egk")
+	if !garbled.Decode() || !garbled.hasParsedDataFor(djangoDecoder) {
+		t.Fatalf("could not decode a cookie with an unparseable timestamp")
+	}
+
+	if !strings.Contains(garbled.String(), "Timestamp: not-base62\n") {
+		t.Errorf("unparseable timestamp was not shown raw")
+	}
+
+	if _, ok := garbled.SignedAt(); ok {
+		t.Errorf("unparseable timestamp was resolved")
+	}
+
+	empty := NewCookie("eyJzZWxmdGVzdCI6dHJ1ZX0::ITvvu5K3UcFMu1q-MATldqm3Egk")
+	if !empty.Decode() || !strings.Contains(empty.String(), "Timestamp: none (empty)\n") {
+		t.Errorf("empty timestamp was not reported")
+	}
+}
@@ -0,0 +1,58 @@
+package monster
+
+import (
+	"runtime"
+	"time"
+)
+
+const (
+	// How many wordlist entries each candidate worker count is timed over.
+	autoTuneSampleSize = 2000
+)
+
+// The worker counts the auto-tuner compares. HMAC is CPU-bound, so there is
+// little point in going far beyond the number of cores.
+func autoTuneCandidates() []uint64 {
+	cpus := uint64(runtime.NumCPU())
+	return []uint64{1, cpus, cpus * 4, cpus * 16}
+}
+
+// Times `attempt` over consecutive samples of `entries` at each candidate
+// worker count, and returns the fastest count along with the entries that
+// weren't used for tuning. If the wordlist is too small to be worth tuning,
+// `fallback` is returned with every entry.
+func (c *Cookie) autoTune(entries [][]byte, fallback uint64, attempt func(entry []byte, keys *derivedKeys)) (concurrency uint64, remaining [][]byte) {
+	candidates := autoTuneCandidates()
+	if len(entries) < len(candidates)*autoTuneSampleSize*2 {
+		return fallback, entries
+	}
+
+	concurrency = pickConcurrency(candidates, func(candidate uint64) time.Duration {
+		sample := entries[:autoTuneSampleSize]
+		entries = entries[autoTuneSampleSize:]
+
+		start := time.Now()
+		c.bruteForce(sample, candidate, attempt)
+		return time.Since(start)
+	})
+
+	return concurrency, entries
+}
+
+// Returns the candidate for which `measure` reports the shortest time. Each
+// candidate is measured exactly once, in order.
+func pickConcurrency(candidates []uint64, measure func(candidate uint64) time.Duration) uint64 {
+	best := candidates[0]
+	var bestTime time.Duration
+
+	for i, candidate := range candidates {
+		elapsed := measure(candidate)
+
+		if i == 0 || elapsed < bestTime {
+			best = candidate
+			bestTime = elapsed
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,52 @@
+package monster
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPickConcurrency(t *testing.T) {
+	// Pretend throughput peaks at 8 workers and falls off after that.
+	timings := map[uint64]time.Duration{
+		1:  800 * time.Millisecond,
+		8:  100 * time.Millisecond,
+		32: 150 * time.Millisecond,
+		64: 300 * time.Millisecond,
+	}
+
+	var measured []uint64
+	best := pickConcurrency([]uint64{1, 8, 32, 64}, func(candidate uint64) time.Duration {
+		measured = append(measured, candidate)
+		return timings[candidate]
+	})
+
+	if best != 8 {
+		t.Errorf("expected 8 workers, got %d", best)
+	}
+
+	if len(measured) != 4 {
+		t.Errorf("expected every candidate to be measured once, got %v", measured)
+	}
+}
+
+func TestUnsignWithAutoTune(t *testing.T) {
+	// Enough entries to tune with, with the key at the very end.
+	var entries [][]byte
+	for i := 0; i < len(autoTuneCandidates())*autoTuneSampleSize*2; i++ {
+		entries = append(entries, []byte("wrong"))
+	}
+
+	entries = append(entries, []byte("super secret"))
+
+	wl := NewWordlist()
+	if err := wl.LoadFromArray(entries); err != nil {
+		t.Errorf("could not LoadFromArray")
+	}
+
+	c := NewCookie("BAhJIgl0ZXN0BjoGRVQ=--8c5ae09ed57f1e933cc466f5b99ea636d1fc31a2")
+	c.Decode()
+
+	if _, success := c.Unsign(wl, 100, WithAutoTune()); !success {
+		t.Errorf("could not unsign an unsignable cookie after tuning")
+	}
+}
@@ -1,15 +1,213 @@
 package monster
 
-import "sync"
+import (
+	"sync"
+	"time"
+)
 
 type Cookie struct {
+	// First, so that its counters are 64-bit aligned on 32-bit platforms.
+	progress unsignProgress
+
 	raw           string
-	decodedBy     map[string]interface{}
+	decodedBy     map[string]decoderData
 	mutex         sync.RWMutex
 	unsignedBy    string
 	unsignedKey   []byte
+	unsignedEntry []byte
 	unsignedMutex sync.RWMutex
 	wasUnwrapped  bool
+
+	// Where `Unsign()` sends its messages; see `WithLogSink()`.
+	logSink LogSink
+
+	// Set when `Unsign()` stops at `WithMaxCandidates()`.
+	limitReached bool
+
+	// Set by `WithSecretTransform()`.
+	secretTransform SecretTransform
+
+	// Stand-ins for base64 padding; see `SetPaddingChars()`.
+	paddingChars string
+
+	// Set by `SetDjangoSalt()` and `SetDjangoSaltOrder()`.
+	djangoSaltOverride string
+	djangoSaltOrder    SaltOrder
+
+	// Set by `WithDjangoSalts()` for a run of `Unsign()`.
+	djangoSalts []string
+
+	// Set by `SetRailsKeyDerivation()`.
+	railsSalt       string
+	railsIterations int
+
+	// Set by `SetDataProtectionPurposes()`.
+	dataProtectionPurposes []string
+
+	// Set by `SetRememberMePasswords()`.
+	rememberMePasswords []string
+
+	// Set by `NewDetachedCookie()` for signatures sent outside the cookie.
+	detachedSignature string
+
+	// Why decoders which recognized the cookie's structure declined it
+	// anyway; see `Diagnostics()`.
+	diagnostics []string
+}
+
+// What a decoder keeps about a cookie it decoded. Each decoder answers for
+// its own format, so callers needn't know about every one.
+type decoderData interface {
+	String() string
+
+	// The payload after decoding, e.g. a session's JSON, for the `payload`
+	// package to deserialize, or nil if the decoder can't decode it.
+	serialized(c *Cookie) []byte
+
+	// What `Render()` and `DecodedFields()` report.
+	fields() parsedFields
+
+	// A copy for the decode cache, without anything a run of `Unsign()`
+	// recorded in it.
+	clone() decoderData
+}
+
+// The components a decoder split a cookie into.
+type parsedFields struct {
+	data      string
+	timestamp string
+
+	// The signature as the cookie has it, and decoded.
+	signature        string
+	decodedSignature []byte
+
+	// The HMAC algorithm the signature uses, or empty if it has none we
+	// know of.
+	algorithm string
+
+	compressed bool
+}
+
+// Everything `Crack()` found out about a cookie.
+type CrackResult struct {
+	// The decoded cookie, for anything not summarized here.
+	Cookie *Cookie
+
+	Found   bool
+	Key     []byte
+	Decoder string
+
+	// The cookie resigned with the new data, if any was given and the key
+	// was found.
+	Resigned string
+}
+
+// How far a run of `Unsign()` has got; see `Cookie.Progress()`.
+type Progress struct {
+	// How many candidate keys have been tried so far.
+	Tried uint64
+
+	// How long the run has taken, or has been running for.
+	Elapsed time.Duration
+
+	// Set once the run has finished.
+	Done bool
+}
+
+// Returns how many candidate keys were tried per second.
+func (p Progress) Rate() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+
+	return float64(p.Tried) / p.Elapsed.Seconds()
+}
+
+// A signer configuration found by `DetectConfig()`.
+type Config struct {
+	// The HMAC algorithm, which the signature's length gives.
+	Algorithm string
+
+	// The Django salt, or empty for the default.
+	Salt string
+
+	// What the secret was derived with before Django derived its key, or
+	// nil if it was used as is.
+	KDF KDF
+}
+
+// Options for `ResignWith()`.
+type ResignOptions struct {
+	// The algorithm to sign with, e.g. "sha256"; if empty, the algorithm the
+	// original cookie was signed with is used.
+	Algorithm string
+
+	// Permits signing with a weaker algorithm than the original cookie used.
+	AllowDowngrade bool
+
+	// Whether to compress the new data, for formats which support it; by
+	// default, we compress only if the original cookie was compressed.
+	Compression Compression
+
+	// Treats the new data as edited JSON and re-serializes it the way the
+	// framework's serializer would, so the server parses it as its own.
+	Reserialize bool
+
+	// The secret to sign with, e.g. an app's new key after a rotation; by
+	// default, we use the one `Unsign()` discovered.
+	Key []byte
+
+	// When to say the new data was signed, for formats which timestamp it
+	// (Django and Flask), e.g. `time.Now()` so a server enforcing a max age
+	// accepts it; the zero time keeps the original cookie's timestamp. A
+	// Rails session's expiry is moved to the original's lifetime after it.
+	Timestamp time.Time
+}
+
+// How resigning treats compression; see `ResignOptions`.
+type Compression int
+
+const (
+	CompressionOriginal Compression = iota
+	CompressionAlways
+	CompressionNever
+)
+
+// Returns whether to compress, given whether the original cookie was.
+func (c Compression) enabled(original bool) bool {
+	switch c {
+	case CompressionAlways:
+		return true
+	case CompressionNever:
+		return false
+	default:
+		return original
+	}
+}
+
+// Where a salted key derivation puts the salt relative to the secret.
+type SaltOrder int
+
+const (
+	// `salt + secret`, as Django does.
+	SaltPrefix SaltOrder = iota
+
+	// `secret + salt`, as some custom signers do.
+	SaltSuffix
+)
+
+// The components of a resigned cookie; see `PreviewResign()`.
+type ResignPreview struct {
+	Decoder   string
+	Algorithm string
+
+	// The new data, before and after encoding it for the cookie.
+	Data        string
+	EncodedData string
+
+	Timestamp  string
+	ToBeSigned string
+	Signature  string
 }
 
 type Wordlist struct {
@@ -18,3 +216,37 @@ type Wordlist struct {
 	entries [][]byte
 	mutex   sync.RWMutex
 }
+
+// Tracks which decoders `Unsign()` should try keys against.
+type unsignPlan struct {
+	// The built-in decoders which decoded the cookie and which the options
+	// allow.
+	builtins []*builtin
+
+	// The registered decoders which recognized the cookie.
+	custom []registeredDecoder
+
+	// Also verify detached JSON values in their canonical form.
+	canonicalJSON bool
+
+	// Derives the key for detached signatures, if set.
+	kdf KDF
+
+	// Also verify three-segment cookies as `keyid.data.signature`.
+	keyID bool
+
+	// Also verify detached values prefixed with this cookie name.
+	cookieName string
+
+	// The salts to derive Django's key with, worked out once for the run;
+	// see `djangoSaltCandidates()`.
+	djangoSalts []string
+
+	// The digests `WithAlgorithms()` allows Rails to derive its key with;
+	// see `railsAllowedDigests()`.
+	railsDigests map[string]bool
+}
+
+func (p unsignPlan) any() bool {
+	return len(p.builtins) > 0 || len(p.custom) > 0
+}
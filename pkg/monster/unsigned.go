@@ -0,0 +1,134 @@
+package monster
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type unsignedParsedData struct {
+	data        string
+	decodedData []byte
+	compression string
+
+	parsed bool
+}
+
+func (d *unsignedParsedData) String() string {
+	if !d.parsed {
+		return "Unparsed data"
+	}
+
+	return fmt.Sprintf("Signed: false (anyone can forge this cookie; there is no secret)\nCompression: %s\nData: %s\nDecoded data: %s\n", d.compression, d.data, displayBytes(d.decodedData))
+}
+
+func (d *unsignedParsedData) serialized(c *Cookie) []byte {
+	return d.decodedData
+}
+
+func (d *unsignedParsedData) fields() parsedFields {
+	return parsedFields{data: d.data, compressed: true}
+}
+
+func (d *unsignedParsedData) clone() decoderData {
+	copied := *d
+	return &copied
+}
+
+const (
+	unsignedDecoder   = "unsigned"
+	unsignedMinLength = 16
+
+	unsignedCompressionGzip = "gzip"
+	unsignedCompressionZlib = "zlib"
+)
+
+var (
+	// Characters which separate a signature from the data in the formats we
+	// know; unsigned cookies can't contain them.
+	unsignedRejectedSeparators = []string{".", ":", "^", "--"}
+)
+
+// Recognizes cookies which are only base64url-encoded, compressed JSON, as
+// some apps use for caching. These aren't signed at all, so this decoder is
+// for inspection only and never takes part in `Unsign()`.
+func unsignedDecode(c *Cookie) bool {
+	if len(c.raw) < unsignedMinLength {
+		return false
+	}
+
+	// Anything another decoder understood is signed, so we leave it alone.
+	if len(c.decodedBy) > 0 {
+		return false
+	}
+
+	for _, separator := range unsignedRejectedSeparators {
+		if strings.Contains(c.raw, separator) {
+			return false
+		}
+	}
+
+	raw := strings.TrimRight(c.raw, "=")
+
+	compressed, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		if compressed, err = base64.RawStdEncoding.DecodeString(raw); err != nil {
+			return false
+		}
+	}
+
+	decompressed, compression, ok := unsignedDecompress(compressed)
+	if !ok || !json.Valid(decompressed) {
+		return false
+	}
+
+	c.wasDecodedBy(unsignedDecoder, &unsignedParsedData{
+		data:        c.raw,
+		decodedData: decompressed,
+		compression: compression,
+		parsed:      true,
+	})
+
+	return true
+}
+
+// Decompresses gzip or zlib data, recognizing either from its header.
+func unsignedDecompress(data []byte) ([]byte, string, bool) {
+	var reader io.ReadCloser
+	var compression string
+	var err error
+
+	switch {
+	case len(data) > 2 && data[0] == 0x1f && data[1] == 0x8b:
+		reader, err = gzip.NewReader(bytes.NewReader(data))
+		compression = unsignedCompressionGzip
+	case len(data) > 2 && data[0] == 0x78:
+		reader, err = zlib.NewReader(bytes.NewReader(data))
+		compression = unsignedCompressionZlib
+	default:
+		return nil, "", false
+	}
+
+	if err != nil {
+		return nil, "", false
+	}
+	defer reader.Close()
+
+	decompressed, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, "", false
+	}
+
+	return decompressed, compression, true
+}
+
+// Reports whether the cookie is compressed JSON without any signature, so
+// it can be modified without a secret.
+func (c *Cookie) HasNoSignature() bool {
+	return c.hasParsedDataFor(unsignedDecoder)
+}
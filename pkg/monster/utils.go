@@ -1,19 +1,138 @@
 package monster
 
-type limiter struct {
-	ch chan struct{}
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Counts the candidate keys a run of `Unsign()` has tried, and times it.
+// Every field is accessed atomically, since workers update it while callers
+// may be reading it from elsewhere; times are in Unix nanoseconds.
+type unsignProgress struct {
+	attempts uint64
+	started  int64
+	finished int64
+}
+
+func (p *unsignProgress) start() {
+	atomic.StoreUint64(&p.attempts, 0)
+	atomic.StoreInt64(&p.finished, 0)
+	atomic.StoreInt64(&p.started, time.Now().UnixNano())
+}
+
+func (p *unsignProgress) tried() {
+	atomic.AddUint64(&p.attempts, 1)
 }
 
-// Creates a new `limiter` which uses Go channels to throttle how many
-// goroutines execute at a time.
-func newLimiter(concurrencyLimit uint64) *limiter {
-	return &limiter{make(chan struct{}, concurrencyLimit)}
+func (p *unsignProgress) finish() {
+	atomic.StoreInt64(&p.finished, time.Now().UnixNano())
 }
 
-func (l *limiter) Add() {
-	l.ch <- struct{}{}
+func (p *unsignProgress) snapshot() Progress {
+	progress := Progress{Tried: atomic.LoadUint64(&p.attempts)}
+
+	started := atomic.LoadInt64(&p.started)
+	if started == 0 {
+		return progress
+	}
+
+	end := atomic.LoadInt64(&p.finished)
+	progress.Done = end != 0
+	if !progress.Done {
+		end = time.Now().UnixNano()
+	}
+
+	progress.Elapsed = time.Duration(end - started)
+	return progress
 }
 
-func (l *limiter) Done() {
-	<-l.ch
+// Counts the candidate keys `Unsign()` tries against an optional cap. A
+// `max` of zero means there is no cap.
+type candidateBudget struct {
+	max   uint64
+	taken uint64
+}
+
+// Takes one candidate, reporting whether it is within the cap.
+func (b *candidateBudget) take() bool {
+	if b.max == 0 {
+		return true
+	}
+
+	return atomic.AddUint64(&b.taken, 1) <= b.max
+}
+
+// Takes as many of `entries` as the cap allows, and returns them.
+func (b *candidateBudget) limit(entries [][]byte) [][]byte {
+	if b.max == 0 {
+		return entries
+	}
+
+	taken := atomic.LoadUint64(&b.taken)
+	if taken >= b.max {
+		atomic.StoreUint64(&b.taken, b.max+1)
+		return nil
+	}
+
+	if remaining := b.max - taken; uint64(len(entries)) > remaining {
+		// We note that we left candidates untried.
+		atomic.StoreUint64(&b.taken, b.max+1)
+		return entries[:remaining]
+	}
+
+	atomic.AddUint64(&b.taken, uint64(len(entries)))
+	return entries
+}
+
+// Reports whether any candidate was turned away by the cap.
+func (b *candidateBudget) exhausted() bool {
+	return b.max > 0 && atomic.LoadUint64(&b.taken) > b.max
+}
+
+// Returns the Shannon entropy of `b`, in bits per byte.
+func shannonEntropy(b []byte) float64 {
+	var counts [256]int
+	for _, c := range b {
+		counts[c]++
+	}
+
+	var entropy float64
+	for _, count := range counts {
+		if count > 0 {
+			p := float64(count) / float64(len(b))
+			entropy -= p * math.Log2(p)
+		}
+	}
+
+	return entropy
+}
+
+// Returns the highest entropy `n` bytes can have, which is when every byte
+// is distinct; short samples can't reach the full 8 bits per byte.
+func maxShannonEntropy(n int) float64 {
+	if n > 256 {
+		n = 256
+	}
+
+	return math.Log2(float64(n))
+}
+
+// Formats decoded bytes for display, quoting them if they are not printable
+// text (e.g. a pickled Django session) so we don't garble the terminal.
+func displayBytes(b []byte) string {
+	if !utf8.Valid(b) {
+		return fmt.Sprintf("%q", b)
+	}
+
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return fmt.Sprintf("%q", b)
+		}
+	}
+
+	return string(b)
 }
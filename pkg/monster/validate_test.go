@@ -0,0 +1,22 @@
+package monster
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	for _, decoder := range signingDecoders {
+		if err := Validate(decoder); err != nil {
+			t.Errorf("%v", err)
+		}
+	}
+
+	if err := Validate("nonexistent"); !errors.Is(err, ErrUnknownDecoder) {
+		t.Errorf("unexpected error for an unknown decoder: %v", err)
+	}
+
+	if err := ValidateAll(); err != nil {
+		t.Errorf("%v", err)
+	}
+}
@@ -2,45 +2,124 @@ package monster
 
 import (
 	"bufio"
+	"compress/gzip"
 	"encoding/base64"
+	"io"
 	"os"
 	"strings"
 )
 
+const (
+	// The path `OpenWordlist()` reads standard input for.
+	stdinWordlist = "-"
+
+	// Wordlists are mostly short secrets, but some entries are whole keys,
+	// so we allow lines far longer than `bufio.Scanner`'s default.
+	maxWordlistLine = 1 << 20
+)
+
 func NewWordlist() *Wordlist {
 	return &Wordlist{entries: [][]byte{}}
 }
 
-// Load wordlist entries from the provided `path`.
-func (w *Wordlist) Load(path string) error {
-	w.mutex.Lock()
-	defer w.mutex.Unlock()
+// Opens the wordlist at `path` for reading; `-` is standard input, and a
+// path ending in `.gz` is decompressed as it's read.
+func OpenWordlist(path string) (io.ReadCloser, error) {
+	if path == stdinWordlist {
+		return io.NopCloser(os.Stdin), nil
+	}
 
 	file, err := os.Open(path)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	defer file.Close()
-	scanner := bufio.NewScanner(file)
+	if !strings.HasSuffix(path, ".gz") {
+		return file, nil
+	}
 
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		entry, err := base64.StdEncoding.DecodeString(line)
+	decompressed, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return gzipWordlist{decompressed, file}, nil
+}
+
+// Closes both the gzip stream and the file underneath it.
+type gzipWordlist struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (g gzipWordlist) Close() error {
+	g.Reader.Close()
+	return g.file.Close()
+}
+
+// Reads base64-encoded wordlist entries one line at a time. Blank lines
+// are skipped, and surrounding whitespace (including the `\r` of Windows
+// line endings) is trimmed.
+type wordlistScanner struct {
+	scanner *bufio.Scanner
+	err     error
+}
 
+func newWordlistScanner(r io.Reader) *wordlistScanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxWordlistLine)
+
+	return &wordlistScanner{scanner: scanner}
+}
+
+// Returns the next entry, or false once the input ends or a line isn't
+// valid base64, in which case `err` says why.
+func (s *wordlistScanner) next() ([]byte, bool) {
+	for s.err == nil && s.scanner.Scan() {
+		entry, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s.scanner.Text()))
 		if err != nil {
-			return err
+			s.err = err
+			return nil, false
 		}
 
 		if len(entry) > 0 {
-			w.entries = append(w.entries, entry)
+			return entry, true
 		}
 	}
 
-	if err := scanner.Err(); err != nil {
+	if s.err == nil {
+		s.err = s.scanner.Err()
+	}
+
+	return nil, false
+}
+
+// Load wordlist entries from the provided `path`; see `OpenWordlist()`.
+func (w *Wordlist) Load(path string) error {
+	file, err := OpenWordlist(path)
+	if err != nil {
 		return err
 	}
 
+	defer file.Close()
+	return w.LoadFromReader(file)
+}
+
+// Load wordlist entries from `r`, one base64-encoded entry per line.
+func (w *Wordlist) LoadFromReader(r io.Reader) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	scanner := newWordlistScanner(r)
+	for entry, ok := scanner.next(); ok; entry, ok = scanner.next() {
+		w.entries = append(w.entries, entry)
+	}
+
+	if scanner.err != nil {
+		return scanner.err
+	}
+
 	w.loaded = true
 	return nil
 }
@@ -89,13 +168,13 @@ func (w *Wordlist) Count() uint64 {
 	return uint64(len(w.entries))
 }
 
-// Load wordlist entries from the provided array of byte arrays.
+// Returns the wordlist's entries, or nil if none have been loaded.
 func (w *Wordlist) Entries() [][]byte {
 	w.mutex.RLock()
 	defer w.mutex.RUnlock()
 
 	if !w.loaded {
-		panic("cannot get entries from unloaded wordlist")
+		return nil
 	}
 
 	return w.entries
@@ -1,7 +1,13 @@
 package monster
 
 import (
+	"bytes"
+	"compress/gzip"
 	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -25,3 +31,69 @@ func TestNewWordlist(t *testing.T) {
 		t.Error("new entries did not get loaded", len(wl.entries))
 	}
 }
+
+func TestLoadGzipWordlist(t *testing.T) {
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+
+	// Windows line endings and blank lines, as hand-edited lists have.
+	writer.Write([]byte("YWJj\r\n\r\nZ2hp\r\n"))
+	writer.Close()
+
+	path := filepath.Join(t.TempDir(), "wordlist.txt.gz")
+	if err := os.WriteFile(path, compressed.Bytes(), 0600); err != nil {
+		t.Fatalf("could not write wordlist: %v", err)
+	}
+
+	wl := NewWordlist()
+	if err := wl.Load(path); err != nil {
+		t.Fatalf("could not load gzip wordlist: %v", err)
+	}
+
+	if entries := wl.Entries(); len(entries) != 2 || string(entries[0]) != "abc" || string(entries[1]) != "ghi" {
+		t.Errorf("unexpected entries: %q", entries)
+	}
+}
+
+func TestLoadLongWordlistLine(t *testing.T) {
+	long := bytes.Repeat([]byte("k"), 128*1024)
+
+	wl := NewWordlist()
+	if err := wl.LoadFromReader(strings.NewReader(base64.StdEncoding.EncodeToString(long) + "\n")); err != nil {
+		t.Fatalf("could not load a long entry: %v", err)
+	}
+
+	if entries := wl.Entries(); len(entries) != 1 || !bytes.Equal(entries[0], long) {
+		t.Errorf("long entry was not loaded")
+	}
+}
+
+func TestUnsignReader(t *testing.T) {
+	var lines strings.Builder
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintln(&lines, base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("wrong-%d", i))))
+	}
+
+	fmt.Fprintln(&lines, base64.StdEncoding.EncodeToString([]byte("changeme")))
+
+	c := NewCookie("eyJzZWxmdGVzdCI6dHJ1ZX0:1mhTAe:ITvvu5K3UcFMu1q-MATldqm3Egk")
+	c.Decode()
+
+	if key, success, err := c.UnsignReader(strings.NewReader(lines.String()), 4); err != nil || !success || string(key) != "changeme" {
+		t.Errorf("could not unsign from a stream: %q %t %v", key, success, err)
+	}
+
+	capped := NewCookie("eyJzZWxmdGVzdCI6dHJ1ZX0:1mhTAe:ITvvu5K3UcFMu1q-MATldqm3Egk")
+	capped.Decode()
+
+	if _, success, _ := capped.UnsignReader(strings.NewReader(lines.String()), 4, WithMaxCandidates(10)); success || !capped.LimitReached() {
+		t.Errorf("streamed unsign did not stop at the candidate cap")
+	}
+
+	invalid := NewCookie("eyJzZWxmdGVzdCI6dHJ1ZX0:1mhTAe:ITvvu5K3UcFMu1q-MATldqm3Egk")
+	invalid.Decode()
+
+	if _, _, err := invalid.UnsignReader(strings.NewReader("not base64!\n"), 1); err == nil {
+		t.Errorf("invalid wordlist line was not reported")
+	}
+}
@@ -0,0 +1,151 @@
+package monster
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+type yiiParsedData struct {
+	data             string
+	signature        string
+	decodedSignature []byte
+	algorithm        string
+
+	// Whether the cookie was URL-encoded, as PHP's `setcookie()` sends it.
+	escaped bool
+
+	parsed bool
+}
+
+func (d *yiiParsedData) String() string {
+	if !d.parsed {
+		return "Unparsed data"
+	}
+
+	return fmt.Sprintf("Signature: %s\nData: %s\nAlgorithm: %s\n", d.signature, d.data, d.algorithm)
+}
+
+func (d *yiiParsedData) serialized(c *Cookie) []byte {
+	return []byte(d.data)
+}
+
+func (d *yiiParsedData) fields() parsedFields {
+	return parsedFields{data: d.data, signature: d.signature, decodedSignature: d.decodedSignature, algorithm: d.algorithm}
+}
+
+func (d *yiiParsedData) clone() decoderData {
+	copied := *d
+	return &copied
+}
+
+// Yii validates cookies with `Security::hashData()`, which prepends the hex
+// HMAC of the serialized value, with `cookieValidationKey` (Yii 1.1's
+// `validationKey`) as the key. There's no separator, so the signature is
+// told apart from the data by where a PHP value could start.
+const (
+	yiiDecoder   = "yii"
+	yiiMinLength = 44
+)
+
+var (
+	// Yii 2 signs with SHA256 by default and Yii 1.1 with SHA1, but both let
+	// apps configure another `hash_hmac()` algorithm; longer digests go
+	// first, since every shorter prefix of them is hex too.
+	yiiAlgorithms = []string{"sha512", "sha384", "sha256", "sha1"}
+)
+
+func yiiDecode(c *Cookie) bool {
+	if len(c.raw) < yiiMinLength {
+		return false
+	}
+
+	rawData := c.raw
+	var parsedData yiiParsedData
+
+	if strings.Contains(rawData, "%") {
+		unescaped, err := url.QueryUnescape(rawData)
+		if err != nil {
+			return false
+		}
+
+		rawData = unescaped
+		parsedData.escaped = true
+	}
+
+	for _, algorithm := range yiiAlgorithms {
+		length := hex.EncodedLen(algorithmDigestLength[algorithm])
+		if len(rawData) <= length || !phpSerialized(rawData[length:]) {
+			continue
+		}
+
+		// PHP's `hash_hmac()` writes lowercase hex.
+		signature := rawData[:length]
+		if strings.ToLower(signature) != signature {
+			continue
+		}
+
+		decodedSignature, err := hex.DecodeString(signature)
+		if err != nil {
+			continue
+		}
+
+		parsedData.signature = signature
+		parsedData.decodedSignature = decodedSignature
+		parsedData.data = rawData[length:]
+		parsedData.algorithm = algorithm
+		parsedData.parsed = true
+		c.wasDecodedBy(yiiDecoder, &parsedData)
+
+		return true
+	}
+
+	return false
+}
+
+func yiiUnsign(c *Cookie, secret []byte, keys *derivedKeys) bool {
+	parsedData := c.parsedDataFor(yiiDecoder).(*yiiParsedData)
+	return keys.verify(secretKeyed(parsedData.algorithm), secret, []byte(parsedData.data), parsedData.decodedSignature)
+}
+
+// Resigns the cookie with new serialized PHP `data`, which for Yii 2 is
+// the cookie's name and value as `a:2:{i:0;s:4:"name";i:1;...}`. The URL
+// encoding of the original cookie is kept.
+func yiiResign(c *Cookie, data string, secret []byte, algorithm string) (string, error) {
+	parsedData := c.parsedDataFor(yiiDecoder).(*yiiParsedData)
+
+	hashAlgorithm, ok := hashAlgorithms[algorithm]
+	if !ok {
+		return "", ErrUnknownAlgorithm
+	}
+
+	resigned := hex.EncodeToString(hashAlgorithm.hmac(secret, []byte(data))) + data
+	if parsedData.escaped {
+		resigned = url.QueryEscape(resigned)
+	}
+
+	return resigned, nil
+}
+
+// Reports whether `data` looks like the output of PHP's `serialize()`: a
+// typed value such as `a:2:{...}`, `s:5:"admin";` or `i:1;`. It checks the
+// shape rather than parsing it, which the `payload` package does.
+func phpSerialized(data string) bool {
+	if data == "N;" {
+		return true
+	}
+
+	if len(data) < 4 || data[1] != ':' {
+		return false
+	}
+
+	switch data[0] {
+	case 'a', 'O', 'C':
+		return strings.HasSuffix(data, "}")
+	case 's', 'i', 'd', 'b':
+		return strings.HasSuffix(data, ";")
+	default:
+		return false
+	}
+}
@@ -0,0 +1,59 @@
+package monster
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestDecodeYii(t *testing.T) {
+	for _, test := range []struct {
+		raw       string
+		algorithm string
+		data      string
+	}{
+		{selfTestFixtures[yiiDecoder][0].cookie, "sha256", `a:2:{i:0;s:9:"_identity";i:1;s:17:"[1,"abc",2592000]";}`},
+		{selfTestFixtures[yiiDecoder][1].cookie, "sha1", `s:5:"admin";`},
+	} {
+		c := NewCookie(test.raw)
+		if !c.Decode() || !c.hasParsedDataFor(yiiDecoder) {
+			t.Fatalf("could not decode %s", test.raw)
+		}
+
+		if algorithm := c.signatureAlgorithm(yiiDecoder); algorithm != test.algorithm {
+			t.Errorf("expected %s, got %s", test.algorithm, algorithm)
+		}
+
+		if payload, ok := c.Payload(yiiDecoder); !ok || string(payload) != test.data {
+			t.Errorf("expected the data %s, got %s", test.data, payload)
+		}
+	}
+
+	// Hex that isn't followed by a PHP value isn't Yii's.
+	if c := NewCookie("50a34f78a882deb28ec9be569059832ff0299936admin"); c.Decode() && c.hasParsedDataFor(yiiDecoder) {
+		t.Errorf("decoded a value with no serialized data as Yii's")
+	}
+}
+
+func TestResignYii(t *testing.T) {
+	c := NewCookie(selfTestFixtures[yiiDecoder][0].cookie)
+	if !c.Decode() || !c.UnsignWithSecret([]byte("changeme")) {
+		t.Fatalf("could not unsign the Yii cookie")
+	}
+
+	data := `a:2:{i:0;s:9:"_identity";i:1;s:17:"[2,"abc",2592000]";}`
+
+	resigned, err := c.Resign(data)
+	if err != nil {
+		t.Fatalf("could not resign the Yii cookie: %v", err)
+	}
+
+	// The original was URL-encoded, so the forgery must be too.
+	if unescaped, err := url.QueryUnescape(resigned); err != nil || unescaped[64:] != data {
+		t.Errorf("unexpected resigned cookie %s", resigned)
+	}
+
+	forged := NewCookie(resigned)
+	if !forged.Decode() || !forged.UnsignWithSecret([]byte("changeme")) {
+		t.Errorf("the resigned cookie does not verify")
+	}
+}
@@ -0,0 +1,407 @@
+package payload
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var (
+	errInvalidMarshal = errors.New("invalid Ruby Marshal data")
+)
+
+const (
+	// Every dump Ruby has produced since 1.8 starts with version 4.8.
+	marshalMajor = 4
+	marshalMinor = 8
+
+	// The key we show the class of a Ruby object under.
+	marshalClassKey = "__class__"
+)
+
+type marshalReader struct {
+	data   []byte
+	offset int
+
+	// Symbols and objects seen so far, which later ones may refer back to.
+	symbols []string
+	objects []interface{}
+}
+
+// Reads a Ruby `Marshal.dump`, as older Rails apps and Rack serialize
+// sessions, without instantiating anything: objects become their instance
+// variables along with their class name.
+func unmarshalRuby(data []byte) (interface{}, error) {
+	r := &marshalReader{data: data, offset: 2}
+
+	value, err := r.value(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.offset != len(data) {
+		return nil, errInvalidMarshal
+	}
+
+	return value, nil
+}
+
+func (r *marshalReader) read(n int) ([]byte, error) {
+	if n < 0 || len(r.data)-r.offset < n {
+		return nil, errInvalidMarshal
+	}
+
+	b := r.data[r.offset : r.offset+n]
+	r.offset += n
+
+	return b, nil
+}
+
+func (r *marshalReader) byte() (byte, error) {
+	b, err := r.read(1)
+	if err != nil {
+		return 0, err
+	}
+
+	return b[0], nil
+}
+
+// Reads Marshal's variable-length integer.
+func (r *marshalReader) int() (int, error) {
+	c, err := r.byte()
+	if err != nil {
+		return 0, err
+	}
+
+	n := int(int8(c))
+
+	switch {
+	case n == 0:
+		return 0, nil
+	case n > 4:
+		return n - 5, nil
+	case n < -4:
+		return n + 5, nil
+	}
+
+	size := n
+	if size < 0 {
+		size = -size
+	}
+
+	b, err := r.read(size)
+	if err != nil {
+		return 0, err
+	}
+
+	value := 0
+	for i := size - 1; i >= 0; i-- {
+		value = value<<8 | int(b[i])
+	}
+
+	if n < 0 {
+		value -= 1 << (8 * uint(size))
+	}
+
+	return value, nil
+}
+
+// Reads a length, then that many bytes.
+func (r *marshalReader) bytes() ([]byte, error) {
+	n, err := r.int()
+	if err != nil {
+		return nil, err
+	}
+
+	return r.read(n)
+}
+
+// Reads a symbol or a link to an earlier one.
+func (r *marshalReader) symbol() (string, error) {
+	tag, err := r.byte()
+	if err != nil {
+		return "", err
+	}
+
+	switch tag {
+	case ':':
+		b, err := r.bytes()
+		if err != nil {
+			return "", err
+		}
+
+		r.symbols = append(r.symbols, string(b))
+		return string(b), nil
+	case ';':
+		index, err := r.int()
+		if err != nil {
+			return "", err
+		}
+
+		if index < 0 || index >= len(r.symbols) {
+			return "", errInvalidMarshal
+		}
+
+		return r.symbols[index], nil
+	default:
+		return "", errInvalidMarshal
+	}
+}
+
+// Keeps an object so later links can refer to it, returning its index.
+func (r *marshalReader) remember(value interface{}) int {
+	r.objects = append(r.objects, value)
+	return len(r.objects) - 1
+}
+
+func (r *marshalReader) value(depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, errInvalidMarshal
+	}
+
+	tag, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tag {
+	case '0':
+		return nil, nil
+	case 'T':
+		return true, nil
+	case 'F':
+		return false, nil
+	case 'i':
+		n, err := r.int()
+		return int64(n), err
+	case ':', ';':
+		r.offset--
+		return r.symbol()
+	case '@':
+		index, err := r.int()
+		if err != nil {
+			return nil, err
+		}
+
+		if index < 0 || index >= len(r.objects) {
+			return nil, errInvalidMarshal
+		}
+
+		return r.objects[index], nil
+	case '"':
+		b, err := r.bytes()
+		if err != nil {
+			return nil, err
+		}
+
+		r.remember(string(b))
+		return string(b), nil
+	case 'f':
+		b, err := r.bytes()
+		if err != nil {
+			return nil, err
+		}
+
+		r.remember(string(b))
+
+		// Ruby writes `inf`, `-inf` and `nan` as words, which JSON can't show.
+		if f, err := strconv.ParseFloat(string(b), 64); err == nil && f-f == 0 {
+			return f, nil
+		}
+
+		return string(b), nil
+	case 'l':
+		sign, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+
+		// The length is in 16-bit words, little-endian.
+		words, err := r.int()
+		if err != nil || words < 0 {
+			return nil, errInvalidMarshal
+		}
+
+		b, err := r.read(words * 2)
+		if err != nil {
+			return nil, err
+		}
+
+		digits := make([]byte, 0, len(b)*2+1)
+		if sign == '-' {
+			digits = append(digits, '-')
+		}
+
+		for i := len(b) - 1; i >= 0; i-- {
+			digits = append(digits, "0123456789abcdef"[b[i]>>4], "0123456789abcdef"[b[i]&0xf])
+		}
+
+		value := "0x" + string(digits)
+		r.remember(value)
+
+		return value, nil
+	case 'I':
+		// A value with instance variables, usually a string with its
+		// encoding, which we drop.
+		value, err := r.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := r.ivars(depth, nil); err != nil {
+			return nil, err
+		}
+
+		return value, nil
+	case '[':
+		n, err := r.int()
+		if err != nil || n < 0 || n > len(r.data) {
+			return nil, errInvalidMarshal
+		}
+
+		array := make([]interface{}, 0, n)
+		index := r.remember(array)
+
+		for i := 0; i < n; i++ {
+			element, err := r.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+
+			array = append(array, element)
+		}
+
+		r.objects[index] = array
+		return array, nil
+	case '{', '}':
+		n, err := r.int()
+		if err != nil || n < 0 || n > len(r.data) {
+			return nil, errInvalidMarshal
+		}
+
+		hash := make(map[string]interface{}, n)
+		r.remember(hash)
+
+		for i := 0; i < n; i++ {
+			key, err := r.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+
+			value, err := r.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+
+			hash[fmt.Sprint(key)] = value
+		}
+
+		// A hash with a default value has it last.
+		if tag == '}' {
+			if _, err := r.value(depth + 1); err != nil {
+				return nil, err
+			}
+		}
+
+		return hash, nil
+	case 'o', 'S':
+		class, err := r.symbol()
+		if err != nil {
+			return nil, err
+		}
+
+		object := map[string]interface{}{marshalClassKey: class}
+		r.remember(object)
+
+		if _, err := r.ivars(depth, object); err != nil {
+			return nil, err
+		}
+
+		return object, nil
+	case 'u', 'U', 'C', 'e':
+		// User-serialized objects, subclasses and extended objects: the
+		// class, then either its raw dump or the wrapped value.
+		class, err := r.symbol()
+		if err != nil {
+			return nil, err
+		}
+
+		// Only user-serialized objects can be linked to; the others' wrapped
+		// value is what's remembered.
+		object := map[string]interface{}{marshalClassKey: class}
+		if tag == 'u' || tag == 'U' {
+			r.remember(object)
+		}
+
+		if tag == 'u' {
+			b, err := r.bytes()
+			if err != nil {
+				return nil, err
+			}
+
+			object["data"] = string(b)
+			return object, nil
+		}
+
+		value, err := r.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		object["data"] = value
+		return object, nil
+	case 'c', 'm', 'M':
+		b, err := r.bytes()
+		if err != nil {
+			return nil, err
+		}
+
+		r.remember(string(b))
+		return map[string]interface{}{marshalClassKey: string(b)}, nil
+	case '/':
+		b, err := r.bytes()
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := r.byte(); err != nil {
+			return nil, err
+		}
+
+		r.remember(string(b))
+		return string(b), nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported type %q", errInvalidMarshal, tag)
+	}
+}
+
+// Reads a count of instance variables and their values, adding them to
+// `object` without their `@` if it isn't nil.
+func (r *marshalReader) ivars(depth int, object map[string]interface{}) (int, error) {
+	n, err := r.int()
+	if err != nil || n < 0 || n > len(r.data) {
+		return 0, errInvalidMarshal
+	}
+
+	for i := 0; i < n; i++ {
+		name, err := r.symbol()
+		if err != nil {
+			return 0, err
+		}
+
+		value, err := r.value(depth + 1)
+		if err != nil {
+			return 0, err
+		}
+
+		if object != nil {
+			if len(name) > 1 && name[0] == '@' {
+				name = name[1:]
+			}
+
+			object[name] = value
+		}
+	}
+
+	return n, nil
+}
@@ -0,0 +1,260 @@
+// Package payload deserializes the data inside session cookies (JSON,
+// Python pickles, Ruby Marshal dumps and serialized PHP) without running any
+// of it, and points out the fields worth tampering with.
+package payload
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/iangcarroll/cookiemonster/pkg/timestamp"
+)
+
+var (
+	ErrUnknownFormat = errors.New("the payload is not JSON, a pickle, a Ruby Marshal dump or serialized PHP")
+)
+
+const (
+	FormatJSON    = "json"
+	FormatPickle  = "pickle"
+	FormatMarshal = "marshal"
+	FormatPHP     = "php"
+)
+
+// We refuse to nest deeper than this, so garbage can't blow the stack.
+const maxDepth = 64
+
+// A deserialized payload. Maps become `map[string]interface{}` (other keys
+// are formatted with `fmt`), so `Value` can always be shown as JSON.
+type Payload struct {
+	Format string      `json:"format"`
+	Value  interface{} `json:"value"`
+
+	// Fields worth a closer look, in the order they appear.
+	Flags []Flag `json:"flags,omitempty"`
+}
+
+// A field of a payload that's likely to matter for forging it.
+type Flag struct {
+	// The dotted path to the field, e.g. `user.id`.
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+
+	// One of the `Reason` constants.
+	Reason string `json:"reason"`
+
+	// When the field is a timestamp, the time it holds.
+	Time *time.Time `json:"time,omitempty"`
+}
+
+const (
+	// The field names who the session belongs to.
+	ReasonIdentity = "identity"
+
+	// The field grants roles or privileges.
+	ReasonPrivilege = "privilege"
+
+	// The field says when the session expires or was issued.
+	ReasonExpiry = "expiry"
+
+	// A pickle calls or builds a Python object, and would run code when a
+	// server loads it.
+	ReasonCallable = "callable"
+
+	// A Marshal dump holds a Ruby object, or serialized PHP holds a PHP
+	// object, which may be used for a gadget chain when a server loads it.
+	ReasonObject = "object"
+)
+
+// Field names, lowercased and without `_` or `-`, and why they matter.
+var reasonsByName = map[string]string{
+	"userid":      ReasonIdentity,
+	"uid":         ReasonIdentity,
+	"user":        ReasonIdentity,
+	"username":    ReasonIdentity,
+	"email":       ReasonIdentity,
+	"sub":         ReasonIdentity,
+	"authuserid":  ReasonIdentity,
+	"accountid":   ReasonIdentity,
+	"admin":       ReasonPrivilege,
+	"isadmin":     ReasonPrivilege,
+	"isstaff":     ReasonPrivilege,
+	"issuperuser": ReasonPrivilege,
+	"superuser":   ReasonPrivilege,
+	"role":        ReasonPrivilege,
+	"roles":       ReasonPrivilege,
+	"scope":       ReasonPrivilege,
+	"scopes":      ReasonPrivilege,
+	"permissions": ReasonPrivilege,
+	"groups":      ReasonPrivilege,
+	"exp":         ReasonExpiry,
+	"iat":         ReasonExpiry,
+	"nbf":         ReasonExpiry,
+	"expires":     ReasonExpiry,
+	"expiry":      ReasonExpiry,
+	"expiresat":   ReasonExpiry,
+	"expiresin":   ReasonExpiry,
+}
+
+// Deserializes `data`, working out its format from how it starts, and flags
+// its interesting fields. A Rails message envelope is unwrapped, so the
+// session inside it is what's returned.
+func Decode(data []byte) (Payload, error) {
+	return decode(data, 0)
+}
+
+func decode(data []byte, depth int) (Payload, error) {
+	var payload Payload
+	var err error
+
+	switch {
+	case len(data) > 1 && data[0] == pickleProto:
+		payload.Format = FormatPickle
+		payload.Value, err = unpickle(data)
+	case len(data) > 2 && data[0] == marshalMajor && data[1] == marshalMinor:
+		payload.Format = FormatMarshal
+		payload.Value, err = unmarshalRuby(data)
+	case isPHP(data):
+		payload.Format = FormatPHP
+		payload.Value, err = unserializePHP(data)
+	case json.Valid(data):
+		payload.Format = FormatJSON
+
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		err = decoder.Decode(&payload.Value)
+
+		// The envelope's own expiry is still worth flagging.
+		if inner, ok := railsMessage(payload.Value); ok && depth == 0 {
+			unwrapped, err := decode(inner, depth+1)
+			if err != nil {
+				return Payload{}, err
+			}
+
+			unwrapped.Flags = append(unwrapped.Flags, flagsOf(payload.Value, "")...)
+			return unwrapped, nil
+		}
+	default:
+		return Payload{}, ErrUnknownFormat
+	}
+
+	if err != nil {
+		return Payload{}, err
+	}
+
+	payload.Flags = flagsOf(payload.Value, "")
+	return payload, nil
+}
+
+// Returns the message inside Rails' `{"_rails": {"message": "..."}}`
+// envelope, which Rails 5.2 and later wrap each cookie in.
+func railsMessage(value interface{}) ([]byte, bool) {
+	envelope, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	rails, ok := envelope["_rails"].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	message, ok := rails["message"].(string)
+	if !ok {
+		return nil, false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(message)
+	return decoded, err == nil
+}
+
+// Walks `value`, flagging interesting fields, with keys visited in sorted
+// order so the result is stable.
+func flagsOf(value interface{}, path string) (flags []Flag) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+
+			if flag, ok := flagFor(key, child, value[key]); ok {
+				flags = append(flags, flag)
+			}
+
+			flags = append(flags, flagsOf(value[key], child)...)
+		}
+	case []interface{}:
+		for i, element := range value {
+			flags = append(flags, flagsOf(element, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	}
+
+	return flags
+}
+
+func flagFor(key string, path string, value interface{}) (Flag, bool) {
+	switch key {
+	case pickleCallableKey:
+		return Flag{Path: path, Value: value, Reason: ReasonCallable}, true
+	case marshalClassKey:
+		return Flag{Path: path, Value: value, Reason: ReasonObject}, true
+	}
+
+	name := strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+
+	reason, ok := reasonsByName[name]
+	if !ok {
+		return Flag{}, false
+	}
+
+	flag := Flag{Path: path, Value: value, Reason: reason}
+	if reason == ReasonExpiry {
+		flag.Time = timeOf(value)
+	}
+
+	return flag, true
+}
+
+// Parses a timestamp as a Unix time in seconds or milliseconds, or as a
+// string `timestamp.Parse()` accepts, reporting nil if it's neither.
+func timeOf(value interface{}) *time.Time {
+	var t time.Time
+	var ok bool
+
+	switch value := value.(type) {
+	case json.Number:
+		seconds, err := value.Float64()
+		if err != nil {
+			return nil
+		}
+
+		t, ok = timestamp.FromUnix(seconds)
+	case int64:
+		t, ok = timestamp.FromUnix(float64(value))
+	case float64:
+		t, ok = timestamp.FromUnix(value)
+	case string:
+		t, _, ok = timestamp.Parse(value)
+	}
+
+	if !ok {
+		return nil
+	}
+
+	return &t
+}
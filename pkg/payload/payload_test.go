@@ -0,0 +1,181 @@
+package payload
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func mustBase64(t *testing.T, s string) []byte {
+	decoded, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatalf("invalid fixture %q: %v", s, err)
+	}
+
+	return decoded
+}
+
+func reasonsOf(flags []Flag) map[string]string {
+	reasons := make(map[string]string)
+	for _, flag := range flags {
+		reasons[flag.Path] = flag.Reason
+	}
+
+	return reasons
+}
+
+func TestDecodeJSON(t *testing.T) {
+	decoded, err := Decode([]byte(`{"user_id":42,"is_admin":false,"profile":{"role":"user"},"exp":1516239022}`))
+	if err != nil {
+		t.Fatalf("Decode() failed: %v", err)
+	}
+
+	if decoded.Format != FormatJSON {
+		t.Errorf("expected the json format, got %q", decoded.Format)
+	}
+
+	want := map[string]string{"exp": ReasonExpiry, "is_admin": ReasonPrivilege, "profile.role": ReasonPrivilege, "user_id": ReasonIdentity}
+	if got := reasonsOf(decoded.Flags); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected flags %v, got %v", want, got)
+	}
+
+	for _, flag := range decoded.Flags {
+		if flag.Path == "exp" && (flag.Time == nil || flag.Time.Unix() != 1516239022) {
+			t.Errorf("expected exp to be parsed as a time, got %v", flag.Time)
+		}
+	}
+
+	if _, err := Decode([]byte("not a payload")); err != ErrUnknownFormat {
+		t.Errorf("expected ErrUnknownFormat, got %v", err)
+	}
+}
+
+func TestDecodeRailsEnvelope(t *testing.T) {
+	message := base64.StdEncoding.EncodeToString([]byte(`{"user_id":7}`))
+
+	decoded, err := Decode([]byte(`{"_rails":{"message":"` + message + `","exp":"2030-01-01T00:00:00.000Z","pur":"cookie._session"}}`))
+	if err != nil {
+		t.Fatalf("Decode() failed: %v", err)
+	}
+
+	if value, ok := decoded.Value.(map[string]interface{}); !ok || value["user_id"] != json.Number("7") {
+		t.Errorf("expected the session inside the envelope, got %v", decoded.Value)
+	}
+
+	want := map[string]string{"user_id": ReasonIdentity, "_rails.exp": ReasonExpiry}
+	if got := reasonsOf(decoded.Flags); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected flags %v, got %v", want, got)
+	}
+}
+
+func TestDecodePickle(t *testing.T) {
+	// pickle.dumps({'_auth_user_id': '1', 'is_admin': False, 'tags': ['a', 'b'], 'n': 2**70}, protocol=2)
+	decoded, err := Decode(mustBase64(t, "gAJ9cQAoWA0AAABfYXV0aF91c2VyX2lkcQFYAQAAADFxAlgIAAAAaXNfYWRtaW5xA4lYBAAAAHRhZ3NxBF1xBShYAQAAAGFxBlgBAAAAYnEHZVgBAAAAbnEIigkAAAAAAAAAAEB1Lg=="))
+	if err != nil {
+		t.Fatalf("Decode() failed: %v", err)
+	}
+
+	want := map[string]interface{}{"_auth_user_id": "1", "is_admin": false, "tags": []interface{}{"a", "b"}, "n": "1180591620717411303424"}
+	if decoded.Format != FormatPickle || !reflect.DeepEqual(decoded.Value, want) {
+		t.Errorf("expected %v, got %q %v", want, decoded.Format, decoded.Value)
+	}
+
+	// pickle.dumps({'a': [1, 2], 'b': (True, None, 1.5, b'hi', -3, 70000)}, protocol=5)
+	decoded, err = Decode(mustBase64(t, "gAWVMgAAAAAAAAB9lCiMAWGUXZQoSwFLAmWMAWKUKIhORz/4AAAAAAAAQwJoaZRK/f///0pwEQEAdJR1Lg=="))
+	if err != nil {
+		t.Fatalf("Decode() failed: %v", err)
+	}
+
+	want = map[string]interface{}{"a": []interface{}{int64(1), int64(2)}, "b": []interface{}{true, nil, 1.5, []byte("hi"), int64(-3), int64(70000)}}
+	if !reflect.DeepEqual(decoded.Value, want) {
+		t.Errorf("expected %v, got %v", want, decoded.Value)
+	}
+
+	// An object whose `__reduce__` returns `(os.system, ('id',))`.
+	decoded, err = Decode(mustBase64(t, "gASVJAAAAAAAAAB9lIwBeJSMBXBvc2l4lIwGc3lzdGVtlJOUjAJpZJSFlFKUcy4="))
+	if err != nil {
+		t.Fatalf("Decode() failed: %v", err)
+	}
+
+	if len(decoded.Flags) != 1 || decoded.Flags[0].Reason != ReasonCallable || decoded.Flags[0].Value != "posix.system" {
+		t.Errorf("expected the call to be flagged, got %v", decoded.Flags)
+	}
+
+	if _, err := Decode([]byte{pickleProto, 2, '}', 'q'}); err == nil {
+		t.Errorf("expected a truncated pickle to fail")
+	}
+}
+
+func TestDecodeMarshal(t *testing.T) {
+	// Marshal.dump({"user_id" => 42, "admin" => true})
+	data := []byte("\x04\x08{\x07I\"\x0cuser_id\x06:\x06ETi/I\"\x0aadmin\x06;\x00TT")
+
+	decoded, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode() failed: %v", err)
+	}
+
+	want := map[string]interface{}{"user_id": int64(42), "admin": true}
+	if decoded.Format != FormatMarshal || !reflect.DeepEqual(decoded.Value, want) {
+		t.Errorf("expected %v, got %q %v", want, decoded.Format, decoded.Value)
+	}
+
+	if got := reasonsOf(decoded.Flags); got["admin"] != ReasonPrivilege || got["user_id"] != ReasonIdentity {
+		t.Errorf("expected admin and user_id to be flagged, got %v", got)
+	}
+
+	// Marshal.dump([User.new]), where User has a single @id of 300.
+	object := []byte("\x04\x08[\x06o:\x09User\x06:\x08@idi\x02,\x01")
+
+	decoded, err = Decode(object)
+	if err != nil {
+		t.Fatalf("Decode() failed: %v", err)
+	}
+
+	want2 := []interface{}{map[string]interface{}{marshalClassKey: "User", "id": int64(300)}}
+	if !reflect.DeepEqual(decoded.Value, want2) {
+		t.Errorf("expected %v, got %v", want2, decoded.Value)
+	}
+
+	if got := reasonsOf(decoded.Flags); got["[0].__class__"] != ReasonObject {
+		t.Errorf("expected the object to be flagged, got %v", got)
+	}
+}
+
+func TestDecodePHP(t *testing.T) {
+	// serialize(['user_id' => 42, 'is_admin' => false, 'tags' => ['a', 'b'], 'score' => 1.5, 'note' => null])
+	decoded, err := Decode([]byte(`a:5:{s:7:"user_id";i:42;s:8:"is_admin";b:0;s:4:"tags";a:2:{i:0;s:1:"a";i:1;s:1:"b";}s:5:"score";d:1.5;s:4:"note";N;}`))
+	if err != nil {
+		t.Fatalf("Decode() failed: %v", err)
+	}
+
+	want := map[string]interface{}{"user_id": int64(42), "is_admin": false, "tags": []interface{}{"a", "b"}, "score": 1.5, "note": nil}
+	if decoded.Format != FormatPHP || !reflect.DeepEqual(decoded.Value, want) {
+		t.Errorf("expected %v, got %q %v", want, decoded.Format, decoded.Value)
+	}
+
+	if got := reasonsOf(decoded.Flags); got["is_admin"] != ReasonPrivilege || got["user_id"] != ReasonIdentity {
+		t.Errorf("expected is_admin and user_id to be flagged, got %v", got)
+	}
+
+	// serialize(new User()), where User has a public $id of 7 and a
+	// protected $role of "user".
+	decoded, err = Decode([]byte("O:4:\"User\":2:{s:2:\"id\";i:7;s:7:\"\x00*\x00role\";s:4:\"user\";}"))
+	if err != nil {
+		t.Fatalf("Decode() failed: %v", err)
+	}
+
+	want = map[string]interface{}{marshalClassKey: "User", "id": int64(7), "role": "user"}
+	if !reflect.DeepEqual(decoded.Value, want) {
+		t.Errorf("expected %v, got %v", want, decoded.Value)
+	}
+
+	if got := reasonsOf(decoded.Flags); got["__class__"] != ReasonObject || got["role"] != ReasonPrivilege {
+		t.Errorf("expected the object and its role to be flagged, got %v", got)
+	}
+
+	if _, err := Decode([]byte(`s:10:"short";`)); err == nil {
+		t.Errorf("expected a string shorter than its length to fail")
+	}
+}
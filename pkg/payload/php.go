@@ -0,0 +1,297 @@
+package payload
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var (
+	errInvalidPHP = errors.New("invalid serialized PHP data")
+)
+
+type phpReader struct {
+	data   []byte
+	offset int
+}
+
+// Reports whether `data` starts like the output of PHP's `serialize()`.
+func isPHP(data []byte) bool {
+	if bytes.Equal(data, []byte("N;")) {
+		return true
+	}
+
+	return len(data) > 3 && data[1] == ':' && bytes.IndexByte([]byte("aOCsidb"), data[0]) >= 0
+}
+
+// Reads the output of PHP's `serialize()`, as Yii and hand-rolled PHP
+// signers store cookies, without instantiating anything: objects become
+// their properties along with their class name, and arrays become lists
+// when their keys are 0 to n-1, or maps otherwise.
+func unserializePHP(data []byte) (interface{}, error) {
+	r := &phpReader{data: data}
+
+	value, err := r.value(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.offset != len(data) {
+		return nil, errInvalidPHP
+	}
+
+	return value, nil
+}
+
+// Reads up to the next `delimiter`, consuming it.
+func (r *phpReader) until(delimiter byte) (string, error) {
+	index := bytes.IndexByte(r.data[r.offset:], delimiter)
+	if index < 0 {
+		return "", errInvalidPHP
+	}
+
+	s := string(r.data[r.offset : r.offset+index])
+	r.offset += index + 1
+
+	return s, nil
+}
+
+func (r *phpReader) expect(s string) error {
+	if !bytes.HasPrefix(r.data[r.offset:], []byte(s)) {
+		return errInvalidPHP
+	}
+
+	r.offset += len(s)
+	return nil
+}
+
+func (r *phpReader) length(delimiter byte) (int, error) {
+	s, err := r.until(delimiter)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, errInvalidPHP
+	}
+
+	return n, nil
+}
+
+// Reads `"..."`, whose length in bytes was given beforehand.
+func (r *phpReader) quoted(n int) (string, error) {
+	if err := r.expect(`"`); err != nil {
+		return "", err
+	}
+
+	if len(r.data)-r.offset < n {
+		return "", errInvalidPHP
+	}
+
+	s := string(r.data[r.offset : r.offset+n])
+	r.offset += n
+
+	return s, r.expect(`"`)
+}
+
+func (r *phpReader) value(depth int) (interface{}, error) {
+	if depth > maxDepth || len(r.data)-r.offset < 2 {
+		return nil, errInvalidPHP
+	}
+
+	tag := r.data[r.offset]
+	if tag == 'N' {
+		return nil, r.expect("N;")
+	}
+
+	if err := r.expect(string([]byte{tag, ':'})); err != nil {
+		return nil, err
+	}
+
+	switch tag {
+	case 'b':
+		s, err := r.until(';')
+		if err != nil || (s != "0" && s != "1") {
+			return nil, errInvalidPHP
+		}
+
+		return s == "1", nil
+	case 'i':
+		s, err := r.until(';')
+		if err != nil {
+			return nil, err
+		}
+
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, errInvalidPHP
+		}
+
+		return n, nil
+	case 'd':
+		s, err := r.until(';')
+		if err != nil {
+			return nil, err
+		}
+
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			// JSON can't show INF or NAN, so they're kept as written.
+			return s, nil
+		}
+
+		return f, nil
+	case 's':
+		n, err := r.length(':')
+		if err != nil {
+			return nil, err
+		}
+
+		s, err := r.quoted(n)
+		if err != nil {
+			return nil, err
+		}
+
+		return s, r.expect(";")
+	case 'r', 'R':
+		// References point back at earlier values, which we don't keep.
+		s, err := r.until(';')
+		return "&" + s, err
+	case 'a':
+		n, err := r.length(':')
+		if err != nil {
+			return nil, err
+		}
+
+		return r.array(n, depth)
+	case 'O':
+		n, err := r.length(':')
+		if err != nil {
+			return nil, err
+		}
+
+		class, err := r.quoted(n)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := r.expect(":"); err != nil {
+			return nil, err
+		}
+
+		count, err := r.length(':')
+		if err != nil {
+			return nil, err
+		}
+
+		properties, err := r.array(count, depth)
+		if err != nil {
+			return nil, err
+		}
+
+		object := map[string]interface{}{marshalClassKey: class}
+		if fields, ok := properties.(map[string]interface{}); ok {
+			for name, value := range fields {
+				object[phpPropertyName(name)] = value
+			}
+		}
+
+		return object, nil
+	case 'C':
+		// Classes implementing `Serializable` write their own format.
+		n, err := r.length(':')
+		if err != nil {
+			return nil, err
+		}
+
+		class, err := r.quoted(n)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := r.expect(":"); err != nil {
+			return nil, err
+		}
+
+		size, err := r.length(':')
+		if err != nil {
+			return nil, err
+		}
+
+		if err := r.expect("{"); err != nil || len(r.data)-r.offset < size {
+			return nil, errInvalidPHP
+		}
+
+		data := string(r.data[r.offset : r.offset+size])
+		r.offset += size
+
+		return map[string]interface{}{marshalClassKey: class, "data": data}, r.expect("}")
+	default:
+		return nil, errInvalidPHP
+	}
+}
+
+// Reads the `{key;value...}` of an array or an object's properties.
+func (r *phpReader) array(n int, depth int) (interface{}, error) {
+	if err := r.expect("{"); err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, n)
+	values := make([]interface{}, 0, n)
+	list := true
+
+	for i := 0; i < n; i++ {
+		key, err := r.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		var name string
+		switch key := key.(type) {
+		case int64:
+			name = strconv.FormatInt(key, 10)
+			list = list && key == int64(i)
+		case string:
+			name, list = key, false
+		default:
+			return nil, errInvalidPHP
+		}
+
+		value, err := r.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		keys, values = append(keys, name), append(values, value)
+	}
+
+	if err := r.expect("}"); err != nil {
+		return nil, err
+	}
+
+	if list {
+		return values, nil
+	}
+
+	m := make(map[string]interface{}, n)
+	for i, key := range keys {
+		m[key] = values[i]
+	}
+
+	return m, nil
+}
+
+// Strips the NUL-wrapped prefix PHP gives protected (`\0*\0`) and private
+// (`\0Class\0`) properties.
+func phpPropertyName(name string) string {
+	if strings.HasPrefix(name, "\x00") {
+		if index := strings.LastIndex(name, "\x00"); index > 0 {
+			return name[index+1:]
+		}
+	}
+
+	return name
+}
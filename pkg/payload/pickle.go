@@ -0,0 +1,508 @@
+package payload
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+var (
+	errInvalidPickle = errors.New("invalid pickle")
+)
+
+const (
+	pickleProto = 0x80
+
+	// The key we show a pickled global (usually a class or function) under,
+	// along with the arguments it's called with, if any.
+	pickleCallableKey = "__callable__"
+	pickleArgsKey     = "__args__"
+	pickleStateKey    = "__state__"
+)
+
+// A list while it's being unpickled, since `APPEND` changes it in place and
+// the memo may refer to it.
+type pickleList struct {
+	items []interface{}
+}
+
+type pickleMark struct{}
+
+type unpickler struct {
+	data   []byte
+	offset int
+
+	stack []interface{}
+	memo  map[int]interface{}
+}
+
+// Interprets the pickle opcodes Python's `pickle` emits for sessions, from
+// protocol 2 to 5, without importing or calling anything: globals are kept
+// as their names, and calls as the name with its arguments.
+func unpickle(data []byte) (interface{}, error) {
+	u := &unpickler{data: data, memo: make(map[int]interface{})}
+
+	for {
+		op, err := u.read(1)
+		if err != nil {
+			return nil, err
+		}
+
+		if op[0] == '.' {
+			value, err := u.pop()
+			if err != nil {
+				return nil, err
+			}
+
+			return pickleValue(value, 0)
+		}
+
+		if err := u.step(op[0]); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (u *unpickler) read(n int) ([]byte, error) {
+	if n < 0 || len(u.data)-u.offset < n {
+		return nil, errInvalidPickle
+	}
+
+	b := u.data[u.offset : u.offset+n]
+	u.offset += n
+
+	return b, nil
+}
+
+// Reads a little-endian unsigned integer of `size` bytes.
+func (u *unpickler) uint(size int) (uint64, error) {
+	b, err := u.read(size)
+	if err != nil {
+		return 0, err
+	}
+
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.LittleEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.LittleEndian.Uint32(b)), nil
+	default:
+		return binary.LittleEndian.Uint64(b), nil
+	}
+}
+
+// Reads a length of `size` bytes, then that many bytes.
+func (u *unpickler) sized(size int) ([]byte, error) {
+	n, err := u.uint(size)
+	if err != nil {
+		return nil, err
+	}
+
+	if n > uint64(len(u.data)) {
+		return nil, errInvalidPickle
+	}
+
+	return u.read(int(n))
+}
+
+// Reads a newline-terminated argument, as the text opcodes have.
+func (u *unpickler) line() (string, error) {
+	for i := u.offset; i < len(u.data); i++ {
+		if u.data[i] == '\n' {
+			line := string(u.data[u.offset:i])
+			u.offset = i + 1
+
+			return line, nil
+		}
+	}
+
+	return "", errInvalidPickle
+}
+
+func (u *unpickler) push(value interface{}) {
+	u.stack = append(u.stack, value)
+}
+
+func (u *unpickler) pop() (interface{}, error) {
+	if len(u.stack) == 0 {
+		return nil, errInvalidPickle
+	}
+
+	value := u.stack[len(u.stack)-1]
+	u.stack = u.stack[:len(u.stack)-1]
+
+	return value, nil
+}
+
+func (u *unpickler) top() (interface{}, error) {
+	if len(u.stack) == 0 {
+		return nil, errInvalidPickle
+	}
+
+	return u.stack[len(u.stack)-1], nil
+}
+
+// Pops everything down to the last mark.
+func (u *unpickler) popMark() ([]interface{}, error) {
+	for i := len(u.stack) - 1; i >= 0; i-- {
+		if _, ok := u.stack[i].(pickleMark); ok {
+			items := append([]interface{}{}, u.stack[i+1:]...)
+			u.stack = u.stack[:i]
+
+			return items, nil
+		}
+	}
+
+	return nil, errInvalidPickle
+}
+
+func (u *unpickler) step(op byte) error {
+	switch op {
+	case pickleProto:
+		_, err := u.read(1)
+		return err
+	case 0x95: // FRAME
+		_, err := u.read(8)
+		return err
+	case 'N':
+		u.push(nil)
+	case 0x88:
+		u.push(true)
+	case 0x89:
+		u.push(false)
+	case 'K', 'M', 'J':
+		size := map[byte]int{'K': 1, 'M': 2, 'J': 4}[op]
+
+		n, err := u.uint(size)
+		if err != nil {
+			return err
+		}
+
+		if op == 'J' {
+			u.push(int64(int32(n)))
+		} else {
+			u.push(int64(n))
+		}
+	case 0x8a, 0x8b: // LONG1 and LONG4
+		b, err := u.sized(map[byte]int{0x8a: 1, 0x8b: 4}[op])
+		if err != nil {
+			return err
+		}
+
+		u.push(pickleLong(b))
+	case 'G':
+		b, err := u.read(8)
+		if err != nil {
+			return err
+		}
+
+		u.push(math.Float64frombits(binary.BigEndian.Uint64(b)))
+	case 'X', 0x8c, 0x8d: // BINUNICODE, SHORT_BINUNICODE and BINUNICODE8
+		b, err := u.sized(map[byte]int{'X': 4, 0x8c: 1, 0x8d: 8}[op])
+		if err != nil {
+			return err
+		}
+
+		u.push(string(b))
+	case 'T', 'U': // BINSTRING and SHORT_BINSTRING, from Python 2
+		b, err := u.sized(map[byte]int{'T': 4, 'U': 1}[op])
+		if err != nil {
+			return err
+		}
+
+		u.push(string(b))
+	case 'B', 'C', 0x8e, 0x96: // BINBYTES, SHORT_BINBYTES, BINBYTES8 and BYTEARRAY8
+		b, err := u.sized(map[byte]int{'B': 4, 'C': 1, 0x8e: 8, 0x96: 8}[op])
+		if err != nil {
+			return err
+		}
+
+		u.push(append([]byte{}, b...))
+	case '}':
+		u.push(make(map[string]interface{}))
+	case ']':
+		u.push(&pickleList{})
+	case ')':
+		u.push([]interface{}{})
+	case 0x8f: // EMPTY_SET
+		u.push(&pickleList{})
+	case '(':
+		u.push(pickleMark{})
+	case 't', 'l', 0x91: // TUPLE, LIST and FROZENSET
+		items, err := u.popMark()
+		if err != nil {
+			return err
+		}
+
+		if op == 'l' {
+			u.push(&pickleList{items: items})
+		} else {
+			u.push(items)
+		}
+	case 0x85, 0x86, 0x87: // TUPLE1, TUPLE2 and TUPLE3
+		n := int(op - 0x84)
+		if len(u.stack) < n {
+			return errInvalidPickle
+		}
+
+		items := append([]interface{}{}, u.stack[len(u.stack)-n:]...)
+		u.stack = u.stack[:len(u.stack)-n]
+		u.push(items)
+	case 'd':
+		items, err := u.popMark()
+		if err != nil {
+			return err
+		}
+
+		dict := make(map[string]interface{})
+		if err := pickleSetItems(dict, items); err != nil {
+			return err
+		}
+
+		u.push(dict)
+	case 's', 'u': // SETITEM and SETITEMS
+		var items []interface{}
+		var err error
+
+		if op == 's' {
+			if len(u.stack) < 2 {
+				return errInvalidPickle
+			}
+
+			items = append([]interface{}{}, u.stack[len(u.stack)-2:]...)
+			u.stack = u.stack[:len(u.stack)-2]
+		} else if items, err = u.popMark(); err != nil {
+			return err
+		}
+
+		top, err := u.top()
+		if err != nil {
+			return err
+		}
+
+		dict, ok := top.(map[string]interface{})
+		if !ok {
+			return errInvalidPickle
+		}
+
+		return pickleSetItems(dict, items)
+	case 'a', 'e', 0x90: // APPEND, APPENDS and ADDITEMS
+		var items []interface{}
+
+		if op == 'a' {
+			item, err := u.pop()
+			if err != nil {
+				return err
+			}
+
+			items = []interface{}{item}
+		} else {
+			var err error
+			if items, err = u.popMark(); err != nil {
+				return err
+			}
+		}
+
+		top, err := u.top()
+		if err != nil {
+			return err
+		}
+
+		list, ok := top.(*pickleList)
+		if !ok {
+			return errInvalidPickle
+		}
+
+		list.items = append(list.items, items...)
+	case 'q', 'r': // BINPUT and LONG_BINPUT
+		index, err := u.uint(map[byte]int{'q': 1, 'r': 4}[op])
+		if err != nil {
+			return err
+		}
+
+		top, err := u.top()
+		if err != nil {
+			return err
+		}
+
+		u.memo[int(index)] = top
+	case 0x94: // MEMOIZE
+		top, err := u.top()
+		if err != nil {
+			return err
+		}
+
+		u.memo[len(u.memo)] = top
+	case 'h', 'j': // BINGET and LONG_BINGET
+		index, err := u.uint(map[byte]int{'h': 1, 'j': 4}[op])
+		if err != nil {
+			return err
+		}
+
+		value, ok := u.memo[int(index)]
+		if !ok {
+			return errInvalidPickle
+		}
+
+		u.push(value)
+	case 'c': // GLOBAL
+		module, err := u.line()
+		if err != nil {
+			return err
+		}
+
+		name, err := u.line()
+		if err != nil {
+			return err
+		}
+
+		u.push(map[string]interface{}{pickleCallableKey: module + "." + name})
+	case 0x93: // STACK_GLOBAL
+		if len(u.stack) < 2 {
+			return errInvalidPickle
+		}
+
+		module, name := u.stack[len(u.stack)-2], u.stack[len(u.stack)-1]
+		u.stack = u.stack[:len(u.stack)-2]
+		u.push(map[string]interface{}{pickleCallableKey: fmt.Sprintf("%v.%v", module, name)})
+	case 'R', 0x81: // REDUCE and NEWOBJ
+		args, err := u.pop()
+		if err != nil {
+			return err
+		}
+
+		callable, err := u.pop()
+		if err != nil {
+			return err
+		}
+
+		global, ok := callable.(map[string]interface{})
+		if !ok {
+			return errInvalidPickle
+		}
+
+		u.push(map[string]interface{}{pickleCallableKey: global[pickleCallableKey], pickleArgsKey: args})
+	case 'b': // BUILD
+		state, err := u.pop()
+		if err != nil {
+			return err
+		}
+
+		top, err := u.top()
+		if err != nil {
+			return err
+		}
+
+		object, ok := top.(map[string]interface{})
+		if !ok {
+			return errInvalidPickle
+		}
+
+		object[pickleStateKey] = state
+	case '0': // POP
+		_, err := u.pop()
+		return err
+	case '2': // DUP
+		top, err := u.top()
+		if err != nil {
+			return err
+		}
+
+		u.push(top)
+	default:
+		return fmt.Errorf("%w: unsupported opcode 0x%02x", errInvalidPickle, op)
+	}
+
+	return nil
+}
+
+// Sets alternating keys and values on a dict, formatting keys which
+// aren't strings.
+func pickleSetItems(dict map[string]interface{}, items []interface{}) error {
+	if len(items)%2 != 0 {
+		return errInvalidPickle
+	}
+
+	for i := 0; i < len(items); i += 2 {
+		key, ok := items[i].(string)
+		if !ok {
+			key = fmt.Sprint(items[i])
+		}
+
+		dict[key] = items[i+1]
+	}
+
+	return nil
+}
+
+// Decodes a little-endian two's complement integer, as a string if it
+// doesn't fit in an `int64`.
+func pickleLong(b []byte) interface{} {
+	if len(b) == 0 {
+		return int64(0)
+	}
+
+	bigEndian := make([]byte, len(b))
+	for i := range b {
+		bigEndian[len(b)-1-i] = b[i]
+	}
+
+	n := new(big.Int).SetBytes(bigEndian)
+	if b[len(b)-1]&0x80 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(len(b)*8)))
+	}
+
+	if n.IsInt64() {
+		return n.Int64()
+	}
+
+	return n.String()
+}
+
+// Replaces the lists we built in place with plain slices.
+func pickleValue(value interface{}, depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, errInvalidPickle
+	}
+
+	switch value := value.(type) {
+	case *pickleList:
+		return pickleValues(value.items, depth)
+	case []interface{}:
+		return pickleValues(value, depth)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(value))
+		for key, element := range value {
+			converted, err := pickleValue(element, depth+1)
+			if err != nil {
+				return nil, err
+			}
+
+			out[key] = converted
+		}
+
+		return out, nil
+	case pickleMark:
+		return nil, errInvalidPickle
+	default:
+		return value, nil
+	}
+}
+
+func pickleValues(items []interface{}, depth int) ([]interface{}, error) {
+	out := make([]interface{}, len(items))
+	for i, item := range items {
+		converted, err := pickleValue(item, depth+1)
+		if err != nil {
+			return nil, err
+		}
+
+		out[i] = converted
+	}
+
+	return out, nil
+}
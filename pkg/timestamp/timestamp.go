@@ -0,0 +1,63 @@
+package timestamp
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/iangcarroll/cookiemonster/pkg/base62"
+)
+
+// The formats `Parse()` reports.
+const (
+	Base62  = "base62"
+	RFC3339 = "rfc3339"
+	Epoch   = "epoch"
+)
+
+var (
+	// Timestamps outside this window are treated as misparses. Notably, an
+	// epoch integer is also valid base62, but decodes to a wildly distant
+	// date that this rejects.
+	plausibleStart = time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)
+	plausibleEnd   = time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// Parses a timestamp segment, trying Django's base62 seconds first, then
+// RFC3339, then a decimal Unix epoch, and reports which format matched.
+func Parse(raw string) (timestamp time.Time, format string, ok bool) {
+	if seconds, err := base62.Decode(raw); err == nil {
+		if t := time.Unix(int64(seconds), 0).UTC(); Plausible(t) {
+			return t, Base62, true
+		}
+	}
+
+	if t, err := time.Parse(time.RFC3339, raw); err == nil && Plausible(t) {
+		return t.UTC(), RFC3339, true
+	}
+
+	if seconds, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		if t := time.Unix(seconds, 0).UTC(); Plausible(t) {
+			return t, Epoch, true
+		}
+	}
+
+	return time.Time{}, "", false
+}
+
+// Returns the time `seconds` after the Unix epoch, e.g. a JWT's `exp`.
+// Values past the year 33658 in seconds are almost certainly milliseconds,
+// so they're taken as such.
+func FromUnix(seconds float64) (time.Time, bool) {
+	if seconds > 1e12 {
+		seconds /= 1000
+	}
+
+	t := time.Unix(int64(seconds), 0).UTC()
+	return t, Plausible(t)
+}
+
+// Reports whether `t` is a date a cookie could really have been signed or
+// expire at.
+func Plausible(t time.Time) bool {
+	return t.After(plausibleStart) && t.Before(plausibleEnd)
+}
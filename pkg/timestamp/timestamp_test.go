@@ -0,0 +1,49 @@
+package timestamp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	expected := time.Date(2021, 11, 1, 9, 0, 0, 0, time.UTC)
+
+	vectors := map[string]string{
+		"1mhTAe":                    Base62,
+		"2021-11-01T09:00:00Z":      RFC3339,
+		"2021-11-01T10:00:00+01:00": RFC3339,
+		"1635757200":                Epoch,
+	}
+
+	for raw, expectedFormat := range vectors {
+		parsed, format, ok := Parse(raw)
+		if !ok {
+			t.Errorf("could not parse %s timestamp %q", expectedFormat, raw)
+			continue
+		}
+
+		if format != expectedFormat || !parsed.Equal(expected) {
+			t.Errorf("parsed %q as %s %v", raw, format, parsed)
+		}
+	}
+
+	for _, invalid := range []string{"", "not-a-time", "zzzzzzzzzz"} {
+		if _, _, ok := Parse(invalid); ok {
+			t.Errorf("parsed invalid timestamp %q", invalid)
+		}
+	}
+}
+
+func TestFromUnix(t *testing.T) {
+	expected := time.Date(2021, 11, 1, 9, 0, 0, 0, time.UTC)
+
+	for _, seconds := range []float64{1635757200, 1635757200000} {
+		if parsed, ok := FromUnix(seconds); !ok || !parsed.Equal(expected) {
+			t.Errorf("parsed %f as %v", seconds, parsed)
+		}
+	}
+
+	if _, ok := FromUnix(0); ok {
+		t.Errorf("the epoch itself was plausible")
+	}
+}